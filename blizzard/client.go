@@ -0,0 +1,253 @@
+package blizzard
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/models"
+	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+)
+
+var _ rioClient.ConditionalClient = (*DefaultClient)(nil)
+
+const (
+	_defaultTokenURL = "https://oauth.battle.net/token"
+	_defaultLocale   = "en_US"
+)
+
+// DefaultClient fetches weekly M+ runs from the Blizzard Profile API.
+type DefaultClient struct {
+	baseURL  string
+	tokenURL string
+	locale   string
+	clientID string
+	secret   string
+	http     *http.Client
+	clock    clock.Clock
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// Params holds configuration for creating a new Blizzard client.
+type Params struct {
+	ClientID     string
+	ClientSecret string
+	Locale       string
+	// BaseURL overrides the regional API host, e.g. https://us.api.blizzard.com.
+	BaseURL    string
+	TokenURL   string
+	HTTPClient *http.Client
+	// Clock times token expiry. Nil uses the system clock.
+	Clock clock.Clock
+}
+
+// New creates a new Blizzard client with the given parameters.
+func New(p Params) *DefaultClient {
+	tokenURL := p.TokenURL
+	if tokenURL == "" {
+		tokenURL = _defaultTokenURL
+	}
+	locale := p.Locale
+	if locale == "" {
+		locale = _defaultLocale
+	}
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
+
+	return &DefaultClient{
+		baseURL:  p.BaseURL,
+		tokenURL: tokenURL,
+		locale:   locale,
+		clientID: p.ClientID,
+		secret:   p.ClientSecret,
+		http:     httpClient,
+		clock:    clk,
+	}
+}
+
+// FetchWeeklyRuns fetches the current period's best M+ runs for a character
+// from the mythic-keystone-profile endpoint. Blizzard does not expose run IDs,
+// so the returned keys rely on synthetic IDs.
+//
+// The profile lists only each dungeon's best run of the week, so a character
+// who ran the same dungeon twice reports one key and weekly vault counts from
+// this source alone are too low. Use it alongside RaiderIO, whose recent runs
+// fill in the repeats, rather than on its own.
+func (c *DefaultClient) FetchWeeklyRuns(ctx context.Context, character models.Character) (rioClient.ProfileResult, error) {
+	result, _, err := c.FetchWeeklyRunsIfChanged(ctx, character, rioClient.Validator{})
+	return result, err
+}
+
+// FetchWeeklyRunsIfChanged fetches the current period's best M+ runs unless
+// the profile's Last-Modified time shows it unchanged since v.
+func (c *DefaultClient) FetchWeeklyRunsIfChanged(ctx context.Context, character models.Character, v rioClient.Validator) (rioClient.ProfileResult, rioClient.Validator, error) {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return rioClient.ProfileResult{}, rioClient.Validator{}, err
+	}
+
+	region := strings.ToLower(character.Region)
+	endpoint, err := url.Parse(c.apiBase(region))
+	if err != nil {
+		return rioClient.ProfileResult{}, rioClient.Validator{}, err
+	}
+	endpoint.Path = fmt.Sprintf("/profile/wow/character/%s/%s/mythic-keystone-profile",
+		url.PathEscape(strings.ToLower(character.Realm)),
+		url.PathEscape(strings.ToLower(character.Name)))
+
+	query := endpoint.Query()
+	query.Set("namespace", "profile-"+region)
+	query.Set("locale", c.locale)
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return rioClient.ProfileResult{}, rioClient.Validator{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	v.Apply(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return rioClient.ProfileResult{}, rioClient.Validator{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return rioClient.ProfileResult{}, v, rioClient.ErrNotModified
+	}
+	// Characters that have never run a keystone have no profile.
+	if resp.StatusCode == http.StatusNotFound {
+		return rioClient.ProfileResult{}, rioClient.Validator{}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return rioClient.ProfileResult{}, rioClient.Validator{}, fmt.Errorf("blizzard: status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var payload keystoneProfileResponse
+	if err = json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return rioClient.ProfileResult{}, rioClient.Validator{}, err
+	}
+
+	// best_runs holds one run per dungeon; repeats of a dungeon are missing.
+	out := make([]models.CompletedKey, 0, len(payload.CurrentPeriod.BestRuns))
+	for _, run := range payload.CurrentPeriod.BestRuns {
+		out = append(out, models.CompletedKey{
+			Character:   strings.ToLower(character.Name),
+			Region:      region,
+			Realm:       strings.ToLower(character.Realm),
+			Dungeon:     run.Dungeon.Name,
+			KeyLevel:    run.KeystoneLevel,
+			RunTimeMS:   run.Duration,
+			CompletedAt: time.UnixMilli(run.CompletedTimestamp).UTC().Format(time.RFC3339),
+			Source:      models.SourceBlizzard,
+		})
+	}
+
+	return rioClient.ProfileResult{Keys: out, RIOScore: payload.CurrentMythicRating.Rating}, rioClient.ValidatorFrom(resp.Header), nil
+}
+
+func (c *DefaultClient) apiBase(region string) string {
+	if c.baseURL != "" {
+		return c.baseURL
+	}
+	return fmt.Sprintf("https://%s.api.blizzard.com", region)
+}
+
+func (c *DefaultClient) getToken(ctx context.Context) (string, error) {
+	if c.clientID == "" || c.secret == "" {
+		return "", errors.New("blizzard: missing client credentials")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.clock.Now().Before(c.tokenExpiry.Add(-30*time.Second)) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	credentials := base64.StdEncoding.EncodeToString([]byte(c.clientID + ":" + c.secret))
+	req.Header.Set("Authorization", "Basic "+credentials)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return "", fmt.Errorf("blizzard: token status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var payload struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	if payload.AccessToken == "" {
+		return "", errors.New("blizzard: empty access token")
+	}
+
+	c.token = payload.AccessToken
+	if payload.ExpiresIn > 0 {
+		c.tokenExpiry = c.clock.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
+	} else {
+		c.tokenExpiry = c.clock.Now().Add(5 * time.Minute)
+	}
+
+	return c.token, nil
+}
+
+type keystoneProfileResponse struct {
+	CurrentPeriod struct {
+		BestRuns []bestRun `json:"best_runs"`
+	} `json:"current_period"`
+	CurrentMythicRating struct {
+		Rating float64 `json:"rating"`
+	} `json:"current_mythic_rating"`
+}
+
+// bestRun is a run from best_runs. Whether it was timed is not decoded:
+// depleted keys count toward the vault like timed ones, and no other source
+// records the difference either.
+type bestRun struct {
+	CompletedTimestamp int64 `json:"completed_timestamp"`
+	Duration           int64 `json:"duration"`
+	KeystoneLevel      int   `json:"keystone_level"`
+	Dungeon            struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"dungeon"`
+}
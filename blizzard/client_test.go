@@ -0,0 +1,128 @@
+package blizzard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+func TestFetchWeeklyRuns(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			if user, pass, ok := r.BasicAuth(); !ok || user != "id" || pass != "secret" {
+				t.Fatalf("unexpected basic auth: %q %q", user, pass)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "tok", "expires_in": 3600}`))
+		case "/profile/wow/character/illidan/arthas/mythic-keystone-profile":
+			if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+				t.Fatalf("unexpected authorization header: %s", got)
+			}
+			if got := r.URL.Query().Get("namespace"); got != "profile-us" {
+				t.Fatalf("unexpected namespace: %s", got)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{
+  "current_period": {
+    "best_runs": [
+      {
+        "completed_timestamp": 1770168225000,
+        "duration": 1320000,
+        "keystone_level": 10,
+        "is_completed_within_time": true,
+        "dungeon": {"id": 375, "name": "Mists of Tirna Scithe"}
+      }
+    ]
+  },
+  "current_mythic_rating": {"rating": 2450.5}
+}`))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := New(Params{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		BaseURL:      server.URL,
+		TokenURL:     server.URL + "/token",
+		HTTPClient:   server.Client(),
+	})
+
+	char := models.Character{Region: "US", Realm: "Illidan", Name: "Arthas"}
+	result, err := client.FetchWeeklyRuns(context.Background(), char)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(result.Keys) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(result.Keys))
+	}
+
+	key := result.Keys[0]
+	if key.KeyID != 0 {
+		t.Fatalf("expected no key id, got %d", key.KeyID)
+	}
+	if key.Source != models.SourceBlizzard {
+		t.Fatalf("expected source %q, got %q", models.SourceBlizzard, key.Source)
+	}
+	if key.Dungeon != "Mists of Tirna Scithe" || key.KeyLevel != 10 || key.RunTimeMS != 1320000 {
+		t.Fatalf("unexpected run mapping: %#v", key)
+	}
+	if key.CompletedAt != "2026-02-04T01:23:45Z" {
+		t.Fatalf("expected completed_at 2026-02-04T01:23:45Z, got %s", key.CompletedAt)
+	}
+	if key.Character != "arthas" || key.Realm != "illidan" || key.Region != "us" {
+		t.Fatalf("expected lowercased identity, got %s-%s (%s)", key.Character, key.Realm, key.Region)
+	}
+	if result.RIOScore != 2450.5 {
+		t.Fatalf("expected rating 2450.5, got %f", result.RIOScore)
+	}
+
+	if _, err := client.FetchWeeklyRuns(context.Background(), char); err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if tokenRequests != 1 {
+		t.Fatalf("expected token to be cached, got %d token requests", tokenRequests)
+	}
+}
+
+func TestFetchWeeklyRunsNoProfile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			_, _ = w.Write([]byte(`{"access_token": "tok", "expires_in": 3600}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := New(Params{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		BaseURL:      server.URL,
+		TokenURL:     server.URL + "/token",
+		HTTPClient:   server.Client(),
+	})
+
+	result, err := client.FetchWeeklyRuns(context.Background(), models.Character{Region: "us", Realm: "illidan", Name: "nobody"})
+	if err != nil {
+		t.Fatalf("expected no error for missing profile, got %v", err)
+	}
+	if len(result.Keys) != 0 {
+		t.Fatalf("expected no runs, got %d", len(result.Keys))
+	}
+}
+
+func TestFetchWeeklyRunsMissingCredentials(t *testing.T) {
+	client := New(Params{})
+	if _, err := client.FetchWeeklyRuns(context.Background(), models.Character{Region: "us", Realm: "illidan", Name: "arthas"}); err == nil {
+		t.Fatal("expected error without client credentials")
+	}
+}
@@ -0,0 +1,17 @@
+package blizzard
+
+import "github.com/tnicklin/celestial_orrey/secret"
+
+// Config holds Blizzard API client configuration.
+type Config struct {
+	ClientID     string        `yaml:"client_id"`
+	ClientSecret secret.String `yaml:"client_secret"`
+	Locale       string        `yaml:"locale"`
+}
+
+// Defaults applies default values to the config.
+func (c *Config) Defaults() {
+	if c.Locale == "" {
+		c.Locale = "en_US"
+	}
+}
@@ -8,9 +8,70 @@ type Clock interface {
 	Now() time.Time
 }
 
+// Timer is a single event after a duration, like *time.Timer.
+type Timer interface {
+	// C delivers the time the timer fired. It is nil for AfterFunc timers.
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker delivers ticks at an interval, like *time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// TimerClock is a Clock that also drives timers, such as Fake. Use the
+// package's NewTimer, NewTicker and AfterFunc, which fall back to real
+// timers for clocks that do not.
+type TimerClock interface {
+	Clock
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
 // System returns a Clock backed by time.Now().
 func System() Clock { return systemClock{} }
 
 type systemClock struct{}
 
 func (systemClock) Now() time.Time { return time.Now() }
+
+// NewTimer returns a timer that fires after d on c.
+func NewTimer(c Clock, d time.Duration) Timer {
+	if tc, ok := c.(TimerClock); ok {
+		return tc.NewTimer(d)
+	}
+	return realTimer{time.NewTimer(d)}
+}
+
+// NewTicker returns a ticker that ticks every d on c.
+func NewTicker(c Clock, d time.Duration) Ticker {
+	if tc, ok := c.(TimerClock); ok {
+		return tc.NewTicker(d)
+	}
+	return realTicker{time.NewTicker(d)}
+}
+
+// AfterFunc calls f in its own goroutine after d on c.
+func AfterFunc(c Clock, d time.Duration, f func()) Timer {
+	if tc, ok := c.(TimerClock); ok {
+		return tc.AfterFunc(d, f)
+	}
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (r realTimer) C() <-chan time.Time        { return r.t.C }
+func (r realTimer) Stop() bool                 { return r.t.Stop() }
+func (r realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time   { return r.t.C }
+func (r realTicker) Stop()                 { r.t.Stop() }
+func (r realTicker) Reset(d time.Duration) { r.t.Reset(d) }
@@ -0,0 +1,209 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+var _ TimerClock = (*Fake)(nil)
+
+// Fake is a Clock whose time only moves when Advance or Set is called.
+// Timers, tickers and AfterFunc calls created from it fire as time passes
+// their deadline, in deadline order, which lets tests step through hours or
+// days of scheduling without waiting.
+type Fake struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	seq     int
+	pending map[*fakeTimer]struct{}
+}
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now, pending: make(map[*fakeTimer]struct{})}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d, firing every timer due on the way.
+// Ticks and timer sends are dropped when nobody has read the previous one,
+// as with real timers; AfterFunc callbacks run in their own goroutine.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	end := f.now.Add(d)
+	for {
+		t := f.nextLocked(end)
+		if t == nil {
+			break
+		}
+		if t.at.After(f.now) {
+			f.now = t.at
+		}
+		f.fireLocked(t)
+	}
+	if end.After(f.now) {
+		f.now = end
+	}
+}
+
+// Set moves the clock to t if it is later than the current time.
+func (f *Fake) Set(t time.Time) {
+	f.Advance(t.Sub(f.Now()))
+}
+
+// Pending returns how many timers and tickers are waiting to fire.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending)
+}
+
+// BlockUntil waits until at least n timers and tickers are waiting to fire,
+// e.g. until a goroutine has handled a tick and armed its next timer.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.pending) < n {
+		f.changed.Wait()
+	}
+}
+
+// NewTimer returns a timer that fires once the clock has advanced by d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker returns a ticker that ticks every d of fake time.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.resetTicker(d)
+	return fakeTicker{t}
+}
+
+// AfterFunc calls fn in its own goroutine once the clock has advanced by d.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{clock: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// nextLocked returns the pending timer due first, no later than end.
+func (f *Fake) nextLocked(end time.Time) *fakeTimer {
+	var next *fakeTimer
+	for t := range f.pending {
+		if t.at.After(end) {
+			continue
+		}
+		if next == nil || t.at.Before(next.at) || (t.at.Equal(next.at) && t.seq < next.seq) {
+			next = t
+		}
+	}
+	return next
+}
+
+// fireLocked fires t and re-arms it if it is a ticker.
+func (f *Fake) fireLocked(t *fakeTimer) {
+	if t.period > 0 {
+		t.at = t.at.Add(t.period)
+	} else {
+		delete(f.pending, t)
+	}
+
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	select {
+	case t.c <- f.now:
+	default:
+	}
+}
+
+// armLocked schedules t to fire after d.
+func (f *Fake) armLocked(t *fakeTimer, d time.Duration) {
+	f.seq++
+	t.seq = f.seq
+	t.at = f.now.Add(d)
+	f.pending[t] = struct{}{}
+	f.changed.Broadcast()
+	if d <= 0 {
+		f.fireLocked(t)
+	}
+}
+
+type fakeTimer struct {
+	clock  *Fake
+	c      chan time.Time
+	fn     func()
+	at     time.Time
+	period time.Duration
+	seq    int
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	_, active := t.clock.pending[t]
+	delete(t.clock.pending, t)
+	t.drainLocked()
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	_, active := t.clock.pending[t]
+	t.period = 0
+	t.drainLocked()
+	t.clock.armLocked(t, d)
+	return active
+}
+
+func (t *fakeTimer) resetTicker(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.period = d
+	t.drainLocked()
+	t.clock.armLocked(t, d)
+}
+
+// drainLocked discards a tick not yet received, as time.Timer's Stop and
+// Reset do since Go 1.23, so no stale value is read after them.
+func (t *fakeTimer) drainLocked() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (k fakeTicker) C() <-chan time.Time { return k.t.c }
+func (k fakeTicker) Stop()               { k.t.Stop() }
+
+func (k fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	k.t.resetTicker(d)
+}
@@ -0,0 +1,123 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTimersFireInOrder(t *testing.T) {
+	start := time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	late := f.NewTimer(2 * time.Hour)
+	early := f.NewTimer(time.Hour)
+	ticker := f.NewTicker(45 * time.Minute)
+	called := make(chan time.Time, 1)
+	f.AfterFunc(90*time.Minute, func() { called <- f.Now() })
+
+	if f.Pending() != 4 {
+		t.Fatalf("pending = %d, want 4", f.Pending())
+	}
+
+	f.Advance(time.Hour)
+	if got := <-early.C(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("early fired at %s", got)
+	}
+	if got := <-ticker.C(); !got.Equal(start.Add(45 * time.Minute)) {
+		t.Errorf("tick at %s", got)
+	}
+	select {
+	case <-late.C():
+		t.Fatal("late timer fired early")
+	default:
+	}
+
+	f.Advance(time.Hour)
+	if got := <-late.C(); !got.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("late fired at %s", got)
+	}
+	if got := <-ticker.C(); !got.Equal(start.Add(90 * time.Minute)) {
+		t.Errorf("second tick at %s, want the first unread tick", got)
+	}
+	<-called
+	if !f.Now().Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("now = %s", f.Now())
+	}
+
+	ticker.Stop()
+	if late.Stop() {
+		t.Error("Stop on a fired timer reported it active")
+	}
+	if f.Pending() != 0 {
+		t.Errorf("pending = %d, want 0", f.Pending())
+	}
+}
+
+func TestFakeStopAndResetDrainStaleTicks(t *testing.T) {
+	f := NewFake(time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC))
+
+	timer := f.NewTimer(time.Minute)
+	f.Advance(time.Minute)
+	timer.Reset(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("Reset left a stale tick")
+	default:
+	}
+
+	f.Advance(time.Hour)
+	timer.Stop()
+	select {
+	case <-timer.C():
+		t.Fatal("Stop left a stale tick")
+	default:
+	}
+
+	ticker := f.NewTicker(time.Minute)
+	f.Advance(time.Minute)
+	ticker.Reset(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker Reset left a stale tick")
+	default:
+	}
+	ticker.Stop()
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC))
+	timer := f.NewTimer(0)
+
+	// A consumer re-arms its timer after each fire.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 3 {
+			<-timer.C()
+			timer.Reset(time.Minute)
+		}
+	}()
+
+	for range 3 {
+		f.BlockUntil(1)
+		f.Advance(time.Minute)
+	}
+	<-done
+}
+
+func TestTimerHelpersFallBack(t *testing.T) {
+	timer := NewTimer(System(), time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(time.Second):
+		t.Fatal("system timer did not fire")
+	}
+
+	fired := make(chan struct{})
+	AfterFunc(NewNTP(), time.Millisecond, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("AfterFunc did not run")
+	}
+}
@@ -2,6 +2,9 @@ package clock
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,15 +18,31 @@ type Logger interface {
 }
 
 // NTPClock provides drift-corrected wall-clock time by periodically
-// syncing with an NTP server.
+// querying a set of NTP servers. Each sync takes the median of the servers'
+// offsets, ignoring servers that disagree with it, and the clock slews
+// towards the result instead of stepping, so Now does not jump for small
+// corrections. A correction larger than the max step is stepped once a
+// second sync confirms it, since slewing it would leave Now wrong for days.
 type NTPClock struct {
-	server  string
-	interval time.Duration
-	timeout  time.Duration
-	logger   Logger
+	servers        []string
+	interval       time.Duration
+	timeout        time.Duration
+	maxSpread      time.Duration
+	maxStep        time.Duration
+	slewRate       float64
+	driftThreshold time.Duration
+	logger         Logger
 
-	mu     sync.RWMutex
-	offset time.Duration
+	mu sync.RWMutex
+	// offset is the correction in effect at slewFrom; slew is the part of
+	// the last sync's correction still to be applied from then on.
+	offset   time.Duration
+	slew     time.Duration
+	slewFrom time.Time
+	lastSync time.Time
+	// pending is a large jump seen once, applied if the next sync agrees.
+	pending  *time.Duration
+	drifting bool
 
 	cancel context.CancelFunc
 	done   chan struct{}
@@ -32,9 +51,15 @@ type NTPClock struct {
 // Option configures an NTPClock.
 type Option func(*NTPClock)
 
-// WithServer sets the NTP server address.
+// WithServer sets a single NTP server address.
 func WithServer(server string) Option {
-	return func(c *NTPClock) { c.server = server }
+	return func(c *NTPClock) { c.servers = []string{server} }
+}
+
+// WithServers sets the NTP server addresses queried on each sync. Start
+// fails if the list is empty.
+func WithServers(servers ...string) Option {
+	return func(c *NTPClock) { c.servers = slices.Clone(servers) }
 }
 
 // WithInterval sets the re-sync interval.
@@ -47,23 +72,91 @@ func WithTimeout(d time.Duration) Option {
 	return func(c *NTPClock) { c.timeout = d }
 }
 
+// WithMaxSpread sets how far a server's offset may be from the median
+// before it is ignored as an outlier.
+func WithMaxSpread(d time.Duration) Option {
+	return func(c *NTPClock) { c.maxSpread = d }
+}
+
+// WithMaxStep sets the largest change in offset slewed in. A larger one is
+// only applied once the next sync confirms it, and is then stepped.
+func WithMaxStep(d time.Duration) Option {
+	return func(c *NTPClock) { c.maxStep = d }
+}
+
+// WithSlewRate sets how fast a new offset is applied, as a fraction of
+// elapsed time: 0.0005 corrects half a millisecond per second.
+func WithSlewRate(rate float64) Option {
+	return func(c *NTPClock) { c.slewRate = rate }
+}
+
+// WithDriftThreshold sets the offset from the system clock above which a
+// warning is logged.
+func WithDriftThreshold(d time.Duration) Option {
+	return func(c *NTPClock) { c.driftThreshold = d }
+}
+
 // WithLogger sets the logger.
 func WithLogger(l Logger) Option {
 	return func(c *NTPClock) { c.logger = l }
 }
 
+// NTPConfig configures the NTP clock from the config file. Zero fields
+// keep the defaults.
+type NTPConfig struct {
+	Servers        []string      `yaml:"servers"`
+	Interval       time.Duration `yaml:"interval"`
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxSpread      time.Duration `yaml:"max_spread"`
+	MaxStep        time.Duration `yaml:"max_step"`
+	DriftThreshold time.Duration `yaml:"drift_threshold"`
+}
+
+// WithConfig applies the set fields of cfg.
+func WithConfig(cfg NTPConfig) Option {
+	return func(c *NTPClock) {
+		if len(cfg.Servers) > 0 {
+			c.servers = slices.Clone(cfg.Servers)
+		}
+		if cfg.Interval > 0 {
+			c.interval = cfg.Interval
+		}
+		if cfg.Timeout > 0 {
+			c.timeout = cfg.Timeout
+		}
+		if cfg.MaxSpread > 0 {
+			c.maxSpread = cfg.MaxSpread
+		}
+		if cfg.MaxStep > 0 {
+			c.maxStep = cfg.MaxStep
+		}
+		if cfg.DriftThreshold > 0 {
+			c.driftThreshold = cfg.DriftThreshold
+		}
+	}
+}
+
 const (
-	defaultServer   = "pool.ntp.org"
-	defaultInterval = 30 * time.Minute
-	defaultTimeout  = 5 * time.Second
+	defaultInterval       = 30 * time.Minute
+	defaultTimeout        = 5 * time.Second
+	defaultMaxSpread      = 250 * time.Millisecond
+	defaultMaxStep        = 2 * time.Second
+	defaultSlewRate       = 0.0005
+	defaultDriftThreshold = time.Second
 )
 
+var defaultServers = []string{"0.pool.ntp.org", "1.pool.ntp.org", "2.pool.ntp.org", "3.pool.ntp.org"}
+
 // NewNTP creates an NTPClock with the given options.
 func NewNTP(opts ...Option) *NTPClock {
 	c := &NTPClock{
-		server:   defaultServer,
-		interval: defaultInterval,
-		timeout:  defaultTimeout,
+		servers:        slices.Clone(defaultServers),
+		interval:       defaultInterval,
+		timeout:        defaultTimeout,
+		maxSpread:      defaultMaxSpread,
+		maxStep:        defaultMaxStep,
+		slewRate:       defaultSlewRate,
+		driftThreshold: defaultDriftThreshold,
 	}
 	for _, o := range opts {
 		o(c)
@@ -73,22 +166,50 @@ func NewNTP(opts ...Option) *NTPClock {
 
 // Now returns the current time adjusted by the NTP offset.
 func (c *NTPClock) Now() time.Time {
+	now := time.Now()
 	c.mu.RLock()
-	off := c.offset
+	off := c.offsetAt(now)
 	c.mu.RUnlock()
-	return time.Now().Add(off)
+	return now.Add(off)
 }
 
-// Offset returns the current NTP offset.
+// Offset returns the NTP offset currently applied.
 func (c *NTPClock) Offset() time.Duration {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.offset
+	return c.offsetAt(time.Now())
+}
+
+// LastSync returns when the offset was last updated from the servers, or
+// the zero time if no sync has succeeded.
+func (c *NTPClock) LastSync() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastSync
+}
+
+// offsetAt returns the offset in effect at system time now, partway
+// through any slew. c.mu must be held.
+func (c *NTPClock) offsetAt(now time.Time) time.Duration {
+	if c.slew == 0 {
+		return c.offset
+	}
+	applied := time.Duration(float64(now.Sub(c.slewFrom)) * c.slewRate)
+	if applied >= abs(c.slew) {
+		return c.offset + c.slew
+	}
+	if c.slew < 0 {
+		return c.offset - applied
+	}
+	return c.offset + applied
 }
 
 // Start performs an initial NTP sync and starts a background goroutine
 // that re-syncs on the configured interval.
 func (c *NTPClock) Start(ctx context.Context) error {
+	if len(c.servers) == 0 {
+		return errors.New("ntp: no servers configured")
+	}
 	c.sync()
 
 	ctx, c.cancel = context.WithCancel(ctx)
@@ -122,21 +243,122 @@ func (c *NTPClock) run(ctx context.Context) {
 }
 
 func (c *NTPClock) sync() {
-	resp, err := ntp.QueryWithOptions(c.server, ntp.QueryOptions{
-		Timeout: c.timeout,
-	})
+	offsets := c.queryAll()
+	offset, err := agreedOffset(offsets, c.maxSpread)
 	if err != nil {
-		if c.logger != nil {
-			c.logger.WarnW("ntp sync failed, keeping last offset", "error", err)
-		}
+		c.warn("ntp sync failed, keeping last offset", "error", err, "servers", len(c.servers), "answered", len(offsets))
 		return
 	}
 
+	now := time.Now()
 	c.mu.Lock()
-	c.offset = resp.ClockOffset
+	synced := !c.lastSync.IsZero()
+	target := c.offset + c.slew
+	jumped := synced && abs(offset-target) > c.maxStep
+	if jumped && (c.pending == nil || abs(offset-*c.pending) > c.maxSpread) {
+		c.pending = &offset
+		c.mu.Unlock()
+		c.warn("ntp offset jumped, waiting for the next sync to confirm", "offset", offset, "current", target)
+		return
+	}
+	c.pending = nil
+
+	if synced && !jumped {
+		c.offset = c.offsetAt(now)
+		c.slew = offset - c.offset
+	} else {
+		// The first sync runs before anything relies on the corrected
+		// time, and a confirmed jump would take days to slew, so both
+		// step straight to the new offset.
+		c.offset, c.slew = offset, 0
+	}
+	c.slewFrom = now
+	c.lastSync = now
+
+	drifting := abs(offset) > c.driftThreshold
+	crossed := drifting != c.drifting
+	c.drifting = drifting
 	c.mu.Unlock()
 
 	if c.logger != nil {
-		c.logger.InfoW("ntp sync", "offset", resp.ClockOffset)
+		c.logger.InfoW("ntp sync", "offset", offset, "servers", len(c.servers), "answered", len(offsets))
+	}
+	switch {
+	case crossed && drifting:
+		c.warn("system clock drift over threshold", "offset", offset, "threshold", c.driftThreshold)
+	case crossed && c.logger != nil:
+		c.logger.InfoW("system clock drift back within threshold", "offset", offset, "threshold", c.driftThreshold)
+	}
+}
+
+// queryAll queries every server at once and returns the offsets of those
+// that gave a valid answer.
+func (c *NTPClock) queryAll() []time.Duration {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		offsets []time.Duration
+	)
+	for _, server := range c.servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := ntp.QueryWithOptions(server, ntp.QueryOptions{Timeout: c.timeout})
+			if err == nil {
+				err = resp.Validate()
+			}
+			if err != nil {
+				c.warn("ntp query failed", "server", server, "error", err)
+				return
+			}
+			mu.Lock()
+			offsets = append(offsets, resp.ClockOffset)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return offsets
+}
+
+// agreedOffset returns the median of the offsets within maxSpread of the
+// median of all of them. It fails if those are not a majority.
+func agreedOffset(offsets []time.Duration, maxSpread time.Duration) (time.Duration, error) {
+	if len(offsets) == 0 {
+		return 0, errors.New("no server answered")
+	}
+
+	mid := median(offsets)
+	var kept []time.Duration
+	for _, off := range offsets {
+		if abs(off-mid) <= maxSpread {
+			kept = append(kept, off)
+		}
+	}
+	if len(kept) <= len(offsets)/2 {
+		return 0, fmt.Errorf("servers disagree: only %d of %d within %s of the median", len(kept), len(offsets), maxSpread)
+	}
+	return median(kept), nil
+}
+
+func median(values []time.Duration) time.Duration {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (c *NTPClock) warn(msg string, keysAndValues ...any) {
+	if c.logger != nil {
+		c.logger.WarnW(msg, keysAndValues...)
 	}
 }
@@ -1,6 +1,12 @@
 package clock
 
 import (
+	"context"
+	"encoding/binary"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -74,3 +80,186 @@ func TestNTPClock_Integration(t *testing.T) {
 		t.Fatalf("NTPClock.Now() differs from time.Now() by %v after sync", diff)
 	}
 }
+
+// fakeNTPServer answers NTP queries on a local UDP port as a server whose
+// clock is ahead of the system clock by offset.
+type fakeNTPServer struct {
+	conn   net.PacketConn
+	offset atomic.Int64
+}
+
+func startFakeNTP(t *testing.T, offset time.Duration) *fakeNTPServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &fakeNTPServer{conn: conn}
+	s.offset.Store(int64(offset))
+	go s.serve()
+	return s
+}
+
+func (s *fakeNTPServer) addr() string { return s.conn.LocalAddr().String() }
+
+func (s *fakeNTPServer) setOffset(d time.Duration) { s.offset.Store(int64(d)) }
+
+func (s *fakeNTPServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < 48 {
+			continue
+		}
+
+		now := time.Now().Add(time.Duration(s.offset.Load()))
+		resp := make([]byte, 48)
+		resp[0] = 4<<3 | 4 // no leap warning, version 4, server mode
+		resp[1] = 2        // stratum
+		copy(resp[12:16], "TEST")
+		putNTPTime(resp[16:], now.Add(-time.Minute)) // reference time
+		copy(resp[24:32], buf[40:48])                // origin: the query's transmit time
+		putNTPTime(resp[32:], now)                   // receive time
+		putNTPTime(resp[40:], now)                   // transmit time
+		_, _ = s.conn.WriteTo(resp, addr)
+	}
+}
+
+func putNTPTime(b []byte, t time.Time) {
+	const ntpEpochOffset = 2208988800 // seconds from 1900 to 1970
+	nanos := t.UnixNano()
+	sec := uint64(nanos/1e9 + ntpEpochOffset)
+	frac := uint64(nanos%1e9) << 32 / 1e9
+	binary.BigEndian.PutUint64(b, sec<<32|frac)
+}
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *recordingLogger) InfoW(string, ...any) {}
+
+func (l *recordingLogger) WarnW(msg string, _ ...any) {
+	l.mu.Lock()
+	l.warns = append(l.warns, msg)
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) warned(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.warns, msg)
+}
+
+// near reports whether got is within 50ms of want, allowing for the
+// round trip to the local responders.
+func near(got, want time.Duration) bool {
+	return abs(got-want) < 50*time.Millisecond
+}
+
+func TestNTPClockIgnoresOutliers(t *testing.T) {
+	a := startFakeNTP(t, 1200*time.Millisecond)
+	b := startFakeNTP(t, 1300*time.Millisecond)
+	outlier := startFakeNTP(t, -time.Hour)
+	log := &recordingLogger{}
+
+	c := NewNTP(WithServers(a.addr(), b.addr(), outlier.addr()), WithTimeout(time.Second), WithLogger(log))
+	c.sync()
+
+	if off := c.Offset(); !near(off, 1250*time.Millisecond) {
+		t.Fatalf("Offset() = %s, want ~1.25s", off)
+	}
+	if c.LastSync().IsZero() {
+		t.Error("LastSync not set")
+	}
+	if !log.warned("system clock drift over threshold") {
+		t.Errorf("expected a drift warning, got %q", log.warns)
+	}
+}
+
+func TestNTPClockConfirmsJumpsAndSlews(t *testing.T) {
+	servers := []*fakeNTPServer{
+		startFakeNTP(t, 100*time.Millisecond),
+		startFakeNTP(t, 100*time.Millisecond),
+		startFakeNTP(t, 100*time.Millisecond),
+	}
+	var addrs []string
+	for _, s := range servers {
+		addrs = append(addrs, s.addr())
+	}
+	c := NewNTP(WithServers(addrs...), WithTimeout(time.Second))
+
+	c.sync()
+	if off := c.Offset(); !near(off, 100*time.Millisecond) {
+		t.Fatalf("first sync: Offset() = %s, want ~100ms", off)
+	}
+
+	for _, s := range servers {
+		s.setOffset(10 * time.Second)
+	}
+	c.sync()
+	if off := c.Offset(); !near(off, 100*time.Millisecond) {
+		t.Fatalf("unconfirmed jump applied: Offset() = %s", off)
+	}
+
+	c.sync()
+	if off := c.Offset(); !near(off, 10*time.Second) {
+		t.Fatalf("confirmed jump not stepped: Offset() = %s, want ~10s", off)
+	}
+
+	// A change within the max step is slewed in.
+	for _, s := range servers {
+		s.setOffset(11 * time.Second)
+	}
+	c.sync()
+	c.mu.RLock()
+	start, from, slew := c.offset, c.slewFrom, c.slew
+	afterSecond := c.offsetAt(from.Add(time.Second))
+	afterHour := c.offsetAt(from.Add(time.Hour))
+	c.mu.RUnlock()
+
+	if !near(slew, time.Second) {
+		t.Fatalf("small change: slew = %s, want ~1s", slew)
+	}
+	if got := afterSecond - start; got != 500*time.Microsecond {
+		t.Errorf("slewed %s in a second, want 500µs", got)
+	}
+	if !near(afterHour, 11*time.Second) {
+		t.Errorf("offset an hour later = %s, want ~11s", afterHour)
+	}
+}
+
+func TestNTPClockRequiresServers(t *testing.T) {
+	c := NewNTP(WithServers())
+	if err := c.Start(context.Background()); err == nil {
+		c.Stop()
+		t.Fatal("Start with no servers succeeded")
+	}
+}
+
+func TestAgreedOffset(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		offsets []time.Duration
+		want    time.Duration
+		wantErr bool
+	}{
+		{"single", []time.Duration{40 * ms}, 40 * ms, false},
+		{"median of agreeing", []time.Duration{10 * ms, 30 * ms, 20 * ms, 400 * ms}, 20 * ms, false},
+		{"split", []time.Duration{0, 5 * time.Second}, 0, true},
+		{"none", nil, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := agreedOffset(tt.offsets, 250*ms)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: agreedOffset = %s, %v; want %s, error %t", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
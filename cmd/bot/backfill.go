@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+	"github.com/tnicklin/celestial_orrey/warcraftlogs"
+)
+
+// runBackfill fetches a character's runs for the current week, stores them
+// the way the poller does and prints how each one was stored and which
+// WarcraftLogs fight it matches, to debug missing keys and links.
+// Usage: celestial-orrey backfill <name> <realm> [region]
+func runBackfill(args []string) int {
+	if len(args) < 2 || len(args) > 3 {
+		return usageError("backfill")
+	}
+
+	char := models.Character{
+		Name:   args[0],
+		Realm:  args[1],
+		Region: "us",
+	}
+	if len(args) == 3 {
+		char.Region = strings.ToLower(args[2])
+	}
+
+	if err := backfill(context.Background(), char); err != nil {
+		fmt.Fprintf(os.Stderr, "backfill: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func backfill(ctx context.Context, char models.Character) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	rio, _, err := buildKeyClient(cfg, clock.System())
+	if err != nil {
+		return err
+	}
+	st, closeStore, err := openStoreLocked(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	result, err := rio.FetchWeeklyRuns(ctx, char)
+	if err != nil {
+		_ = st.Close()
+		return fmt.Errorf("fetch runs: %w", err)
+	}
+
+	now := time.Now()
+	cutoff := timeutil.WeeklyResetAt(now)
+	var keys []models.CompletedKey
+	for _, key := range result.Keys {
+		completed, err := timeutil.ParseRFC3339(key.CompletedAt)
+		if err != nil || completed.Before(cutoff) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	fmt.Printf("%s: score %.1f, %d runs this week\n", char.Key(), result.RIOScore, len(keys))
+
+	httpClient, err := apiClient(cfg)
+	if err != nil {
+		_ = st.Close()
+		return err
+	}
+	linker := warcraftlogs.NewLinker(warcraftlogs.LinkerParams{
+		Store: st,
+		Client: warcraftlogs.New(warcraftlogs.Params{
+			ClientID:     cfg.WarcraftLogs.ClientID,
+			ClientSecret: cfg.WarcraftLogs.ClientSecret.Reveal(),
+			HTTPClient:   httpClient,
+		}),
+		Logger: logger.NewNop(),
+	})
+	linker.MatchWindow = now.Sub(cutoff) + 24*time.Hour
+
+	statuses, err := linker.ReconcileKeys(ctx, keys)
+	if err != nil {
+		_ = st.Close()
+		return fmt.Errorf("store keys: %w", err)
+	}
+	if err := st.UpdateCharacterScore(ctx, char.Name, char.Realm, char.Region, result.RIOScore); err != nil {
+		_ = st.Close()
+		return fmt.Errorf("update score: %w", err)
+	}
+
+	canMatch := cfg.WarcraftLogs.ClientID != "" && cfg.WarcraftLogs.ClientSecret != ""
+	for i, key := range keys {
+		fmt.Printf("  %s +%d  %s  %s  %s\n",
+			key.Dungeon, key.KeyLevel, key.CompletedAt, key.Source, statuses[i])
+		if !canMatch {
+			continue
+		}
+
+		match, err := linker.MatchKey(ctx, key)
+		switch {
+		case err != nil:
+			fmt.Printf("    warcraftlogs: %v\n", err)
+		case match == nil:
+			fmt.Println("    warcraftlogs: no matching fight")
+		default:
+			fmt.Printf("    warcraftlogs: %s (confidence %.2f)\n",
+				warcraftlogs.BuildMythicPlusURL(match.Run), match.Confidence)
+		}
+	}
+
+	return closeStore()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+// command is a maintenance subcommand run instead of the bot. It returns
+// the process exit code: 0 on success, 1 on failure and 2 on bad usage.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) int
+}
+
+var _commands []command
+
+func init() {
+	_commands = []command{
+		{name: "migrate", usage: "migrate", summary: "apply schema migrations to the configured store", run: runMigrate},
+		{name: "check-config", usage: "check-config", summary: "load and validate the config without connecting", run: runCheckConfig},
+		{name: "backfill", usage: "backfill <name> <realm> [region]", summary: "fetch and store a character's runs this week, showing how each is matched", run: runBackfill},
+		{name: "restore", usage: "restore <archive>", summary: "replace the SQLite snapshot with an archive, keeping the old one", run: runRestore},
+		{name: "vacuum", usage: "vacuum", summary: "compact the database and its snapshot", run: runVacuum},
+		{name: "report", usage: "report [--week[=YYYY-MM-DD]]", summary: "print the Great Vault report for a week", run: runReport},
+		{name: "export", usage: "export [-snapshot path] [-range week|season] [-format csv|json] [-out dir]", summary: "write keys, links and scores to files", run: runExport},
+		{name: "import", usage: "import <file>...", summary: "load a roster or key file into the store", run: runImport},
+		{name: "help", usage: "help", summary: "list subcommands", run: runHelp},
+	}
+}
+
+// lookupCommand returns the subcommand called name.
+func lookupCommand(name string) (command, bool) {
+	for _, c := range _commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func runHelp([]string) int {
+	printCommands(os.Stdout)
+	return 0
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "usage: celestial-orrey [command]")
+	fmt.Fprintln(w, "\nWith no command the bot starts. Commands:")
+	for _, c := range _commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.summary)
+	}
+}
+
+// usageError prints the usage of the named command and returns the bad
+// usage exit code.
+func usageError(name string) int {
+	c, _ := lookupCommand(name)
+	fmt.Fprintf(os.Stderr, "usage: celestial-orrey %s\n", c.usage)
+	return 2
+}
+
+// openStore builds and opens the configured store, loading the SQLite
+// snapshot the way the bot does at startup. The returned close func flushes
+// a SQLite store back to its snapshot before closing it.
+func openStore(ctx context.Context, cfg appConfig) (store.Store, func() error, error) {
+	st, err := buildStore(cfg, logger.NewNop(), clock.System())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := st.Open(ctx); err != nil {
+		return nil, nil, fmt.Errorf("open store: %w", err)
+	}
+
+	restored, err := st.RestoreSnapshot(ctx)
+	if err != nil {
+		_ = st.Close()
+		return nil, nil, fmt.Errorf("load snapshot: %w", err)
+	}
+	if restored.FellBack() {
+		fmt.Fprintf(os.Stderr, "snapshot not used (%s), loaded %q\n", restored.Reason, restored.RestoredFrom)
+	}
+	return st, func() error { return st.Shutdown(ctx) }, nil
+}
+
+// openStoreLocked is openStore for commands that write the store. It holds
+// the store lock, which the running bot also holds, until the returned
+// close func runs.
+func openStoreLocked(ctx context.Context, cfg appConfig) (store.Store, func() error, error) {
+	lock, err := lockStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, closeStore, err := openStore(ctx, cfg)
+	if err != nil {
+		_ = lock.Release()
+		return nil, nil, err
+	}
+	return st, func() error { return errors.Join(closeStore(), lock.Release()) }, nil
+}
+
+// flushSnapshot writes a SQLite store back to its snapshot now, returning
+// any error that closing the store would only log. Other backends persist
+// each write and need nothing.
+func flushSnapshot(ctx context.Context, st store.Store, cfg appConfig) error {
+	sqlite, ok := st.(*store.SQLiteStore)
+	if !ok {
+		return nil
+	}
+	if err := sqlite.FlushToDisk(ctx, cfg.Store.Path); err != nil && !errors.Is(err, store.ErrEmptyOverwrite) {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/discord"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/replay"
+	"github.com/tnicklin/celestial_orrey/secret"
+	"github.com/tnicklin/celestial_orrey/store"
+	"go.uber.org/config"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	_configDir = "config"
+	// _envPrefix starts the environment variables that override config
+	// values. Appending _envFileSuffix names a file to read the value from
+	// instead, as Docker and Kubernetes secrets are mounted.
+	_envPrefix     = "CELESTIAL_ORREY_"
+	_envFileSuffix = "_FILE"
+	// _replayCredential stands in for API credentials left unset in replay
+	// mode, where recorded token responses are served instead.
+	_replayCredential = "replay"
+)
+
+// envOverride is a config value that can be set from the environment.
+type envOverride struct {
+	field string
+	set   func(cfg *appConfig, value string)
+}
+
+// _envOverrides lists the credentials that can be kept out of config/.
+// Each is read from _envPrefix plus the field path in upper case with dots
+// as underscores, e.g. CELESTIAL_ORREY_DISCORD_TOKEN.
+var _envOverrides = []envOverride{
+	{"discord.token", func(c *appConfig, v string) { c.Discord.Token = secret.String(v) }},
+	{"warcraftlogs.client_id", func(c *appConfig, v string) { c.WarcraftLogs.ClientID = v }},
+	{"warcraftlogs.client_secret", func(c *appConfig, v string) { c.WarcraftLogs.ClientSecret = secret.String(v) }},
+	{"blizzard.client_id", func(c *appConfig, v string) { c.Blizzard.ClientID = v }},
+	{"blizzard.client_secret", func(c *appConfig, v string) { c.Blizzard.ClientSecret = secret.String(v) }},
+	{"store.postgres.dsn", func(c *appConfig, v string) { c.Store.Postgres.DSN = secret.String(v) }},
+	{"store.s3.access_key_id", func(c *appConfig, v string) { c.Store.S3.AccessKeyID = v }},
+	{"store.s3.secret_access_key", func(c *appConfig, v string) { c.Store.S3.SecretAccessKey = secret.String(v) }},
+}
+
+// envName returns the variable that overrides field.
+func envName(field string) string {
+	return _envPrefix + strings.ToUpper(strings.ReplaceAll(field, ".", "_"))
+}
+
+// loadConfig reads the config and checks every field the bot and its
+// subcommands share.
+func loadConfig() (appConfig, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return appConfig{}, err
+	}
+	if err := validateConfig(cfg); err != nil {
+		return appConfig{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// readConfig merges the YAML files in config/ and applies environment
+// overrides, without validating the result.
+func readConfig() (appConfig, error) {
+	files, err := os.ReadDir(_configDir)
+	if err != nil {
+		return appConfig{}, fmt.Errorf("read config dir: %w", err)
+	}
+
+	var opts []config.YAMLOption
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), ".yaml") {
+			continue
+		}
+		path := filepath.Join(_configDir, f.Name())
+		opts = append(opts, config.File(path))
+	}
+	if len(opts) == 0 {
+		return appConfig{}, fmt.Errorf("no yaml files found in %q", _configDir)
+	}
+
+	provider, err := config.NewYAML(opts...)
+	if err != nil {
+		return appConfig{}, err
+	}
+
+	var cfg appConfig
+	if err = provider.Get(config.Root).Populate(&cfg); err != nil {
+		return appConfig{}, err
+	}
+
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return appConfig{}, err
+	}
+	applyReplay(&cfg)
+	return cfg, nil
+}
+
+// applyReplay fills in placeholder API credentials in replay mode, so the
+// clients run against fixtures without real secrets.
+func applyReplay(cfg *appConfig) {
+	if cfg.Replay.Mode != replay.ModeReplay {
+		return
+	}
+	if cfg.WarcraftLogs.ClientID == "" && !cfg.WarcraftLogs.ClientSecret.IsSet() {
+		cfg.WarcraftLogs.ClientID = _replayCredential
+		cfg.WarcraftLogs.ClientSecret = _replayCredential
+	}
+	if cfg.Blizzard.ClientID == "" && !cfg.Blizzard.ClientSecret.IsSet() {
+		cfg.Blizzard.ClientID = _replayCredential
+		cfg.Blizzard.ClientSecret = _replayCredential
+	}
+}
+
+// applyEnv overrides cfg with the values set in the environment. A
+// variable naming a file takes precedence over the plain variable.
+func applyEnv(cfg *appConfig, lookup func(string) (string, bool)) error {
+	for _, o := range _envOverrides {
+		name := envName(o.field)
+		if path, ok := lookup(name + _envFileSuffix); ok {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name+_envFileSuffix, err)
+			}
+			o.set(cfg, strings.TrimSpace(string(raw)))
+			continue
+		}
+		if value, ok := lookup(name); ok {
+			o.set(cfg, value)
+		}
+	}
+	return nil
+}
+
+// fieldError is a problem with one config field, named by its YAML path.
+type fieldError struct {
+	field   string
+	problem string
+}
+
+func (e fieldError) Error() string {
+	return e.field + ": " + e.problem
+}
+
+// fieldErrors collects every problem found in a config.
+type fieldErrors []error
+
+func (errs *fieldErrors) add(field, format string, args ...any) {
+	*errs = append(*errs, fieldError{field: field, problem: fmt.Sprintf(format, args...)})
+}
+
+func (errs fieldErrors) err() error {
+	return errors.Join(errs...)
+}
+
+// validateConfig checks the fields every command relies on. Fields only
+// the running bot needs are checked by validateBotConfig.
+func validateConfig(cfg appConfig) error {
+	var errs fieldErrors
+
+	if cfg.Logger.Level != "" {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(cfg.Logger.Level)); err != nil {
+			errs.add("logger.level", "unknown level %q", cfg.Logger.Level)
+		}
+	}
+
+	if cfg.Discord.SeasonStart != "" {
+		if _, err := time.Parse(time.DateOnly, cfg.Discord.SeasonStart); err != nil {
+			errs.add("discord.season_start", "want YYYY-MM-DD, got %q", cfg.Discord.SeasonStart)
+		}
+	}
+	if cfg.Discord.VaultTable != "" {
+		if _, ok := discord.VaultTables[cfg.Discord.VaultTable]; !ok {
+			errs.add("discord.vault_table", "want one of %s, got %q",
+				strings.Join(discord.VaultTableNames(), ", "), cfg.Discord.VaultTable)
+		}
+	}
+	for i, id := range cfg.Discord.Admins {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			errs.add(fmt.Sprintf("discord.admins[%d]", i), "want a Discord user ID, got %q", id)
+		}
+	}
+
+	var blizzard bool
+	for i, source := range cfg.RaiderIO.Sources {
+		switch strings.ToLower(source) {
+		case models.SourceRaiderIO:
+		case models.SourceBlizzard:
+			blizzard = true
+		default:
+			errs.add(fmt.Sprintf("raiderio.sources[%d]", i), "unknown source %q", source)
+		}
+	}
+	if cfg.RaiderIO.BaseURL != "" {
+		checkURL(&errs, "raiderio.base_url", cfg.RaiderIO.BaseURL)
+	}
+	if cfg.RaiderIO.PollInterval < 0 {
+		errs.add("raiderio.poll_interval", "must not be negative")
+	}
+	if cfg.RaiderIO.MaxConcurrent < 0 {
+		errs.add("raiderio.max_concurrent", "must not be negative")
+	}
+	if cfg.RaiderIO.ActiveWindow < 0 {
+		errs.add("raiderio.active_window", "must not be negative")
+	}
+	if cfg.RaiderIO.IdlePollInterval < 0 {
+		errs.add("raiderio.idle_poll_interval", "must not be negative")
+	}
+	if cfg.RaiderIO.CacheTTL < 0 {
+		errs.add("raiderio.cache_ttl", "must not be negative")
+	}
+
+	if blizzard {
+		if cfg.Blizzard.ClientID == "" {
+			errs.add("blizzard.client_id", "is required by the blizzard source")
+		}
+		if !cfg.Blizzard.ClientSecret.IsSet() {
+			errs.add("blizzard.client_secret", "is required by the blizzard source")
+		}
+	}
+	if (cfg.WarcraftLogs.ClientID == "") != !cfg.WarcraftLogs.ClientSecret.IsSet() {
+		errs.add("warcraftlogs", "client_id and client_secret must be set together")
+	}
+
+	if cfg.ElvUI.PollInterval < 0 {
+		errs.add("elvui.poll_interval", "must not be negative")
+	}
+	if cfg.ElvUI.APIURL != "" {
+		checkURL(&errs, "elvui.api_url", cfg.ElvUI.APIURL)
+	}
+
+	switch cfg.Replay.Mode {
+	case replay.ModeOff:
+	case replay.ModeRecord, replay.ModeReplay:
+		if cfg.Replay.Dir == "" {
+			errs.add("replay.dir", "is required in %s mode", cfg.Replay.Mode)
+		}
+	default:
+		errs.add("replay.mode", "want %q, %q or empty, got %q", replay.ModeRecord, replay.ModeReplay, cfg.Replay.Mode)
+	}
+
+	for i, server := range cfg.NTP.Servers {
+		if strings.TrimSpace(server) == "" {
+			errs.add(fmt.Sprintf("ntp.servers[%d]", i), "must not be empty")
+		}
+	}
+	if cfg.NTP.Interval < 0 {
+		errs.add("ntp.interval", "must not be negative")
+	}
+	if cfg.NTP.Timeout < 0 {
+		errs.add("ntp.timeout", "must not be negative")
+	}
+	if cfg.NTP.MaxSpread < 0 {
+		errs.add("ntp.max_spread", "must not be negative")
+	}
+	if cfg.NTP.MaxStep < 0 {
+		errs.add("ntp.max_step", "must not be negative")
+	}
+	if cfg.NTP.DriftThreshold < 0 {
+		errs.add("ntp.drift_threshold", "must not be negative")
+	}
+
+	validateStoreConfig(&errs, cfg.Store)
+	return errs.err()
+}
+
+func validateStoreConfig(errs *fieldErrors, cfg store.Config) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", store.BackendSQLite:
+		if cfg.Path == "" {
+			errs.add("store.path", "is required by the sqlite backend")
+		}
+	case store.BackendPostgres:
+		if !cfg.Postgres.DSN.IsSet() {
+			errs.add("store.postgres.dsn", "is required by the postgres backend")
+		}
+	default:
+		errs.add("store.backend", "want %q or %q, got %q", store.BackendSQLite, store.BackendPostgres, cfg.Backend)
+	}
+	if cfg.Postgres.MaxOpenConns < 0 {
+		errs.add("store.postgres.max_open_conns", "must not be negative")
+	}
+
+	compressions := []string{"", store.CompressionNone, store.CompressionGzip, store.CompressionZstd}
+	if !slices.Contains(compressions, cfg.Backup.Compression) {
+		errs.add("store.backup.compression", "want none, gzip or zstd, got %q", cfg.Backup.Compression)
+	}
+	if cfg.Backup.KeepWeekly < 0 {
+		errs.add("store.backup.keep_weekly", "must not be negative")
+	}
+	if cfg.Backup.KeepMonthly < 0 {
+		errs.add("store.backup.keep_monthly", "must not be negative")
+	}
+
+	if cfg.S3.Enabled() {
+		if cfg.S3.Endpoint == "" {
+			errs.add("store.s3.endpoint", "is required when a bucket is set")
+		} else {
+			checkURL(errs, "store.s3.endpoint", cfg.S3.Endpoint)
+		}
+		if cfg.S3.AccessKeyID == "" {
+			errs.add("store.s3.access_key_id", "is required when a bucket is set")
+		}
+		if !cfg.S3.SecretAccessKey.IsSet() {
+			errs.add("store.s3.secret_access_key", "is required when a bucket is set")
+		}
+	}
+	if cfg.S3.SnapshotInterval < 0 {
+		errs.add("store.s3.snapshot_interval", "must not be negative")
+	}
+	if cfg.S3.KeepSnapshots < 0 {
+		errs.add("store.s3.keep_snapshots", "must not be negative")
+	}
+}
+
+// validateBotConfig checks the fields only the running bot needs.
+func validateBotConfig(cfg appConfig) error {
+	var errs fieldErrors
+	if !cfg.Discord.Token.IsSet() {
+		errs.add("discord.token", "is required; set it in config/ or %s", envName("discord.token"))
+	}
+	if cfg.Discord.ListenChannel == "" {
+		errs.add("discord.listen_channel", "is required")
+	}
+	return errs.err()
+}
+
+func checkURL(errs *fieldErrors, field, value string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		errs.add(field, "want an absolute URL, got %q", value)
+	}
+}
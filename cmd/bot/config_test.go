@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/secret"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+func TestApplyEnv(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("  from-file\n"), 0o600); err != nil {
+		t.Fatalf("write token file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    func(appConfig) bool
+		wantErr bool
+	}{
+		{
+			name: "plain variable",
+			env:  map[string]string{"CELESTIAL_ORREY_DISCORD_TOKEN": "plain"},
+			want: func(c appConfig) bool { return c.Discord.Token.Reveal() == "plain" },
+		},
+		{
+			name: "file takes precedence and is trimmed",
+			env: map[string]string{
+				"CELESTIAL_ORREY_DISCORD_TOKEN":      "plain",
+				"CELESTIAL_ORREY_DISCORD_TOKEN_FILE": tokenFile,
+			},
+			want: func(c appConfig) bool { return c.Discord.Token.Reveal() == "from-file" },
+		},
+		{
+			name:    "missing file",
+			env:     map[string]string{"CELESTIAL_ORREY_DISCORD_TOKEN_FILE": filepath.Join(dir, "missing")},
+			wantErr: true,
+		},
+		{
+			name: "nested fields",
+			env: map[string]string{
+				"CELESTIAL_ORREY_STORE_POSTGRES_DSN":         "postgres://db",
+				"CELESTIAL_ORREY_STORE_S3_ACCESS_KEY_ID":     "key",
+				"CELESTIAL_ORREY_STORE_S3_SECRET_ACCESS_KEY": "s3-secret",
+			},
+			want: func(c appConfig) bool {
+				return c.Store.Postgres.DSN.Reveal() == "postgres://db" &&
+					c.Store.S3.AccessKeyID == "key" &&
+					c.Store.S3.SecretAccessKey.Reveal() == "s3-secret"
+			},
+		},
+		{
+			name: "unset keeps config",
+			env:  map[string]string{},
+			want: func(c appConfig) bool { return c.Discord.Token.Reveal() == "from-config" },
+		},
+	}
+	for _, tt := range tests {
+		cfg := appConfig{}
+		cfg.Discord.Token = secret.String("from-config")
+		err := applyEnv(&cfg, func(name string) (string, bool) {
+			v, ok := tt.env[name]
+			return v, ok
+		})
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "CELESTIAL_ORREY_DISCORD_TOKEN_FILE") {
+				t.Errorf("%s: expected error naming the variable, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !tt.want(cfg) {
+			t.Errorf("%s: unexpected config %+v", tt.name, cfg)
+		}
+	}
+}
+
+// validTestConfig returns a config that passes validateConfig.
+func validTestConfig() appConfig {
+	var cfg appConfig
+	cfg.Store.Path = "data/keys.db"
+	return cfg
+}
+
+func TestValidateConfig(t *testing.T) {
+	if err := validateConfig(validTestConfig()); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*appConfig)
+		fields []string
+	}{
+		{
+			name:   "logger level",
+			modify: func(c *appConfig) { c.Logger.Level = "loud" },
+			fields: []string{"logger.level"},
+		},
+		{
+			name: "several bad fields",
+			modify: func(c *appConfig) {
+				c.Discord.SeasonStart = "March"
+				c.Discord.Admins = []string{"42", "bob"}
+				c.RaiderIO.Sources = []string{"raiderio", "wowhead"}
+				c.RaiderIO.PollInterval = -time.Minute
+			},
+			fields: []string{"discord.season_start", "discord.admins[1]", "raiderio.sources[1]", "raiderio.poll_interval"},
+		},
+		{
+			name:   "blizzard source needs credentials",
+			modify: func(c *appConfig) { c.RaiderIO.Sources = []string{"blizzard"} },
+			fields: []string{"blizzard.client_id", "blizzard.client_secret"},
+		},
+		{
+			name:   "warcraftlogs id without secret",
+			modify: func(c *appConfig) { c.WarcraftLogs.ClientID = "id" },
+			fields: []string{"warcraftlogs"},
+		},
+		{
+			name:   "warcraftlogs secret without id",
+			modify: func(c *appConfig) { c.WarcraftLogs.ClientSecret = secret.String("shh") },
+			fields: []string{"warcraftlogs"},
+		},
+		{
+			name: "warcraftlogs id and secret",
+			modify: func(c *appConfig) {
+				c.WarcraftLogs.ClientID = "id"
+				c.WarcraftLogs.ClientSecret = secret.String("shh")
+			},
+		},
+		{
+			name:   "sqlite needs a path",
+			modify: func(c *appConfig) { c.Store.Path = "" },
+			fields: []string{"store.path"},
+		},
+		{
+			name:   "postgres needs a dsn",
+			modify: func(c *appConfig) { c.Store.Backend = store.BackendPostgres },
+			fields: []string{"store.postgres.dsn"},
+		},
+		{
+			name:   "unknown backend",
+			modify: func(c *appConfig) { c.Store.Backend = "mysql" },
+			fields: []string{"store.backend"},
+		},
+		{
+			name: "s3 bucket needs the rest",
+			modify: func(c *appConfig) {
+				c.Store.S3.Bucket = "backups"
+				c.Store.Backup.Compression = "zip"
+			},
+			fields: []string{"store.backup.compression", "store.s3.endpoint", "store.s3.access_key_id", "store.s3.secret_access_key"},
+		},
+	}
+	for _, tt := range tests {
+		cfg := validTestConfig()
+		tt.modify(&cfg)
+		got := errorFields(validateConfig(cfg))
+		slices.Sort(got)
+		want := slices.Clone(tt.fields)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: fields = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestValidateBotConfig(t *testing.T) {
+	got := errorFields(validateBotConfig(appConfig{}))
+	if !slices.Equal(got, []string{"discord.token", "discord.listen_channel"}) {
+		t.Fatalf("fields = %v", got)
+	}
+}
+
+// errorFields returns the field of each fieldError joined in err.
+func errorFields(err error) []string {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return nil
+	}
+	var fields []string
+	for _, e := range joined.Unwrap() {
+		var fe fieldError
+		if errors.As(e, &fe) {
+			fields = append(fields, fe.field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/export"
+	"github.com/tnicklin/celestial_orrey/store"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+// runExport writes an export from a snapshot or archive file without
+// starting the bot.
+// Usage: celestial-orrey export [-snapshot path] [-range week|season]
+// [-season-start YYYY-MM-DD] [-format csv|json] [-out dir]
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	snapshot := flags.String("snapshot", "data/celestial_orrey.db", "snapshot or archive file to read")
+	span := flags.String("range", "week", "week or season")
+	seasonStart := flags.String("season-start", "", "season start date (YYYY-MM-DD); empty exports everything")
+	format := flags.String("format", "csv", "csv or json")
+	out := flags.String("out", ".", "directory to write files to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := writeExport(*snapshot, *span, *seasonStart, *format, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func writeExport(snapshot, span, seasonStart, format, out string) error {
+	f, err := export.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var r export.Range
+	switch span {
+	case "week":
+		r = export.Week(now)
+	case "season":
+		var start time.Time
+		if seasonStart != "" {
+			start, err = time.ParseInLocation(time.DateOnly, seasonStart, timeutil.Location())
+			if err != nil {
+				return fmt.Errorf("parse season start: %w", err)
+			}
+		}
+		r = export.Season(now, start)
+	default:
+		return fmt.Errorf("unknown range %q", span)
+	}
+
+	ctx := context.Background()
+	st, err := store.OpenSnapshot(ctx, snapshot)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	data, err := export.Collect(ctx, st, r)
+	if err != nil {
+		return err
+	}
+	files, err := data.Files(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+	for _, file := range files {
+		path := filepath.Join(out, file.Name)
+		if err := os.WriteFile(path, file.Body, 0o644); err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	return nil
+}
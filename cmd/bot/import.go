@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/importer"
+)
+
+// runImport loads a roster or key file into the configured store without
+// starting the bot. With the SQLite backend the snapshot is rewritten after
+// each file; it fails while the bot is running.
+// Usage: celestial-orrey import <file>...
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		return usageError("import")
+	}
+
+	if err := importFiles(flags.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func importFiles(paths []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	rio, _, err := buildKeyClient(cfg, clock.System())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	st, closeStore, err := openStoreLocked(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	im := importer.New(importer.Params{Store: st, RaiderIO: rio})
+	var saved []string
+	for _, path := range paths {
+		// Each file is written to the snapshot before its summary is
+		// printed, so a later failure never loses rows reported as accepted.
+		summary, err := importFile(ctx, im, path)
+		if err == nil {
+			err = flushSnapshot(ctx, st, cfg)
+		}
+		if err != nil {
+			_ = st.Close()
+			return fmt.Errorf("%s: %w (saved: %s)", path, err, savedFiles(saved))
+		}
+		printImportSummary(path, summary)
+		saved = append(saved, path)
+	}
+
+	return closeStore()
+}
+
+func importFile(ctx context.Context, im *importer.Importer, path string) (importer.Summary, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return importer.Summary{}, err
+	}
+	return im.Import(ctx, filepath.Base(path), body)
+}
+
+func savedFiles(paths []string) string {
+	if len(paths) == 0 {
+		return "none"
+	}
+	return strings.Join(paths, ", ")
+}
+
+func printImportSummary(path string, s importer.Summary) {
+	fmt.Printf("%s: %d rows accepted, %d rejected\n", path, s.Accepted(), len(s.Rejected))
+	fmt.Printf("  characters: %d new, %d already tracked\n", s.Characters, s.KnownCharacters)
+	fmt.Printf("  keys: %d stored, %d already stored, %d duplicates\n", s.Keys, s.KnownKeys, s.DuplicateKeys)
+	for _, r := range s.Rejected {
+		fmt.Printf("  rejected row %d %s %q: %s\n", r.Row, r.Kind, r.Value, r.Reason)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+// storeLock is an exclusive lock on the SQLite snapshot. The bot holds it
+// while it runs, and so does every subcommand that writes the snapshot, so
+// neither overwrites the other's changes with its own in-memory copy.
+type storeLock struct {
+	file *os.File
+}
+
+// lockStore takes the lock beside the configured snapshot, failing at once
+// if it is held. Other backends keep no snapshot and need no lock.
+func lockStore(cfg appConfig) (*storeLock, error) {
+	backend := strings.ToLower(cfg.Store.Backend)
+	if (backend != "" && backend != store.BackendSQLite) || cfg.Store.Path == "" {
+		return &storeLock{}, nil
+	}
+
+	path := cfg.Store.Path + ".lock"
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%s is locked; stop the bot or the other command first", path)
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return &storeLock{file: f}, nil
+}
+
+// Release gives up the lock.
+func (l *storeLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
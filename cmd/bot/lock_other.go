@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+// storeLock is a no-op where file locks are unavailable; the bot and its
+// subcommands must then not be run at the same time.
+type storeLock struct{}
+
+func lockStore(appConfig) (*storeLock, error) { return &storeLock{}, nil }
+
+// Release gives up the lock.
+func (l *storeLock) Release() error { return nil }
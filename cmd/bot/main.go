@@ -2,28 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/tnicklin/celestial_orrey/blizzard"
 	"github.com/tnicklin/celestial_orrey/clock"
 	"github.com/tnicklin/celestial_orrey/discord"
 	"github.com/tnicklin/celestial_orrey/elvui"
+	"github.com/tnicklin/celestial_orrey/jobs"
 	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/models"
 	"github.com/tnicklin/celestial_orrey/raiderio"
 	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+	"github.com/tnicklin/celestial_orrey/replay"
 	"github.com/tnicklin/celestial_orrey/store"
 	"github.com/tnicklin/celestial_orrey/warcraftlogs"
-	"go.uber.org/config"
 	"go.uber.org/fx"
 )
 
-const _configDir = "config"
-
-func main() { fx.New(_app).Run() }
+func main() {
+	if len(os.Args) > 1 {
+		c, ok := lookupCommand(os.Args[1])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+			printCommands(os.Stderr)
+			os.Exit(2)
+		}
+		os.Exit(c.run(os.Args[2:]))
+	}
+	fx.New(_app).Run()
+}
 
 var _app = fx.Options(
 	fx.Provide(build),
@@ -34,9 +46,12 @@ type appConfig struct {
 	Logger       logger.Config       `yaml:"logger"`
 	Discord      discord.Config      `yaml:"discord"`
 	RaiderIO     raiderio.Config     `yaml:"raiderio"`
+	Blizzard     blizzard.Config     `yaml:"blizzard"`
 	WarcraftLogs warcraftlogs.Config `yaml:"warcraftlogs"`
 	Store        store.Config        `yaml:"store"`
 	ElvUI        elvui.Config        `yaml:"elvui"`
+	Replay       replay.Config       `yaml:"replay"`
+	NTP          clock.NTPConfig     `yaml:"ntp"`
 }
 
 type result struct {
@@ -44,14 +59,13 @@ type result struct {
 
 	Config        appConfig
 	Logger        logger.Logger
-	Store         *store.SQLiteStore
+	Store         store.Store
 	NTPClock      *clock.NTPClock
 	RaiderIO      rioClient.Client
-	RIOPoller     raiderio.Poller
 	WarcraftLogs  warcraftlogs.WCL
-	WCLPoller     warcraftlogs.Poller
-	ElvUIPoller   elvui.Poller
+	Jobs          *jobs.Runner
 	DiscordClient discord.Discord
+	Reloader      *reloader
 }
 
 func build() (result, error) {
@@ -59,36 +73,54 @@ func build() (result, error) {
 	if err != nil {
 		return result{}, fmt.Errorf("load config: %w", err)
 	}
+	if err := validateBotConfig(cfg); err != nil {
+		return result{}, fmt.Errorf("invalid config:\n%w", err)
+	}
 
 	appLogger, err := logger.New(cfg.Logger)
 	if err != nil {
 		return result{}, fmt.Errorf("initialize logger: %w", err)
 	}
+	appLogger.DebugW("loaded config", "config", cfg)
 
-	ntpClock := clock.NewNTP(clock.WithLogger(appLogger))
+	ntpClock := clock.NewNTP(clock.WithConfig(cfg.NTP), clock.WithLogger(appLogger))
 
-	st := store.NewSQLiteStore(store.Params{
-		Path:      cfg.Store.Path,
-		BackupDir: cfg.Store.BackupDir,
-		Logger:    appLogger,
+	runner := jobs.NewRunner(jobs.Params{
+		Clock:  ntpClock,
+		Logger: appLogger,
 	})
 
+	// rl is completed below, once the parts it reloads exist.
+	rl := &reloader{logger: appLogger, jobs: runner, cfg: cfg}
+
+	st, err := buildStore(cfg, appLogger, ntpClock)
+	if err != nil {
+		return result{}, fmt.Errorf("store: %w", err)
+	}
+
+	httpClient, err := apiClient(cfg)
+	if err != nil {
+		return result{}, err
+	}
+
 	wclClient := warcraftlogs.New(warcraftlogs.Params{
 		ClientID:     cfg.WarcraftLogs.ClientID,
-		ClientSecret: cfg.WarcraftLogs.ClientSecret,
-		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+		ClientSecret: cfg.WarcraftLogs.ClientSecret.Reveal(),
+		HTTPClient:   httpClient,
+		Clock:        ntpClock,
 	})
 
 	wclLinker := warcraftlogs.NewLinker(warcraftlogs.LinkerParams{
 		Store:  st,
 		Client: wclClient,
+		Logger: appLogger,
+		Clock:  ntpClock,
 	})
 
-	rio := rioClient.New(rioClient.Params{
-		BaseURL:    cfg.RaiderIO.BaseURL,
-		UserAgent:  cfg.RaiderIO.UserAgent,
-		HTTPClient: &http.Client{Timeout: 30 * time.Second},
-	})
+	rio, caches, err := buildKeyClient(cfg, ntpClock)
+	if err != nil {
+		return result{}, fmt.Errorf("key client: %w", err)
+	}
 
 	rioPoller := raiderio.New(raiderio.Params{
 		Config:    cfg.RaiderIO,
@@ -102,16 +134,22 @@ func build() (result, error) {
 		Store:    st,
 		Client:   wclClient,
 		Clock:    ntpClock,
+		Logger:   appLogger,
 		Interval: 5 * time.Minute,
 	})
 
 	discordClient, err := discord.New(discord.Params{
 		Config:       cfg.Discord,
 		Store:        st,
+		Outbox:       st,
 		RaiderIO:     rio,
 		WarcraftLogs: wclClient,
 		Logger:       appLogger,
 		Clock:        ntpClock,
+		Reload:       rl.summary,
+		Jobs:         runner,
+		Schedule:     rioPoller.Schedule,
+		Caches:       caches,
 	})
 	if err != nil {
 		return result{}, fmt.Errorf("discord client: %w", err)
@@ -120,16 +158,26 @@ func build() (result, error) {
 	elvuiPoller := elvui.New(elvui.Params{
 		Config:     cfg.ElvUI,
 		Store:      st,
-		HTTPClient: &http.Client{Timeout: 30 * time.Second},
-		OnNewVersion: func(v elvui.VersionInfo) {
-			msg := fmt.Sprintf("**ElvUI %s** is now available!\n[Download](%s) | [Changelog](%s)",
-				v.Version, v.URL, v.ChangelogURL)
-			if err := discordClient.WriteMessage(cfg.Discord.ListenChannel, msg); err != nil {
-				appLogger.ErrorW("elvui notification", "error", err)
+		HTTPClient: httpClient,
+		Outbox:     st,
+		Announce: func(v elvui.VersionInfo) store.OutboxMessage {
+			return store.OutboxMessage{
+				DedupKey:  "elvui:" + v.Version,
+				ChannelID: rl.config().Discord.ListenChannel,
+				Content:   elvuiAnnouncement(v),
 			}
 		},
 	})
 
+	for _, job := range []jobs.Job{rioPoller.Job(), wclPoller.Job(), elvuiPoller.Job()} {
+		if err := runner.Add(job); err != nil {
+			return result{}, err
+		}
+	}
+
+	rl.rioPoller = rioPoller
+	rl.discord = discordClient
+
 	return result{
 		Config:        cfg,
 		Logger:        appLogger,
@@ -137,10 +185,9 @@ func build() (result, error) {
 		Store:         st,
 		NTPClock:      ntpClock,
 		RaiderIO:      rio,
-		RIOPoller:     rioPoller,
 		WarcraftLogs:  wclClient,
-		WCLPoller:     wclPoller,
-		ElvUIPoller:   elvuiPoller,
+		Jobs:          runner,
+		Reloader:      rl,
 	}, nil
 }
 
@@ -149,14 +196,13 @@ type runParams struct {
 
 	Lifecycle     fx.Lifecycle
 	Config        appConfig
-	Store         *store.SQLiteStore
+	Store         store.Store
 	NTPClock      *clock.NTPClock
 	RaiderIO      rioClient.Client
-	RIOPoller     raiderio.Poller
 	WarcraftLogs  warcraftlogs.WCL
-	WCLPoller     warcraftlogs.Poller
-	ElvUIPoller   elvui.Poller
+	Jobs          *jobs.Runner
 	DiscordClient discord.Discord
+	Reloader      *reloader
 	Logger        logger.Logger
 }
 
@@ -171,19 +217,40 @@ func run(p runParams) error {
 		},
 	})
 
+	// The store lock keeps maintenance subcommands from rewriting the
+	// snapshot while the bot holds its own copy in memory.
+	var lock *storeLock
 	p.Lifecycle.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
+			var err error
+			if lock, err = lockStore(p.Config); err != nil {
+				return err
+			}
 			ctx := context.Background()
 			if err := p.Store.Open(ctx); err != nil {
+				_ = lock.Release()
 				return fmt.Errorf("open keydb store: %w", err)
 			}
-			if err := p.Store.RestoreFromDisk(ctx, p.Config.Store.Path); err != nil {
+			restored, err := p.Store.RestoreSnapshot(ctx)
+			if err != nil {
 				p.Logger.WarnW("restore from disk", "error", err)
 			}
+			if restored.FellBack() {
+				p.Logger.WarnW("snapshot not used at startup",
+					"reason", restored.Reason,
+					"restored_from", restored.RestoredFrom,
+					"quarantined", restored.Quarantined,
+				)
+			}
+			if n, err := p.Store.IndexHistory(ctx, p.NTPClock.Now()); err != nil {
+				p.Logger.WarnW("index weekly history", "error", err)
+			} else if n > 0 {
+				p.Logger.InfoW("indexed weekly history", "rows", n)
+			}
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
-			return p.Store.Shutdown(ctx)
+			return errors.Join(p.Store.Shutdown(ctx), lock.Release())
 		},
 	})
 
@@ -203,42 +270,22 @@ func run(p runParams) error {
 
 	p.Lifecycle.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
-			if err := p.WCLPoller.Start(context.Background()); err != nil {
-				return fmt.Errorf("start wcl poller: %w", err)
-			}
-
-			return nil
-		},
-		OnStop: func(_ context.Context) error {
-			p.WCLPoller.Stop()
-			return nil
-		},
-	})
-
-	p.Lifecycle.Append(fx.Hook{
-		OnStart: func(_ context.Context) error {
-			if err := p.RIOPoller.Start(context.Background()); err != nil {
-				return fmt.Errorf("start rio poller: %w", err)
-			}
-
-			return nil
+			return p.Jobs.Start(context.Background())
 		},
 		OnStop: func(_ context.Context) error {
-			p.RIOPoller.Stop()
+			p.Jobs.Stop()
 			return nil
 		},
 	})
 
+	stopWatch := make(chan struct{})
 	p.Lifecycle.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
-			if err := p.ElvUIPoller.Start(context.Background()); err != nil {
-				return fmt.Errorf("start elvui poller: %w", err)
-			}
-
+			go p.Reloader.watch(stopWatch)
 			return nil
 		},
 		OnStop: func(_ context.Context) error {
-			p.ElvUIPoller.Stop()
+			close(stopWatch)
 			return nil
 		},
 	})
@@ -246,36 +293,104 @@ func run(p runParams) error {
 	return nil
 }
 
-func loadConfig() (appConfig, error) {
-	files, err := os.ReadDir(_configDir)
-	if err != nil {
-		return appConfig{}, fmt.Errorf("read config dir: %w", err)
-	}
+func elvuiAnnouncement(v elvui.VersionInfo) string {
+	return fmt.Sprintf("**ElvUI %s** is now available!\n[Download](%s) | [Changelog](%s)",
+		v.Version, v.URL, v.ChangelogURL)
+}
 
-	var opts []config.YAMLOption
-	for _, f := range files {
-		if f.IsDir() {
-			continue
-		}
-		if !strings.HasSuffix(f.Name(), ".yaml") {
-			continue
+// buildStore returns the store backend selected in the config.
+func buildStore(cfg appConfig, appLogger logger.Logger, clk clock.Clock) (store.Store, error) {
+	switch strings.ToLower(cfg.Store.Backend) {
+	case "", store.BackendSQLite:
+		var sink store.BackupSink
+		if cfg.Store.S3.Enabled() {
+			s3Sink, err := store.NewS3Sink(store.S3Params{Config: cfg.Store.S3})
+			if err != nil {
+				return nil, fmt.Errorf("backup sink: %w", err)
+			}
+			sink = s3Sink
 		}
-		path := filepath.Join(_configDir, f.Name())
-		opts = append(opts, config.File(path))
+
+		return store.NewSQLiteStore(store.Params{
+			Path:                cfg.Store.Path,
+			BackupDir:           cfg.Store.BackupDir,
+			Backup:              cfg.Store.Backup,
+			Logger:              appLogger,
+			AllowEmptyOverwrite: cfg.Store.AllowEmptyOverwrite,
+			Sink:                sink,
+			SinkConfig:          cfg.Store.S3,
+			Clock:               clk,
+		}), nil
+	case store.BackendPostgres:
+		return store.NewPostgresStore(store.PostgresParams{
+			Config: cfg.Store.Postgres,
+			Logger: appLogger,
+			Clock:  clk,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", cfg.Store.Backend)
 	}
-	if len(opts) == 0 {
-		return appConfig{}, fmt.Errorf("no yaml files found in %q", _configDir)
+}
+
+// apiClient returns the HTTP client for RaiderIO, Blizzard, WarcraftLogs and
+// TukUI, which records or replays their responses when the config asks.
+func apiClient(cfg appConfig) (*http.Client, error) {
+	client, err := replay.Client(cfg.Replay, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("api client: %w", err)
 	}
+	return client, nil
+}
 
-	provider, err := config.NewYAML(opts...)
+// buildKeyClient returns the client for the configured weekly run sources,
+// merged when there are several. Each source is behind its own cache, which
+// is also returned for its stats.
+func buildKeyClient(cfg appConfig, clk clock.Clock) (rioClient.Client, []*rioClient.CachingClient, error) {
+	cfg.RaiderIO.Defaults()
+	cfg.Blizzard.Defaults()
+
+	httpClient, err := apiClient(cfg)
 	if err != nil {
-		return appConfig{}, err
+		return nil, nil, err
 	}
 
-	var cfg appConfig
-	if err = provider.Get(config.Root).Populate(&cfg); err != nil {
-		return appConfig{}, err
+	var (
+		caches  []*rioClient.CachingClient
+		sources []rioClient.Source
+	)
+	for _, source := range cfg.RaiderIO.Sources {
+		var client rioClient.Client
+		switch strings.ToLower(source) {
+		case models.SourceRaiderIO:
+			client = rioClient.New(rioClient.Params{
+				BaseURL:    cfg.RaiderIO.BaseURL,
+				UserAgent:  cfg.RaiderIO.UserAgent,
+				HTTPClient: httpClient,
+			})
+		case models.SourceBlizzard:
+			client = blizzard.New(blizzard.Params{
+				ClientID:     cfg.Blizzard.ClientID,
+				ClientSecret: cfg.Blizzard.ClientSecret.Reveal(),
+				Locale:       cfg.Blizzard.Locale,
+				HTTPClient:   httpClient,
+				Clock:        clk,
+			})
+		default:
+			return nil, nil, fmt.Errorf("unknown key source %q", source)
+		}
+		cache := rioClient.NewCache(rioClient.CacheParams{
+			Name:   strings.ToLower(source),
+			Client: client,
+			TTL:    cfg.RaiderIO.CacheTTL,
+			Stale:  cfg.RaiderIO.CacheStale,
+			Clock:  clk,
+		})
+		caches = append(caches, cache)
+		sources = append(sources, rioClient.Source{Name: strings.ToLower(source), Client: cache})
 	}
 
-	return cfg, nil
+	if len(caches) == 1 {
+		return caches[0], caches, nil
+	}
+	return rioClient.NewMulti(sources...), caches, nil
 }
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/store"
+	"gopkg.in/yaml.v2"
+)
+
+// runMigrate applies any pending schema migrations. With SQLite the
+// migrated database is written back to the snapshot.
+// Usage: celestial-orrey migrate
+func runMigrate(args []string) int {
+	if len(args) > 0 {
+		return usageError("migrate")
+	}
+
+	if err := migrate(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	fmt.Println("schema is up to date")
+	return 0
+}
+
+func migrate(ctx context.Context) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	// Opening the store applies the migrations.
+	st, closeStore, err := openStoreLocked(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := flushSnapshot(ctx, st, cfg); err != nil {
+		_ = st.Close()
+		return err
+	}
+	return closeStore()
+}
+
+// runCheckConfig loads the config, prints it with secrets redacted and
+// reports every invalid field, without opening any connections.
+// Usage: celestial-orrey check-config
+func runCheckConfig(args []string) int {
+	if len(args) > 0 {
+		return usageError("check-config")
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check-config: %v\n", err)
+		return 1
+	}
+
+	dump, err := yaml.Marshal(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check-config: %v\n", err)
+		return 1
+	}
+	fmt.Print(string(dump))
+
+	if err := errors.Join(validateConfig(cfg), validateBotConfig(cfg)); err != nil {
+		fmt.Fprintf(os.Stderr, "\ninvalid config:\n%v\n", err)
+		return 1
+	}
+	fmt.Println("\nconfig OK")
+	return 0
+}
+
+// runRestore replaces the SQLite snapshot with an archive after checking
+// its integrity. The old snapshot is kept beside it, renamed with the time
+// of the restore. It fails while the bot is running.
+// Usage: celestial-orrey restore <archive>
+func runRestore(args []string) int {
+	if len(args) != 1 {
+		return usageError("restore")
+	}
+
+	if err := restore(context.Background(), args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func restore(ctx context.Context, archive string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	st, err := buildStore(cfg, logger.NewNop(), clock.System())
+	if err != nil {
+		return err
+	}
+	sqlite, ok := st.(*store.SQLiteStore)
+	if !ok {
+		return errors.New("restore only applies to the sqlite backend")
+	}
+
+	lock, err := lockStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if err := sqlite.Open(ctx); err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	if err := sqlite.RestoreArchive(ctx, archive); err != nil {
+		_ = sqlite.Close()
+		return err
+	}
+
+	// Writing the archive over the snapshot in place would leave nothing to
+	// go back to, so the old snapshot is moved aside first.
+	aside, err := moveAside(cfg.Store.Path, time.Now())
+	if err != nil {
+		_ = sqlite.Close()
+		return fmt.Errorf("move old snapshot aside: %w", err)
+	}
+	if err := sqlite.FlushToDisk(ctx, cfg.Store.Path); err != nil {
+		_ = sqlite.Close()
+		switch putErr := putBack(cfg.Store.Path, aside); {
+		case putErr != nil && aside != "":
+			return fmt.Errorf("write snapshot: %w; old snapshot left at %s: %w", err, aside, putErr)
+		case putErr != nil:
+			return fmt.Errorf("write snapshot: %w; remove partial snapshot: %w", err, putErr)
+		case aside != "":
+			return fmt.Errorf("write snapshot: %w; old snapshot put back", err)
+		default:
+			return fmt.Errorf("write snapshot: %w", err)
+		}
+	}
+	if aside != "" {
+		fmt.Printf("moved old snapshot to %s\n", aside)
+	}
+	fmt.Printf("restored %s to %s\n", archive, cfg.Store.Path)
+	return sqlite.Close()
+}
+
+// moveAside renames the file at path to one stamped with now, returning the
+// new name, or "" if there was no file.
+func moveAside(path string, now time.Time) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	aside := fmt.Sprintf("%s.pre-restore-%s", path, now.UTC().Format("20060102T150405Z"))
+	if err := os.Rename(path, aside); err != nil {
+		return "", err
+	}
+	return aside, nil
+}
+
+// putBack undoes moveAside after a failed write, replacing whatever was
+// partly written at path with the file at aside, or removing it if there
+// was nothing to move aside.
+func putBack(path, aside string) error {
+	if aside == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.Rename(aside, path)
+}
+
+// runVacuum compacts the database. With SQLite the compacted database is
+// written over the snapshot, which shrinks the file; it fails while the bot
+// is running.
+// Usage: celestial-orrey vacuum
+func runVacuum(args []string) int {
+	if len(args) > 0 {
+		return usageError("vacuum")
+	}
+
+	if err := vacuum(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "vacuum: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func vacuum(ctx context.Context) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	st, closeStore, err := openStoreLocked(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := st.Vacuum(ctx); err != nil {
+		_ = st.Close()
+		return err
+	}
+	// Write the snapshot now rather than leave it to closeStore, which only
+	// logs a failed flush.
+	sqlite, isSQLite := st.(*store.SQLiteStore)
+	if isSQLite {
+		if err := sqlite.FlushToDisk(ctx, cfg.Store.Path); err != nil {
+			_ = st.Close()
+			return fmt.Errorf("write snapshot: %w", err)
+		}
+	}
+	if err := closeStore(); err != nil {
+		return err
+	}
+
+	if isSQLite {
+		if info, err := os.Stat(cfg.Store.Path); err == nil {
+			fmt.Printf("%s is now %d bytes\n", cfg.Store.Path, info.Size())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMoveAsideAndPutBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.db")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+
+	aside, err := moveAside(path, time.Date(2026, 2, 4, 15, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("move aside: %v", err)
+	}
+	if aside != path+".pre-restore-20260204T150000Z" {
+		t.Fatalf("aside = %q", aside)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the snapshot moved, got %v", err)
+	}
+
+	// A failed write leaves a partial file, which putBack replaces.
+	if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+		t.Fatalf("write partial: %v", err)
+	}
+	if err := putBack(path, aside); err != nil {
+		t.Fatalf("put back: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "old" {
+		t.Fatalf("snapshot = %q, want the old one back", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if aside, err := moveAside(path, time.Now()); err != nil || aside != "" {
+		t.Fatalf("expected nothing to move, got %q, %v", aside, err)
+	}
+	if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+		t.Fatalf("write partial: %v", err)
+	}
+	if err := putBack(path, ""); err != nil {
+		t.Fatalf("put back: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial snapshot removed, got %v", err)
+	}
+}
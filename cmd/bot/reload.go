@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/tnicklin/celestial_orrey/discord"
+	"github.com/tnicklin/celestial_orrey/elvui"
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/raiderio"
+)
+
+// configField is one setting compared between the running config and a
+// reloaded one. Reloadable fields have an apply func that copies the field
+// from src to dst.
+type configField struct {
+	name  string
+	value func(c appConfig) any
+	apply func(dst *appConfig, src appConfig)
+}
+
+// _reloadableFields are applied to the running bot on reload.
+var _reloadableFields = []configField{
+	{
+		name:  "raiderio.poll_interval",
+		value: func(c appConfig) any { return c.RaiderIO.PollInterval },
+		apply: func(dst *appConfig, src appConfig) { dst.RaiderIO.PollInterval = src.RaiderIO.PollInterval },
+	},
+	{
+		name:  "raiderio.max_concurrent",
+		value: func(c appConfig) any { return c.RaiderIO.MaxConcurrent },
+		apply: func(dst *appConfig, src appConfig) { dst.RaiderIO.MaxConcurrent = src.RaiderIO.MaxConcurrent },
+	},
+	{
+		name:  "raiderio.active_window",
+		value: func(c appConfig) any { return c.RaiderIO.ActiveWindow },
+		apply: func(dst *appConfig, src appConfig) { dst.RaiderIO.ActiveWindow = src.RaiderIO.ActiveWindow },
+	},
+	{
+		name:  "raiderio.idle_poll_interval",
+		value: func(c appConfig) any { return c.RaiderIO.IdlePollInterval },
+		apply: func(dst *appConfig, src appConfig) { dst.RaiderIO.IdlePollInterval = src.RaiderIO.IdlePollInterval },
+	},
+	{
+		name:  "elvui.poll_interval",
+		value: func(c appConfig) any { return c.ElvUI.PollInterval },
+		apply: func(dst *appConfig, src appConfig) { dst.ElvUI.PollInterval = src.ElvUI.PollInterval },
+	},
+	{
+		name:  "discord.listen_channel",
+		value: func(c appConfig) any { return c.Discord.ListenChannel },
+		apply: func(dst *appConfig, src appConfig) { dst.Discord.ListenChannel = src.Discord.ListenChannel },
+	},
+	{
+		name:  "discord.admins",
+		value: func(c appConfig) any { return c.Discord.Admins },
+		apply: func(dst *appConfig, src appConfig) { dst.Discord.Admins = src.Discord.Admins },
+	},
+	{
+		name:  "discord.season_start",
+		value: func(c appConfig) any { return c.Discord.SeasonStart },
+		apply: func(dst *appConfig, src appConfig) { dst.Discord.SeasonStart = src.Discord.SeasonStart },
+	},
+	{
+		name:  "discord.vault_table",
+		value: func(c appConfig) any { return c.Discord.VaultTable },
+		apply: func(dst *appConfig, src appConfig) { dst.Discord.VaultTable = src.Discord.VaultTable },
+	},
+}
+
+// _restartFields only take effect when the bot restarts. Their values are
+// never printed, as several hold secrets.
+var _restartFields = []configField{
+	{name: "logger", value: func(c appConfig) any { return c.Logger }},
+	{name: "discord.token", value: func(c appConfig) any { return c.Discord.Token }},
+	{name: "discord.guild_id", value: func(c appConfig) any { return c.Discord.GuildID }},
+	{name: "raiderio.sources", value: func(c appConfig) any { return c.RaiderIO.Sources }},
+	{name: "raiderio.base_url", value: func(c appConfig) any { return c.RaiderIO.BaseURL }},
+	{name: "raiderio.user_agent", value: func(c appConfig) any { return c.RaiderIO.UserAgent }},
+	{name: "raiderio.cache_ttl", value: func(c appConfig) any { return c.RaiderIO.CacheTTL }},
+	{name: "raiderio.cache_stale", value: func(c appConfig) any { return c.RaiderIO.CacheStale }},
+	{name: "blizzard", value: func(c appConfig) any { return c.Blizzard }},
+	{name: "warcraftlogs", value: func(c appConfig) any { return c.WarcraftLogs }},
+	{name: "store", value: func(c appConfig) any { return c.Store }},
+	{name: "elvui.api_url", value: func(c appConfig) any { return c.ElvUI.APIURL }},
+	{name: "replay", value: func(c appConfig) any { return c.Replay }},
+	{name: "ntp", value: func(c appConfig) any { return c.NTP }},
+}
+
+// reloadResult describes what a reload changed.
+type reloadResult struct {
+	// applied lists the reloadable fields that changed, with their values.
+	applied []string
+	// restart lists changed fields that need a restart.
+	restart []string
+}
+
+func (r reloadResult) String() string {
+	if len(r.applied) == 0 && len(r.restart) == 0 {
+		return "Config reloaded, nothing changed."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Config reloaded.")
+	if len(r.applied) > 0 {
+		sb.WriteString("\nApplied:")
+		for _, line := range r.applied {
+			sb.WriteString("\n  " + line)
+		}
+	}
+	if len(r.restart) > 0 {
+		sb.WriteString("\nNeeds a restart: " + strings.Join(r.restart, ", "))
+	}
+	return sb.String()
+}
+
+// diffConfig compares the running config with next. It returns the config
+// to run with, which is running plus next's reloadable fields.
+func diffConfig(running, next appConfig) (appConfig, reloadResult) {
+	var result reloadResult
+	applied := running
+	for _, f := range _reloadableFields {
+		before, after := f.value(running), f.value(next)
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		f.apply(&applied, next)
+		result.applied = append(result.applied, fmt.Sprintf("%s: %s → %s", f.name, formatValue(before), formatValue(after)))
+	}
+	for _, f := range _restartFields {
+		if !reflect.DeepEqual(f.value(running), f.value(next)) {
+			result.restart = append(result.restart, f.name)
+		}
+	}
+	return applied, result
+}
+
+func formatValue(v any) string {
+	s := fmt.Sprint(v)
+	if s == "" || s == "[]" {
+		return "(unset)"
+	}
+	return s
+}
+
+// reloader re-reads config/ and applies the reloadable settings to the
+// running pollers and Discord client, keeping their in-memory state.
+type reloader struct {
+	logger    logger.Logger
+	jobs      *jobs.Runner
+	rioPoller raiderio.Poller
+	discord   discord.Discord
+
+	// mu serialises reloads and guards cfg, the config in effect.
+	mu  sync.Mutex
+	cfg appConfig
+}
+
+// config returns the config in effect.
+func (r *reloader) config() appConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// reload validates the config on disk and applies what it can. Nothing is
+// applied if the new config is invalid.
+func (r *reloader) reload() (reloadResult, error) {
+	next, err := loadConfig()
+	if err != nil {
+		return reloadResult{}, err
+	}
+	if err := validateBotConfig(next); err != nil {
+		return reloadResult{}, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return r.apply(next)
+}
+
+// apply applies next's reloadable settings to the running bot. Each part is
+// recorded in r.cfg as soon as it is live, so if a later part fails r.cfg
+// still describes what the bot runs with.
+func (r *reloader) apply(next appConfig) (reloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	applied, result := diffConfig(r.cfg, next)
+	if len(result.applied) == 0 {
+		return result, nil
+	}
+
+	// Setting an interval reschedules the job, so only changed ones are set.
+	// Defaults are applied as they were when the pollers were built.
+	if applied.RaiderIO.PollInterval != r.cfg.RaiderIO.PollInterval {
+		rioCfg := applied.RaiderIO
+		rioCfg.Defaults()
+		if err := r.jobs.SetInterval(raiderio.JobName, rioCfg.PollInterval); err != nil {
+			return reloadResult{}, err
+		}
+	}
+	r.rioPoller.Reload(applied.RaiderIO)
+	r.cfg.RaiderIO = applied.RaiderIO
+
+	if applied.ElvUI.PollInterval != r.cfg.ElvUI.PollInterval {
+		elvCfg := applied.ElvUI
+		elvCfg.Defaults()
+		if err := r.jobs.SetInterval(elvui.JobName, elvCfg.PollInterval); err != nil {
+			return reloadResult{}, fmt.Errorf("%w; raiderio settings were applied", err)
+		}
+	}
+	r.cfg.ElvUI = applied.ElvUI
+
+	if err := r.discord.Reload(applied.Discord); err != nil {
+		return reloadResult{}, fmt.Errorf("discord: %w; raiderio and elvui settings were applied", err)
+	}
+	r.cfg = applied
+	return result, nil
+}
+
+// summary reloads, logs the outcome and describes it for the !reload
+// command.
+func (r *reloader) summary(context.Context) (string, error) {
+	result, err := r.reload()
+	if err != nil {
+		r.logger.WarnW("config reload failed", "error", err)
+		return "", err
+	}
+	r.logger.InfoW("config reloaded",
+		"applied", result.applied,
+		"needs_restart", result.restart,
+	)
+	return result.String(), nil
+}
+
+// watch reloads on every SIGHUP until stop is closed.
+func (r *reloader) watch(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			_, _ = r.summary(context.Background())
+		}
+	}
+}
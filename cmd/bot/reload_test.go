@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/discord"
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/raiderio"
+	"github.com/tnicklin/celestial_orrey/secret"
+)
+
+func TestDiffConfig(t *testing.T) {
+	running := validTestConfig()
+	running.RaiderIO.PollInterval = time.Minute
+	running.Discord.ListenChannel = "keys"
+	running.Discord.Token = secret.String("old-token")
+	running.WarcraftLogs.ClientID = "id"
+	running.WarcraftLogs.ClientSecret = secret.String("old-wcl-secret")
+
+	next := running
+	next.RaiderIO.PollInterval = 2 * time.Minute
+	next.Discord.ListenChannel = "vault"
+	next.Discord.Admins = []string{"42"}
+	next.Discord.Token = secret.String("new-token")
+	next.WarcraftLogs.ClientSecret = secret.String("new-wcl-secret")
+	next.Store.Postgres.DSN = secret.String("postgres://user:hunter2@db")
+
+	applied, result := diffConfig(running, next)
+
+	wantApplied := []string{
+		"raiderio.poll_interval: 1m0s → 2m0s",
+		"discord.listen_channel: keys → vault",
+		"discord.admins: (unset) → [42]",
+	}
+	if !slices.Equal(result.applied, wantApplied) {
+		t.Errorf("applied = %q, want %q", result.applied, wantApplied)
+	}
+	if want := []string{"discord.token", "warcraftlogs", "store"}; !slices.Equal(result.restart, want) {
+		t.Errorf("restart = %q, want %q", result.restart, want)
+	}
+
+	if applied.RaiderIO.PollInterval != 2*time.Minute || applied.Discord.ListenChannel != "vault" {
+		t.Errorf("reloadable fields not applied: %+v", applied)
+	}
+	if applied.Discord.Token.Reveal() != "old-token" || applied.WarcraftLogs.ClientSecret.Reveal() != "old-wcl-secret" || applied.Store.Postgres.DSN.IsSet() {
+		t.Error("restart fields were applied")
+	}
+
+	summary := result.String()
+	for _, value := range []string{"old-token", "new-token", "old-wcl-secret", "new-wcl-secret", "hunter2"} {
+		if strings.Contains(summary, value) {
+			t.Errorf("summary reveals %q:\n%s", value, summary)
+		}
+	}
+
+	if _, result := diffConfig(running, running); result.String() != "Config reloaded, nothing changed." {
+		t.Errorf("unexpected summary for an unchanged config: %q", result.String())
+	}
+}
+
+type fakeDiscord struct {
+	discord.Discord
+	reloaded []discord.Config
+}
+
+func (f *fakeDiscord) Reload(cfg discord.Config) error {
+	f.reloaded = append(f.reloaded, cfg)
+	return nil
+}
+
+type fakePoller struct {
+	raiderio.Poller
+	reloaded []raiderio.Config
+}
+
+func (f *fakePoller) Reload(cfg raiderio.Config) {
+	f.reloaded = append(f.reloaded, cfg)
+}
+
+func TestReloaderRecordsPartialReload(t *testing.T) {
+	// Without an elvui job, setting its interval fails after the raiderio
+	// settings are live.
+	runner := jobs.NewRunner(jobs.Params{})
+	if err := runner.Add(jobs.Job{Name: raiderio.JobName, Interval: time.Minute, Run: func(context.Context) error { return nil }}); err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	disc := &fakeDiscord{}
+	poller := &fakePoller{}
+
+	running := validTestConfig()
+	running.RaiderIO.PollInterval = time.Minute
+	running.ElvUI.PollInterval = time.Hour
+	running.Discord.ListenChannel = "keys"
+	r := &reloader{logger: logger.NewNop(), jobs: runner, rioPoller: poller, discord: disc, cfg: running}
+
+	next := running
+	next.RaiderIO.PollInterval = 2 * time.Minute
+	next.ElvUI.PollInterval = 2 * time.Hour
+	next.Discord.ListenChannel = "vault"
+	if _, err := r.apply(next); err == nil {
+		t.Fatal("expected the elvui interval to fail")
+	}
+
+	got := r.config()
+	if got.RaiderIO.PollInterval != 2*time.Minute || len(poller.reloaded) != 1 {
+		t.Errorf("expected raiderio settings applied and recorded, got %s", got.RaiderIO.PollInterval)
+	}
+	if got.ElvUI.PollInterval != time.Hour || got.Discord.ListenChannel != "keys" || len(disc.reloaded) != 0 {
+		t.Errorf("expected elvui and discord settings left as they were, got %+v", got)
+	}
+	if status := runner.Status(); status[0].Interval != 2*time.Minute {
+		t.Errorf("raiderio job interval = %s", status[0].Interval)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/discord"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+// weekFlag selects a reset week. Given bare, or not at all, it is the
+// current week; given a date it is the week containing that date.
+type weekFlag struct {
+	day time.Time
+}
+
+func (w *weekFlag) String() string {
+	if w.day.IsZero() {
+		return ""
+	}
+	return w.day.Format(time.DateOnly)
+}
+
+func (w *weekFlag) Set(value string) error {
+	if value == "true" {
+		w.day = time.Time{}
+		return nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, timeutil.Location())
+	if err != nil {
+		return fmt.Errorf("want YYYY-MM-DD: %w", err)
+	}
+	w.day = day
+	return nil
+}
+
+func (w *weekFlag) IsBoolFlag() bool { return true }
+
+// start returns the weekly reset the selected week begins at.
+func (w *weekFlag) start(now time.Time) time.Time {
+	if w.day.IsZero() {
+		return timeutil.WeeklyResetAt(now)
+	}
+	return timeutil.WeeklyResetAt(w.day)
+}
+
+// runReport prints the Great Vault report !report would post for a week.
+// Usage: celestial-orrey report [--week[=YYYY-MM-DD]]
+func runReport(args []string) int {
+	var week weekFlag
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.Var(&week, "week", "report the current week, or the week containing YYYY-MM-DD")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		return usageError("report")
+	}
+
+	if err := report(context.Background(), week.start(time.Now())); err != nil {
+		fmt.Fprintf(os.Stderr, "report: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func report(ctx context.Context, since time.Time) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	if err := discord.UseVaultTable(cfg.Discord.VaultTable); err != nil {
+		return err
+	}
+
+	st, _, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	// Reading leaves nothing to flush, so the store is closed without
+	// touching the snapshot.
+	defer st.Close()
+
+	out, err := discord.VaultReport(ctx, st, since)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
@@ -0,0 +1,244 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/raiderio"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+const adminOnlyMessage = "That command is restricted to bot admins."
+
+const (
+	_cmdVerify = "verify"
+	_cmdReload = "reload"
+	_cmdPoll   = "poll"
+	_cmdNow    = "now"
+	_cmdSched  = "schedule"
+	// _maxScheduleLines caps !poll schedule to fit in one embed.
+	_maxScheduleLines = 40
+)
+
+// isAdmin reports whether user may run admin commands.
+func (c *DefaultDiscord) isAdmin(user *discordgo.User) bool {
+	if user == nil {
+		return false
+	}
+	_, ok := c.current().admins[user.ID]
+	return ok
+}
+
+// cmdReload reloads the config and reports what changed.
+// Usage: !reload
+func (c *DefaultDiscord) cmdReload(ctx context.Context) (cmdResponse, error) {
+	if c.reload == nil {
+		return cmdResponse{content: "Reloading is not enabled."}, nil
+	}
+
+	summary, err := c.reload(ctx)
+	if err != nil {
+		return cmdResponse{content: fmt.Sprintf("Config not reloaded: %v", err)}, nil
+	}
+	return cmdResponse{content: summary}, nil
+}
+
+// cmdPoll lists the background jobs with their last runs, asks one to run
+// now, or shows the RaiderIO poll schedule.
+// Usage: !poll [now <job>|schedule]
+func (c *DefaultDiscord) cmdPoll(args []string) (cmdResponse, error) {
+	if len(args) == 1 && strings.ToLower(args[0]) == _cmdSched {
+		return c.cmdPollSchedule(), nil
+	}
+	if c.jobs == nil {
+		return cmdResponse{content: "Background jobs are not running."}, nil
+	}
+
+	if len(args) > 0 {
+		if strings.ToLower(args[0]) != _cmdNow || len(args) != 2 {
+			return cmdResponse{content: "Usage: !poll [now <job>|schedule]"}, nil
+		}
+		name := strings.ToLower(args[1])
+		if err := c.jobs.RunNow(name); err != nil {
+			if errors.Is(err, jobs.ErrUnknownJob) {
+				return cmdResponse{content: fmt.Sprintf("Unknown job %q. Try: %s", name, strings.Join(jobNames(c.jobs.Status()), ", "))}, nil
+			}
+			return cmdResponse{}, err
+		}
+		return cmdResponse{content: fmt.Sprintf("Running **%s** now.", name)}, nil
+	}
+
+	statuses := c.jobs.Status()
+	if len(statuses) == 0 {
+		return cmdResponse{content: "No background jobs."}, nil
+	}
+
+	var sb strings.Builder
+	writeJobLines(&sb, statuses)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Pollers",
+		Description: truncate(sb.String(), _maxEmbedDescription),
+		Color:       embedColor,
+	}
+	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+// cmdPollSchedule lists when each character is polled next, soonest first.
+func (c *DefaultDiscord) cmdPollSchedule() cmdResponse {
+	if c.schedule == nil {
+		return cmdResponse{content: "No poll schedule available."}
+	}
+	schedule := c.schedule()
+	if len(schedule) == 0 {
+		return cmdResponse{content: "No characters polled yet."}
+	}
+
+	var sb strings.Builder
+	writeScheduleLines(&sb, schedule)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "RaiderIO poll schedule",
+		Description: truncate(sb.String(), _maxEmbedDescription),
+		Color:       embedColor,
+	}
+	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}
+}
+
+// writeScheduleLines writes one line per character, up to
+// _maxScheduleLines.
+func writeScheduleLines(sb *strings.Builder, schedule []raiderio.ScheduledPoll) {
+	for i, s := range schedule {
+		if i == _maxScheduleLines {
+			sb.WriteString(fmt.Sprintf("…and %d more\n", len(schedule)-i))
+			return
+		}
+		state := "💤"
+		if s.Active {
+			state = "🔥"
+		}
+		sb.WriteString(fmt.Sprintf("%s `%s` next %s, last key %s",
+			state, s.Character, formatJobTime(s.NextPoll), formatJobTime(s.LastActive)))
+		if s.Failures > 0 {
+			sb.WriteString(fmt.Sprintf(", %d failed", s.Failures))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+func jobNames(statuses []jobs.Status) []string {
+	names := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// writeJobLines writes a few lines per job: its schedule, its last success
+// and, while it is failing, the last error.
+func writeJobLines(sb *strings.Builder, statuses []jobs.Status) {
+	for _, s := range statuses {
+		state := "✅"
+		switch {
+		case s.Running:
+			state = "🔄"
+		case s.Failures > 0:
+			state = "❌"
+		case s.LastRun.IsZero():
+			state = "❔"
+		}
+
+		sb.WriteString(fmt.Sprintf("%s **%s** every %s, next %s\n",
+			state, s.Name, s.Interval, formatJobTime(s.NextRun)))
+		sb.WriteString(fmt.Sprintf("  last ok %s\n", formatJobTime(s.LastSuccess)))
+		if s.Failures > 0 {
+			sb.WriteString(fmt.Sprintf("  %d failed in a row, last at %s: %s\n",
+				s.Failures, formatJobTime(s.LastErrorAt), truncate(s.LastError, _maxErrorLen)))
+		}
+	}
+}
+
+func formatJobTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.In(_pstLocation).Format("Jan 2 15:04")
+}
+
+// cmdBackup lists backups with their last integrity check, or re-runs the
+// checks.
+// Usage: !backup [verify]
+func (c *DefaultDiscord) cmdBackup(ctx context.Context, args []string) (cmdResponse, error) {
+	if c.store == nil {
+		return cmdResponse{}, errors.New("database not configured")
+	}
+
+	var (
+		backups []store.BackupInfo
+		err     error
+	)
+	title := "Backups"
+	if len(args) > 0 && strings.ToLower(args[0]) == _cmdVerify {
+		title = "Backup verification"
+		// Failed checks are listed below; only a failure to list is fatal.
+		backups, err = c.store.VerifyBackups(ctx)
+		if len(backups) > 0 {
+			err = nil
+		}
+	} else {
+		backups, err = c.store.ListBackups(ctx)
+	}
+	if err != nil {
+		return cmdResponse{}, err
+	}
+
+	if len(backups) == 0 {
+		return cmdResponse{content: "No backups found."}, nil
+	}
+
+	var sb strings.Builder
+	writeBackupLines(&sb, backups)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: sb.String(),
+		Color:       embedColor,
+	}
+
+	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+// writeBackupLines writes one line per backup with its size and check result.
+func writeBackupLines(sb *strings.Builder, backups []store.BackupInfo) {
+	for _, b := range backups {
+		status := "❔ not checked"
+		switch {
+		case b.Healthy():
+			status = "✅ ok"
+		case b.Err != "":
+			status = "❌ " + b.Err
+		}
+
+		sb.WriteString(fmt.Sprintf("`%s`  %s  %s  %s\n",
+			filepath.Base(b.Path), b.CreatedAt.In(_pstLocation).Format("Jan 2 15:04"), formatBytes(b.Size), status))
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,110 @@
+package discord
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/raiderio"
+)
+
+func TestDiscordReload(t *testing.T) {
+	t.Cleanup(func() { _ = UseVaultTable("") })
+
+	c := &DefaultDiscord{}
+	admin := &discordgo.User{ID: "42"}
+	if c.isAdmin(admin) {
+		t.Fatal("no admins configured yet")
+	}
+
+	if err := c.Reload(Config{
+		ListenChannel: "chan-2",
+		Admins:        []string{"42"},
+		SeasonStart:   "2026-03-24",
+		VaultTable:    "season1",
+	}); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !c.isAdmin(admin) {
+		t.Error("expected reloaded admin")
+	}
+	if got := c.current().listenChannel; got != "chan-2" {
+		t.Errorf("listen channel = %q, want chan-2", got)
+	}
+	if got := VaultRewards().Season; got != VaultRewardsSeason1.Season {
+		t.Errorf("vault table = %q, want %q", got, VaultRewardsSeason1.Season)
+	}
+
+	if err := c.Reload(Config{SeasonStart: "March"}); err == nil {
+		t.Fatal("expected error for bad season_start")
+	}
+	if got := c.current().listenChannel; got != "chan-2" {
+		t.Errorf("failed reload changed listen channel to %q", got)
+	}
+}
+
+func TestDiscordPoll(t *testing.T) {
+	c := &DefaultDiscord{}
+	resp, err := c.cmdPoll(nil)
+	if err != nil || resp.content != "Background jobs are not running." {
+		t.Fatalf("unexpected response without jobs: %+v, %v", resp, err)
+	}
+
+	runner := jobs.NewRunner(jobs.Params{})
+	if err := runner.Add(jobs.Job{
+		Name:     "raiderio",
+		Interval: 5 * time.Minute,
+		Run:      func(context.Context) error { return nil },
+	}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	c.jobs = runner
+
+	resp, err = c.cmdPoll(nil)
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if len(resp.embeds) != 1 || !strings.Contains(resp.embeds[0].Description, "**raiderio** every 5m0s") {
+		t.Fatalf("unexpected status embed: %+v", resp)
+	}
+
+	resp, _ = c.cmdPoll([]string{"now", "RaiderIO"})
+	if resp.content != "Running **raiderio** now." {
+		t.Errorf("unexpected run now response: %q", resp.content)
+	}
+	resp, _ = c.cmdPoll([]string{"now", "nope"})
+	if !strings.Contains(resp.content, "Unknown job") {
+		t.Errorf("unexpected unknown job response: %q", resp.content)
+	}
+}
+
+func TestDiscordPollSchedule(t *testing.T) {
+	c := &DefaultDiscord{}
+	if resp, _ := c.cmdPoll([]string{"schedule"}); resp.content != "No poll schedule available." {
+		t.Fatalf("unexpected response without a schedule: %q", resp.content)
+	}
+
+	next := time.Date(2026, 2, 5, 20, 1, 0, 0, _pstLocation)
+	c.schedule = func() []raiderio.ScheduledPoll {
+		return []raiderio.ScheduledPoll{{Character: "arthas-illidan-us", Active: true, NextPoll: next}}
+	}
+	resp, err := c.cmdPoll([]string{"schedule"})
+	if err != nil {
+		t.Fatalf("poll schedule: %v", err)
+	}
+	want := "🔥 `arthas-illidan-us` next Feb 5 20:01, last key never\n"
+	if len(resp.embeds) != 1 || resp.embeds[0].Description != want {
+		t.Fatalf("unexpected schedule embed: %+v", resp)
+	}
+}
+
+func TestCharImportIsAdminOnly(t *testing.T) {
+	c := &DefaultDiscord{}
+	got, err := c.cmdChar(context.Background(), []string{"import"}, &discordgo.User{ID: "7"}, nil)
+	if err != nil || got != adminOnlyMessage {
+		t.Fatalf("expected admin-only reply, got %q, %v", got, err)
+	}
+}
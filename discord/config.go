@@ -1,8 +1,19 @@
 package discord
 
+import "github.com/tnicklin/celestial_orrey/secret"
+
 // Config holds Discord-specific configuration.
 type Config struct {
-	Token         string `yaml:"token"`
-	GuildID       string `yaml:"guild_id"`
-	ListenChannel string `yaml:"listen_channel"`
+	Token         secret.String `yaml:"token"`
+	GuildID       string        `yaml:"guild_id"`
+	ListenChannel string        `yaml:"listen_channel"`
+	// Admins lists the Discord user IDs allowed to run admin commands.
+	Admins []string `yaml:"admins"`
+	// SeasonStart is the date (YYYY-MM-DD, Pacific) the current M+ season
+	// began, used by !export season. When empty, season exports cover
+	// everything recorded.
+	SeasonStart string `yaml:"season_start"`
+	// VaultTable names the vault reward table in VaultTables; empty uses
+	// DefaultVaultTable.
+	VaultTable string `yaml:"vault_table"`
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -25,10 +26,12 @@ const commandPrefix = "!"
 const embedColor = 0x9B59B6
 
 // cmdResponse holds the response from a command handler.
-// Either content (plain text) or embeds (rich embed) should be set.
+// Either content (plain text) or embeds (rich embed) should be set. Files
+// are attached alongside the content.
 type cmdResponse struct {
 	content string
 	embeds  []*discordgo.MessageEmbed
+	files   []*discordgo.File
 }
 
 var _pstLocation = timeutil.Location()
@@ -36,10 +39,20 @@ var _pstLocation = timeutil.Location()
 var _ Discord = (*DefaultDiscord)(nil)
 
 type DefaultDiscord struct {
-	session       *discordgo.Session
-	guildID       string
-	listenChannel string
+	session *discordgo.Session
+	guildID string
+
+	// mu guards settings, which Reload replaces while handlers run.
+	mu       sync.RWMutex
+	settings settings
+
+	reload        ReloadFunc
+	jobs          Jobs
+	schedule      ScheduleFunc
+	caches        []*rioClient.CachingClient
 	store         store.Store
+	outbox        store.Outbox
+	dispatcher    *outboxDispatcher
 	raiderIO      rioClient.Client
 	warcraftLogs  warcraftlogs.WCL
 	logger        logger.Logger
@@ -52,16 +65,54 @@ type DefaultDiscord struct {
 type Params struct {
 	Config       Config
 	Store        store.Store
+	Outbox       store.Outbox
 	RaiderIO     rioClient.Client
 	WarcraftLogs warcraftlogs.WCL
 	Logger       logger.Logger
 	Clock        clock.Clock
+	// Reload, when set, backs the !reload admin command.
+	Reload ReloadFunc
+	// Jobs, when set, backs the !poll admin command.
+	Jobs Jobs
+	// Schedule, when set, backs !poll schedule.
+	Schedule ScheduleFunc
+	// Caches are the RaiderIO client's caches, reported by !status.
+	Caches []*rioClient.CachingClient
+}
+
+// settings are the parts of Config that can change while the bot runs.
+type settings struct {
+	listenChannel string
+	admins        map[string]struct{}
+	seasonStart   time.Time
+}
+
+func newSettings(cfg Config) (settings, error) {
+	var seasonStart time.Time
+	if cfg.SeasonStart != "" {
+		var err error
+		seasonStart, err = time.ParseInLocation(time.DateOnly, cfg.SeasonStart, _pstLocation)
+		if err != nil {
+			return settings{}, fmt.Errorf("parse season_start: %w", err)
+		}
+	}
+
+	admins := make(map[string]struct{}, len(cfg.Admins))
+	for _, id := range cfg.Admins {
+		admins[id] = struct{}{}
+	}
+
+	return settings{
+		listenChannel: cfg.ListenChannel,
+		admins:        admins,
+		seasonStart:   seasonStart,
+	}, nil
 }
 
 func New(p Params) (*DefaultDiscord, error) {
 	cfg := p.Config
 
-	session, err := discordgo.New("Bot " + cfg.Token)
+	session, err := discordgo.New("Bot " + cfg.Token.Reveal())
 	if err != nil {
 		return nil, fmt.Errorf("create discord session: %w", err)
 	}
@@ -71,30 +122,84 @@ func New(p Params) (*DefaultDiscord, error) {
 		clk = clock.System()
 	}
 
+	current, err := newSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := UseVaultTable(cfg.VaultTable); err != nil {
+		return nil, err
+	}
+
 	return &DefaultDiscord{
-		session:       session,
-		guildID:       cfg.GuildID,
-		listenChannel: cfg.ListenChannel,
-		store:         p.Store,
-		raiderIO:      p.RaiderIO,
-		warcraftLogs:  p.WarcraftLogs,
-		logger:        p.Logger,
-		clock:         clk,
+		session:      session,
+		guildID:      cfg.GuildID,
+		settings:     current,
+		reload:       p.Reload,
+		jobs:         p.Jobs,
+		schedule:     p.Schedule,
+		caches:       p.Caches,
+		store:        p.Store,
+		outbox:       p.Outbox,
+		raiderIO:     p.RaiderIO,
+		warcraftLogs: p.WarcraftLogs,
+		logger:       p.Logger,
+		clock:        clk,
 	}, nil
 }
 
+// Reload applies the listen channel, admins, season start and vault table
+// from cfg. The token and guild only take effect on restart.
+func (c *DefaultDiscord) Reload(cfg Config) error {
+	next, err := newSettings(cfg)
+	if err != nil {
+		return err
+	}
+	if err := UseVaultTable(cfg.VaultTable); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.settings = next
+	c.mu.Unlock()
+	return nil
+}
+
+// current returns the settings in effect.
+func (c *DefaultDiscord) current() settings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings
+}
+
 func (c *DefaultDiscord) Start(ctx context.Context) error {
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("open discord connection: %w", err)
 	}
 
+	c.startWorkers(c.session)
+	return nil
+}
+
+// startWorkers starts the outbox dispatcher, sending through sender, and
+// then the message handler and the scheduler. The dispatcher comes first
+// because both may post through it as soon as they run.
+func (c *DefaultDiscord) startWorkers(sender messageSender) {
+	if c.outbox != nil {
+		c.dispatcher = &outboxDispatcher{
+			outbox: c.outbox,
+			sender: sender,
+			selfID: func() string { return c.session.State.User.ID },
+			logger: c.logger,
+			clock:  c.clock,
+		}
+		c.dispatcher.start()
+	}
+
 	c.removeHandler = c.session.AddHandler(c.handleMessage)
 	c.stopScheduler = make(chan struct{})
 	c.schedulerDone = make(chan struct{})
 
 	go c.runScheduler()
-
-	return nil
 }
 
 func (c *DefaultDiscord) Stop() {
@@ -106,13 +211,16 @@ func (c *DefaultDiscord) Stop() {
 		close(c.stopScheduler)
 		<-c.schedulerDone
 	}
+	if c.dispatcher != nil {
+		c.dispatcher.close()
+	}
 	c.session.Close()
 }
 
 func (c *DefaultDiscord) runScheduler() {
 	defer close(c.schedulerDone)
 
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := clock.NewTicker(c.clock, 1*time.Minute)
 	defer ticker.Stop()
 
 	var lastPost time.Time
@@ -120,31 +228,40 @@ func (c *DefaultDiscord) runScheduler() {
 		select {
 		case <-c.stopScheduler:
 			return
-		case <-ticker.C:
-			now := c.clock.Now()
-			pstNow := now.In(_pstLocation)
-
-			if pstNow.Hour() == 7 && pstNow.Minute() == 0 {
-				today := time.Date(pstNow.Year(), pstNow.Month(), pstNow.Day(), 7, 0, 0, 0, _pstLocation)
-				if !today.Equal(lastPost) {
-					lastPost = today
-					c.postDailyAnnouncement(pstNow)
-				}
-			}
+		case <-ticker.C():
+			lastPost = c.scheduleTick(c.clock.Now(), lastPost)
 		}
 	}
 }
 
+// scheduleTick posts the daily announcement if now is 07:00 Pacific and it
+// has not been posted today. It returns when the announcement was last
+// posted.
+func (c *DefaultDiscord) scheduleTick(now, lastPost time.Time) time.Time {
+	pstNow := now.In(_pstLocation)
+	if pstNow.Hour() != 7 || pstNow.Minute() != 0 {
+		return lastPost
+	}
+
+	today := time.Date(pstNow.Year(), pstNow.Month(), pstNow.Day(), 7, 0, 0, 0, _pstLocation)
+	if today.Equal(lastPost) {
+		return lastPost
+	}
+	c.postDailyAnnouncement(pstNow)
+	return today
+}
+
 func (c *DefaultDiscord) postDailyAnnouncement(now time.Time) {
 	ctx := context.Background()
+	day := now.Format(time.DateOnly)
 
 	if now.Weekday() == time.Tuesday {
 		if err := c.store.ArchiveWeek(ctx); err != nil {
 			c.logger.ErrorW("archive week", "error", err)
 		}
 
-		msg := "**Dawn of the 1st Day**"
-		if err := c.WriteMessage(c.listenChannel, msg); err != nil {
+		msg := cmdResponse{content: "**Dawn of the 1st Day**"}
+		if err := c.post(ctx, "reset:"+day, c.current().listenChannel, msg); err != nil {
 			c.logger.ErrorW("post reset message", "error", err)
 		}
 		return
@@ -157,24 +274,23 @@ func (c *DefaultDiscord) postDailyAnnouncement(now time.Time) {
 		return
 	}
 
-	if len(resp.embeds) > 0 {
-		if _, err := c.session.ChannelMessageSendComplex(c.listenChannel, &discordgo.MessageSend{
-			Embeds: resp.embeds,
-		}); err != nil {
-			c.logger.ErrorW("post daily report", "error", err)
-		}
-	} else if resp.content != "" {
-		if err := c.WriteMessage(c.listenChannel, resp.content); err != nil {
-			c.logger.ErrorW("post daily report", "error", err)
-		}
+	if len(resp.embeds) == 0 && resp.content == "" {
+		return
+	}
+	if err := c.post(ctx, "report:"+day, c.current().listenChannel, resp); err != nil {
+		c.logger.ErrorW("post daily report", "error", err)
 	}
 }
 
 const (
 	_cmdKeys   = "keys"
+	_cmdKey    = "key"
 	_cmdReport = "report"
 	_cmdChar   = "char"
 	_cmdElv    = "elv"
+	_cmdHist   = "history"
+	_cmdBackup = "backup"
+	_cmdExport = "export"
 	_cmdHelp   = "help"
 )
 
@@ -188,7 +304,7 @@ func (c *DefaultDiscord) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		return
 	}
 
-	if c.listenChannel != "" && m.ChannelID != c.listenChannel {
+	if channel := c.current().listenChannel; channel != "" && m.ChannelID != channel {
 		return
 	}
 
@@ -223,12 +339,50 @@ func (c *DefaultDiscord) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		resp, err = c.cmdKeys(ctx, args)
 	case _cmdReport:
 		resp, err = c.cmdReport(ctx, args)
+	case _cmdKey:
+		var s string
+		s, err = c.cmdKey(ctx, args)
+		resp = cmdResponse{content: s}
 	case _cmdChar:
 		var s string
-		s, err = c.cmdChar(ctx, args)
+		s, err = c.cmdChar(ctx, args, m.Author, m.Attachments)
 		resp = cmdResponse{content: s}
 	case _cmdElv:
 		resp, err = c.cmdElv(ctx)
+	case _cmdHist:
+		resp, err = c.cmdHistory(ctx, args)
+	case _cmdExport:
+		resp, err = c.cmdExport(ctx, args)
+	case _cmdBackup:
+		if !c.isAdmin(m.Author) {
+			resp = cmdResponse{content: adminOnlyMessage}
+			break
+		}
+		resp, err = c.cmdBackup(ctx, args)
+	case _cmdOutbox:
+		if !c.isAdmin(m.Author) {
+			resp = cmdResponse{content: adminOnlyMessage}
+			break
+		}
+		resp, err = c.cmdOutbox(ctx, args)
+	case _cmdReload:
+		if !c.isAdmin(m.Author) {
+			resp = cmdResponse{content: adminOnlyMessage}
+			break
+		}
+		resp, err = c.cmdReload(ctx)
+	case _cmdPoll:
+		if !c.isAdmin(m.Author) {
+			resp = cmdResponse{content: adminOnlyMessage}
+			break
+		}
+		resp, err = c.cmdPoll(args)
+	case _cmdStatus:
+		if !c.isAdmin(m.Author) {
+			resp = cmdResponse{content: adminOnlyMessage}
+			break
+		}
+		resp, err = c.cmdStatus(ctx)
 	case _cmdHelp:
 		resp = cmdResponse{content: c.cmdHelp()}
 	default:
@@ -239,7 +393,14 @@ func (c *DefaultDiscord) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		resp = cmdResponse{content: fmt.Sprintf("Error: %v", err)}
 	}
 
-	if len(resp.embeds) > 0 {
+	if len(resp.files) > 0 {
+		if _, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+			Content: resp.content,
+			Files:   resp.files,
+		}); err != nil {
+			c.logger.ErrorW("failed to send response", "error", err)
+		}
+	} else if len(resp.embeds) > 0 {
 		if _, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
 			Embeds: resp.embeds,
 		}); err != nil {
@@ -278,23 +439,7 @@ func (c *DefaultDiscord) formatCharacterKeys(ctx context.Context, query string,
 		return cmdResponse{}, err
 	}
 
-	var matchingChars []models.Character
-	queryLower := strings.ToLower(query)
-
-	for _, char := range allChars {
-		charKey := strings.ToLower(char.Name + "-" + char.Realm)
-		if charKey == queryLower {
-			matchingChars = append(matchingChars, char)
-		}
-	}
-
-	if len(matchingChars) == 0 {
-		for _, char := range allChars {
-			if strings.ToLower(char.Name) == queryLower {
-				matchingChars = append(matchingChars, char)
-			}
-		}
-	}
+	matchingChars := matchCharacters(allChars, query)
 
 	if len(matchingChars) == 0 {
 		return cmdResponse{content: fmt.Sprintf("No character found matching **%s**.", query)}, nil
@@ -309,18 +454,11 @@ func (c *DefaultDiscord) formatCharacterKeys(ctx context.Context, query string,
 	}
 
 	char := matchingChars[0]
-	keys, err := c.store.ListKeysByCharacterSince(ctx, char.Name, since)
+	charKeys, err := c.store.ListKeysByCharacterSince(ctx, char.Name, char.Realm, char.Region, since)
 	if err != nil {
 		return cmdResponse{}, err
 	}
 
-	var charKeys []models.CompletedKey
-	for _, key := range keys {
-		if strings.EqualFold(key.Realm, char.Realm) && strings.EqualFold(key.Region, char.Region) {
-			charKeys = append(charKeys, key)
-		}
-	}
-
 	if len(charKeys) == 0 {
 		return cmdResponse{content: fmt.Sprintf("No keys found for **%s** (%s) this week.", char.Name, char.Realm)}, nil
 	}
@@ -364,18 +502,11 @@ func (c *DefaultDiscord) formatAllCharacterKeys(ctx context.Context, since time.
 	}
 
 	for _, char := range allChars {
-		keys, err := c.store.ListKeysByCharacterSince(ctx, char.Name, since)
+		charKeys, err := c.store.ListKeysByCharacterSince(ctx, char.Name, char.Realm, char.Region, since)
 		if err != nil {
 			continue
 		}
 
-		var charKeys []models.CompletedKey
-		for _, key := range keys {
-			if strings.EqualFold(key.Realm, char.Realm) && strings.EqualFold(key.Region, char.Region) {
-				charKeys = append(charKeys, key)
-			}
-		}
-
 		if len(charKeys) == 0 {
 			continue
 		}
@@ -401,6 +532,30 @@ func (c *DefaultDiscord) formatAllCharacterKeys(ctx context.Context, since time.
 	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}, nil
 }
 
+// matchCharacters returns the characters matching query, given either as
+// <name>-<realm> or as a bare name, which may match on several realms.
+func matchCharacters(chars []models.Character, query string) []models.Character {
+	var matching []models.Character
+	queryLower := strings.ToLower(query)
+
+	for _, char := range chars {
+		charKey := strings.ToLower(char.Name + "-" + char.Realm)
+		if charKey == queryLower {
+			matching = append(matching, char)
+		}
+	}
+
+	if len(matching) == 0 {
+		for _, char := range chars {
+			if strings.ToLower(char.Name) == queryLower {
+				matching = append(matching, char)
+			}
+		}
+	}
+
+	return matching
+}
+
 // writeKeyLines writes individual key lines to a string builder.
 func (c *DefaultDiscord) writeKeyLines(ctx context.Context, sb *strings.Builder, keys []models.CompletedKey) {
 	for _, key := range keys {
@@ -414,7 +569,12 @@ func (c *DefaultDiscord) writeKeyLines(ctx context.Context, sb *strings.Builder,
 			wclLink = fmt.Sprintf(" [log](<%s>)", links[0].URL)
 		}
 
-		sb.WriteString(fmt.Sprintf("%s  +%d %s  %s%s\n", completedAt, key.KeyLevel, dungeonShort, timing, wclLink))
+		manual := ""
+		if key.Source == models.SourceManual {
+			manual = manualMarker
+		}
+
+		sb.WriteString(fmt.Sprintf("%s  +%d %s  %s%s%s\n", completedAt, key.KeyLevel, dungeonShort, timing, wclLink, manual))
 	}
 }
 
@@ -452,9 +612,14 @@ func (c *DefaultDiscord) formatCharacterReport(ctx context.Context, name string,
 		return matchingChars[i].Realm < matchingChars[j].Realm
 	})
 
+	block, err := reportBlock(ctx, c.store, matchingChars, since)
+	if err != nil {
+		return cmdResponse{}, fmt.Errorf("list vault progress: %w", err)
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "Great Vault Progress",
-		Description: fmt.Sprintf("Week of %s\n%s", since.Format("Jan 2"), c.buildReportBlock(ctx, matchingChars, since)),
+		Description: fmt.Sprintf("Week of %s\n%s", since.Format("Jan 2"), block),
 		Color:       embedColor,
 	}
 
@@ -475,9 +640,14 @@ func (c *DefaultDiscord) formatAllCharactersReport(ctx context.Context, since ti
 		return cmdResponse{content: "No characters in database."}, nil
 	}
 
+	block, err := reportBlock(ctx, c.store, allChars, since)
+	if err != nil {
+		return cmdResponse{}, fmt.Errorf("list vault progress: %w", err)
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "Great Vault Progress",
-		Description: fmt.Sprintf("Week of %s\n%s", since.Format("Jan 2"), c.buildReportBlock(ctx, allChars, since)),
+		Description: fmt.Sprintf("Week of %s\n%s", since.Format("Jan 2"), block),
 		Color:       embedColor,
 	}
 
@@ -491,26 +661,42 @@ type reportEntry struct {
 	vault    string // "M4/M3/--"
 }
 
-// buildReportBlock collects character data and formats it as an aligned code block table.
-func (c *DefaultDiscord) buildReportBlock(ctx context.Context, chars []models.Character, since time.Time) string {
+// VaultReport renders the Great Vault table !report posts, for every tracked
+// character since the weekly reset at since.
+func VaultReport(ctx context.Context, st store.Store, since time.Time) (string, error) {
+	chars, err := st.ListCharacters(ctx)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(chars, func(i, j int) bool {
+		return chars[i].Name < chars[j].Name
+	})
+
+	block, err := reportBlock(ctx, st, chars, since)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Week of %s\n%s", since.Format("Jan 2"), block), nil
+}
+
+// reportBlock formats chars' vault progress as an aligned code block table.
+// It fails rather than render a table of empty progress when the progress
+// cannot be read.
+func reportBlock(ctx context.Context, st store.Store, chars []models.Character, since time.Time) (string, error) {
 	var entries []reportEntry
 	maxNameLen := 0
 
-	for _, char := range chars {
-		keys, err := c.store.ListKeysByCharacterSince(ctx, char.Name, since)
-		if err != nil {
-			c.logger.ErrorW("list keys for character", "character", char.Name, "error", err)
-			continue
-		}
-
-		var charKeys []models.CompletedKey
-		for _, key := range keys {
-			if strings.EqualFold(key.Realm, char.Realm) && strings.EqualFold(key.Region, char.Region) {
-				charKeys = append(charKeys, key)
-			}
-		}
+	rows, err := st.ListVaultProgressSince(ctx, since)
+	if err != nil {
+		return "", err
+	}
+	progress := make(map[string]store.VaultRow, len(rows))
+	for _, row := range rows {
+		progress[row.Character.Key()] = row
+	}
 
-		sortKeysByLevel(charKeys)
+	for _, char := range chars {
+		row := progress[char.Key()]
 
 		if len(char.Name) > maxNameLen {
 			maxNameLen = len(char.Name)
@@ -521,14 +707,14 @@ func (c *DefaultDiscord) buildReportBlock(ctx context.Context, chars []models.Ch
 			score = fmt.Sprintf("%.1f", char.RIOScore)
 		}
 
-		v1 := vaultShortCode(charKeys, 0)
-		v2 := vaultShortCode(charKeys, 3)
-		v3 := vaultShortCode(charKeys, 7)
+		v1 := vaultShortCode(row.TopLevels, 0)
+		v2 := vaultShortCode(row.TopLevels, 3)
+		v3 := vaultShortCode(row.TopLevels, 7)
 
 		entries = append(entries, reportEntry{
 			name:     char.Name,
 			score:    score,
-			keyCount: len(charKeys),
+			keyCount: int(row.KeyCount),
 			vault:    fmt.Sprintf("%s/%s/%s", v1, v2, v3),
 		})
 	}
@@ -550,22 +736,16 @@ func (c *DefaultDiscord) buildReportBlock(ctx context.Context, chars []models.Ch
 			e.name, e.score, fmt.Sprintf("%d", e.keyCount), e.vault))
 	}
 	sb.WriteString("```")
-	return sb.String()
+	return sb.String(), nil
 }
 
 // vaultShortCode returns the item level for a vault slot, or "--" if empty.
-func vaultShortCode(keys []models.CompletedKey, index int) string {
-	if index >= len(keys) {
+// levels must be sorted highest first.
+func vaultShortCode(levels []int, index int) string {
+	if index >= len(levels) {
 		return "---"
 	}
-	return fmt.Sprintf("%d", VaultRewards.GetItemLevel(keys[index].KeyLevel))
-}
-
-// sortKeysByLevel sorts keys by KeyLevel descending (highest first)
-func sortKeysByLevel(keys []models.CompletedKey) {
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i].KeyLevel > keys[j].KeyLevel
-	})
+	return fmt.Sprintf("%d", VaultRewards().GetItemLevel(levels[index]))
 }
 
 func (c *DefaultDiscord) cmdElv(ctx context.Context) (cmdResponse, error) {
@@ -595,22 +775,36 @@ func (c *DefaultDiscord) cmdHelp() string {
 !keys all                  - Show all keys completed this week
 !report                    - Show Great Vault progress for all characters
 !report <name>             - Show Great Vault progress for a character
+!history <name> [weeks]    - Show past weeks for a character
+!export [week|season] [csv|json]
+                           - Download keys, links and scores as files
+!key add <name> <realm> <dungeon> <level> [time]
+                           - Record a key the APIs missed (✍️)
+!key undo <name> <realm>   - Remove the latest manual key
 !char sync <name> <realm>  - Sync character from RaiderIO
 !char purge <name> <realm> - Remove character from database
+!char import               - Track characters and keys from an attached CSV/JSON file (admin)
 !elv                       - Show current ElvUI version
+!backup [verify]           - Show or re-check backups (admin)
+!outbox [retry <id>]       - Show or retry failed posts (admin)
+!reload                    - Reload config from disk (admin)
+!poll [now <job>]          - Show pollers or run one now (admin)
+!poll schedule             - Show when each character is polled next (admin)
+!status                    - Show poller, clock and store health (admin)
 !help                      - Show this help message
 ` + "```"
 }
 
 const (
-	_cmdSync  = "sync"
-	_cmdPurge = "purge"
+	_cmdSync   = "sync"
+	_cmdPurge  = "purge"
+	_cmdImport = "import"
 )
 
 // cmdChar handles character management commands.
-func (c *DefaultDiscord) cmdChar(ctx context.Context, args []string) (string, error) {
+func (c *DefaultDiscord) cmdChar(ctx context.Context, args []string, author *discordgo.User, attachments []*discordgo.MessageAttachment) (string, error) {
 	if len(args) < 1 {
-		return "Usage: `!char sync <name> <realm>` or `!char purge <name> <realm>`", nil
+		return "Usage: `!char sync <name> <realm>`, `!char purge <name> <realm>` or `!char import` with a file attached", nil
 	}
 
 	subCmd := strings.ToLower(args[0])
@@ -621,8 +815,13 @@ func (c *DefaultDiscord) cmdChar(ctx context.Context, args []string) (string, er
 		return c.cmdCharSync(ctx, subArgs)
 	case _cmdPurge:
 		return c.cmdCharPurge(ctx, subArgs)
+	case _cmdImport:
+		if !c.isAdmin(author) {
+			return adminOnlyMessage, nil
+		}
+		return c.cmdCharImport(ctx, attachments)
 	default:
-		return "Unknown subcommand. Use `sync` or `purge`.", nil
+		return "Unknown subcommand. Use `sync`, `purge` or `import`.", nil
 	}
 }
 
@@ -645,7 +844,8 @@ func (c *DefaultDiscord) cmdCharSync(ctx context.Context, args []string) (string
 		Region: "us",
 	}
 
-	result, err := c.raiderIO.FetchWeeklyRuns(ctx, char)
+	// A sync is asked for to see new keys, so a stale profile won't do.
+	result, err := c.raiderIO.FetchWeeklyRuns(rioClient.WithoutStale(ctx), char)
 	if err != nil {
 		return "", fmt.Errorf("fetch from RaiderIO: %w", err)
 	}
@@ -653,23 +853,40 @@ func (c *DefaultDiscord) cmdCharSync(ctx context.Context, args []string) (string
 	// Update character's RIO score
 	_ = c.store.UpdateCharacterScore(ctx, char.Name, char.Realm, char.Region, result.RIOScore)
 
-	insertedCount := 0
-	for _, key := range result.Keys {
-		if err := c.store.UpsertCompletedKey(ctx, key); err == nil {
-			insertedCount++
-		}
-	}
-
-	linkedCount := 0
+	var linker *warcraftlogs.Linker
 	if c.warcraftLogs != nil {
-		linker := warcraftlogs.NewLinker(warcraftlogs.LinkerParams{
+		linker = warcraftlogs.NewLinker(warcraftlogs.LinkerParams{
 			Store:  c.store,
 			Client: c.warcraftLogs,
+			Logger: c.logger,
 		})
 		linker.MatchWindow = 24 * time.Hour
+	}
 
+	var statuses []store.UpsertStatus
+	if linker != nil {
+		statuses, err = linker.ReconcileKeys(ctx, result.Keys)
+	} else {
+		statuses, err = c.store.ReconcileCompletedKeys(ctx, result.Keys)
+	}
+	if err != nil {
+		return "", fmt.Errorf("store keys: %w", err)
+	}
+
+	insertedCount, updatedCount := 0, 0
+	for _, status := range statuses {
+		switch status {
+		case store.UpsertInserted:
+			insertedCount++
+		case store.UpsertUpdated, store.UpsertReplaced:
+			updatedCount++
+		}
+	}
+
+	linkedCount := 0
+	if linker != nil {
 		for _, key := range result.Keys {
-			existingLinks, _ := c.store.ListWarcraftLogsLinksForKey(ctx, key.KeyID)
+			existingLinks, _ := c.store.ListWarcraftLogsLinksForKey(ctx, key.EffectiveID())
 			if len(existingLinks) > 0 {
 				continue
 			}
@@ -683,7 +900,7 @@ func (c *DefaultDiscord) cmdCharSync(ctx context.Context, args []string) (string
 			fightID := int64(match.Run.FightID)
 
 			link := store.WarcraftLogsLink{
-				KeyID:      key.KeyID,
+				KeyID:      key.EffectiveID(),
 				ReportCode: match.Run.ReportCode,
 				FightID:    &fightID,
 				URL:        url,
@@ -699,8 +916,8 @@ func (c *DefaultDiscord) cmdCharSync(ctx context.Context, args []string) (string
 		scoreStr = fmt.Sprintf(" | RIO Score: **%.1f**", result.RIOScore)
 	}
 
-	return fmt.Sprintf("Synced **%s** (%s-%s): %d keys fetched, %d inserted, %d WCL links created.%s",
-		char.Name, char.Realm, char.Region, len(result.Keys), insertedCount, linkedCount, scoreStr), nil
+	return fmt.Sprintf("Synced **%s** (%s-%s): %d keys fetched, %d inserted, %d updated, %d WCL links created.%s",
+		char.Name, char.Realm, char.Region, len(result.Keys), insertedCount, updatedCount, linkedCount, scoreStr), nil
 }
 
 // cmdCharPurge removes a character and all their data from the database
@@ -755,44 +972,6 @@ func formatTimingDiff(runTimeMS, parTimeMS int64) string {
 	return fmt.Sprintf("(%s%d:%02d)", sign, mins, secs)
 }
 
-/*
-func shortenDungeonName(dungeon string) string {
-	replacements := map[string]string{
-		"Magisters’ Terrace":      "Magisters",
-		"Maisara Caverns":         "Maisara",
-		"Nexus Point Xenas":       "Nexus Point",
-		"Windrunner Spire":        "Windrunner",
-		"Algeth’ar Academy":       "Algeth’ar",
-		"Seat of the Triumvirate": "Triumvirate",
-		"Skyreach":                "Skyreach",
-		"Pit of Saron":            "Saron",
-	}
-
-	if short, ok := replacements[dungeon]; ok {
-		return short
-	}
-	return dungeon
-}
-*/
-
-func shortenDungeonName(dungeon string) string {
-	replacements := map[string]string{
-		"Operation: Floodgate":        "Floodgate",
-		"Ara-Kara, City of Echoes":    "Ara-Kara",
-		"The Dawnbreaker":             "Dawnbreaker",
-		"Priory of the Sacred Flame":  "Priory",
-		"Eco-Dome Al'dani":            "Eco-Dome",
-		"Tazavesh: Streets of Wonder": "Streets",
-		"Tazavesh: So'leah's Gambit":  "Gambit",
-		"Halls of Atonement":          "Halls",
-	}
-
-	if short, ok := replacements[dungeon]; ok {
-		return short
-	}
-	return dungeon
-}
-
 func (c *DefaultDiscord) WriteMessage(channelID, msg string) error {
 	if c.session == nil {
 		return errors.New("discord session is nil")
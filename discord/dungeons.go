@@ -0,0 +1,58 @@
+package discord
+
+import (
+	"strings"
+	"unicode"
+)
+
+// seasonDungeon is a dungeon in the active M+ rotation.
+type seasonDungeon struct {
+	Name  string
+	Short string
+}
+
+// seasonDungeons is the active season's dungeon rotation.
+var seasonDungeons = []seasonDungeon{
+	{"Operation: Floodgate", "Floodgate"},
+	{"Ara-Kara, City of Echoes", "Ara-Kara"},
+	{"The Dawnbreaker", "Dawnbreaker"},
+	{"Priory of the Sacred Flame", "Priory"},
+	{"Eco-Dome Al'dani", "Eco-Dome"},
+	{"Tazavesh: Streets of Wonder", "Streets"},
+	{"Tazavesh: So'leah's Gambit", "Gambit"},
+	{"Halls of Atonement", "Halls"},
+}
+
+func shortenDungeonName(dungeon string) string {
+	for _, d := range seasonDungeons {
+		if d.Name == dungeon {
+			return d.Short
+		}
+	}
+	return dungeon
+}
+
+// lookupDungeon resolves a full or short dungeon name from the season list,
+// ignoring case and punctuation. It returns the full dungeon name.
+func lookupDungeon(query string) (string, bool) {
+	q := foldDungeonName(query)
+	if q == "" {
+		return "", false
+	}
+	for _, d := range seasonDungeons {
+		if foldDungeonName(d.Name) == q || foldDungeonName(d.Short) == q {
+			return d.Name, true
+		}
+	}
+	return "", false
+}
+
+func foldDungeonName(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/export"
+)
+
+const (
+	_exportWeek   = "week"
+	_exportSeason = "season"
+)
+
+// cmdExport replies with the week's or season's data as file attachments.
+// Usage: !export [week|season] [csv|json]
+func (c *DefaultDiscord) cmdExport(ctx context.Context, args []string) (cmdResponse, error) {
+	if c.store == nil {
+		return cmdResponse{}, errors.New("database not configured")
+	}
+
+	now := c.clock.Now()
+	span, format := _exportWeek, export.FormatCSV
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case _exportWeek, _exportSeason:
+			span = strings.ToLower(arg)
+		default:
+			f, err := export.ParseFormat(arg)
+			if err != nil {
+				return cmdResponse{content: "Usage: `!export [week|season] [csv|json]`"}, nil
+			}
+			format = f
+		}
+	}
+
+	r := export.Week(now)
+	if span == _exportSeason {
+		r = export.Season(now, c.current().seasonStart)
+	}
+
+	data, err := export.Collect(ctx, c.store, r)
+	if err != nil {
+		return cmdResponse{}, err
+	}
+	files, err := data.Files(format)
+	if err != nil {
+		return cmdResponse{}, err
+	}
+
+	resp := cmdResponse{
+		content: fmt.Sprintf("Export for the %s: %d characters, %d keys, %d links, %d score weeks.",
+			span, len(data.Characters), len(data.Keys), len(data.Links), len(data.Scores)),
+	}
+	for _, f := range files {
+		contentType := "text/csv"
+		if format == export.FormatJSON {
+			contentType = "application/json"
+		}
+		resp.files = append(resp.files, &discordgo.File{
+			Name:        f.Name,
+			ContentType: contentType,
+			Reader:      bytes.NewReader(f.Body),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+const (
+	_defaultHistoryWeeks = 4
+	_maxHistoryWeeks     = 12
+)
+
+// cmdHistory handles the !history command for past weeks.
+// Usage: !history <name> [weeks]
+func (c *DefaultDiscord) cmdHistory(ctx context.Context, args []string) (cmdResponse, error) {
+	if c.store == nil {
+		return cmdResponse{}, errors.New("database not configured")
+	}
+
+	if len(args) == 0 {
+		return cmdResponse{content: "Usage: `!history <name> [weeks]`\nExample: `!history askrm 8`"}, nil
+	}
+
+	weeks := _defaultHistoryWeeks
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return cmdResponse{content: "Weeks must be a positive number."}, nil
+		}
+		weeks = min(n, _maxHistoryWeeks)
+	}
+
+	allChars, err := c.store.ListCharacters(ctx)
+	if err != nil {
+		return cmdResponse{}, err
+	}
+
+	matchingChars := matchCharacters(allChars, args[0])
+	if len(matchingChars) == 0 {
+		return cmdResponse{content: fmt.Sprintf("No character found matching **%s**.", args[0])}, nil
+	}
+	if len(matchingChars) > 1 {
+		var realms []string
+		for _, char := range matchingChars {
+			realms = append(realms, char.Realm)
+		}
+		return cmdResponse{content: fmt.Sprintf("Ambiguous character name **%s** found on multiple realms: %s\nPlease use `!history <name>-<realm>` to specify.", args[0], strings.Join(realms, ", "))}, nil
+	}
+
+	char := matchingChars[0]
+	history, err := c.store.ListWeeklyHistory(ctx, char.Name, char.Realm, char.Region, weeks)
+	if err != nil {
+		return cmdResponse{}, err
+	}
+	if len(history) == 0 {
+		return cmdResponse{content: fmt.Sprintf("No history recorded for **%s** (%s) yet.", char.Name, char.Realm)}, nil
+	}
+
+	var sb strings.Builder
+	writeHistoryLines(&sb, history)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s (%s) — last %d weeks", char.Name, char.Realm, len(history)),
+		Description: sb.String(),
+		Color:       embedColor,
+	}
+
+	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+// writeHistoryLines writes one line per week: key count, best key and the
+// item levels of the vault slots earned.
+func writeHistoryLines(sb *strings.Builder, history []store.WeekSummary) {
+	for _, week := range history {
+		keyWord := "keys"
+		if week.KeyCount == 1 {
+			keyWord = "key"
+		}
+
+		var vault []string
+		for _, level := range week.VaultLevels {
+			if level == 0 {
+				vault = append(vault, "---")
+				continue
+			}
+			vault = append(vault, fmt.Sprintf("%d", VaultRewards().GetItemLevel(level)))
+		}
+
+		score := ""
+		if week.Character.RIOScore > 0 {
+			score = fmt.Sprintf("  (%.1f)", week.Character.RIOScore)
+		}
+
+		sb.WriteString(fmt.Sprintf("**%s**  %d %s  best +%d %s  vault %s%s\n",
+			week.WeekStart.In(_pstLocation).Format("Jan 2"), week.KeyCount, keyWord,
+			week.BestKeyLevel, shortenDungeonName(week.BestDungeon), strings.Join(vault, "/"), score))
+	}
+}
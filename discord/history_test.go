@@ -0,0 +1,51 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+func TestWriteHistoryLines(t *testing.T) {
+	history := []store.WeekSummary{
+		{
+			WeekStart:    time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC),
+			Character:    models.Character{Name: "arthas", Realm: "illidan", Region: "us", RIOScore: 3000},
+			KeyCount:     5,
+			BestKeyLevel: 14,
+			BestDungeon:  "Ara-Kara, City of Echoes",
+			VaultLevels:  [3]int{14, 10, 0},
+		},
+		{
+			WeekStart:    time.Date(2026, 1, 27, 15, 0, 0, 0, time.UTC),
+			Character:    models.Character{Name: "arthas", Realm: "illidan", Region: "us"},
+			KeyCount:     1,
+			BestKeyLevel: 8,
+			BestDungeon:  "Priory of the Sacred Flame",
+			VaultLevels:  [3]int{8, 0, 0},
+		},
+	}
+
+	var sb strings.Builder
+	writeHistoryLines(&sb, history)
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), sb.String())
+	}
+
+	wantFirst := fmt.Sprintf("**Feb 3**  5 keys  best +14 %s  vault %d/%d/---  (3000.0)",
+		shortenDungeonName("Ara-Kara, City of Echoes"), VaultRewards().GetItemLevel(14), VaultRewards().GetItemLevel(10))
+	if lines[0] != wantFirst {
+		t.Errorf("line 0 = %q; want %q", lines[0], wantFirst)
+	}
+
+	wantSecond := fmt.Sprintf("**Jan 27**  1 key  best +8 %s  vault %d/---/---",
+		shortenDungeonName("Priory of the Sacred Flame"), VaultRewards().GetItemLevel(8))
+	if lines[1] != wantSecond {
+		t.Errorf("line 1 = %q; want %q", lines[1], wantSecond)
+	}
+}
@@ -0,0 +1,112 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/importer"
+)
+
+const (
+	// _maxImportSize bounds the attachment size accepted by !char import.
+	_maxImportSize = 1 << 20
+	// _maxImportRows and _maxImportCharacters bound the work one import
+	// does; each distinct character costs a RaiderIO lookup.
+	_maxImportRows       = 500
+	_maxImportCharacters = 50
+	// _maxRejectionLines is how many rejected rows are listed in a reply.
+	_maxRejectionLines = 10
+)
+
+var _attachmentClient = &http.Client{Timeout: 30 * time.Second}
+
+// cmdCharImport tracks the characters and stores the keys in an attached
+// CSV or JSON file, validating each character against RaiderIO. Keys are
+// reconciled against those already stored, as polled keys are.
+// Usage: !char import (with a file attached)
+func (c *DefaultDiscord) cmdCharImport(ctx context.Context, attachments []*discordgo.MessageAttachment) (string, error) {
+	if c.store == nil {
+		return "", errors.New("database not configured")
+	}
+	if c.raiderIO == nil {
+		return "", errors.New("RaiderIO client not configured")
+	}
+	if len(attachments) == 0 {
+		return "Attach a CSV or JSON file to `!char import`.\n" +
+			"CSV rosters need `name,realm[,region]` columns; key files use the `!export` layout.", nil
+	}
+
+	file := attachments[0]
+	if file.Size > _maxImportSize {
+		return fmt.Sprintf("**%s** is too large to import (limit %s).", file.Filename, formatBytes(_maxImportSize)), nil
+	}
+
+	body, err := downloadAttachment(ctx, file.URL)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", file.Filename, err)
+	}
+
+	summary, err := importer.New(importer.Params{
+		Store:         c.store,
+		RaiderIO:      c.raiderIO,
+		MaxRows:       _maxImportRows,
+		MaxCharacters: _maxImportCharacters,
+	}).Import(ctx, file.Filename, body)
+	if err != nil {
+		return "", fmt.Errorf("import %s: %w", file.Filename, err)
+	}
+
+	return formatImportSummary(file.Filename, summary), nil
+}
+
+func downloadAttachment(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := _attachmentClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, _maxImportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > _maxImportSize {
+		return nil, errors.New("file too large")
+	}
+	return body, nil
+}
+
+// formatImportSummary reports accepted and rejected rows, listing the first
+// few rejections.
+func formatImportSummary(filename string, s importer.Summary) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Imported **%s**: %d rows accepted, %d rejected.\n", filename, s.Accepted(), len(s.Rejected)))
+	sb.WriteString(fmt.Sprintf("Characters: %d new, %d already tracked. Keys: %d stored, %d already stored, %d duplicates.\n",
+		s.Characters, s.KnownCharacters, s.Keys, s.KnownKeys, s.DuplicateKeys))
+
+	for i, r := range s.Rejected {
+		if i == _maxRejectionLines {
+			sb.WriteString(fmt.Sprintf("…and %d more.\n", len(s.Rejected)-i))
+			break
+		}
+		if r.Value == "" {
+			sb.WriteString(fmt.Sprintf("❌ row %d: %s\n", r.Row, r.Reason))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("❌ row %d `%s`: %s\n", r.Row, r.Value, r.Reason))
+	}
+	return sb.String()
+}
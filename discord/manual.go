@@ -0,0 +1,242 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+const (
+	_cmdAdd  = "add"
+	_cmdUndo = "undo"
+)
+
+const (
+	_minManualKeyLevel = 2
+	_maxManualKeyLevel = 30
+)
+
+// manualMarker flags manually entered keys in key listings.
+const manualMarker = " ✍️"
+
+// cmdKey handles manual key management commands.
+func (c *DefaultDiscord) cmdKey(ctx context.Context, args []string) (string, error) {
+	if len(args) < 1 {
+		return "Usage: `!key add <name> <realm> <dungeon> <level> [time]` or `!key undo <name> <realm>`", nil
+	}
+
+	subCmd := strings.ToLower(args[0])
+	subArgs := args[1:]
+
+	switch subCmd {
+	case _cmdAdd:
+		return c.cmdKeyAdd(ctx, subArgs)
+	case _cmdUndo:
+		return c.cmdKeyUndo(ctx, subArgs)
+	default:
+		return "Unknown subcommand. Use `add` or `undo`.", nil
+	}
+}
+
+// cmdKeyAdd records a key that no API reported.
+// Usage: !key add <name> <realm> <dungeon> <level> [time]
+func (c *DefaultDiscord) cmdKeyAdd(ctx context.Context, args []string) (string, error) {
+	if c.store == nil {
+		return "", errors.New("database not configured")
+	}
+
+	usage := "Usage: `!key add <name> <realm> <dungeon> <level> [time]`\n" +
+		"Example: `!key add Askrm malganis priory 12 21:30`\n" +
+		"Time is Pacific, as `15:04`, `2006-01-02T15:04` or RFC3339, and defaults to now."
+	if len(args) < 4 {
+		return usage, nil
+	}
+
+	name := strings.ToLower(args[0])
+	realm := strings.ToLower(args[1])
+
+	// The dungeon may span several words; the level is the first numeric
+	// argument after it.
+	levelIdx := -1
+	for i := 3; i < len(args); i++ {
+		if _, err := parseKeyLevel(args[i]); err == nil {
+			levelIdx = i
+			break
+		}
+	}
+	if levelIdx < 0 {
+		return usage, nil
+	}
+
+	dungeon, ok := lookupDungeon(strings.Join(args[2:levelIdx], " "))
+	if !ok {
+		var names []string
+		for _, d := range seasonDungeons {
+			names = append(names, d.Short)
+		}
+		return fmt.Sprintf("Unknown dungeon **%s**. Season dungeons: %s",
+			strings.Join(args[2:levelIdx], " "), strings.Join(names, ", ")), nil
+	}
+
+	level, _ := parseKeyLevel(args[levelIdx])
+	if level < _minManualKeyLevel || level > _maxManualKeyLevel {
+		return fmt.Sprintf("Key level must be between %d and %d.", _minManualKeyLevel, _maxManualKeyLevel), nil
+	}
+
+	now := c.clock.Now()
+	completedAt := now
+	if levelIdx+1 < len(args) {
+		t, err := parseManualTime(args[levelIdx+1], now)
+		if err != nil {
+			return fmt.Sprintf("Could not parse time **%s**.\n%s", args[levelIdx+1], usage), nil
+		}
+		completedAt = t
+	}
+	if completedAt.After(now) {
+		return "Completion time cannot be in the future.", nil
+	}
+
+	char, reply, err := c.manualCharacter(ctx, name, realm)
+	if err != nil || reply != "" {
+		return reply, err
+	}
+	region := char.Region
+
+	key := models.CompletedKey{
+		Character:   name,
+		Region:      region,
+		Realm:       realm,
+		Dungeon:     dungeon,
+		KeyLevel:    level,
+		CompletedAt: completedAt.UTC().Format(time.RFC3339),
+		Source:      models.SourceManual,
+	}
+	// An API source may already have reported the run; the manual key is
+	// then dropped.
+	statuses, err := c.store.ReconcileCompletedKeys(ctx, []models.CompletedKey{key})
+	if err != nil {
+		return "", fmt.Errorf("store manual key: %w", err)
+	}
+	if statuses[0] == store.UpsertUnchanged {
+		return fmt.Sprintf("**%s** (%s-%s) already has a +%d %s recorded this week.",
+			name, realm, region, level, shortenDungeonName(dungeon)), nil
+	}
+
+	warning := ""
+	if !completedAt.After(timeutil.WeeklyResetAt(now)) {
+		warning = "\nNote: this key was completed before the weekly reset and will not count toward this week's vault."
+	}
+
+	return fmt.Sprintf("Added manual key for **%s** (%s-%s): +%d %s at %s.%s",
+		name, realm, region, level, shortenDungeonName(dungeon), formatShortTime(key.CompletedAt), warning), nil
+}
+
+// cmdKeyUndo removes the most recent manual key for a character this week.
+// Usage: !key undo <name> <realm>
+func (c *DefaultDiscord) cmdKeyUndo(ctx context.Context, args []string) (string, error) {
+	if c.store == nil {
+		return "", errors.New("database not configured")
+	}
+
+	if len(args) < 2 {
+		return "Usage: `!key undo <name> <realm>`\nExample: `!key undo Askrm malganis`", nil
+	}
+
+	name := strings.ToLower(args[0])
+	realm := strings.ToLower(args[1])
+
+	char, reply, err := c.manualCharacter(ctx, name, realm)
+	if err != nil || reply != "" {
+		return reply, err
+	}
+	region := char.Region
+
+	since := timeutil.WeeklyResetAt(c.clock.Now())
+	keys, err := c.store.ListKeysByCharacterSince(ctx, name, realm, region, since)
+	if err != nil {
+		return "", err
+	}
+
+	// Keys are ordered newest first.
+	for _, key := range keys {
+		if key.Source != models.SourceManual {
+			continue
+		}
+
+		if err := c.store.DeleteCompletedKey(ctx, name, realm, region, key.KeyID); err != nil {
+			return "", fmt.Errorf("delete manual key: %w", err)
+		}
+		return fmt.Sprintf("Removed manual key for **%s** (%s-%s): +%d %s at %s.",
+			name, realm, region, key.KeyLevel, shortenDungeonName(key.Dungeon), formatShortTime(key.CompletedAt)), nil
+	}
+
+	return fmt.Sprintf("No manual keys found for **%s** (%s-%s) this week.", name, realm, region), nil
+}
+
+// manualCharacter finds the tracked character with name and realm, whose
+// region the manual key commands take from the roster. If there is no single
+// match it returns a reply for the user instead.
+func (c *DefaultDiscord) manualCharacter(ctx context.Context, name, realm string) (models.Character, string, error) {
+	chars, err := c.store.ListCharacters(ctx)
+	if err != nil {
+		return models.Character{}, "", err
+	}
+
+	var matches []models.Character
+	for _, char := range chars {
+		if strings.EqualFold(char.Name, name) && strings.EqualFold(char.Realm, realm) {
+			matches = append(matches, char)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return models.Character{}, fmt.Sprintf("Character **%s** (%s) not found in database. Use `!char sync` first.", name, realm), nil
+	case 1:
+		return matches[0], "", nil
+	default:
+		regions := make([]string, len(matches))
+		for i, char := range matches {
+			regions[i] = char.Region
+		}
+		return models.Character{}, fmt.Sprintf("Character **%s** (%s) is tracked in several regions (%s).",
+			name, realm, strings.Join(regions, ", ")), nil
+	}
+}
+
+func parseKeyLevel(arg string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(arg, "+"))
+}
+
+// parseManualTime parses a completion time given in Pacific time. A bare
+// clock time refers to its most recent occurrence at or before now.
+func parseManualTime(arg string, now time.Time) (time.Time, error) {
+	if t, err := timeutil.ParseRFC3339(arg); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", arg, _pstLocation); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{"15:04", "3:04pm", "3pm"} {
+		clock, err := time.Parse(layout, strings.ToLower(arg))
+		if err != nil {
+			continue
+		}
+		local := now.In(_pstLocation)
+		t := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, _pstLocation)
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q", arg)
+}
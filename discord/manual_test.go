@@ -0,0 +1,139 @@
+package discord
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+func TestLookupDungeon(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{query: "priory", want: "Priory of the Sacred Flame", wantOK: true},
+		{query: "Ara-Kara", want: "Ara-Kara, City of Echoes", wantOK: true},
+		{query: "ara kara city of echoes", want: "Ara-Kara, City of Echoes", wantOK: true},
+		{query: "soleahs gambit", wantOK: false},
+		{query: "gambit", want: "Tazavesh: So'leah's Gambit", wantOK: true},
+		{query: "Mists of Tirna Scithe", wantOK: false},
+		{query: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, ok := lookupDungeon(tt.query)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("lookupDungeon(%q) = %q, %v; want %q, %v", tt.query, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseManualTime(t *testing.T) {
+	// Wednesday 10:00 Pacific.
+	now := time.Date(2026, 2, 4, 10, 0, 0, 0, _pstLocation)
+
+	tests := []struct {
+		name    string
+		arg     string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "clock time earlier today",
+			arg:  "08:30",
+			want: time.Date(2026, 2, 4, 8, 30, 0, 0, _pstLocation),
+		},
+		{
+			name: "clock time later today means yesterday",
+			arg:  "21:30",
+			want: time.Date(2026, 2, 3, 21, 30, 0, 0, _pstLocation),
+		},
+		{
+			name: "12-hour clock",
+			arg:  "9:15PM",
+			want: time.Date(2026, 2, 3, 21, 15, 0, 0, _pstLocation),
+		},
+		{
+			name: "local date and time",
+			arg:  "2026-02-03T19:00",
+			want: time.Date(2026, 2, 3, 19, 0, 0, 0, _pstLocation),
+		},
+		{
+			name: "rfc3339",
+			arg:  "2026-02-04T03:00:00Z",
+			want: time.Date(2026, 2, 4, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "garbage",
+			arg:     "yesterday",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseManualTime(tt.arg, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseManualTime(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("parseManualTime(%q) = %v, want %v", tt.arg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCmdKeyAddUsesRosterRegion(t *testing.T) {
+	ctx := context.Background()
+	// Wednesday 10:00 Pacific.
+	clk := clock.NewFake(time.Date(2026, 2, 4, 10, 0, 0, 0, _pstLocation))
+	st := store.NewSQLiteStore(store.Params{Clock: clk})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	if _, err := st.AddCharacter(ctx, models.Character{Name: "askrm", Realm: "draenor", Region: "eu"}); err != nil {
+		t.Fatalf("add character: %v", err)
+	}
+	c := &DefaultDiscord{store: st, clock: clk}
+
+	resp, err := c.cmdKeyAdd(ctx, []string{"Askrm", "draenor", "priory", "12", "08:30"})
+	if err != nil || !strings.HasPrefix(resp, "Added manual key") {
+		t.Fatalf("add: %q (err %v)", resp, err)
+	}
+	keys, err := st.ListKeysByCharacterSince(ctx, "askrm", "draenor", "eu", time.Time{})
+	if err != nil || len(keys) != 1 || keys[0].Source != models.SourceManual {
+		t.Fatalf("expected the manual key under eu, got %#v (err %v)", keys, err)
+	}
+
+	// A run RaiderIO already reported is not recorded again.
+	if err := st.UpsertCompletedKey(ctx, models.CompletedKey{
+		KeyID:       4242,
+		Character:   "askrm",
+		Region:      "eu",
+		Realm:       "draenor",
+		Dungeon:     "Halls of Atonement",
+		KeyLevel:    10,
+		CompletedAt: "2026-02-04T15:00:00Z",
+		Source:      models.SourceRaiderIO,
+	}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	resp, err = c.cmdKeyAdd(ctx, []string{"Askrm", "draenor", "halls", "10", "09:00"})
+	if err != nil || !strings.Contains(resp, "already has a +10 Halls") {
+		t.Fatalf("expected the duplicate to be refused, got %q (err %v)", resp, err)
+	}
+
+	resp, err = c.cmdKeyAdd(ctx, []string{"Askrm", "illidan", "priory", "12"})
+	if err != nil || !strings.Contains(resp, "not found") {
+		t.Fatalf("expected an unknown character, got %q (err %v)", resp, err)
+	}
+}
@@ -0,0 +1,348 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+const (
+	// _outboxInterval is how often the dispatcher looks for due messages
+	// when it has not been woken by a new one.
+	_outboxInterval = 15 * time.Second
+	_outboxBatch    = 20
+	_outboxMinRetry = 30 * time.Second
+	_outboxMaxRetry = time.Hour
+	// _outboxMaxAttempts is how many sends are tried before a message is
+	// parked as failed for an admin to retry.
+	_outboxMaxAttempts = 8
+	// _outboxLookback is how many recent channel messages are searched for
+	// an earlier copy of an in-doubt message. A message stays in doubt only
+	// between its claim and its sent mark, both written durably, so the
+	// window is one send; but if more than _outboxLookback posts land in
+	// the channel before the bot restarts, the earlier copy is not found
+	// and the message is posted again.
+	_outboxLookback = 50
+	// _outboxRetention is how long delivered messages are kept before they
+	// are pruned. Failed messages are kept until an admin retries them.
+	_outboxRetention = 30 * 24 * time.Hour
+	// _outboxPruneEvery is how often the dispatcher prunes.
+	_outboxPruneEvery = 24 * time.Hour
+)
+
+const (
+	_cmdOutbox = "outbox"
+	_cmdRetry  = "retry"
+)
+
+// messageSender is the part of the Discord session the dispatcher uses.
+type messageSender interface {
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+}
+
+// outboxDispatcher delivers queued outbox messages, retrying failures with
+// backoff. Each send is claimed first so that a message interrupted by a
+// restart is checked against the channel before it is posted again.
+type outboxDispatcher struct {
+	outbox store.Outbox
+	sender messageSender
+	selfID func() string
+	logger logger.Logger
+	clock  clock.Clock
+
+	// mu serialises dispatch so a wake-up never races the ticker.
+	mu        sync.Mutex
+	lastPrune time.Time
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func (d *outboxDispatcher) start() {
+	d.wake = make(chan struct{}, 1)
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+
+	go d.run()
+}
+
+func (d *outboxDispatcher) close() {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+}
+
+// notify asks the dispatcher to look for due messages now.
+func (d *outboxDispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *outboxDispatcher) run() {
+	defer close(d.done)
+
+	ticker := clock.NewTicker(d.clock, _outboxInterval)
+	defer ticker.Stop()
+
+	d.dispatch(context.Background())
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C():
+		case <-d.wake:
+		}
+		d.dispatch(context.Background())
+	}
+}
+
+// dispatch attempts every message that is currently due.
+func (d *outboxDispatcher) dispatch(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	msgs, err := d.outbox.DueMessages(ctx, d.clock.Now(), _outboxBatch)
+	if err != nil {
+		d.logger.ErrorW("list due outbox messages", "error", err)
+		return
+	}
+
+	for _, msg := range msgs {
+		d.deliver(ctx, msg)
+	}
+
+	d.prune(ctx)
+}
+
+// prune deletes delivered messages older than _outboxRetention, at most
+// once per _outboxPruneEvery.
+func (d *outboxDispatcher) prune(ctx context.Context) {
+	now := d.clock.Now()
+	if !d.lastPrune.IsZero() && now.Sub(d.lastPrune) < _outboxPruneEvery {
+		return
+	}
+	d.lastPrune = now
+
+	n, err := d.outbox.PruneSentMessages(ctx, now.Add(-_outboxRetention))
+	if err != nil {
+		d.logger.ErrorW("prune outbox", "error", err)
+		return
+	}
+	if n > 0 {
+		d.logger.InfoW("pruned outbox", "removed", n)
+	}
+}
+
+func (d *outboxDispatcher) deliver(ctx context.Context, msg store.OutboxMessage) {
+	if msg.InDoubt() {
+		posted, err := d.alreadyPosted(msg)
+		if err != nil {
+			d.logger.WarnW("check for earlier outbox post", "dedup_key", msg.DedupKey, "error", err)
+		}
+		if posted {
+			if err := d.outbox.MarkMessageSent(ctx, msg.ID, d.clock.Now()); err != nil {
+				d.logger.ErrorW("mark outbox message sent", "dedup_key", msg.DedupKey, "error", err)
+			}
+			return
+		}
+	}
+
+	if err := d.outbox.ClaimMessage(ctx, msg.ID, d.clock.Now()); err != nil {
+		d.logger.ErrorW("claim outbox message", "dedup_key", msg.DedupKey, "error", err)
+		return
+	}
+
+	send, err := messageSend(msg)
+	if err == nil {
+		_, err = d.sender.ChannelMessageSendComplex(msg.ChannelID, send)
+	}
+	if err == nil {
+		if err := d.outbox.MarkMessageSent(ctx, msg.ID, d.clock.Now()); err != nil {
+			d.logger.ErrorW("mark outbox message sent", "dedup_key", msg.DedupKey, "error", err)
+		}
+		return
+	}
+
+	attempts := msg.Attempts + 1
+	giveUp := attempts >= _outboxMaxAttempts
+	if giveUp {
+		d.logger.ErrorW("outbox message failed",
+			"dedup_key", msg.DedupKey,
+			"attempts", attempts,
+			"error", err,
+		)
+	} else {
+		d.logger.WarnW("outbox send failed, will retry",
+			"dedup_key", msg.DedupKey,
+			"attempts", attempts,
+			"error", err,
+		)
+	}
+
+	retryAt := d.clock.Now().Add(outboxBackoff(attempts))
+	if err := d.outbox.MarkMessageFailed(ctx, msg.ID, err.Error(), retryAt, giveUp); err != nil {
+		d.logger.ErrorW("mark outbox message failed", "dedup_key", msg.DedupKey, "error", err)
+	}
+}
+
+// alreadyPosted reports whether msg appears among the bot's recent posts in
+// its channel, which happens when a send went through but the process
+// stopped before recording it.
+func (d *outboxDispatcher) alreadyPosted(msg store.OutboxMessage) (bool, error) {
+	send, err := messageSend(msg)
+	if err != nil {
+		return false, err
+	}
+
+	recent, err := d.sender.ChannelMessages(msg.ChannelID, _outboxLookback, "", "", "")
+	if err != nil {
+		return false, err
+	}
+
+	self := d.selfID()
+	for _, m := range recent {
+		if m.Author == nil || m.Author.ID != self {
+			continue
+		}
+		if m.Timestamp.Before(msg.CreatedAt) {
+			continue
+		}
+		if samePost(m, send) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// samePost compares the parts of a post the bot sets itself.
+func samePost(m *discordgo.Message, send *discordgo.MessageSend) bool {
+	if m.Content != send.Content || len(m.Embeds) != len(send.Embeds) {
+		return false
+	}
+	for i, e := range send.Embeds {
+		if m.Embeds[i].Title != e.Title || m.Embeds[i].Description != e.Description {
+			return false
+		}
+	}
+	return true
+}
+
+// outboxBackoff doubles from _outboxMinRetry for each attempt, up to
+// _outboxMaxRetry.
+func outboxBackoff(attempts int) time.Duration {
+	delay := _outboxMinRetry
+	for i := 1; i < attempts && delay < _outboxMaxRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, _outboxMaxRetry)
+}
+
+func messageSend(msg store.OutboxMessage) (*discordgo.MessageSend, error) {
+	send := &discordgo.MessageSend{Content: msg.Content}
+	if msg.Embeds != "" {
+		if err := json.Unmarshal([]byte(msg.Embeds), &send.Embeds); err != nil {
+			return nil, fmt.Errorf("decode embeds: %w", err)
+		}
+	}
+	return send, nil
+}
+
+// post queues resp for channelID under dedupKey, so it is posted once even
+// if the caller runs again. Without an outbox it is sent directly.
+func (c *DefaultDiscord) post(ctx context.Context, dedupKey, channelID string, resp cmdResponse) error {
+	if c.outbox == nil {
+		if len(resp.embeds) > 0 {
+			_, err := c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+				Embeds: resp.embeds,
+			})
+			return err
+		}
+		return c.WriteMessage(channelID, resp.content)
+	}
+
+	msg := store.OutboxMessage{
+		DedupKey:      dedupKey,
+		ChannelID:     channelID,
+		Content:       resp.content,
+		NextAttemptAt: c.clock.Now(),
+	}
+	if len(resp.embeds) > 0 {
+		raw, err := json.Marshal(resp.embeds)
+		if err != nil {
+			return fmt.Errorf("encode embeds: %w", err)
+		}
+		msg.Embeds = string(raw)
+	}
+
+	if _, err := c.outbox.EnqueueMessage(ctx, msg); err != nil {
+		return err
+	}
+	if c.dispatcher != nil {
+		c.dispatcher.notify()
+	}
+	return nil
+}
+
+// cmdOutbox lists deliveries that were given up on, or requeues one.
+// Usage: !outbox [retry <id>]
+func (c *DefaultDiscord) cmdOutbox(ctx context.Context, args []string) (cmdResponse, error) {
+	if c.outbox == nil {
+		return cmdResponse{content: "The outbox is not enabled for this store."}, nil
+	}
+
+	if len(args) > 0 && strings.ToLower(args[0]) == _cmdRetry {
+		if len(args) < 2 {
+			return cmdResponse{content: "Usage: `!outbox retry <id>`"}, nil
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return cmdResponse{content: fmt.Sprintf("Invalid message ID **%s**.", args[1])}, nil
+		}
+		ok, err := c.outbox.RequeueMessage(ctx, id, c.clock.Now())
+		if err != nil {
+			return cmdResponse{}, err
+		}
+		if !ok {
+			return cmdResponse{content: fmt.Sprintf("No failed message with ID **%d**.", id)}, nil
+		}
+		if c.dispatcher != nil {
+			c.dispatcher.notify()
+		}
+		return cmdResponse{content: fmt.Sprintf("Message **%d** queued for another attempt.", id)}, nil
+	}
+
+	failed, err := c.outbox.FailedMessages(ctx, 10)
+	if err != nil {
+		return cmdResponse{}, err
+	}
+	if len(failed) == 0 {
+		return cmdResponse{content: "No failed deliveries."}, nil
+	}
+
+	var sb strings.Builder
+	for _, m := range failed {
+		sb.WriteString(fmt.Sprintf("`%d`  %s  %s  %d attempts — %s\n",
+			m.ID, m.DedupKey, m.FailedAt.In(_pstLocation).Format("Jan 2 15:04"), m.Attempts, m.LastError))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Failed deliveries",
+		Description: sb.String(),
+		Color:       embedColor,
+	}
+
+	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
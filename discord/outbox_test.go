@@ -0,0 +1,373 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+const _botID = "bot"
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// fakeSender fails the first failures sends and records the rest.
+type fakeSender struct {
+	mu       sync.Mutex
+	failures int
+	sent     []*discordgo.MessageSend
+	history  []*discordgo.Message
+}
+
+func (f *fakeSender) ChannelMessageSendComplex(_ string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return nil, errors.New("discord unavailable")
+	}
+	f.sent = append(f.sent, data)
+	return &discordgo.Message{Content: data.Content}, nil
+}
+
+// ChannelMessages returns up to limit messages from history, newest first.
+func (f *fakeSender) ChannelMessages(_ string, limit int, _, _, _ string, _ ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.history[:min(limit, len(f.history))], nil
+}
+
+// memOutbox is an in-memory store.Outbox.
+type memOutbox struct {
+	mu   sync.Mutex
+	msgs []*store.OutboxMessage
+}
+
+func (o *memOutbox) find(id int64) *store.OutboxMessage {
+	for _, m := range o.msgs {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func (o *memOutbox) EnqueueMessage(_ context.Context, msg store.OutboxMessage) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range o.msgs {
+		if m.DedupKey == msg.DedupKey {
+			return false, nil
+		}
+	}
+	msg.ID = int64(len(o.msgs) + 1)
+	msg.CreatedAt = msg.NextAttemptAt
+	o.msgs = append(o.msgs, &msg)
+	return true, nil
+}
+
+func (o *memOutbox) RecordElvUIVersion(context.Context, store.ElvUIVersion, store.OutboxMessage) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return false, errors.New("not implemented")
+}
+
+func (o *memOutbox) DueMessages(_ context.Context, now time.Time, limit int) ([]store.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var out []store.OutboxMessage
+	for _, m := range o.msgs {
+		if m.SentAt.IsZero() && m.FailedAt.IsZero() && !m.NextAttemptAt.After(now) && len(out) < limit {
+			out = append(out, *m)
+		}
+	}
+	return out, nil
+}
+
+func (o *memOutbox) ClaimMessage(_ context.Context, id int64, at time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	m.ClaimedAt = at
+	m.Attempts++
+	return nil
+}
+
+func (o *memOutbox) MarkMessageSent(_ context.Context, id int64, at time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	m.SentAt, m.ClaimedAt, m.LastError = at, time.Time{}, ""
+	return nil
+}
+
+func (o *memOutbox) MarkMessageFailed(_ context.Context, id int64, cause string, retryAt time.Time, giveUp bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	m.LastError, m.NextAttemptAt, m.ClaimedAt = cause, retryAt, time.Time{}
+	if giveUp {
+		m.FailedAt = retryAt
+	}
+	return nil
+}
+
+func (o *memOutbox) FailedMessages(_ context.Context, limit int) ([]store.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var out []store.OutboxMessage
+	for _, m := range o.msgs {
+		if !m.FailedAt.IsZero() && len(out) < limit {
+			out = append(out, *m)
+		}
+	}
+	return out, nil
+}
+
+func (o *memOutbox) RequeueMessage(_ context.Context, id int64, at time.Time) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	if m == nil || m.FailedAt.IsZero() {
+		return false, nil
+	}
+	m.FailedAt, m.Attempts, m.NextAttemptAt = time.Time{}, 0, at
+	return true, nil
+}
+
+func (o *memOutbox) PruneSentMessages(_ context.Context, cutoff time.Time) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var kept []*store.OutboxMessage
+	for _, m := range o.msgs {
+		if m.SentAt.IsZero() || !m.SentAt.Before(cutoff) {
+			kept = append(kept, m)
+		}
+	}
+	n := int64(len(o.msgs) - len(kept))
+	o.msgs = kept
+	return n, nil
+}
+
+func newTestDispatcher(sender *fakeSender) (*outboxDispatcher, *memOutbox, *fakeClock) {
+	outbox := &memOutbox{}
+	clk := &fakeClock{now: time.Date(2026, 2, 4, 15, 0, 0, 0, time.UTC)}
+	return &outboxDispatcher{
+		outbox: outbox,
+		sender: sender,
+		selfID: func() string { return _botID },
+		logger: logger.NewNop(),
+		clock:  clk,
+	}, outbox, clk
+}
+
+func enqueue(t *testing.T, outbox store.Outbox, at time.Time, key, content string) {
+	t.Helper()
+	if _, err := outbox.EnqueueMessage(context.Background(), store.OutboxMessage{
+		DedupKey:      key,
+		ChannelID:     "chan",
+		Content:       content,
+		NextAttemptAt: at,
+	}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+}
+
+func TestOutboxDispatcherRetries(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{failures: 1}
+	d, st, clk := newTestDispatcher(sender)
+
+	enqueue(t, st, clk.now, "report:2026-02-04", "report")
+	enqueue(t, st, clk.now, "report:2026-02-04", "report")
+
+	d.dispatch(ctx)
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected first send to fail, got %d sent", len(sender.sent))
+	}
+
+	d.dispatch(ctx)
+	if len(sender.sent) != 0 {
+		t.Fatal("expected no retry before the backoff elapses")
+	}
+
+	clk.now = clk.now.Add(_outboxMinRetry)
+	d.dispatch(ctx)
+	d.dispatch(ctx)
+	if len(sender.sent) != 1 || sender.sent[0].Content != "report" {
+		t.Fatalf("expected exactly one post after retry, got %d", len(sender.sent))
+	}
+}
+
+func TestOutboxDispatcherGivesUp(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{failures: _outboxMaxAttempts}
+	d, st, clk := newTestDispatcher(sender)
+
+	enqueue(t, st, clk.now, "reset:2026-02-03", "reset")
+	for range _outboxMaxAttempts {
+		d.dispatch(ctx)
+		clk.now = clk.now.Add(_outboxMaxRetry)
+	}
+
+	failed, err := st.FailedMessages(ctx, 10)
+	if err != nil || len(failed) != 1 || failed[0].Attempts != _outboxMaxAttempts {
+		t.Fatalf("expected message to be parked as failed, got %#v (err %v)", failed, err)
+	}
+
+	resp, err := (&DefaultDiscord{outbox: st, clock: clk}).cmdOutbox(ctx, []string{_cmdRetry, "1"})
+	if err != nil || resp.content != "Message **1** queued for another attempt." {
+		t.Fatalf("retry command: %q (err %v)", resp.content, err)
+	}
+	d.dispatch(ctx)
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected requeued message to be posted, got %d", len(sender.sent))
+	}
+}
+
+func TestOutboxDispatcherSkipsInDoubtDuplicate(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	d, st, clk := newTestDispatcher(sender)
+
+	enqueue(t, st, clk.now, "elvui:13.81", "ElvUI 13.81")
+	due, err := st.DueMessages(ctx, clk.now, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("due: %v", err)
+	}
+	// Simulate a restart after the post went out but before it was marked.
+	if err := st.ClaimMessage(ctx, due[0].ID, clk.now); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	sender.history = []*discordgo.Message{
+		{Author: &discordgo.User{ID: "someone"}, Content: "ElvUI 13.81", Timestamp: clk.now},
+		{Author: &discordgo.User{ID: _botID}, Content: "ElvUI 13.81", Timestamp: clk.now.Add(time.Second)},
+	}
+
+	d.dispatch(ctx)
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected in-doubt message not to be resent, got %d", len(sender.sent))
+	}
+	if due, _ := st.DueMessages(ctx, clk.now.Add(time.Hour), 10); len(due) != 0 {
+		t.Fatalf("expected message to be marked sent, got %d due", len(due))
+	}
+}
+
+func TestOutboxDispatcherResendsBeyondLookback(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	d, st, clk := newTestDispatcher(sender)
+
+	enqueue(t, st, clk.now, "elvui:13.81", "ElvUI 13.81")
+	due, _ := st.DueMessages(ctx, clk.now, 10)
+	if err := st.ClaimMessage(ctx, due[0].ID, clk.now); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	// The earlier post is older than the last _outboxLookback messages, so
+	// the check cannot see it and the message is posted again.
+	for range _outboxLookback {
+		sender.history = append(sender.history, &discordgo.Message{Author: &discordgo.User{ID: "someone"}, Timestamp: clk.now.Add(time.Minute)})
+	}
+	sender.history = append(sender.history, &discordgo.Message{Author: &discordgo.User{ID: _botID}, Content: "ElvUI 13.81", Timestamp: clk.now})
+
+	d.dispatch(ctx)
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected a post hidden past the lookback to be resent, got %d", len(sender.sent))
+	}
+}
+
+func TestOutboxDispatcherPrunesSent(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	d, st, clk := newTestDispatcher(sender)
+
+	enqueue(t, st, clk.now, "report:2026-02-04", "report")
+	d.dispatch(ctx)
+	if len(st.msgs) != 1 || st.msgs[0].SentAt.IsZero() {
+		t.Fatalf("expected the report to be sent and kept, got %#v", st.msgs)
+	}
+
+	// The sent message is past retention, but pruning already ran today.
+	clk.now = clk.now.Add(_outboxRetention + time.Hour)
+	d.lastPrune = clk.now.Add(-time.Hour)
+	d.dispatch(ctx)
+	if len(st.msgs) != 1 {
+		t.Fatal("expected no prune within a day of the last one")
+	}
+
+	clk.now = clk.now.Add(_outboxPruneEvery)
+	d.dispatch(ctx)
+	if len(st.msgs) != 0 {
+		t.Fatalf("expected the sent message to be pruned, got %d left", len(st.msgs))
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 20, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %v; want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDiscordStartWorkersPostsThroughDispatcher(t *testing.T) {
+	ctx := context.Background()
+	// A minute before the daily report on a Wednesday.
+	clk := clock.NewFake(time.Date(2026, 2, 4, 6, 59, 0, 0, _pstLocation))
+	st := store.NewSQLiteStore(store.Params{Clock: clk})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	if _, err := st.AddCharacter(ctx, models.Character{Name: "arthas", Realm: "illidan", Region: "us"}); err != nil {
+		t.Fatalf("add character: %v", err)
+	}
+
+	sender := &fakeSender{}
+	c := &DefaultDiscord{
+		settings: settings{listenChannel: "keys"},
+		session:  &discordgo.Session{},
+		store:    st,
+		outbox:   st,
+		logger:   logger.NewNop(),
+		clock:    clk,
+	}
+	c.startWorkers(sender)
+	t.Cleanup(c.Stop)
+
+	// The scheduler posts through the dispatcher on its first tick.
+	clk.BlockUntil(2)
+	clk.Advance(time.Minute)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sender.mu.Lock()
+		sent := len(sender.sent)
+		sender.mu.Unlock()
+		if sent == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the daily report delivered, got %d messages", sent)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,156 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+const _cmdStatus = "status"
+
+const (
+	// _maxEmbedDescription and _maxEmbedFieldValue are Discord's limits, in
+	// characters, on an embed's description and each field's value.
+	_maxEmbedDescription = 4096
+	_maxEmbedFieldValue  = 1024
+	// _maxErrorLen shortens errors in job lines, so one long error does not
+	// push the other jobs out of the embed.
+	_maxErrorLen = 200
+)
+
+// syncedClock is a clock corrected against a time server, such as
+// clock.NTPClock.
+type syncedClock interface {
+	Offset() time.Duration
+	LastSync() time.Time
+}
+
+// flushReporter is a store that keeps an on-disk snapshot, such as
+// store.SQLiteStore.
+type flushReporter interface {
+	FlushState() store.FlushState
+}
+
+// cmdStatus reports the health of the pollers, the clock and the store.
+// Usage: !status
+func (c *DefaultDiscord) cmdStatus(ctx context.Context) (cmdResponse, error) {
+	embed := &discordgo.MessageEmbed{
+		Title: "Status",
+		Color: embedColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Pollers", Value: c.pollerStatus()},
+			{Name: "Profile cache", Value: c.cacheStatus()},
+			{Name: "Clock", Value: c.clockStatus()},
+			{Name: "Store", Value: c.storeStatus(ctx)},
+			{Name: "ElvUI", Value: c.elvuiStatus(ctx)},
+		},
+	}
+	for _, f := range embed.Fields {
+		f.Value = truncate(f.Value, _maxEmbedFieldValue)
+	}
+	return cmdResponse{embeds: []*discordgo.MessageEmbed{embed}}, nil
+}
+
+// truncate shortens s to at most limit characters, ending it with an
+// ellipsis when anything was cut.
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:limit-1]) + "…"
+}
+
+func (c *DefaultDiscord) pollerStatus() string {
+	if c.jobs == nil {
+		return "Background jobs are not running."
+	}
+	statuses := c.jobs.Status()
+	if len(statuses) == 0 {
+		return "No background jobs."
+	}
+
+	var sb strings.Builder
+	writeJobLines(&sb, statuses)
+	return sb.String()
+}
+
+func (c *DefaultDiscord) cacheStatus() string {
+	if len(c.caches) == 0 {
+		return "Not enabled."
+	}
+
+	var sb strings.Builder
+	for _, cache := range c.caches {
+		s := cache.Stats()
+		served := s.Hits + s.StaleHits
+		rate := 0.0
+		if total := served + s.Misses; total > 0 {
+			rate = 100 * float64(served) / float64(total)
+		}
+		sb.WriteString(fmt.Sprintf("**%s** %.0f%% hits (%d fresh, %d stale), %d misses (%d not modified), %d errors, %d cached\n",
+			s.Name, rate, s.Hits, s.StaleHits, s.Misses, s.NotModified, s.Errors, s.Entries))
+	}
+	return sb.String()
+}
+
+func (c *DefaultDiscord) clockStatus() string {
+	synced, ok := c.clock.(syncedClock)
+	if !ok {
+		return "System clock, not synced."
+	}
+	lastSync := synced.LastSync()
+	if lastSync.IsZero() {
+		return "❌ NTP has not synced; using the system clock."
+	}
+	return fmt.Sprintf("✅ NTP offset %s, last sync %s", synced.Offset(), formatJobTime(lastSync))
+}
+
+func (c *DefaultDiscord) storeStatus(ctx context.Context) string {
+	if c.store == nil {
+		return "Database not configured."
+	}
+
+	var sb strings.Builder
+	if fr, ok := c.store.(flushReporter); ok {
+		state := fr.FlushState()
+		switch {
+		case state.LastError != "":
+			sb.WriteString(fmt.Sprintf("❌ last flush failed: %s\n", truncate(state.LastError, _maxErrorLen)))
+		case state.Dirty:
+			sb.WriteString("⏳ changes waiting to be flushed\n")
+		default:
+			sb.WriteString("✅ snapshot up to date\n")
+		}
+		sb.WriteString(fmt.Sprintf("Last flush %s\n", formatJobTime(state.LastFlush)))
+	}
+
+	backups, err := c.store.ListBackups(ctx)
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("Backups: ❌ %v", err))
+	} else {
+		sb.WriteString(fmt.Sprintf("Backups: %d", len(backups)))
+	}
+	return sb.String()
+}
+
+func (c *DefaultDiscord) elvuiStatus(ctx context.Context) string {
+	if c.store == nil {
+		return "Database not configured."
+	}
+	v, err := c.store.GetElvUIVersion(ctx)
+	if err != nil {
+		return "Not checked yet."
+	}
+
+	checked := v.CheckedAt
+	if t, err := time.Parse(time.RFC3339, v.CheckedAt); err == nil {
+		checked = formatJobTime(t)
+	}
+	return fmt.Sprintf("%s, last checked %s", v.Version, checked)
+}
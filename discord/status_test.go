@@ -0,0 +1,132 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/models"
+	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+type fakeSyncedClock struct {
+	offset   time.Duration
+	lastSync time.Time
+}
+
+func (f fakeSyncedClock) Now() time.Time        { return time.Now().Add(f.offset) }
+func (f fakeSyncedClock) Offset() time.Duration { return f.offset }
+func (f fakeSyncedClock) LastSync() time.Time   { return f.lastSync }
+
+func TestDiscordStatus(t *testing.T) {
+	c := &DefaultDiscord{clock: fakeSyncedClock{}}
+	if got := c.clockStatus(); !strings.Contains(got, "has not synced") {
+		t.Errorf("unsynced clock status = %q", got)
+	}
+
+	synced := time.Date(2026, 2, 3, 20, 0, 0, 0, time.UTC)
+	c.clock = fakeSyncedClock{offset: 12 * time.Millisecond, lastSync: synced}
+	want := "✅ NTP offset 12ms, last sync " + formatJobTime(synced)
+	if got := c.clockStatus(); got != want {
+		t.Errorf("clock status = %q, want %q", got, want)
+	}
+
+	resp, err := c.cmdStatus(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	fields := resp.embeds[0].Fields
+	if len(fields) != 5 {
+		t.Fatalf("expected 5 fields, got %d", len(fields))
+	}
+	if fields[0].Value != "Background jobs are not running." || fields[3].Value != "Database not configured." {
+		t.Errorf("unexpected fields without jobs or store: %q, %q", fields[0].Value, fields[3].Value)
+	}
+
+	cache := rioClient.NewCache(rioClient.CacheParams{Name: "raiderio", Client: stubRIO{}})
+	char := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+	for range 4 {
+		_, _ = cache.FetchWeeklyRuns(context.Background(), char)
+	}
+	c.caches = []*rioClient.CachingClient{cache}
+	want = "**raiderio** 75% hits (3 fresh, 0 stale), 1 misses (0 not modified), 0 errors, 1 cached\n"
+	if got := c.cacheStatus(); got != want {
+		t.Errorf("cache status = %q, want %q", got, want)
+	}
+}
+
+func TestDiscordStatusTruncatesLongValues(t *testing.T) {
+	long := strings.Repeat("disk I/O error ", 500)
+	c := &DefaultDiscord{clock: fakeSyncedClock{}, store: flushFailingStore{err: long}}
+	resp, err := c.cmdStatus(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, f := range resp.embeds[0].Fields {
+		if n := utf8.RuneCountInString(f.Value); n > _maxEmbedFieldValue {
+			t.Errorf("field %s has %d characters", f.Name, n)
+		}
+	}
+	if got := resp.embeds[0].Fields[3].Value; !strings.Contains(got, "last flush failed") || !strings.Contains(got, "Backups: 0") {
+		t.Errorf("expected the store field to keep its lines, got %q", got)
+	}
+
+	runner := jobs.NewRunner(jobs.Params{})
+	for i := range 100 {
+		if err := runner.Add(jobs.Job{
+			Name:     fmt.Sprintf("%s-%03d", strings.Repeat("poller", 10), i),
+			Interval: time.Minute,
+			Run:      func(context.Context) error { return nil },
+		}); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	c.jobs = runner
+	if resp, _ = c.cmdStatus(context.Background()); utf8.RuneCountInString(resp.embeds[0].Fields[0].Value) > _maxEmbedFieldValue {
+		t.Error("pollers field over the limit")
+	}
+	if resp, _ = c.cmdPoll(nil); utf8.RuneCountInString(resp.embeds[0].Description) > _maxEmbedDescription {
+		t.Error("!poll description over the limit")
+	}
+}
+
+func TestWriteJobLinesShortensErrors(t *testing.T) {
+	var sb strings.Builder
+	writeJobLines(&sb, []jobs.Status{{Name: "raiderio", Failures: 2, LastError: strings.Repeat("x", 5000)}})
+	if n := utf8.RuneCountInString(sb.String()); n > 400 {
+		t.Fatalf("expected the error shortened, got %d characters", n)
+	}
+	if !strings.Contains(sb.String(), "…") {
+		t.Fatal("expected an ellipsis marking the cut")
+	}
+}
+
+// flushFailingStore reports a failed flush, no backups and no ElvUI check.
+type flushFailingStore struct {
+	store.Store
+	err string
+}
+
+func (f flushFailingStore) FlushState() store.FlushState {
+	return store.FlushState{LastError: f.err}
+}
+
+func (f flushFailingStore) ListBackups(context.Context) ([]store.BackupInfo, error) {
+	return nil, nil
+}
+
+func (f flushFailingStore) GetElvUIVersion(context.Context) (*store.ElvUIVersion, error) {
+	return nil, errors.New("not found")
+}
+
+type stubRIO struct{}
+
+func (stubRIO) FetchWeeklyRuns(context.Context, models.Character) (rioClient.ProfileResult, error) {
+	return rioClient.ProfileResult{}, nil
+}
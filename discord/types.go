@@ -2,6 +2,9 @@ package discord
 
 import (
 	"context"
+
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/raiderio"
 )
 
 // Discord defines the interface for the Discord client.
@@ -9,4 +12,17 @@ type Discord interface {
 	WriteMessage(channelNameOrID, msg string) error
 	Start(ctx context.Context) error
 	Stop()
+	Reload(cfg Config) error
 }
+
+// ReloadFunc reloads the bot's config and returns a summary of what changed.
+type ReloadFunc func(ctx context.Context) (string, error)
+
+// Jobs is the background job runner behind the !poll command.
+type Jobs interface {
+	Status() []jobs.Status
+	RunNow(name string) error
+}
+
+// ScheduleFunc returns the RaiderIO poller's per-character schedule.
+type ScheduleFunc func() []raiderio.ScheduledPoll
@@ -1,6 +1,12 @@
 package discord
 
-import "fmt"
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
 
 // Discord ANSI escape codes for code blocks
 const (
@@ -10,9 +16,45 @@ const (
 	ansiGray   = "\033[90m"
 )
 
-// VaultRewards is the active vault reward table.
-// Switch between VaultRewardsPrepatch and VaultRewardsSeason1 as needed.
-var VaultRewards = VaultRewardsPrepatch
+// DefaultVaultTable names the table used when vault_table is unset.
+const DefaultVaultTable = "prepatch"
+
+// VaultTables maps the names accepted by the vault_table setting to their
+// reward tables.
+var VaultTables = map[string]VaultRewardTable{
+	"prepatch": VaultRewardsPrepatch,
+	"season1":  VaultRewardsSeason1,
+}
+
+// VaultTableNames returns the names in VaultTables, sorted.
+func VaultTableNames() []string {
+	return slices.Sorted(maps.Keys(VaultTables))
+}
+
+var _vaultRewards atomic.Pointer[VaultRewardTable]
+
+func init() {
+	_vaultRewards.Store(&VaultRewardsPrepatch)
+}
+
+// VaultRewards returns the active vault reward table.
+func VaultRewards() VaultRewardTable {
+	return *_vaultRewards.Load()
+}
+
+// UseVaultTable makes the named table from VaultTables the active one. An
+// empty name selects DefaultVaultTable.
+func UseVaultTable(name string) error {
+	if name == "" {
+		name = DefaultVaultTable
+	}
+	table, ok := VaultTables[name]
+	if !ok {
+		return fmt.Errorf("unknown vault table %q, want one of %s", name, strings.Join(VaultTableNames(), ", "))
+	}
+	_vaultRewards.Store(&table)
+	return nil
+}
 
 // VaultRewardsPrepatch contains vault rewards for 12.0.0 prepatch.
 // M+ caps at +12 during prepatch with no additional rewards beyond.
@@ -1,9 +1,25 @@
 package discord
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
 )
 
+// progressFailingStore fails every vault progress read.
+type progressFailingStore struct {
+	store.Store
+}
+
+func (progressFailingStore) ListVaultProgressSince(context.Context, time.Time) ([]store.VaultRow, error) {
+	return nil, errors.New("database is locked")
+}
+
 func TestVaultRewardTable_GetThreshold(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -177,7 +193,7 @@ func TestVaultRewardTable_GetVaultSlotDisplay(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := VaultRewards.GetVaultSlotDisplay(tt.keyLevel)
+			got := VaultRewards().GetVaultSlotDisplay(tt.keyLevel)
 			if len(got) < tt.wantLen {
 				t.Errorf("GetVaultSlotDisplay(%d) = %q, want non-empty string", tt.keyLevel, got)
 			}
@@ -198,3 +214,54 @@ func TestEmptySlotDisplayColored(t *testing.T) {
 		t.Error("EmptySlotDisplayColored() returned empty string")
 	}
 }
+
+func TestUseVaultTable(t *testing.T) {
+	t.Cleanup(func() { _ = UseVaultTable("") })
+
+	if err := UseVaultTable("season1"); err != nil {
+		t.Fatalf("use season1: %v", err)
+	}
+	if got := VaultRewards().Season; got != VaultRewardsSeason1.Season {
+		t.Errorf("active table = %q, want %q", got, VaultRewardsSeason1.Season)
+	}
+
+	if err := UseVaultTable("season9"); err == nil {
+		t.Error("expected error for unknown table")
+	}
+	if got := VaultRewards().Season; got != VaultRewardsSeason1.Season {
+		t.Errorf("unknown table replaced the active one: %q", got)
+	}
+
+	if err := UseVaultTable(""); err != nil {
+		t.Fatalf("use default: %v", err)
+	}
+	if got := VaultRewards().Season; got != VaultRewardsPrepatch.Season {
+		t.Errorf("default table = %q, want %q", got, VaultRewardsPrepatch.Season)
+	}
+}
+
+func TestVaultReportFailsOnStoreError(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 2, 4, 18, 0, 0, 0, time.UTC)
+	sqlite := store.NewSQLiteStore(store.Params{})
+	if err := sqlite.Open(ctx); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlite.Close() })
+	if _, err := sqlite.AddCharacter(ctx, models.Character{Name: "arthas", Realm: "illidan", Region: "us"}); err != nil {
+		t.Fatalf("add character: %v", err)
+	}
+	st := progressFailingStore{sqlite}
+
+	if out, err := VaultReport(ctx, st, now); err == nil {
+		t.Fatalf("expected VaultReport to fail, got %q", out)
+	}
+
+	c := &DefaultDiscord{store: st, clock: clock.NewFake(now)}
+	if resp, err := c.cmdReport(ctx, nil); err == nil {
+		t.Fatalf("expected !report to fail rather than post empty progress, got %#v", resp)
+	}
+	if resp, err := c.cmdReport(ctx, []string{"arthas"}); err == nil {
+		t.Fatalf("expected !report arthas to fail, got %#v", resp)
+	}
+}
@@ -0,0 +1,231 @@
+package discord
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/raiderio"
+	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+	"github.com/tnicklin/celestial_orrey/store"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+// stubRaiderIO serves a fixed set of runs the way RaiderIO's weekly runs
+// do: only those completed since the last reset, as of the clock's time.
+type stubRaiderIO struct {
+	clock clock.Clock
+
+	mu   sync.Mutex
+	runs []models.CompletedKey
+}
+
+func (s *stubRaiderIO) FetchWeeklyRuns(_ context.Context, char models.Character) (rioClient.ProfileResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	reset := timeutil.WeeklyResetAt(now)
+	var keys []models.CompletedKey
+	for _, run := range s.runs {
+		at, _ := timeutil.ParseRFC3339(run.CompletedAt)
+		if run.Character == char.Name && !at.Before(reset) && !at.After(now) {
+			keys = append(keys, run)
+		}
+	}
+	return rioClient.ProfileResult{Keys: keys, RIOScore: 2500}, nil
+}
+
+// weekHarness runs the RaiderIO poller and the daily scheduler against a
+// fake clock, a real SQLite store and a stub RaiderIO, one minute at a time.
+type weekHarness struct {
+	t        *testing.T
+	clock    *clock.Fake
+	store    *store.SQLiteStore
+	rio      *stubRaiderIO
+	discord  *DefaultDiscord
+	runner   *jobs.Runner
+	lastPost time.Time
+}
+
+func newWeekHarness(t *testing.T, start time.Time, chars ...models.Character) *weekHarness {
+	t.Helper()
+	ctx := context.Background()
+
+	clk := clock.NewFake(start)
+	st := store.NewSQLiteStore(store.Params{BackupDir: t.TempDir(), Clock: clk})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	for _, char := range chars {
+		if _, err := st.AddCharacter(ctx, char); err != nil {
+			t.Fatalf("add character: %v", err)
+		}
+	}
+
+	rio := &stubRaiderIO{clock: clk}
+	poller := raiderio.New(raiderio.Params{
+		Config: raiderio.Config{PollInterval: time.Minute},
+		Client: rio,
+		Store:  st,
+		Clock:  clk,
+	})
+	runner := jobs.NewRunner(jobs.Params{Clock: clk})
+	// Without jitter, polls land on the minute the harness steps to.
+	job := poller.Job()
+	job.Jitter = -1
+	if err := runner.Add(job); err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	if err := runner.Start(ctx); err != nil {
+		t.Fatalf("start runner: %v", err)
+	}
+	t.Cleanup(runner.Stop)
+
+	h := &weekHarness{
+		t:     t,
+		clock: clk,
+		store: st,
+		rio:   rio,
+		discord: &DefaultDiscord{
+			settings: settings{listenChannel: "keys"},
+			store:    st,
+			outbox:   st,
+			logger:   logger.NewNop(),
+			clock:    clk,
+		},
+		runner: runner,
+	}
+	// Wait for the first poll to finish and schedule the next.
+	clk.BlockUntil(1)
+	return h
+}
+
+// complete adds a run that RaiderIO reports from its completion time on.
+func (h *weekHarness) complete(key models.CompletedKey) {
+	h.rio.mu.Lock()
+	h.rio.runs = append(h.rio.runs, key)
+	h.rio.mu.Unlock()
+}
+
+// runUntil steps the clock a minute at a time until end, letting each due
+// poll finish before the scheduler's tick.
+func (h *weekHarness) runUntil(end time.Time) {
+	for h.clock.Now().Before(end) {
+		h.clock.Advance(time.Minute)
+		h.clock.BlockUntil(1)
+		h.lastPost = h.discord.scheduleTick(h.clock.Now(), h.lastPost)
+	}
+}
+
+func (h *weekHarness) keysSince(cutoff time.Time) []models.CompletedKey {
+	h.t.Helper()
+	keys, err := h.store.ListKeysSince(context.Background(), cutoff)
+	if err != nil {
+		h.t.Fatalf("list keys: %v", err)
+	}
+	return keys
+}
+
+func TestWeekRollover(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 2, day, hour, minute, 0, 0, _pstLocation)
+	}
+	run := func(id int64, dungeon string, level int, completed time.Time) models.CompletedKey {
+		return models.CompletedKey{
+			KeyID:       id,
+			Character:   "arthas",
+			Region:      "us",
+			Realm:       "illidan",
+			Dungeon:     dungeon,
+			KeyLevel:    level,
+			CompletedAt: completed.UTC().Format(time.RFC3339),
+			Source:      models.SourceRaiderIO,
+		}
+	}
+	arthas := models.Character{Name: "arthas", Realm: "illidan", Region: "us"}
+	ctx := context.Background()
+
+	// Monday noon, the day before the Feb 3 reset.
+	h := newWeekHarness(t, at(2, 12, 0), arthas)
+	h.complete(run(1, "Ara-Kara, City of Echoes", 10, at(2, 20, 0)))
+	// Overnight, when idle characters are polled least; the refresh before
+	// the reset must still see it.
+	h.complete(run(2, "The Dawnbreaker", 12, at(3, 5, 0)))
+	h.complete(run(3, "Priory of the Sacred Flame", 11, at(3, 19, 0)))
+	h.complete(run(4, "Operation: Floodgate", 14, at(5, 21, 0)))
+
+	h.runUntil(at(3, 6, 59))
+	if got := h.keysSince(timeutil.WeeklyReset(h.clock)); len(got) != 2 {
+		t.Fatalf("before the reset: expected 2 keys this week, got %d", len(got))
+	}
+	backups, err := h.store.ListBackups(ctx)
+	if err != nil || len(backups) != 0 {
+		t.Fatalf("before the reset: backups = %d, %v", len(backups), err)
+	}
+
+	h.runUntil(at(4, 12, 0))
+	if got := h.keysSince(timeutil.WeeklyReset(h.clock)); len(got) != 1 || got[0].KeyID != 3 {
+		t.Fatalf("after the reset: expected only key 3 this week, got %+v", got)
+	}
+	backups, err = h.store.ListBackups(ctx)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("after the reset: backups = %d, %v", len(backups), err)
+	}
+	history, err := h.store.ListWeeklyHistory(ctx, "arthas", "illidan", "us", 5)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("after the reset: history = %+v, %v", history, err)
+	}
+	if week := history[0]; !week.WeekStart.Equal(at(3, 7, 0).AddDate(0, 0, -7)) || week.KeyCount != 2 || week.BestKeyLevel != 12 {
+		t.Errorf("archived week = %+v", week)
+	}
+
+	// Through the next reset.
+	h.runUntil(at(10, 7, 30))
+	if got := h.keysSince(time.Time{}); len(got) != 4 {
+		t.Fatalf("expected all 4 keys stored, got %d", len(got))
+	}
+	if got := h.keysSince(timeutil.WeeklyReset(h.clock)); len(got) != 0 {
+		t.Errorf("expected no keys in the new week, got %d", len(got))
+	}
+	backups, err = h.store.ListBackups(ctx)
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("backups = %d, %v", len(backups), err)
+	}
+	history, err = h.store.ListWeeklyHistory(ctx, "arthas", "illidan", "us", 5)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+	if week := history[0]; !week.WeekStart.Equal(at(3, 7, 0)) || week.KeyCount != 2 || week.BestKeyLevel != 14 {
+		t.Errorf("second archived week = %+v", week)
+	}
+
+	msgs, err := h.store.DueMessages(ctx, h.clock.Now(), 100)
+	if err != nil {
+		t.Fatalf("due messages: %v", err)
+	}
+	var posted []string
+	for _, msg := range msgs {
+		posted = append(posted, msg.DedupKey)
+	}
+	want := []string{
+		"reset:2026-02-03",
+		"report:2026-02-04",
+		"report:2026-02-05",
+		"report:2026-02-06",
+		"report:2026-02-07",
+		"report:2026-02-08",
+		"report:2026-02-09",
+		"reset:2026-02-10",
+	}
+	if !slices.Equal(posted, want) {
+		t.Errorf("posted %q, want %q", posted, want)
+	}
+}
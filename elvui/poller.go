@@ -3,9 +3,11 @@ package elvui
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/tnicklin/celestial_orrey/jobs"
 	"github.com/tnicklin/celestial_orrey/store"
 )
 
@@ -15,10 +17,14 @@ var _ Poller = (*DefaultPoller)(nil)
 type DefaultPoller struct {
 	client       *Client
 	store        store.Store
-	interval     time.Duration
+	outbox       store.Outbox
+	announce     AnnounceFunc
 	onNewVersion NotifyFunc
-	stop         chan struct{}
-	done         chan struct{}
+
+	interval time.Duration
+	// polled is set once a version has been recorded. The job runner never
+	// overlaps runs, so it needs no lock.
+	polled bool
 }
 
 // Params holds configuration for creating a new ElvUI Poller.
@@ -27,6 +33,11 @@ type Params struct {
 	Store        store.Store
 	HTTPClient   *http.Client
 	OnNewVersion NotifyFunc
+	// Outbox and Announce, when both set, replace OnNewVersion: the
+	// announcement is queued in the same transaction that records the
+	// version, so it is posted exactly once.
+	Outbox   store.Outbox
+	Announce AnnounceFunc
 }
 
 // New creates a new ElvUI poller.
@@ -41,69 +52,68 @@ func New(p Params) *DefaultPoller {
 	return &DefaultPoller{
 		client:       NewClient(p.Config.APIURL, httpClient),
 		store:        p.Store,
+		outbox:       p.Outbox,
+		announce:     p.Announce,
 		interval:     p.Config.PollInterval,
 		onNewVersion: p.OnNewVersion,
 	}
 }
 
-// Start begins the polling loop.
-func (p *DefaultPoller) Start(ctx context.Context) error {
-	if p.store == nil {
-		return errors.New("elvui: store is required")
-	}
-
-	p.stop = make(chan struct{})
-	p.done = make(chan struct{})
-
-	go p.run(ctx)
-	return nil
-}
+// JobName names the poller's job in the job runner.
+const JobName = "elvui"
 
-// Stop stops the polling loop.
-func (p *DefaultPoller) Stop() {
-	if p.stop != nil {
-		close(p.stop)
-		<-p.done
+// Job returns the poller's job, which records the latest ElvUI version and
+// announces it when it changes.
+func (p *DefaultPoller) Job() jobs.Job {
+	return jobs.Job{
+		Name:     JobName,
+		Interval: p.interval,
+		Run:      p.pollOnce,
 	}
 }
 
-func (p *DefaultPoller) run(ctx context.Context) {
-	defer close(p.done)
-
-	p.pollOnce(ctx, true)
-
-	ticker := time.NewTicker(p.interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-p.stop:
-			return
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			p.pollOnce(ctx, false)
-		}
+// pollOnce fetches the current version. The first successful poll after
+// startup only records it, so a restart never repeats an announcement.
+func (p *DefaultPoller) pollOnce(ctx context.Context) error {
+	if p.store == nil {
+		return errors.New("elvui: store is required")
 	}
-}
 
-func (p *DefaultPoller) pollOnce(ctx context.Context, isInitial bool) {
 	info, err := p.client.FetchVersion(ctx)
 	if err != nil {
-		return
+		return err
 	}
+	isInitial := !p.polled
 
-	current, err := p.store.GetElvUIVersion(ctx)
-	isNew := err != nil || current.Version != info.Version
-
-	_ = p.store.UpsertElvUIVersion(ctx, store.ElvUIVersion{
+	version := store.ElvUIVersion{
 		Version:      info.Version,
 		DownloadURL:  info.URL,
 		ChangelogURL: info.ChangelogURL,
 		LastUpdate:   info.LastUpdate,
-	})
+	}
+
+	if p.outbox != nil && p.announce != nil {
+		var msg store.OutboxMessage
+		if !isInitial {
+			msg = p.announce(*info)
+		}
+		if _, err := p.outbox.RecordElvUIVersion(ctx, version, msg); err != nil {
+			return fmt.Errorf("record version: %w", err)
+		}
+		p.polled = true
+		return nil
+	}
+
+	current, err := p.store.GetElvUIVersion(ctx)
+	isNew := err != nil || current.Version != info.Version
+
+	if err := p.store.UpsertElvUIVersion(ctx, version); err != nil {
+		return fmt.Errorf("store version: %w", err)
+	}
+	p.polled = true
 
 	if isNew && !isInitial && p.onNewVersion != nil {
 		p.onNewVersion(*info)
 	}
+	return nil
 }
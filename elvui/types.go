@@ -1,11 +1,13 @@
 package elvui
 
-import "context"
+import (
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/store"
+)
 
 // Poller defines the interface for polling ElvUI version updates.
 type Poller interface {
-	Start(ctx context.Context) error
-	Stop()
+	Job() jobs.Job
 }
 
 // VersionInfo holds the ElvUI version information from the TukUI API.
@@ -20,3 +22,6 @@ type VersionInfo struct {
 
 // NotifyFunc is called when a new ElvUI version is detected.
 type NotifyFunc func(VersionInfo)
+
+// AnnounceFunc builds the outbox message announcing a new ElvUI version.
+type AnnounceFunc func(VersionInfo) store.OutboxMessage
@@ -0,0 +1,282 @@
+// Package export writes the bot's data out as CSV or JSON files.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// _historyWeeks bounds how many weeks of score history are read per
+// character.
+const _historyWeeks = 520
+
+// ParseFormat returns the format named by s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatCSV, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q", s)
+	}
+}
+
+// Range is the time span an export covers. A zero Start or End leaves that
+// side open.
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Week returns the weekly reset period containing now.
+func Week(now time.Time) Range {
+	start := timeutil.WeeklyResetAt(now)
+	return Range{Start: start, End: start.AddDate(0, 0, 7)}
+}
+
+// Season returns the range from seasonStart up to now. A zero seasonStart
+// covers everything recorded.
+func Season(now, seasonStart time.Time) Range {
+	return Range{Start: seasonStart, End: now}
+}
+
+// Contains reports whether t falls within r.
+func (r Range) Contains(t time.Time) bool {
+	if !r.Start.IsZero() && t.Before(r.Start) {
+		return false
+	}
+	if !r.End.IsZero() && !t.Before(r.End) {
+		return false
+	}
+	return true
+}
+
+// Score is a character's RaiderIO score at the end of a recorded week.
+type Score struct {
+	WeekStart    time.Time `json:"week_start"`
+	Name         string    `json:"name"`
+	Realm        string    `json:"realm"`
+	Region       string    `json:"region"`
+	RIOScore     float64   `json:"rio_score"`
+	KeyCount     int64     `json:"key_count"`
+	BestKeyLevel int       `json:"best_key_lvl"`
+}
+
+// Link is a WarcraftLogs link attached to an exported key.
+type Link struct {
+	KeyID      int64  `json:"key_id"`
+	ReportCode string `json:"report_code"`
+	FightID    *int64 `json:"fight_id,omitempty"`
+	PullID     *int64 `json:"pull_id,omitempty"`
+	URL        string `json:"url"`
+	InsertedAt string `json:"inserted_at"`
+}
+
+// Data is everything exported for a range.
+type Data struct {
+	Start      time.Time             `json:"start,omitzero"`
+	End        time.Time             `json:"end,omitzero"`
+	Characters []models.Character    `json:"characters"`
+	Keys       []models.CompletedKey `json:"keys"`
+	Links      []Link                `json:"links"`
+	Scores     []Score               `json:"scores"`
+}
+
+// File is a named export file.
+type File struct {
+	Name string
+	Body []byte
+}
+
+// Collect reads the characters, keys completed within r with their links,
+// and the score history recorded for weeks starting within r.
+func Collect(ctx context.Context, st store.Store, r Range) (*Data, error) {
+	data := &Data{Start: r.Start, End: r.End}
+
+	chars, err := st.ListCharacters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list characters: %w", err)
+	}
+	sort.Slice(chars, func(i, j int) bool {
+		return chars[i].Key() < chars[j].Key()
+	})
+	data.Characters = chars
+
+	keys, err := st.ListKeysSince(ctx, r.Start.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list keys: %w", err)
+	}
+	for _, key := range keys {
+		completed, err := timeutil.ParseRFC3339(key.CompletedAt)
+		if err != nil || !r.Contains(completed) {
+			continue
+		}
+		data.Keys = append(data.Keys, key)
+	}
+	sort.SliceStable(data.Keys, func(i, j int) bool {
+		return data.Keys[i].CompletedAt < data.Keys[j].CompletedAt
+	})
+
+	seen := make(map[int64]struct{})
+	for _, key := range data.Keys {
+		if _, ok := seen[key.KeyID]; ok {
+			continue
+		}
+		seen[key.KeyID] = struct{}{}
+
+		links, err := st.ListWarcraftLogsLinksForKey(ctx, key.KeyID)
+		if err != nil {
+			return nil, fmt.Errorf("list links for key %d: %w", key.KeyID, err)
+		}
+		for _, link := range links {
+			data.Links = append(data.Links, Link(link))
+		}
+	}
+
+	for _, char := range chars {
+		weeks, err := st.ListWeeklyHistory(ctx, char.Name, char.Realm, char.Region, _historyWeeks)
+		if err != nil {
+			return nil, fmt.Errorf("list history for %s: %w", char.Key(), err)
+		}
+		for i := len(weeks) - 1; i >= 0; i-- {
+			w := weeks[i]
+			if !r.Contains(w.WeekStart) {
+				continue
+			}
+			data.Scores = append(data.Scores, Score{
+				WeekStart:    w.WeekStart,
+				Name:         w.Character.Name,
+				Realm:        w.Character.Realm,
+				Region:       w.Character.Region,
+				RIOScore:     w.Character.RIOScore,
+				KeyCount:     w.KeyCount,
+				BestKeyLevel: w.BestKeyLevel,
+			})
+		}
+	}
+
+	return data, nil
+}
+
+// Files encodes d in format f: a single JSON document, or one CSV file per
+// table.
+func (d *Data) Files(f Format) ([]File, error) {
+	base := d.baseName()
+
+	switch f {
+	case FormatJSON:
+		body, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return []File{{Name: base + ".json", Body: body}}, nil
+	case FormatCSV:
+		tables := []struct {
+			name string
+			rows [][]string
+		}{
+			{name: "characters", rows: d.characterRows()},
+			{name: "keys", rows: d.keyRows()},
+			{name: "links", rows: d.linkRows()},
+			{name: "scores", rows: d.scoreRows()},
+		}
+
+		files := make([]File, 0, len(tables))
+		for _, t := range tables {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			if err := w.WriteAll(t.rows); err != nil {
+				return nil, err
+			}
+			files = append(files, File{Name: base + "_" + t.name + ".csv", Body: buf.Bytes()})
+		}
+		return files, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", f)
+	}
+}
+
+// baseName names export files after the range they cover.
+func (d *Data) baseName() string {
+	day := func(t time.Time, open string) string {
+		if t.IsZero() {
+			return open
+		}
+		return t.Format(time.DateOnly)
+	}
+	return fmt.Sprintf("celestial_orrey_%s_%s", day(d.Start, "start"), day(d.End, "now"))
+}
+
+func (d *Data) characterRows() [][]string {
+	rows := [][]string{{"name", "realm", "region", "rio_score"}}
+	for _, c := range d.Characters {
+		rows = append(rows, []string{c.Name, c.Realm, c.Region, formatFloat(c.RIOScore)})
+	}
+	return rows
+}
+
+func (d *Data) keyRows() [][]string {
+	rows := [][]string{{
+		"key_id", "character", "realm", "region", "dungeon", "key_lvl",
+		"run_time_ms", "par_time_ms", "completed_at", "source",
+	}}
+	for _, k := range d.Keys {
+		rows = append(rows, []string{
+			strconv.FormatInt(k.KeyID, 10), k.Character, k.Realm, k.Region, k.Dungeon,
+			strconv.Itoa(k.KeyLevel), strconv.FormatInt(k.RunTimeMS, 10),
+			strconv.FormatInt(k.ParTimeMS, 10), k.CompletedAt, k.Source,
+		})
+	}
+	return rows
+}
+
+func (d *Data) linkRows() [][]string {
+	rows := [][]string{{"key_id", "report_code", "fight_id", "pull_id", "url", "inserted_at"}}
+	for _, l := range d.Links {
+		rows = append(rows, []string{
+			strconv.FormatInt(l.KeyID, 10), l.ReportCode, formatOptional(l.FightID),
+			formatOptional(l.PullID), l.URL, l.InsertedAt,
+		})
+	}
+	return rows
+}
+
+func (d *Data) scoreRows() [][]string {
+	rows := [][]string{{"week_start", "name", "realm", "region", "rio_score", "key_count", "best_key_lvl"}}
+	for _, s := range d.Scores {
+		rows = append(rows, []string{
+			s.WeekStart.Format(time.RFC3339), s.Name, s.Realm, s.Region,
+			formatFloat(s.RIOScore), strconv.FormatInt(s.KeyCount, 10), strconv.Itoa(s.BestKeyLevel),
+		})
+	}
+	return rows
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatOptional(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+// fakeStore serves the reads Collect makes; other methods are not used.
+type fakeStore struct {
+	store.Store
+	chars   []models.Character
+	keys    []models.CompletedKey
+	links   map[int64][]store.WarcraftLogsLink
+	history []store.WeekSummary
+}
+
+func (f *fakeStore) ListCharacters(context.Context) ([]models.Character, error) {
+	return f.chars, nil
+}
+
+func (f *fakeStore) ListKeysSince(_ context.Context, cutoff time.Time) ([]models.CompletedKey, error) {
+	var out []models.CompletedKey
+	for _, k := range f.keys {
+		if k.CompletedAt > cutoff.Format(time.RFC3339) {
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) ListWarcraftLogsLinksForKey(_ context.Context, keyID int64) ([]store.WarcraftLogsLink, error) {
+	return f.links[keyID], nil
+}
+
+func (f *fakeStore) ListWeeklyHistory(_ context.Context, name, _, _ string, _ int) ([]store.WeekSummary, error) {
+	var out []store.WeekSummary
+	for _, w := range f.history {
+		if w.Character.Name == name {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+func TestCollectAndEncode(t *testing.T) {
+	// Wednesday after the 2026-02-03 reset.
+	now := time.Date(2026, 2, 4, 12, 0, 0, 0, time.UTC)
+	arthas := models.Character{Name: "arthas", Realm: "illidan", Region: "us", RIOScore: 2500}
+	fightID := int64(7)
+
+	st := &fakeStore{
+		chars: []models.Character{arthas},
+		keys: []models.CompletedKey{
+			{KeyID: 1, Character: "arthas", Realm: "illidan", Region: "us", Dungeon: "Priory", KeyLevel: 10, CompletedAt: "2026-02-04T01:00:00Z", Source: "raiderio"},
+			{KeyID: 2, Character: "arthas", Realm: "illidan", Region: "us", Dungeon: "Priory", KeyLevel: 9, CompletedAt: "2026-01-30T01:00:00Z", Source: "raiderio"},
+		},
+		links: map[int64][]store.WarcraftLogsLink{
+			1: {{KeyID: 1, ReportCode: "ABC", FightID: &fightID, URL: "https://example.com/ABC"}},
+		},
+		history: []store.WeekSummary{
+			{WeekStart: time.Date(2026, 1, 27, 15, 0, 0, 0, time.UTC), Character: arthas, KeyCount: 8, BestKeyLevel: 12},
+		},
+	}
+
+	week, err := Collect(context.Background(), st, Week(now))
+	if err != nil {
+		t.Fatalf("collect week: %v", err)
+	}
+	if len(week.Keys) != 1 || week.Keys[0].KeyID != 1 || len(week.Links) != 1 || len(week.Scores) != 0 {
+		t.Fatalf("unexpected week export: %d keys, %d links, %d scores", len(week.Keys), len(week.Links), len(week.Scores))
+	}
+
+	season, err := Collect(context.Background(), st, Season(now, time.Time{}))
+	if err != nil {
+		t.Fatalf("collect season: %v", err)
+	}
+	if len(season.Keys) != 2 || season.Keys[0].KeyID != 2 || len(season.Scores) != 1 {
+		t.Fatalf("unexpected season export: %d keys, %d scores", len(season.Keys), len(season.Scores))
+	}
+
+	files, err := week.Files(FormatCSV)
+	if err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if len(files) != 4 || files[1].Name != "celestial_orrey_2026-02-03_2026-02-10_keys.csv" {
+		t.Fatalf("unexpected csv files %v", files)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(files[2].Body)).ReadAll()
+	if err != nil || len(rows) != 2 || rows[1][1] != "ABC" || rows[1][2] != "7" || rows[1][3] != "" {
+		t.Fatalf("unexpected links csv %v (err %v)", rows, err)
+	}
+
+	files, err = season.Files(FormatJSON)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded Data
+	if err := json.Unmarshal(files[0].Body, &decoded); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if len(decoded.Keys) != 2 || decoded.Scores[0].BestKeyLevel != 12 || !decoded.Start.IsZero() {
+		t.Fatalf("unexpected decoded export %#v", decoded)
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	r := Range{
+		Start: time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{t: r.Start, want: true},
+		{t: r.End, want: false},
+		{t: r.Start.Add(-time.Second), want: false},
+		{t: r.End.Add(-time.Second), want: true},
+	}
+	for _, tt := range tests {
+		if got := r.Contains(tt.t); got != tt.want {
+			t.Errorf("Contains(%v) = %v; want %v", tt.t, got, tt.want)
+		}
+	}
+	if !(Range{}).Contains(time.Time{}) {
+		t.Error("expected open range to contain everything")
+	}
+}
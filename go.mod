@@ -5,11 +5,14 @@ go 1.25
 require (
 	github.com/beevik/ntp v1.5.0
 	github.com/bwmarrin/discordgo v0.29.0
+	github.com/klauspost/compress v1.17.11
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.33
 	go.uber.org/atomic v1.11.0
 	go.uber.org/config v1.4.0
 	go.uber.org/fx v1.24.0
 	go.uber.org/zap v1.27.1
+	gopkg.in/yaml.v2 v2.2.5
 )
 
 require (
@@ -22,5 +25,4 @@ require (
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
 	golang.org/x/tools v0.36.0 // indirect
-	gopkg.in/yaml.v2 v2.2.5 // indirect
 )
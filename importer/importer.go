@@ -0,0 +1,466 @@
+// Package importer loads a roster and historical keys from CSV or JSON
+// files, such as those written by the export package.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+	"github.com/tnicklin/celestial_orrey/store"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+const _defaultRegion = "us"
+
+// Row kinds reported in rejections.
+const (
+	KindCharacter = "character"
+	KindKey       = "key"
+)
+
+// Rejection is a row that was not imported.
+type Rejection struct {
+	// Row is the CSV line, or the 1-based position in the JSON list.
+	Row    int
+	Kind   string
+	Value  string
+	Reason string
+}
+
+// Summary describes the outcome of an import.
+type Summary struct {
+	// Characters counts newly tracked characters; KnownCharacters counts
+	// rows for characters that were already tracked.
+	Characters      int
+	KnownCharacters int
+	// Keys counts stored keys that were new or changed; KnownKeys counts
+	// keys already stored as given, and DuplicateKeys rows repeating an
+	// earlier key in the same file.
+	Keys          int
+	KnownKeys     int
+	DuplicateKeys int
+	Rejected      []Rejection
+}
+
+// Accepted returns how many rows were imported or already present.
+func (s Summary) Accepted() int {
+	return s.Characters + s.KnownCharacters + s.Keys + s.KnownKeys + s.DuplicateKeys
+}
+
+// Params holds the dependencies of an Importer.
+type Params struct {
+	Store store.Store
+	// RaiderIO confirms each character exists before it is tracked, and
+	// that keys claiming an API source are in the character's profile.
+	RaiderIO rioClient.Client
+	// MaxRows and MaxCharacters bound a file's rows and the distinct
+	// characters it names, each of which costs a RaiderIO lookup. Files
+	// over either limit are refused. Zero means no limit.
+	MaxRows       int
+	MaxCharacters int
+}
+
+// Importer validates and stores imported rows.
+type Importer struct {
+	store         store.Store
+	raiderIO      rioClient.Client
+	maxRows       int
+	maxCharacters int
+}
+
+func New(p Params) *Importer {
+	return &Importer{
+		store:         p.Store,
+		raiderIO:      p.RaiderIO,
+		maxRows:       p.MaxRows,
+		maxCharacters: p.MaxCharacters,
+	}
+}
+
+type characterRow struct {
+	row  int
+	char models.Character
+}
+
+type keyRow struct {
+	row int
+	key models.CompletedKey
+}
+
+// Import reads the file called name and stores what it holds. A file that
+// cannot be parsed at all is an error; individual bad rows are rejected in
+// the summary.
+func (im *Importer) Import(ctx context.Context, name string, body []byte) (Summary, error) {
+	if im.store == nil || im.raiderIO == nil {
+		return Summary{}, errors.New("importer: store and raiderio client are required")
+	}
+
+	chars, keys, rejected, err := parse(name, body)
+	if err != nil {
+		return Summary{}, err
+	}
+	if err := im.checkLimits(chars, keys, rejected); err != nil {
+		return Summary{}, err
+	}
+	summary := Summary{Rejected: rejected}
+
+	profiles := make(map[string]*rioClient.ProfileResult)
+	failures := make(map[string]string)
+	validate := func(char models.Character) string {
+		id := char.Key()
+		if _, ok := profiles[id]; ok {
+			return ""
+		}
+		if reason, ok := failures[id]; ok {
+			return reason
+		}
+		result, err := im.raiderIO.FetchWeeklyRuns(ctx, char)
+		if err != nil {
+			failures[id] = fmt.Sprintf("not found on RaiderIO: %v", err)
+			return failures[id]
+		}
+		profiles[id] = &result
+		return ""
+	}
+
+	for _, r := range chars {
+		if reason := validate(r.char); reason != "" {
+			summary.reject(r.row, KindCharacter, characterLabel(r.char), reason)
+			continue
+		}
+		r.char.RIOScore = profiles[r.char.Key()].RIOScore
+		added, err := im.store.AddCharacter(ctx, r.char)
+		if err != nil {
+			return summary, fmt.Errorf("add %s: %w", characterLabel(r.char), err)
+		}
+		if added {
+			summary.Characters++
+		} else {
+			summary.KnownCharacters++
+		}
+	}
+
+	seen := make(map[string]struct{})
+	var (
+		batch   []models.CompletedKey
+		touched = make(map[string]models.Character)
+	)
+	for _, r := range keys {
+		char := models.Character{Name: r.key.Character, Realm: r.key.Realm, Region: r.key.Region}
+		if reason := validate(char); reason != "" {
+			summary.reject(r.row, KindKey, keyLabel(r.key), reason)
+			continue
+		}
+		if r.key.Source != models.SourceManual && !inProfile(r.key, profiles[char.Key()]) {
+			summary.reject(r.row, KindKey, keyLabel(r.key), fmt.Sprintf(
+				"%s key is not in the character's profile; leave source empty to import it as manual", r.key.Source))
+			continue
+		}
+		id := r.key.KeyIDOrSynthetic()
+		if _, ok := seen[id]; ok {
+			summary.DuplicateKeys++
+			continue
+		}
+		seen[id] = struct{}{}
+		batch = append(batch, r.key)
+		touched[char.Key()] = char
+	}
+
+	// Imported keys are reconciled like polled ones, so a manual row for a
+	// run an API already reported is dropped rather than counted twice.
+	statuses, err := im.store.ReconcileCompletedKeys(ctx, batch)
+	if err != nil {
+		return summary, fmt.Errorf("store keys: %w", err)
+	}
+	for _, status := range statuses {
+		if status == store.UpsertUnchanged {
+			summary.KnownKeys++
+		} else {
+			summary.Keys++
+		}
+	}
+
+	// Characters first seen through their keys pick up their score too.
+	for id, char := range touched {
+		_ = im.store.UpdateCharacterScore(ctx, char.Name, char.Realm, char.Region, profiles[id].RIOScore)
+	}
+
+	return summary, nil
+}
+
+// checkLimits refuses files with more rows or distinct characters than the
+// importer allows.
+func (im *Importer) checkLimits(chars []characterRow, keys []keyRow, rejected []Rejection) error {
+	if rows := len(chars) + len(keys) + len(rejected); im.maxRows > 0 && rows > im.maxRows {
+		return fmt.Errorf("file has %d rows; the limit is %d", rows, im.maxRows)
+	}
+	if im.maxCharacters <= 0 {
+		return nil
+	}
+	distinct := make(map[string]struct{})
+	for _, r := range chars {
+		distinct[r.char.Key()] = struct{}{}
+	}
+	for _, r := range keys {
+		distinct[models.Character{Name: r.key.Character, Realm: r.key.Realm, Region: r.key.Region}.Key()] = struct{}{}
+	}
+	if len(distinct) > im.maxCharacters {
+		return fmt.Errorf("file names %d characters; the limit is %d", len(distinct), im.maxCharacters)
+	}
+	return nil
+}
+
+// inProfile reports whether profile holds key from the same source, which
+// is how keys claiming an API source are verified.
+func inProfile(key models.CompletedKey, profile *rioClient.ProfileResult) bool {
+	if profile == nil {
+		return false
+	}
+	id := key.EffectiveID()
+	for _, k := range profile.Keys {
+		if k.EffectiveID() == id && strings.EqualFold(k.Source, key.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Summary) reject(row int, kind, value, reason string) {
+	s.Rejected = append(s.Rejected, Rejection{Row: row, Kind: kind, Value: value, Reason: reason})
+}
+
+// parse splits a file into character and key rows, rejecting rows that are
+// malformed on their own.
+func parse(name string, body []byte) ([]characterRow, []keyRow, []Rejection, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, nil, nil, errors.New("file is empty")
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return parseJSON(trimmed)
+	case ".csv":
+		return parseCSV(trimmed)
+	}
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		return parseJSON(trimmed)
+	}
+	return parseCSV(trimmed)
+}
+
+// jsonFile is the layout written by the export package. A bare list is
+// read as characters.
+type jsonFile struct {
+	Characters []models.Character    `json:"characters"`
+	Keys       []models.CompletedKey `json:"keys"`
+}
+
+func parseJSON(body []byte) ([]characterRow, []keyRow, []Rejection, error) {
+	var file jsonFile
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &file.Characters); err != nil {
+			return nil, nil, nil, fmt.Errorf("decode json: %w", err)
+		}
+	} else if err := json.Unmarshal(body, &file); err != nil {
+		return nil, nil, nil, fmt.Errorf("decode json: %w", err)
+	}
+
+	var (
+		chars    []characterRow
+		keys     []keyRow
+		rejected []Rejection
+	)
+	for i, c := range file.Characters {
+		char, reason := checkCharacter(c)
+		if reason != "" {
+			rejected = append(rejected, Rejection{Row: i + 1, Kind: KindCharacter, Value: characterLabel(c), Reason: reason})
+			continue
+		}
+		chars = append(chars, characterRow{row: i + 1, char: char})
+	}
+	for i, k := range file.Keys {
+		key, reason := checkKey(k)
+		if reason != "" {
+			rejected = append(rejected, Rejection{Row: i + 1, Kind: KindKey, Value: keyLabel(k), Reason: reason})
+			continue
+		}
+		keys = append(keys, keyRow{row: i + 1, key: key})
+	}
+	return chars, keys, rejected, nil
+}
+
+// parseCSV reads a headed CSV file. Files with a dungeon column hold keys;
+// any other file is a roster of name, realm and optional region.
+func parseCSV(body []byte) ([]characterRow, []keyRow, []Rejection, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read csv header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	field := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := cols[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+
+	_, isKeys := cols["dungeon"]
+	if _, ok := cols["name"]; !ok && !isKeys {
+		return nil, nil, nil, errors.New("csv header needs a name column")
+	}
+
+	var (
+		chars    []characterRow
+		keys     []keyRow
+		rejected []Rejection
+	)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, nil, fmt.Errorf("read csv: %w", err)
+			}
+			rejected = append(rejected, Rejection{Row: parseErr.Line, Reason: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+
+		if !isKeys {
+			c := models.Character{
+				Name:   field(record, "name"),
+				Realm:  field(record, "realm"),
+				Region: field(record, "region"),
+			}
+			char, reason := checkCharacter(c)
+			if reason != "" {
+				rejected = append(rejected, Rejection{Row: line, Kind: KindCharacter, Value: characterLabel(c), Reason: reason})
+				continue
+			}
+			chars = append(chars, characterRow{row: line, char: char})
+			continue
+		}
+
+		k := models.CompletedKey{
+			Character:   field(record, "character", "name"),
+			Realm:       field(record, "realm"),
+			Region:      field(record, "region"),
+			Dungeon:     field(record, "dungeon"),
+			CompletedAt: field(record, "completed_at"),
+			Source:      field(record, "source"),
+		}
+		var reason string
+		k.KeyID, reason = parseInt(field(record, "key_id"), "key_id")
+		if reason == "" {
+			var level int64
+			level, reason = parseInt(field(record, "key_lvl", "level"), "key_lvl")
+			k.KeyLevel = int(level)
+		}
+		if reason == "" {
+			k.RunTimeMS, reason = parseInt(field(record, "run_time_ms"), "run_time_ms")
+		}
+		if reason == "" {
+			k.ParTimeMS, reason = parseInt(field(record, "par_time_ms"), "par_time_ms")
+		}
+		if reason == "" {
+			k, reason = checkKey(k)
+		}
+		if reason != "" {
+			rejected = append(rejected, Rejection{Row: line, Kind: KindKey, Value: keyLabel(k), Reason: reason})
+			continue
+		}
+		keys = append(keys, keyRow{row: line, key: k})
+	}
+	return chars, keys, rejected, nil
+}
+
+// checkCharacter normalizes c, or returns why it cannot be imported.
+func checkCharacter(c models.Character) (models.Character, string) {
+	c.Name = strings.ToLower(strings.TrimSpace(c.Name))
+	c.Realm = strings.ToLower(strings.TrimSpace(c.Realm))
+	c.Region = strings.ToLower(strings.TrimSpace(c.Region))
+	if c.Region == "" {
+		c.Region = _defaultRegion
+	}
+	if c.Name == "" || c.Realm == "" {
+		return c, "name and realm are required"
+	}
+	return c, ""
+}
+
+// checkKey normalizes k, or returns why it cannot be imported. Keys without
+// a source are recorded as manual entries.
+func checkKey(k models.CompletedKey) (models.CompletedKey, string) {
+	char, reason := checkCharacter(models.Character{Name: k.Character, Realm: k.Realm, Region: k.Region})
+	if reason != "" {
+		return k, reason
+	}
+	k.Character, k.Realm, k.Region = char.Name, char.Realm, char.Region
+
+	switch {
+	case strings.TrimSpace(k.Dungeon) == "":
+		return k, "dungeon is required"
+	case k.KeyLevel <= 0:
+		return k, "key_lvl must be positive"
+	case k.KeyID < 0 || k.RunTimeMS < 0 || k.ParTimeMS < 0:
+		return k, "key_id and times cannot be negative"
+	}
+	// CompletedAt is kept as given: it feeds the synthetic key ID, so
+	// reformatting it would stop re-imports from matching stored keys.
+	if _, err := timeutil.ParseRFC3339(k.CompletedAt); err != nil {
+		return k, "completed_at must be an RFC 3339 time"
+	}
+
+	if k.Source == "" {
+		k.Source = models.SourceManual
+	}
+	k.Source = strings.ToLower(k.Source)
+	switch k.Source {
+	case models.SourceRaiderIO, models.SourceBlizzard, models.SourceWarcraftLogs, models.SourceManual:
+	default:
+		return k, fmt.Sprintf("unknown source %q", k.Source)
+	}
+	return k, ""
+}
+
+func parseInt(value, column string) (int64, string) {
+	if value == "" {
+		return 0, ""
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, column + " must be a whole number"
+	}
+	return n, ""
+}
+
+func characterLabel(c models.Character) string {
+	return fmt.Sprintf("%s-%s", c.Name, c.Realm)
+}
+
+func keyLabel(k models.CompletedKey) string {
+	return fmt.Sprintf("%s-%s %s +%d", k.Character, k.Realm, k.Dungeon, k.KeyLevel)
+}
@@ -0,0 +1,249 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+// fakeStore records the writes an import makes; other methods are not used.
+type fakeStore struct {
+	store.Store
+	chars  map[string]models.Character
+	keys   map[int64]models.CompletedKey
+	scores map[string]float64
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		chars:  make(map[string]models.Character),
+		keys:   make(map[int64]models.CompletedKey),
+		scores: make(map[string]float64),
+	}
+}
+
+func (f *fakeStore) AddCharacter(_ context.Context, char models.Character) (bool, error) {
+	if _, ok := f.chars[char.Key()]; ok {
+		return false, nil
+	}
+	f.chars[char.Key()] = char
+	return true, nil
+}
+
+func (f *fakeStore) UpsertCompletedKeys(_ context.Context, keys []models.CompletedKey) ([]store.UpsertStatus, error) {
+	statuses := make([]store.UpsertStatus, len(keys))
+	for i, key := range keys {
+		if existing, ok := f.keys[key.EffectiveID()]; ok && existing == key {
+			continue
+		}
+		f.keys[key.EffectiveID()] = key
+		statuses[i] = store.UpsertInserted
+	}
+	return statuses, nil
+}
+
+func (f *fakeStore) ReconcileCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]store.UpsertStatus, error) {
+	return f.UpsertCompletedKeys(ctx, keys)
+}
+
+func (f *fakeStore) UpdateCharacterScore(_ context.Context, name, realm, region string, score float64) error {
+	f.scores[models.Character{Name: name, Realm: realm, Region: region}.Key()] = score
+	return nil
+}
+
+// fakeRaiderIO knows the characters listed in scores, and reports the
+// profile keys listed in keys.
+type fakeRaiderIO struct {
+	scores map[string]float64
+	keys   map[string][]models.CompletedKey
+	calls  int
+}
+
+func (f *fakeRaiderIO) FetchWeeklyRuns(_ context.Context, char models.Character) (rioClient.ProfileResult, error) {
+	f.calls++
+	score, ok := f.scores[char.Name]
+	if !ok {
+		return rioClient.ProfileResult{}, errors.New("404 Not Found")
+	}
+	return rioClient.ProfileResult{RIOScore: score, Keys: f.keys[char.Name]}, nil
+}
+
+func TestImportRosterCSV(t *testing.T) {
+	st := newFakeStore()
+	rio := &fakeRaiderIO{scores: map[string]float64{"arthas": 2500, "jaina": 3100}}
+	st.chars[models.Character{Name: "jaina", Realm: "illidan", Region: "us"}.Key()] = models.Character{}
+
+	body := "name,realm,region\n" +
+		"Arthas,Illidan,\n" +
+		"jaina,illidan,us\n" +
+		"thrall,,us\n" +
+		"garrosh,illidan,us\n"
+
+	summary, err := New(Params{Store: st, RaiderIO: rio}).Import(context.Background(), "roster.csv", []byte(body))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	if summary.Characters != 1 || summary.KnownCharacters != 1 || summary.Accepted() != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if len(summary.Rejected) != 2 {
+		t.Fatalf("expected 2 rejections, got %+v", summary.Rejected)
+	}
+	if r := summary.Rejected[0]; r.Row != 4 || r.Reason != "name and realm are required" {
+		t.Fatalf("unexpected first rejection %+v", r)
+	}
+	if r := summary.Rejected[1]; r.Row != 5 || !strings.HasPrefix(r.Reason, "not found on RaiderIO") {
+		t.Fatalf("unexpected second rejection %+v", r)
+	}
+
+	added := st.chars[models.Character{Name: "arthas", Realm: "illidan", Region: "us"}.Key()]
+	if added.Name != "arthas" || added.RIOScore != 2500 {
+		t.Fatalf("expected arthas tracked with score, got %+v", added)
+	}
+}
+
+func TestImportKeysJSON(t *testing.T) {
+	st := newFakeStore()
+	rio := &fakeRaiderIO{
+		scores: map[string]float64{"arthas": 2500},
+		keys:   map[string][]models.CompletedKey{"arthas": {{KeyID: 11, Source: models.SourceRaiderIO}}},
+	}
+
+	body := `{"keys": [
+		{"key_id": 11, "character": "arthas", "realm": "illidan", "region": "us", "dungeon": "Priory", "key_lvl": 10, "completed_at": "2026-01-28T01:00:00Z", "source": "raiderio"},
+		{"key_id": 11, "character": "arthas", "realm": "illidan", "region": "us", "dungeon": "Priory", "key_lvl": 10, "completed_at": "2026-01-28T01:00:00Z", "source": "raiderio"},
+		{"character": "arthas", "realm": "illidan", "dungeon": "Floodgate", "key_lvl": 8, "completed_at": "2026-01-29T01:00:00Z"},
+		{"character": "arthas", "realm": "illidan", "dungeon": "Floodgate", "key_lvl": 0, "completed_at": "2026-01-29T01:00:00Z"},
+		{"character": "arthas", "realm": "illidan", "dungeon": "Floodgate", "key_lvl": 9, "completed_at": "yesterday"},
+		{"character": "nobody", "realm": "illidan", "dungeon": "Floodgate", "key_lvl": 9, "completed_at": "2026-01-29T01:00:00Z"}
+	]}`
+
+	im := New(Params{Store: st, RaiderIO: rio})
+	summary, err := im.Import(context.Background(), "keys.json", []byte(body))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Keys != 2 || summary.DuplicateKeys != 1 || len(summary.Rejected) != 3 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if rio.calls != 2 {
+		t.Fatalf("expected one RaiderIO lookup per character, got %d", rio.calls)
+	}
+
+	var manual models.CompletedKey
+	for _, key := range st.keys {
+		if key.KeyID == 0 {
+			manual = key
+		}
+	}
+	if manual.Source != models.SourceManual || manual.Region != "us" {
+		t.Fatalf("expected sourceless key to default to manual in us, got %+v", manual)
+	}
+	if st.scores[models.Character{Name: "arthas", Realm: "illidan", Region: "us"}.Key()] != 2500 {
+		t.Fatal("expected imported character to pick up its score")
+	}
+
+	summary, err = im.Import(context.Background(), "keys.json", []byte(body))
+	if err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	if summary.Keys != 0 || summary.KnownKeys != 2 {
+		t.Fatalf("expected re-import to find keys already stored, got %+v", summary)
+	}
+}
+
+func TestImportCSVKeysUseExportLayout(t *testing.T) {
+	st := newFakeStore()
+	rio := &fakeRaiderIO{
+		scores: map[string]float64{"arthas": 2500},
+		keys:   map[string][]models.CompletedKey{"arthas": {{KeyID: 5, Source: models.SourceRaiderIO}}},
+	}
+
+	body := "key_id,character,realm,region,dungeon,key_lvl,run_time_ms,par_time_ms,completed_at,source\n" +
+		"5,arthas,illidan,us,Priory,10,1320000,1500000,2026-02-04T01:00:00Z,raiderio\n" +
+		"x,arthas,illidan,us,Priory,10,0,0,2026-02-04T01:00:00Z,raiderio\n"
+
+	summary, err := New(Params{Store: st, RaiderIO: rio}).Import(context.Background(), "export_keys.csv", []byte(body))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Keys != 1 || len(summary.Rejected) != 1 || summary.Rejected[0].Reason != "key_id must be a whole number" {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if key := st.keys[5]; key.RunTimeMS != 1320000 || key.Source != "raiderio" {
+		t.Fatalf("unexpected stored key %+v", key)
+	}
+}
+
+func TestImportVerifiesKeySources(t *testing.T) {
+	st := newFakeStore()
+	rio := &fakeRaiderIO{
+		scores: map[string]float64{"arthas": 2500},
+		keys:   map[string][]models.CompletedKey{"arthas": {{KeyID: 11, Source: models.SourceRaiderIO}}},
+	}
+
+	body := `{"keys": [
+		{"key_id": 11, "character": "arthas", "realm": "illidan", "dungeon": "Priory", "key_lvl": 10, "completed_at": "2026-01-28T01:00:00Z", "source": "raiderio"},
+		{"key_id": 12, "character": "arthas", "realm": "illidan", "dungeon": "Priory", "key_lvl": 12, "completed_at": "2026-01-28T02:00:00Z", "source": "raiderio"},
+		{"key_id": 11, "character": "arthas", "realm": "illidan", "dungeon": "Priory", "key_lvl": 10, "completed_at": "2026-01-28T01:00:00Z", "source": "blizzard"},
+		{"key_id": 13, "character": "arthas", "realm": "illidan", "dungeon": "Priory", "key_lvl": 10, "completed_at": "2026-01-28T03:00:00Z", "source": "guildsheet"}
+	]}`
+
+	summary, err := New(Params{Store: st, RaiderIO: rio}).Import(context.Background(), "keys.json", []byte(body))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Keys != 1 || len(summary.Rejected) != 3 {
+		t.Fatalf("expected only the profile key stored, got %+v", summary)
+	}
+	if _, ok := st.keys[11]; !ok || len(st.keys) != 1 {
+		t.Fatalf("unexpected stored keys %+v", st.keys)
+	}
+	for _, r := range summary.Rejected {
+		if r.Reason == "" {
+			t.Fatalf("expected a reason for row %d", r.Row)
+		}
+	}
+}
+
+func TestImportEnforcesLimits(t *testing.T) {
+	body := "name,realm\narthas,illidan\njaina,illidan\nthrall,illidan\n"
+	for name, params := range map[string]Params{
+		"rows":       {MaxRows: 2},
+		"characters": {MaxCharacters: 2},
+	} {
+		rio := &fakeRaiderIO{scores: map[string]float64{"arthas": 1, "jaina": 1, "thrall": 1}}
+		params.Store = newFakeStore()
+		params.RaiderIO = rio
+		if _, err := New(params).Import(context.Background(), "roster.csv", []byte(body)); err == nil {
+			t.Errorf("%s: expected import over the limit to fail", name)
+		}
+		if rio.calls != 0 {
+			t.Errorf("%s: expected no RaiderIO lookups, got %d", name, rio.calls)
+		}
+	}
+
+	im := New(Params{Store: newFakeStore(), RaiderIO: &fakeRaiderIO{}, MaxRows: 3, MaxCharacters: 3})
+	if _, err := im.Import(context.Background(), "roster.csv", []byte(body)); err != nil {
+		t.Fatalf("expected import at the limit to succeed: %v", err)
+	}
+}
+
+func TestImportRejectsUnreadableFiles(t *testing.T) {
+	im := New(Params{Store: newFakeStore(), RaiderIO: &fakeRaiderIO{}})
+	for name, body := range map[string]string{
+		"empty.csv":  "  ",
+		"bad.json":   "{not json",
+		"nohead.csv": "realm,region\nillidan,us\n",
+	} {
+		if _, err := im.Import(context.Background(), name, []byte(body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
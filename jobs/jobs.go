@@ -0,0 +1,342 @@
+// Package jobs runs periodic background work such as the pollers, with
+// jitter, backoff after errors, on-demand runs and a status report.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+)
+
+const (
+	// _defaultJitter spreads runs by up to this fraction of the interval.
+	_defaultJitter = 0.1
+	// _defaultMaxBackoff caps the delay after repeated failures, unless the
+	// interval itself is longer.
+	_defaultMaxBackoff = time.Hour
+)
+
+var (
+	// ErrUnknownJob is returned for a job name that was never added.
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrDuplicateJob is returned when a job name is added twice.
+	ErrDuplicateJob = errors.New("job already added")
+)
+
+// Job is a unit of work run every Interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Jitter is the fraction of Interval each wait is randomly moved by, so
+	// jobs do not fire in lockstep. Zero uses 10%; negative disables it.
+	Jitter float64
+	// MaxBackoff caps how long the runner waits after consecutive failures,
+	// doubling from Interval. Zero uses an hour.
+	MaxBackoff time.Duration
+	// Run does the work. A returned error is recorded and backs off the
+	// next run; ctx is cancelled when the runner stops.
+	Run func(ctx context.Context) error
+}
+
+// Status describes a job's recent runs.
+type Status struct {
+	Name     string
+	Interval time.Duration
+	// Running is set while a run is in progress.
+	Running     bool
+	LastRun     time.Time
+	LastSuccess time.Time
+	LastError   string
+	LastErrorAt time.Time
+	// Failures counts consecutive failed runs.
+	Failures int
+	NextRun  time.Time
+}
+
+// Runner runs jobs on their schedules. Each job runs on its own goroutine,
+// so a slow job never delays another and never overlaps itself.
+type Runner struct {
+	clock  clock.Clock
+	logger logger.Logger
+
+	mu      sync.Mutex
+	jobs    []*entry
+	byName  map[string]*entry
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// Params holds the dependencies of a Runner.
+type Params struct {
+	Clock  clock.Clock
+	Logger logger.Logger
+}
+
+// entry is a job with its schedule and status.
+type entry struct {
+	job    Job
+	rng    *rand.Rand
+	now    chan struct{}
+	reset  chan struct{}
+	status Status
+}
+
+// NewRunner creates a Runner. Add jobs, then Start it.
+func NewRunner(p Params) *Runner {
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
+	log := p.Logger
+	if log == nil {
+		log = logger.NewNop()
+	}
+
+	return &Runner{
+		clock:  clk,
+		logger: log,
+		byName: make(map[string]*entry),
+	}
+}
+
+// Add registers job. Jobs added after Start begin right away.
+func (r *Runner) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("jobs: name and run func are required")
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("jobs: %s: interval must be positive", job.Name)
+	}
+	if job.Jitter == 0 {
+		job.Jitter = _defaultJitter
+	}
+	if job.MaxBackoff == 0 {
+		job.MaxBackoff = _defaultMaxBackoff
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byName[job.Name]; ok {
+		return fmt.Errorf("jobs: %s: %w", job.Name, ErrDuplicateJob)
+	}
+
+	e := &entry{
+		job:    job,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:    make(chan struct{}, 1),
+		reset:  make(chan struct{}, 1),
+		status: Status{Name: job.Name, Interval: job.Interval},
+	}
+	r.jobs = append(r.jobs, e)
+	r.byName[job.Name] = e
+
+	if r.started {
+		r.launch(e)
+	}
+	return nil
+}
+
+// Start runs every job once and then on its schedule.
+func (r *Runner) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return errors.New("jobs: runner already started")
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	r.started = true
+	for _, e := range r.jobs {
+		r.launch(e)
+	}
+	return nil
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	if !r.started {
+		r.mu.Unlock()
+		return
+	}
+	r.cancel()
+	r.started = false
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// RunNow asks for the named job to run immediately. If it is running, it
+// runs again as soon as the current run ends; repeated requests in the
+// meantime are merged into one.
+func (r *Runner) RunNow(name string) error {
+	r.mu.Lock()
+	e, ok := r.byName[name]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownJob, name)
+	}
+
+	select {
+	case e.now <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SetInterval changes how often the named job runs, rescheduling its next
+// run from now.
+func (r *Runner) SetInterval(name string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("jobs: %s: interval must be positive", name)
+	}
+
+	r.mu.Lock()
+	e, ok := r.byName[name]
+	if ok {
+		e.job.Interval = interval
+		e.status.Interval = interval
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownJob, name)
+	}
+
+	select {
+	case e.reset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Names returns the job names in the order they were added.
+func (r *Runner) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.jobs))
+	for _, e := range r.jobs {
+		names = append(names, e.job.Name)
+	}
+	return names
+}
+
+// Status returns every job's status in the order they were added.
+func (r *Runner) Status() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Status, 0, len(r.jobs))
+	for _, e := range r.jobs {
+		out = append(out, e.status)
+	}
+	return out
+}
+
+// launch starts e's loop. r.mu must be held.
+func (r *Runner) launch(e *entry) {
+	r.wg.Add(1)
+	go func(ctx context.Context) {
+		defer r.wg.Done()
+		r.loop(ctx, e)
+	}(r.ctx)
+}
+
+func (r *Runner) loop(ctx context.Context, e *entry) {
+	timer := clock.NewTimer(r.clock, 0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.reset:
+			timer.Reset(r.schedule(e, false))
+			continue
+		case <-e.now:
+		case <-timer.C():
+		}
+
+		failed := r.runOnce(ctx, e)
+		if ctx.Err() != nil {
+			return
+		}
+		timer.Reset(r.schedule(e, failed))
+	}
+}
+
+// runOnce runs e's job, recording the outcome. It reports whether the run
+// failed.
+func (r *Runner) runOnce(ctx context.Context, e *entry) bool {
+	r.mu.Lock()
+	e.status.Running = true
+	e.status.LastRun = r.clock.Now()
+	run := e.job.Run
+	r.mu.Unlock()
+
+	err := run(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.status.Running = false
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+		e.status.LastErrorAt = r.clock.Now()
+		if ctx.Err() == nil {
+			r.logger.WarnW("job failed",
+				"job", e.job.Name,
+				"failures", e.status.Failures,
+				"error", err,
+			)
+		}
+		return true
+	}
+	e.status.Failures = 0
+	e.status.LastSuccess = r.clock.Now()
+	return false
+}
+
+// schedule returns the wait before e's next run and records when that is.
+func (r *Runner) schedule(e *entry, failed bool) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wait := e.job.Interval
+	if failed {
+		wait = backoff(e.job.Interval, e.job.MaxBackoff, e.status.Failures)
+	}
+	wait = jitter(wait, e.job.Jitter, e.rng)
+	e.status.NextRun = r.clock.Now().Add(wait)
+	return wait
+}
+
+// backoff doubles interval for each consecutive failure after the first,
+// up to maxBackoff or interval, whichever is longer.
+func backoff(interval, maxBackoff time.Duration, failures int) time.Duration {
+	limit := max(maxBackoff, interval)
+	wait := interval
+	for i := 1; i < failures && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
+
+// jitter moves d by a random amount within ±fraction of d.
+func jitter(d time.Duration, fraction float64, rng *rand.Rand) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	spread := float64(d) * fraction
+	return d + time.Duration((rng.Float64()*2-1)*spread)
+}
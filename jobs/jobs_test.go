@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunnerRunsOnStartAndRecordsStatus(t *testing.T) {
+	r := NewRunner(Params{})
+	var runs atomic.Int32
+	if err := r.Add(Job{
+		Name:     "ok",
+		Interval: time.Hour,
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := r.Add(Job{
+		Name:     "broken",
+		Interval: time.Hour,
+		Run:      func(context.Context) error { return errors.New("boom") },
+	}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer r.Stop()
+
+	waitFor(t, func() bool {
+		st := r.Status()
+		return !st[0].LastSuccess.IsZero() && st[1].Failures == 1
+	})
+
+	st := r.Status()
+	if st[0].Name != "ok" || st[0].LastError != "" || st[0].NextRun.IsZero() {
+		t.Errorf("unexpected ok status: %+v", st[0])
+	}
+	if st[1].LastError != "boom" || !st[1].LastSuccess.IsZero() {
+		t.Errorf("unexpected broken status: %+v", st[1])
+	}
+	if runs.Load() != 1 {
+		t.Errorf("expected 1 run, got %d", runs.Load())
+	}
+}
+
+func TestRunnerRunNow(t *testing.T) {
+	r := NewRunner(Params{})
+	var runs atomic.Int32
+	_ = r.Add(Job{
+		Name:     "poll",
+		Interval: time.Hour,
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	})
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer r.Stop()
+
+	waitFor(t, func() bool { return runs.Load() == 1 })
+	if err := r.RunNow("poll"); err != nil {
+		t.Fatalf("run now: %v", err)
+	}
+	waitFor(t, func() bool { return runs.Load() == 2 })
+
+	if err := r.RunNow("nope"); !errors.Is(err, ErrUnknownJob) {
+		t.Fatalf("expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestRunnerNeverOverlapsAJob(t *testing.T) {
+	r := NewRunner(Params{})
+	var (
+		active  atomic.Int32
+		overlap atomic.Bool
+		runs    atomic.Int32
+	)
+	release := make(chan struct{})
+	_ = r.Add(Job{
+		Name:     "slow",
+		Interval: time.Hour,
+		Run: func(context.Context) error {
+			if active.Add(1) > 1 {
+				overlap.Store(true)
+			}
+			defer active.Add(-1)
+			if runs.Add(1) == 1 {
+				<-release
+			}
+			return nil
+		},
+	})
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer r.Stop()
+
+	waitFor(t, func() bool { return r.Status()[0].Running })
+	for range 3 {
+		_ = r.RunNow("slow")
+	}
+	close(release)
+
+	// The requests made during the first run are merged into one more run.
+	waitFor(t, func() bool { return runs.Load() == 2 && !r.Status()[0].Running })
+	time.Sleep(10 * time.Millisecond)
+	if runs.Load() != 2 {
+		t.Errorf("expected 2 runs, got %d", runs.Load())
+	}
+	if overlap.Load() {
+		t.Error("job ran concurrently with itself")
+	}
+}
+
+func TestRunnerStopCancelsRun(t *testing.T) {
+	r := NewRunner(Params{})
+	started := make(chan struct{})
+	_ = r.Add(Job{
+		Name:     "blocking",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+}
+
+func TestRunnerAddValidates(t *testing.T) {
+	r := NewRunner(Params{})
+	run := func(context.Context) error { return nil }
+
+	if err := r.Add(Job{Name: "a", Run: run}); err == nil {
+		t.Error("expected error for missing interval")
+	}
+	if err := r.Add(Job{Name: "a", Interval: time.Minute, Run: run}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := r.Add(Job{Name: "a", Interval: time.Minute, Run: run}); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("expected ErrDuplicateJob, got %v", err)
+	}
+	if err := r.SetInterval("a", 5*time.Minute); err != nil {
+		t.Fatalf("set interval: %v", err)
+	}
+	if got := r.Status()[0].Interval; got != 5*time.Minute {
+		t.Errorf("interval = %s, want 5m", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		interval, max time.Duration
+		failures      int
+		want          time.Duration
+	}{
+		{time.Minute, time.Hour, 1, time.Minute},
+		{time.Minute, time.Hour, 2, 2 * time.Minute},
+		{time.Minute, time.Hour, 4, 8 * time.Minute},
+		{time.Minute, time.Hour, 10, time.Hour},
+		{2 * time.Hour, time.Hour, 3, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.interval, tt.max, tt.failures); got != tt.want {
+			t.Errorf("backoff(%s, %s, %d) = %s, want %s", tt.interval, tt.max, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestJitterStaysInRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 1000 {
+		got := jitter(time.Minute, 0.1, rng)
+		if got < 54*time.Second || got > 66*time.Second {
+			t.Fatalf("jitter out of range: %s", got)
+		}
+	}
+	if got := jitter(time.Minute, -1, rng); got != time.Minute {
+		t.Errorf("negative jitter changed the wait: %s", got)
+	}
+}
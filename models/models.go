@@ -2,11 +2,36 @@ package models
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"strings"
 )
 
+// Key sources recorded in CompletedKey.Source.
+const (
+	SourceRaiderIO     = "raiderio"
+	SourceBlizzard     = "blizzard"
+	SourceWarcraftLogs = "warcraftlogs"
+	SourceManual       = "manual"
+)
+
+// SourcePriority ranks key sources by how much they are trusted. When two
+// sources report the same run, the key from the higher-priority source wins.
+// Manually entered keys rank below every API source.
+func SourcePriority(source string) int {
+	switch normalize(source) {
+	case SourceRaiderIO:
+		return 3
+	case SourceBlizzard:
+		return 2
+	case SourceWarcraftLogs:
+		return 1
+	default:
+		return 0
+	}
+}
+
 type Character struct {
 	Name     string  `json:"name" yaml:"name"`
 	Realm    string  `json:"realm" yaml:"realm"`
@@ -55,6 +80,31 @@ func (k CompletedKey) SyntheticKey() string {
 	return hex.EncodeToString(sum[:])
 }
 
+// EffectiveID returns the ID the key is stored under: KeyID when the source
+// supplied one, otherwise SyntheticID.
+func (k CompletedKey) EffectiveID() int64 {
+	if k.KeyID > 0 {
+		return k.KeyID
+	}
+	return k.SyntheticID()
+}
+
+// SyntheticID derives a stable positive key ID from SyntheticKey for keys
+// that have no upstream run ID.
+func (k CompletedKey) SyntheticID() int64 {
+	synthetic := k.SyntheticKey()
+	raw, err := hex.DecodeString(synthetic)
+	if err != nil || len(raw) < 8 {
+		sum := sha256.Sum256([]byte(synthetic))
+		raw = sum[:]
+	}
+	value := int64(binary.BigEndian.Uint64(raw[:8]) & 0x7FFFFFFFFFFFFFFF)
+	if value == 0 {
+		return 1
+	}
+	return value
+}
+
 func normalize(in string) string {
 	return strings.ToLower(strings.TrimSpace(in))
 }
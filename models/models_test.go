@@ -39,3 +39,21 @@ func TestSyntheticKeyStableAndSensitive(t *testing.T) {
 		t.Fatalf("expected synthetic key to change when fields change")
 	}
 }
+
+func TestSyntheticIDStableAndPositive(t *testing.T) {
+	key := CompletedKey{
+		Character:   "Arthas",
+		Region:      "us",
+		Realm:       "illidan",
+		Dungeon:     "Mists of Tirna Scithe",
+		KeyLevel:    10,
+		CompletedAt: "2026-02-01T01:23:45Z",
+	}
+	a := key.SyntheticID()
+	if a <= 0 {
+		t.Fatalf("expected positive synthetic ID, got %d", a)
+	}
+	if b := key.SyntheticID(); a != b {
+		t.Fatalf("expected stable synthetic ID, got %d and %d", a, b)
+	}
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+var _ Client = (*CachingClient)(nil)
+
+const (
+	_defaultCacheTTL   = 30 * time.Second
+	_defaultCacheStale = 5 * time.Minute
+	// _fetchTimeout bounds a shared fetch, which runs apart from the
+	// callers waiting on it and may outlive the one that started it.
+	_fetchTimeout = 30 * time.Second
+)
+
+// CachingClient caches another client's profiles per character. A profile
+// younger than the TTL is served from the cache. An older one is served for
+// a further stale window while it is refreshed in the background, unless
+// the caller asked for fresh data with WithoutStale. Concurrent fetches for
+// the same character share one request, and refreshes are conditional when
+// the client supports it.
+type CachingClient struct {
+	name   string
+	client Client
+	ttl    time.Duration
+	stale  time.Duration
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	flights map[string]*flight
+
+	hits        atomic.Int64
+	staleHits   atomic.Int64
+	misses      atomic.Int64
+	notModified atomic.Int64
+	errors      atomic.Int64
+}
+
+// CacheParams holds configuration for creating a CachingClient.
+type CacheParams struct {
+	// Name identifies the cache in its stats, e.g. the source it wraps.
+	Name   string
+	Client Client
+	// TTL is how long a profile is served without asking the API. Zero uses
+	// 30 seconds.
+	TTL time.Duration
+	// Stale is how long after the TTL a profile may still be served while it
+	// is refreshed. Zero uses 5 minutes; negative disables it.
+	Stale time.Duration
+	Clock clock.Clock
+}
+
+// CacheStats counts how a CachingClient's lookups were served.
+type CacheStats struct {
+	Name string
+	// Hits were served from a fresh entry, StaleHits from a stale one.
+	Hits      int64
+	StaleHits int64
+	// Misses went to the API. NotModified of them were answered by a
+	// conditional request without a new body.
+	Misses      int64
+	NotModified int64
+	Errors      int64
+	Entries     int
+}
+
+type cacheEntry struct {
+	result     ProfileResult
+	validator  Validator
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// flight is a fetch in progress, shared by every caller asking for the same
+// character meanwhile.
+type flight struct {
+	done   chan struct{}
+	result ProfileResult
+	err    error
+}
+
+type noStaleKey struct{}
+
+// WithoutStale returns a context under which a CachingClient only serves
+// entries younger than its TTL, for callers that must see new keys promptly.
+func WithoutStale(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noStaleKey{}, true)
+}
+
+func allowStale(ctx context.Context) bool {
+	noStale, _ := ctx.Value(noStaleKey{}).(bool)
+	return !noStale
+}
+
+// NewCache creates a CachingClient around p.Client.
+func NewCache(p CacheParams) *CachingClient {
+	ttl := p.TTL
+	if ttl <= 0 {
+		ttl = _defaultCacheTTL
+	}
+	stale := p.Stale
+	switch {
+	case stale == 0:
+		stale = _defaultCacheStale
+	case stale < 0:
+		stale = 0
+	}
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
+
+	return &CachingClient{
+		name:    p.Name,
+		client:  p.Client,
+		ttl:     ttl,
+		stale:   stale,
+		clock:   clk,
+		entries: make(map[string]*cacheEntry),
+		flights: make(map[string]*flight),
+	}
+}
+
+// FetchWeeklyRuns returns the character's profile from the cache, or from
+// the wrapped client when the cached copy is too old.
+func (c *CachingClient) FetchWeeklyRuns(ctx context.Context, character models.Character) (ProfileResult, error) {
+	key := character.Key()
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		age := now.Sub(e.fetchedAt)
+		if age < c.ttl {
+			c.mu.Unlock()
+			c.hits.Add(1)
+			return e.result.clone(), nil
+		}
+		if age < c.ttl+c.stale && allowStale(ctx) {
+			if !e.refreshing {
+				e.refreshing = true
+				go c.revalidate(character)
+			}
+			c.mu.Unlock()
+			c.staleHits.Add(1)
+			return e.result.clone(), nil
+		}
+	}
+	c.mu.Unlock()
+
+	c.misses.Add(1)
+	return c.fetch(ctx, character)
+}
+
+// Stats returns the cache's counters so far.
+func (c *CachingClient) Stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	return CacheStats{
+		Name:        c.name,
+		Hits:        c.hits.Load(),
+		StaleHits:   c.staleHits.Load(),
+		Misses:      c.misses.Load(),
+		NotModified: c.notModified.Load(),
+		Errors:      c.errors.Load(),
+		Entries:     entries,
+	}
+}
+
+// revalidate refreshes a stale entry in the background.
+func (c *CachingClient) revalidate(character models.Character) {
+	_, _ = c.fetch(context.Background(), character)
+
+	c.mu.Lock()
+	if e, ok := c.entries[character.Key()]; ok {
+		e.refreshing = false
+	}
+	c.mu.Unlock()
+}
+
+// fetch loads the character from the wrapped client, joining a fetch
+// already in progress for it. The load runs on a context detached from
+// ctx, so a caller that gives up does not fail the others sharing it.
+func (c *CachingClient) fetch(ctx context.Context, character models.Character) (ProfileResult, error) {
+	key := character.Key()
+
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		c.flights[key] = f
+		var prev cacheEntry
+		if e, ok := c.entries[key]; ok {
+			prev = *e
+		}
+		go c.run(context.WithoutCancel(ctx), character, f, prev)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.result.clone(), f.err
+	case <-ctx.Done():
+		return ProfileResult{}, ctx.Err()
+	}
+}
+
+// run performs the load for flight f and stores its result.
+func (c *CachingClient) run(ctx context.Context, character models.Character, f *flight, prev cacheEntry) {
+	ctx, cancel := context.WithTimeout(ctx, _fetchTimeout)
+	defer cancel()
+
+	key := character.Key()
+	result, validator, err := c.load(ctx, character, prev)
+
+	c.mu.Lock()
+	delete(c.flights, key)
+	if err == nil {
+		c.pruneLocked()
+		c.entries[key] = &cacheEntry{
+			result:    result,
+			validator: validator,
+			fetchedAt: c.clock.Now(),
+		}
+	}
+	c.mu.Unlock()
+
+	f.result, f.err = result, err
+	close(f.done)
+	if err != nil {
+		c.errors.Add(1)
+	}
+}
+
+// load asks the wrapped client for the profile, conditionally on prev when
+// the client supports it.
+func (c *CachingClient) load(ctx context.Context, character models.Character, prev cacheEntry) (ProfileResult, Validator, error) {
+	cond, ok := c.client.(ConditionalClient)
+	if !ok {
+		result, err := c.client.FetchWeeklyRuns(ctx, character)
+		return result, Validator{}, err
+	}
+	result, validator, err := cond.FetchWeeklyRunsIfChanged(ctx, character, prev.validator)
+	if errors.Is(err, ErrNotModified) && !prev.validator.IsZero() {
+		c.notModified.Add(1)
+		return prev.result, prev.validator, nil
+	}
+	return result, validator, err
+}
+
+// clone copies r so callers can modify its keys without touching the cache.
+func (r ProfileResult) clone() ProfileResult {
+	r.Keys = slices.Clone(r.Keys)
+	return r
+}
+
+// pruneLocked drops entries too old to be served. c.mu must be held.
+func (c *CachingClient) pruneLocked() {
+	now := c.clock.Now()
+	for key, e := range c.entries {
+		if now.Sub(e.fetchedAt) >= c.ttl+c.stale && !e.refreshing {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestCachingClientRevalidates(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"mythic_plus_scores_by_season": [{"scores": {"all": 2500}}]}`))
+	}))
+	defer server.Close()
+
+	clk := &manualClock{now: time.Date(2026, 2, 5, 20, 0, 0, 0, time.UTC)}
+	cache := NewCache(CacheParams{
+		Name:   "raiderio",
+		Client: New(Params{BaseURL: server.URL, HTTPClient: server.Client()}),
+		TTL:    30 * time.Second,
+		Stale:  5 * time.Minute,
+		Clock:  clk,
+	})
+	char := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+	ctx := context.Background()
+
+	for range 2 {
+		result, err := cache.FetchWeeklyRuns(ctx, char)
+		if err != nil || result.RIOScore != 2500 {
+			t.Fatalf("fetch = %+v, %v", result, err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("expected 1 request within the TTL, got %d", requests.Load())
+	}
+
+	// Stale: served at once and revalidated in the background.
+	clk.Advance(time.Minute)
+	result, err := cache.FetchWeeklyRuns(ctx, char)
+	if err != nil || result.RIOScore != 2500 {
+		t.Fatalf("stale fetch = %+v, %v", result, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for notModified.Load() == 0 || cache.refreshing(char) {
+		if time.Now().After(deadline) {
+			t.Fatal("stale entry was not revalidated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Callers that must see new keys skip stale entries.
+	clk.Advance(time.Minute)
+	if _, err := cache.FetchWeeklyRuns(WithoutStale(ctx), char); err != nil {
+		t.Fatalf("fresh fetch: %v", err)
+	}
+	if requests.Load() != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests.Load())
+	}
+
+	stats := cache.Stats()
+	want := CacheStats{Name: "raiderio", Hits: 1, StaleHits: 1, Misses: 2, NotModified: 2, Entries: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func (c *CachingClient) refreshing(char models.Character) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[char.Key()]
+	return ok && e.refreshing
+}
+
+type blockingClient struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingClient) FetchWeeklyRuns(context.Context, models.Character) (ProfileResult, error) {
+	b.calls.Add(1)
+	<-b.release
+	return ProfileResult{RIOScore: 1800}, nil
+}
+
+func TestCachingClientSharesConcurrentFetches(t *testing.T) {
+	upstream := &blockingClient{release: make(chan struct{})}
+	cache := NewCache(CacheParams{Client: upstream})
+	char := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := cache.FetchWeeklyRuns(context.Background(), char)
+			if err != nil || result.RIOScore != 1800 {
+				t.Errorf("fetch = %+v, %v", result, err)
+			}
+		}()
+	}
+	for cache.Stats().Misses < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(upstream.release)
+	wg.Wait()
+
+	if got := upstream.calls.Load(); got != 1 {
+		t.Errorf("expected 1 upstream call, got %d", got)
+	}
+}
+
+func TestCachingClientFetchOutlivesCanceledCaller(t *testing.T) {
+	upstream := &blockingClient{release: make(chan struct{})}
+	cache := NewCache(CacheParams{Client: upstream})
+	char := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := cache.FetchWeeklyRuns(ctx, char)
+		first <- err
+	}()
+	for upstream.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		result, err := cache.FetchWeeklyRuns(context.Background(), char)
+		if err == nil && result.RIOScore != 1800 {
+			err = fmt.Errorf("unexpected result %+v", result)
+		}
+		second <- err
+	}()
+	for cache.Stats().Misses < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the canceled caller to give up, got %v", err)
+	}
+	close(upstream.release)
+	if err := <-second; err != nil {
+		t.Fatalf("shared fetch failed for the other caller: %v", err)
+	}
+	if got := upstream.calls.Load(); got != 1 {
+		t.Errorf("expected 1 upstream call, got %d", got)
+	}
+}
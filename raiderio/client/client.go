@@ -12,11 +12,7 @@ import (
 	"github.com/tnicklin/celestial_orrey/models"
 )
 
-const (
-	_sourceRaiderIO = "raiderio"
-)
-
-var _ Client = (*DefaultClient)(nil)
+var _ ConditionalClient = (*DefaultClient)(nil)
 
 // DefaultClient is the RaiderIO API client.
 type DefaultClient struct {
@@ -43,9 +39,16 @@ func New(p Params) *DefaultClient {
 
 // FetchWeeklyRuns fetches the weekly M+ runs for a character from RaiderIO.
 func (c *DefaultClient) FetchWeeklyRuns(ctx context.Context, character models.Character) (ProfileResult, error) {
+	result, _, err := c.FetchWeeklyRunsIfChanged(ctx, character, Validator{})
+	return result, err
+}
+
+// FetchWeeklyRunsIfChanged fetches the weekly M+ runs for a character unless
+// RaiderIO reports them unchanged since v.
+func (c *DefaultClient) FetchWeeklyRunsIfChanged(ctx context.Context, character models.Character, v Validator) (ProfileResult, Validator, error) {
 	endpoint, err := url.Parse(c.baseURL)
 	if err != nil {
-		return ProfileResult{}, err
+		return ProfileResult{}, Validator{}, err
 	}
 	endpoint.Path = "/api/v1/characters/profile"
 
@@ -58,27 +61,31 @@ func (c *DefaultClient) FetchWeeklyRuns(ctx context.Context, character models.Ch
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
 	if err != nil {
-		return ProfileResult{}, err
+		return ProfileResult{}, Validator{}, err
 	}
 	req.Header.Set("Accept", "application/json")
 	if c.userAgent != "" {
 		req.Header.Set("User-Agent", c.userAgent)
 	}
+	v.Apply(req)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return ProfileResult{}, err
+		return ProfileResult{}, Validator{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return ProfileResult{}, v, ErrNotModified
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
-		return ProfileResult{}, fmt.Errorf("raiderio: status %d: %s", resp.StatusCode, string(body))
+		return ProfileResult{}, Validator{}, fmt.Errorf("raiderio: status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var payload profileResponse
 	if err = json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return ProfileResult{}, err
+		return ProfileResult{}, Validator{}, err
 	}
 
 	out := make([]models.CompletedKey, 0, len(payload.WeeklyRuns))
@@ -93,7 +100,7 @@ func (c *DefaultClient) FetchWeeklyRuns(ctx context.Context, character models.Ch
 			RunTimeMS:   run.ClearTimeMS,
 			ParTimeMS:   run.ParTimeMS,
 			CompletedAt: run.CompletedAt,
-			Source:      _sourceRaiderIO,
+			Source:      models.SourceRaiderIO,
 		}
 		out = append(out, key)
 	}
@@ -103,12 +110,12 @@ func (c *DefaultClient) FetchWeeklyRuns(ctx context.Context, character models.Ch
 		score = payload.Scores[0].Scores.All
 	}
 
-	return ProfileResult{Keys: out, RIOScore: score}, nil
+	return ProfileResult{Keys: out, RIOScore: score}, ValidatorFrom(resp.Header), nil
 }
 
 type profileResponse struct {
-	WeeklyRuns []weeklyRun    `json:"mythic_plus_weekly_highest_level_runs"`
-	Scores     []seasonScore  `json:"mythic_plus_scores_by_season"`
+	WeeklyRuns []weeklyRun   `json:"mythic_plus_weekly_highest_level_runs"`
+	Scores     []seasonScore `json:"mythic_plus_scores_by_season"`
 }
 
 type seasonScore struct {
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/reconcile"
+)
+
+var _ Client = (*MultiClient)(nil)
+
+// Source is a client and the key source it fetches from, such as
+// models.SourceRaiderIO.
+type Source struct {
+	Name   string
+	Client Client
+}
+
+// MultiClient combines several clients into one. Runs reported by more than
+// one client are deduplicated by the same rules the store reconciles with,
+// keeping the copy from the most trusted source.
+type MultiClient struct {
+	sources []Source
+}
+
+// NewMulti creates a MultiClient that queries sources in order.
+func NewMulti(sources ...Source) *MultiClient {
+	return &MultiClient{sources: sources}
+}
+
+// FetchWeeklyRuns fetches weekly runs from every source and merges them. The
+// score is RaiderIO's: if a RaiderIO source is configured and fails, the
+// fetch fails rather than report another source's rating, or none, in its
+// place. Otherwise it only fails when every source fails.
+func (m *MultiClient) FetchWeeklyRuns(ctx context.Context, character models.Character) (ProfileResult, error) {
+	var (
+		merged   ProfileResult
+		firstErr error
+		ok       bool
+	)
+
+	scoreFrom := slices.IndexFunc(m.sources, func(s Source) bool {
+		return strings.EqualFold(s.Name, models.SourceRaiderIO)
+	})
+	for i, s := range m.sources {
+		result, err := s.Client.FetchWeeklyRuns(ctx, character)
+		if err != nil {
+			if i == scoreFrom {
+				return ProfileResult{}, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ok = true
+
+		if i == scoreFrom || (scoreFrom < 0 && merged.RIOScore == 0) {
+			merged.RIOScore = result.RIOScore
+		}
+		for _, key := range result.Keys {
+			merged.Keys = mergeRun(merged.Keys, key)
+		}
+	}
+
+	if !ok && firstErr != nil {
+		return ProfileResult{}, firstErr
+	}
+	return merged, nil
+}
+
+// mergeRun adds key to keys unless another source already reported the
+// run, replacing that copy if key's source is more trusted.
+func mergeRun(keys []models.CompletedKey, key models.CompletedKey) []models.CompletedKey {
+	existing, found := reconcile.Match(key, keys)
+	if !found {
+		return append(keys, key)
+	}
+	if reconcile.Wins(key, existing) {
+		keys[slices.Index(keys, existing)] = key
+	}
+	return keys
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+type stubClient struct {
+	result ProfileResult
+	err    error
+}
+
+func (s stubClient) FetchWeeklyRuns(context.Context, models.Character) (ProfileResult, error) {
+	return s.result, s.err
+}
+
+func TestMultiClientDeduplicatesRuns(t *testing.T) {
+	raiderIO := Source{Name: models.SourceRaiderIO, Client: stubClient{result: ProfileResult{
+		RIOScore: 2500,
+		Keys: []models.CompletedKey{
+			{KeyID: 1, Dungeon: "Mists of Tirna Scithe", KeyLevel: 10, CompletedAt: "2026-02-04T01:23:45.000Z", Source: models.SourceRaiderIO},
+		},
+	}}}
+	blizzard := Source{Name: models.SourceBlizzard, Client: stubClient{result: ProfileResult{
+		RIOScore: 2450,
+		Keys: []models.CompletedKey{
+			// Blizzard's timestamp and dungeon name differ from RaiderIO's
+			// within what the store treats as the same run.
+			{Dungeon: "Mists", KeyLevel: 10, CompletedAt: "2026-02-04T01:40:00Z", Source: models.SourceBlizzard},
+			{Dungeon: "The Necrotic Wake", KeyLevel: 9, CompletedAt: "2026-02-04T03:00:00Z", Source: models.SourceBlizzard},
+		},
+	}}}
+
+	for name, sources := range map[string][]Source{
+		"raiderio first": {raiderIO, blizzard},
+		"blizzard first": {blizzard, raiderIO},
+	} {
+		result, err := NewMulti(sources...).FetchWeeklyRuns(context.Background(), models.Character{})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if len(result.Keys) != 2 {
+			t.Fatalf("%s: expected 2 merged runs, got %+v", name, result.Keys)
+		}
+		for _, key := range result.Keys {
+			if key.Dungeon != "The Necrotic Wake" && key.Source != models.SourceRaiderIO {
+				t.Errorf("%s: expected the RaiderIO copy of the shared run, got %+v", name, key)
+			}
+		}
+		if result.RIOScore != 2500 {
+			t.Errorf("%s: expected RaiderIO's score, got %f", name, result.RIOScore)
+		}
+	}
+}
+
+func TestMultiClientPartialFailure(t *testing.T) {
+	failing := Source{Name: models.SourceBlizzard, Client: stubClient{err: errors.New("down")}}
+	working := Source{Name: models.SourceRaiderIO, Client: stubClient{result: ProfileResult{Keys: []models.CompletedKey{{KeyID: 7}}}}}
+
+	result, err := NewMulti(failing, working).FetchWeeklyRuns(context.Background(), models.Character{})
+	if err != nil {
+		t.Fatalf("expected partial failure to succeed, got %v", err)
+	}
+	if len(result.Keys) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(result.Keys))
+	}
+
+	if _, err := NewMulti(failing, failing).FetchWeeklyRuns(context.Background(), models.Character{}); err == nil {
+		t.Fatal("expected error when every client fails")
+	}
+}
+
+func TestMultiClientFailsWithoutRaiderIOScore(t *testing.T) {
+	raiderIO := Source{Name: models.SourceRaiderIO, Client: stubClient{err: errors.New("down")}}
+	blizzard := Source{Name: models.SourceBlizzard, Client: stubClient{result: ProfileResult{RIOScore: 2450}}}
+
+	if _, err := NewMulti(blizzard, raiderIO).FetchWeeklyRuns(context.Background(), models.Character{}); err == nil {
+		t.Fatal("expected error rather than Blizzard's rating in place of the RaiderIO score")
+	}
+}
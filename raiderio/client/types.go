@@ -2,10 +2,16 @@ package client
 
 import (
 	"context"
+	"errors"
+	"net/http"
 
 	"github.com/tnicklin/celestial_orrey/models"
 )
 
+// ErrNotModified is returned by FetchWeeklyRunsIfChanged when the profile
+// has not changed since the validator was issued.
+var ErrNotModified = errors.New("profile not modified")
+
 // ProfileResult holds the result of a RaiderIO profile fetch.
 type ProfileResult struct {
 	Keys     []models.CompletedKey
@@ -16,3 +22,42 @@ type ProfileResult struct {
 type Client interface {
 	FetchWeeklyRuns(context.Context, models.Character) (ProfileResult, error)
 }
+
+// ConditionalClient is a Client whose API supports conditional requests, so
+// an unchanged profile can be revalidated without downloading it again.
+type ConditionalClient interface {
+	Client
+	// FetchWeeklyRunsIfChanged fetches the profile unless it is unchanged
+	// since v, in which case it returns ErrNotModified. The returned
+	// Validator identifies the new response.
+	FetchWeeklyRunsIfChanged(ctx context.Context, character models.Character, v Validator) (ProfileResult, Validator, error)
+}
+
+// Validator holds the headers an API returned to identify a response.
+type Validator struct {
+	ETag         string
+	LastModified string
+}
+
+// ValidatorFrom reads the validator from response headers.
+func ValidatorFrom(h http.Header) Validator {
+	return Validator{
+		ETag:         h.Get("ETag"),
+		LastModified: h.Get("Last-Modified"),
+	}
+}
+
+// IsZero reports whether v holds no validator.
+func (v Validator) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// Apply makes req conditional on the response v identifies.
+func (v Validator) Apply(req *http.Request) {
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
@@ -3,19 +3,42 @@ package raiderio
 import (
 	"net/http"
 	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
 )
 
 // Config holds RaiderIO client and poller configuration.
 type Config struct {
+	// Sources lists the APIs polled for weekly runs: "raiderio", "blizzard"
+	// or both. A run both report keeps the RaiderIO copy whatever the order,
+	// and the score is always RaiderIO's. Blizzard reports only each
+	// dungeon's best run of the week, so on its own it undercounts vault
+	// progress.
+	Sources       []string      `yaml:"sources"`
 	BaseURL       string        `yaml:"base_url"`
 	UserAgent     string        `yaml:"user_agent"`
 	PollInterval  time.Duration `yaml:"poll_interval"`
 	MaxConcurrent int           `yaml:"max_concurrent"`
-	HTTPClient    *http.Client  `yaml:"-"`
+	// ActiveWindow is how long after completing a key a character is polled
+	// every PollInterval.
+	ActiveWindow time.Duration `yaml:"active_window"`
+	// IdlePollInterval is how often the other characters are polled during
+	// play hours; outside them it is stretched further.
+	IdlePollInterval time.Duration `yaml:"idle_poll_interval"`
+	// CacheTTL is how long a fetched profile is reused by the poller and
+	// the Discord commands. CacheStale is how long after that it may still
+	// be served to commands while it is refreshed; negative disables it.
+	// Zero uses the cache's defaults.
+	CacheTTL   time.Duration `yaml:"cache_ttl"`
+	CacheStale time.Duration `yaml:"cache_stale"`
+	HTTPClient *http.Client  `yaml:"-"`
 }
 
 // Defaults applies default values to the config.
 func (c *Config) Defaults() {
+	if len(c.Sources) == 0 {
+		c.Sources = []string{models.SourceRaiderIO}
+	}
 	if c.BaseURL == "" {
 		c.BaseURL = "https://raider.io"
 	}
@@ -28,6 +51,12 @@ func (c *Config) Defaults() {
 	if c.MaxConcurrent <= 0 {
 		c.MaxConcurrent = 4
 	}
+	if c.ActiveWindow <= 0 {
+		c.ActiveWindow = 2 * time.Hour
+	}
+	if c.IdlePollInterval <= 0 {
+		c.IdlePollInterval = 30 * time.Minute
+	}
 	if c.HTTPClient == nil {
 		c.HTTPClient = &http.Client{Timeout: 30 * time.Second}
 	}
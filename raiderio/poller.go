@@ -3,11 +3,13 @@ package raiderio
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/jobs"
 	"github.com/tnicklin/celestial_orrey/models"
 	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
 	"github.com/tnicklin/celestial_orrey/store"
@@ -19,18 +21,17 @@ var _ Poller = (*DefaultPoller)(nil)
 
 // DefaultPoller polls RaiderIO for new M+ keys.
 type DefaultPoller struct {
-	client        rioClient.Client
-	store         store.Store
-	wclLinker     *warcraftlogs.Linker
-	clock         clock.Clock
-	interval      time.Duration
-	maxConcurrent int
-	rng           *rand.Rand
-
-	mu    sync.Mutex
-	known map[string]map[string]struct{} // character key -> known key IDs
-	stop  chan struct{}
-	done  chan struct{}
+	client    rioClient.Client
+	store     store.Store
+	wclLinker *warcraftlogs.Linker
+	clock     clock.Clock
+	rng       *rand.Rand
+
+	// mu guards known, schedule and cfg, which Reload replaces.
+	mu       sync.Mutex
+	known    map[string]map[string]struct{} // character key -> known key IDs
+	schedule map[string]*pollState          // character key -> poll schedule
+	cfg      Config
 }
 
 // Params holds configuration for creating a new Poller.
@@ -61,79 +62,86 @@ func New(p Params) *DefaultPoller {
 	}
 
 	return &DefaultPoller{
-		client:        client,
-		store:         p.Store,
-		wclLinker:     p.WCLLinker,
-		clock:         clk,
-		interval:      p.Config.PollInterval,
-		maxConcurrent: p.Config.MaxConcurrent,
-		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
-		known:         make(map[string]map[string]struct{}),
+		client:    client,
+		store:     p.Store,
+		wclLinker: p.WCLLinker,
+		clock:     clk,
+		cfg:       p.Config,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		known:     make(map[string]map[string]struct{}),
+		schedule:  make(map[string]*pollState),
 	}
 }
 
-// Start begins the polling loop.
-func (p *DefaultPoller) Start(ctx context.Context) error {
-	if p.client == nil {
-		return errors.New("raiderio: client is required")
-	}
-	if p.store == nil {
-		return errors.New("raiderio: store is required")
-	}
+// JobName names the poller's job in the job runner.
+const JobName = "raiderio"
 
-	p.stop = make(chan struct{})
-	p.done = make(chan struct{})
-
-	go p.run(ctx)
-	return nil
+// Job returns the poller's job, which runs every PollInterval and polls the
+// characters that are due.
+func (p *DefaultPoller) Job() jobs.Job {
+	return jobs.Job{
+		Name:     JobName,
+		Interval: p.settings().PollInterval,
+		Run:      p.pollAllCharacters,
+	}
 }
 
-func (p *DefaultPoller) run(ctx context.Context) {
-	defer close(p.done)
-
-	// Poll immediately on start
-	p.pollAllCharacters(ctx)
-
-	ticker := time.NewTicker(p.interval)
-	defer ticker.Stop()
+// Reload applies the poll concurrency and scheduling settings from cfg,
+// keeping the known keys and the schedule. The interval is applied through
+// the job runner; the sources and client settings only take effect on
+// restart.
+func (p *DefaultPoller) Reload(cfg Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cfg.Defaults()
+	cfg.Sources = p.cfg.Sources
+	cfg.BaseURL = p.cfg.BaseURL
+	cfg.UserAgent = p.cfg.UserAgent
+	cfg.HTTPClient = p.cfg.HTTPClient
+	p.cfg = cfg
+}
 
-	for {
-		select {
-		case <-p.stop:
-			return
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			p.pollAllCharacters(ctx)
-		}
-	}
+// settings returns the config in effect.
+func (p *DefaultPoller) settings() Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg
 }
 
-// Stop stops the polling loop.
-func (p *DefaultPoller) Stop() {
-	if p.stop != nil {
-		close(p.stop)
-		<-p.done
+// pollAllCharacters polls the tracked characters that are due. It fails only
+// when none of them could be fetched, so one bad character does not back off
+// the rest.
+func (p *DefaultPoller) pollAllCharacters(ctx context.Context) error {
+	if p.client == nil {
+		return errors.New("raiderio: client is required")
+	}
+	if p.store == nil {
+		return errors.New("raiderio: store is required")
 	}
-}
 
-func (p *DefaultPoller) pollAllCharacters(ctx context.Context) {
-	characters, err := p.store.ListCharacters(ctx)
-	if err != nil || len(characters) == 0 {
-		return
+	all, err := p.store.ListCharacters(ctx)
+	if err != nil {
+		return fmt.Errorf("list characters: %w", err)
+	}
+	characters := p.due(all, p.clock.Now())
+	if len(characters) == 0 {
+		return nil
 	}
 
-	sem := make(chan struct{}, p.maxConcurrent)
-	var wg sync.WaitGroup
+	sem := make(chan struct{}, p.settings().MaxConcurrent)
+	var (
+		wg       sync.WaitGroup
+		failMu   sync.Mutex
+		failed   int
+		firstErr error
+	)
 
 	for _, char := range characters {
 		select {
 		case <-ctx.Done():
 			wg.Wait()
-			return
-		case <-p.stop:
-			wg.Wait()
-			return
+			return ctx.Err()
 		case sem <- struct{}{}:
 		}
 
@@ -141,14 +149,25 @@ func (p *DefaultPoller) pollAllCharacters(ctx context.Context) {
 		go func(c models.Character) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			p.pollCharacter(ctx, c)
+			if err := p.pollCharacter(ctx, c); err != nil {
+				failMu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", c.Key(), err)
+				}
+				failMu.Unlock()
+			}
 		}(char)
 	}
 
 	wg.Wait()
+	if failed == len(characters) {
+		return fmt.Errorf("all %d characters failed: %w", failed, firstErr)
+	}
+	return nil
 }
 
-func (p *DefaultPoller) pollCharacter(ctx context.Context, character models.Character) {
+func (p *DefaultPoller) pollCharacter(ctx context.Context, character models.Character) error {
 	charKey := character.Key()
 	now := p.clock.Now()
 
@@ -159,7 +178,7 @@ func (p *DefaultPoller) pollCharacter(ctx context.Context, character models.Char
 		p.known[charKey] = known
 
 		cutoff := timeutil.WeeklyResetAt(now)
-		existingKeys, err := p.store.ListKeysByCharacterSince(ctx, character.Name, cutoff)
+		existingKeys, err := p.store.ListKeysByCharacterSince(ctx, character.Name, character.Realm, character.Region, cutoff)
 		if err == nil {
 			for _, key := range existingKeys {
 				known[key.KeyIDOrSynthetic()] = struct{}{}
@@ -168,15 +187,22 @@ func (p *DefaultPoller) pollCharacter(ctx context.Context, character models.Char
 	}
 	p.mu.Unlock()
 
-	result, err := p.client.FetchWeeklyRuns(ctx, character)
+	// A cached profile is only reused while fresh, so new keys are seen on
+	// this poll rather than the next.
+	result, err := p.client.FetchWeeklyRuns(rioClient.WithoutStale(ctx), character)
 	if err != nil {
-		return
+		if ctx.Err() == nil {
+			p.recordFailure(charKey, now)
+		}
+		return err
 	}
 
 	// Update character's RIO score
 	_ = p.store.UpdateCharacterScore(ctx, character.Name, character.Realm, character.Region, result.RIOScore)
+	p.recordPoll(charKey, now, result.Keys)
 
 	cutoff := timeutil.WeeklyResetAt(now)
+	var fresh []models.CompletedKey
 	for _, key := range result.Keys {
 		if !afterCutoff(key.CompletedAt, cutoff) {
 			continue
@@ -194,13 +220,39 @@ func (p *DefaultPoller) pollCharacter(ctx context.Context, character models.Char
 		if exists {
 			continue
 		}
+		fresh = append(fresh, key)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
 
-		if err := p.store.UpsertCompletedKey(ctx, key); err != nil {
-			continue
+	if err := p.storeKeys(ctx, fresh); err != nil {
+		// Forget the keys and poll again on the next run.
+		p.mu.Lock()
+		for _, key := range fresh {
+			delete(known, key.KeyIDOrSynthetic())
 		}
+		delete(p.schedule, charKey)
+		p.mu.Unlock()
+		return err
+	}
 
+	for _, key := range fresh {
 		p.linkToWCL(ctx, key)
 	}
+	return nil
+}
+
+// storeKeys persists new keys in one batch. When a linker is available it
+// reconciles them against runs already recorded by other sources.
+func (p *DefaultPoller) storeKeys(ctx context.Context, keys []models.CompletedKey) error {
+	var err error
+	if p.wclLinker != nil {
+		_, err = p.wclLinker.ReconcileKeys(ctx, keys)
+	} else {
+		_, err = p.store.ReconcileCompletedKeys(ctx, keys)
+	}
+	return err
 }
 
 // linkToWCL attempts to link a newly detected key to WarcraftLogs.
@@ -218,7 +270,7 @@ func (p *DefaultPoller) linkToWCL(ctx context.Context, key models.CompletedKey)
 	fightID := int64(match.Run.FightID)
 
 	link := store.WarcraftLogsLink{
-		KeyID:      key.KeyID,
+		KeyID:      key.EffectiveID(),
 		ReportCode: match.Run.ReportCode,
 		FightID:    &fightID,
 		URL:        url,
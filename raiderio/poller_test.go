@@ -2,9 +2,11 @@ package raiderio
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/tnicklin/celestial_orrey/clock"
 	"github.com/tnicklin/celestial_orrey/models"
 	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
 	"github.com/tnicklin/celestial_orrey/store"
@@ -12,7 +14,7 @@ import (
 )
 
 func TestPollerFiltersKeys(t *testing.T) {
-	cutoff := timeutil.WeeklyReset()
+	cutoff := timeutil.WeeklyReset(clock.System())
 	after := cutoff.Add(24 * time.Hour).Format(time.RFC3339)
 	before := cutoff.Add(-24 * time.Hour).Format(time.RFC3339)
 
@@ -62,42 +64,117 @@ func TestPollerFiltersKeys(t *testing.T) {
 	}
 }
 
+func TestPollerSeedsKnownKeysForExactCharacter(t *testing.T) {
+	after := timeutil.WeeklyReset(clock.System()).Add(24 * time.Hour).Format(time.RFC3339)
+
+	illidan := models.CompletedKey{KeyID: 1, Character: "Arthas", Region: "us", Realm: "illidan", Dungeon: "Mists", KeyLevel: 10, CompletedAt: after, Source: "raiderio"}
+	stormrage := models.CompletedKey{KeyID: 2, Character: "Arthas", Region: "us", Realm: "stormrage", Dungeon: "Mists", KeyLevel: 12, CompletedAt: after, Source: "raiderio"}
+
+	st := &fakeStore{existing: []models.CompletedKey{illidan, stormrage}}
+	poller := New(Params{
+		Client: &fakeClient{runs: []models.CompletedKey{stormrage}},
+		Store:  st,
+	})
+
+	char := models.Character{Region: "us", Realm: "stormrage", Name: "Arthas"}
+	poller.pollCharacter(context.Background(), char)
+
+	known := poller.known[char.Key()]
+	if _, ok := known[illidan.KeyIDOrSynthetic()]; ok {
+		t.Fatal("key from another realm leaked into known set")
+	}
+	if len(st.seen) != 0 {
+		t.Fatalf("expected already-stored key to be skipped, got %d upserts", len(st.seen))
+	}
+}
+
 type fakeClient struct {
-	runs []models.CompletedKey
+	runs  []models.CompletedKey
+	err   error
+	calls int
 }
 
 func (f *fakeClient) FetchWeeklyRuns(ctx context.Context, c models.Character) (rioClient.ProfileResult, error) {
+	f.calls++
+	if f.err != nil {
+		return rioClient.ProfileResult{}, f.err
+	}
 	return rioClient.ProfileResult{Keys: f.runs}, nil
 }
 
 type fakeStore struct {
 	characters []models.Character
+	existing   []models.CompletedKey
 	seen       []models.CompletedKey
 }
 
-func (f *fakeStore) Open(ctx context.Context) error { return nil }
-func (f *fakeStore) Close() error                   { return nil }
+func (f *fakeStore) Open(ctx context.Context) error     { return nil }
+func (f *fakeStore) Close() error                       { return nil }
+func (f *fakeStore) Shutdown(ctx context.Context) error { return nil }
+func (f *fakeStore) RestoreSnapshot(ctx context.Context) (store.RestoreResult, error) {
+	return store.RestoreResult{}, nil
+}
 func (f *fakeStore) RestoreFromDisk(ctx context.Context, path string) error {
 	return nil
 }
 func (f *fakeStore) FlushToDisk(ctx context.Context, path string) error { return nil }
 func (f *fakeStore) ArchiveWeek(ctx context.Context) error              { return nil }
+func (f *fakeStore) IndexHistory(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+func (f *fakeStore) ListBackups(ctx context.Context) ([]store.BackupInfo, error) {
+	return nil, nil
+}
+func (f *fakeStore) VerifyBackups(ctx context.Context) ([]store.BackupInfo, error) {
+	return nil, nil
+}
+func (f *fakeStore) Vacuum(ctx context.Context) error { return nil }
 func (f *fakeStore) UpsertCompletedKey(ctx context.Context, key models.CompletedKey) error {
 	f.seen = append(f.seen, key)
 	return nil
 }
+func (f *fakeStore) UpsertCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]store.UpsertStatus, error) {
+	statuses := make([]store.UpsertStatus, len(keys))
+	for i, key := range keys {
+		f.seen = append(f.seen, key)
+		statuses[i] = store.UpsertInserted
+	}
+	return statuses, nil
+}
+func (f *fakeStore) ReconcileCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]store.UpsertStatus, error) {
+	return f.UpsertCompletedKeys(ctx, keys)
+}
+func (f *fakeStore) ReplaceCompletedKey(ctx context.Context, oldKeyID int64, key models.CompletedKey) error {
+	f.seen = append(f.seen, key)
+	return nil
+}
+func (f *fakeStore) AddCharacter(ctx context.Context, char models.Character) (bool, error) {
+	return false, nil
+}
 func (f *fakeStore) UpsertWarcraftLogsLink(ctx context.Context, link store.WarcraftLogsLink) error {
 	return nil
 }
 func (f *fakeStore) CountKeysByCharacterSince(ctx context.Context, cutoff time.Time) ([]store.CountRow, error) {
 	return nil, nil
 }
-func (f *fakeStore) ListKeysByCharacterSince(ctx context.Context, character string, cutoff time.Time) ([]models.CompletedKey, error) {
-	return nil, nil
+func (f *fakeStore) ListKeysByCharacterSince(ctx context.Context, name, realm, region string, cutoff time.Time) ([]models.CompletedKey, error) {
+	var out []models.CompletedKey
+	for _, key := range f.existing {
+		if strings.EqualFold(key.Character, name) && strings.EqualFold(key.Realm, realm) && strings.EqualFold(key.Region, region) {
+			out = append(out, key)
+		}
+	}
+	return out, nil
 }
 func (f *fakeStore) ListKeysSince(ctx context.Context, cutoff time.Time) ([]models.CompletedKey, error) {
 	return nil, nil
 }
+func (f *fakeStore) ListVaultProgressSince(ctx context.Context, cutoff time.Time) ([]store.VaultRow, error) {
+	return nil, nil
+}
+func (f *fakeStore) ListWeeklyHistory(ctx context.Context, name, realm, region string, weeks int) ([]store.WeekSummary, error) {
+	return nil, nil
+}
 func (f *fakeStore) ListWarcraftLogsLinksForKey(ctx context.Context, keyID int64) ([]store.WarcraftLogsLink, error) {
 	return nil, nil
 }
@@ -110,6 +187,9 @@ func (f *fakeStore) GetCharacter(ctx context.Context, name, realm, region string
 func (f *fakeStore) DeleteCharacter(ctx context.Context, name, realm, region string) error {
 	return nil
 }
+func (f *fakeStore) DeleteCompletedKey(ctx context.Context, name, realm, region string, keyID int64) error {
+	return nil
+}
 func (f *fakeStore) UpdateCharacterScore(ctx context.Context, name, realm, region string, score float64) error {
 	return nil
 }
@@ -122,3 +202,52 @@ func (f *fakeStore) UpsertElvUIVersion(ctx context.Context, v store.ElvUIVersion
 func (f *fakeStore) GetElvUIVersion(ctx context.Context) (*store.ElvUIVersion, error) {
 	return nil, nil
 }
+func (f *fakeStore) EnqueueMessage(ctx context.Context, msg store.OutboxMessage) (bool, error) {
+	return false, nil
+}
+func (f *fakeStore) RecordElvUIVersion(ctx context.Context, v store.ElvUIVersion, announce store.OutboxMessage) (bool, error) {
+	return false, nil
+}
+func (f *fakeStore) DueMessages(ctx context.Context, now time.Time, limit int) ([]store.OutboxMessage, error) {
+	return nil, nil
+}
+func (f *fakeStore) ClaimMessage(ctx context.Context, id int64, at time.Time) error {
+	return nil
+}
+func (f *fakeStore) MarkMessageSent(ctx context.Context, id int64, at time.Time) error {
+	return nil
+}
+func (f *fakeStore) MarkMessageFailed(ctx context.Context, id int64, cause string, retryAt time.Time, giveUp bool) error {
+	return nil
+}
+func (f *fakeStore) FailedMessages(ctx context.Context, limit int) ([]store.OutboxMessage, error) {
+	return nil, nil
+}
+func (f *fakeStore) RequeueMessage(ctx context.Context, id int64, at time.Time) (bool, error) {
+	return false, nil
+}
+func (f *fakeStore) PruneSentMessages(ctx context.Context, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestPollerReload(t *testing.T) {
+	poller := New(Params{
+		Config: Config{PollInterval: time.Minute, MaxConcurrent: 2},
+		Client: &fakeClient{},
+		Store:  &fakeStore{},
+	})
+	poller.known["arthas-illidan-us"] = map[string]struct{}{"1": {}}
+
+	poller.Reload(Config{PollInterval: 5 * time.Minute, MaxConcurrent: 4})
+
+	cfg := poller.settings()
+	if cfg.PollInterval != 5*time.Minute || cfg.MaxConcurrent != 4 {
+		t.Fatalf("settings = %s, %d; want 5m, 4", cfg.PollInterval, cfg.MaxConcurrent)
+	}
+	if len(poller.known) != 1 {
+		t.Fatal("reload dropped the known keys")
+	}
+	if got := poller.Job().Interval; got != 5*time.Minute {
+		t.Fatalf("job interval = %s, want 5m", got)
+	}
+}
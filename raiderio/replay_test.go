@@ -0,0 +1,44 @@
+package raiderio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	rioClient "github.com/tnicklin/celestial_orrey/raiderio/client"
+	"github.com/tnicklin/celestial_orrey/replay"
+)
+
+// TestPollerReplaysProfile polls a recorded RaiderIO profile: of its three
+// weekly runs, the one before the Tuesday reset is dropped.
+func TestPollerReplaysProfile(t *testing.T) {
+	httpClient, err := replay.Client(replay.Config{Mode: replay.ModeReplay, Dir: "testdata/replay"}, time.Second)
+	if err != nil {
+		t.Fatalf("replay client: %v", err)
+	}
+	arthas := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+	st := &fakeStore{characters: []models.Character{arthas}}
+
+	poller := New(Params{
+		Config: Config{PollInterval: time.Minute},
+		Client: rioClient.New(rioClient.Params{BaseURL: "https://raider.io", HTTPClient: httpClient}),
+		Store:  st,
+		Clock:  &fixedClock{now: pacific(5, 20, 0)},
+	})
+	if err := poller.pollAllCharacters(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	if len(st.seen) != 2 {
+		t.Fatalf("expected 2 keys this week, got %+v", st.seen)
+	}
+	for _, key := range st.seen {
+		if key.Character != "arthas" || key.Source != models.SourceRaiderIO {
+			t.Errorf("unexpected key %+v", key)
+		}
+	}
+	if st.seen[0].KeyID != 41234567 || st.seen[0].KeyLevel != 12 {
+		t.Errorf("first key = %+v", st.seen[0])
+	}
+}
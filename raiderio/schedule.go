@@ -0,0 +1,181 @@
+package raiderio
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+const (
+	// _playStartHour and _playEndHour bound the Pacific hours most keys are
+	// run in. Idle characters are polled _offHoursFactor times less often
+	// outside them.
+	_playStartHour  = 12
+	_playEndHour    = 2
+	_offHoursFactor = 4
+	// _refreshHour and _refreshMinute (Pacific) come shortly before the
+	// daily report at 07:00; every character is polled again from then on,
+	// so the report never reads overnight scores.
+	_refreshHour   = 6
+	_refreshMinute = 30
+	// _dueTolerance is the fraction of PollInterval a character may be
+	// polled early by. The job's runs are jittered by as much, so without it
+	// a character due one interval out would wait for a second run.
+	_dueTolerance = 0.1
+)
+
+// ScheduledPoll is one character's place in the poll schedule.
+type ScheduledPoll struct {
+	Character string
+	// Active is set while the character's latest key is within the
+	// configured active window.
+	Active     bool
+	LastPoll   time.Time
+	LastActive time.Time
+	NextPoll   time.Time
+	// Failures counts fetches that failed since the last successful poll.
+	Failures int
+}
+
+// pollState is when a character was last polled and last seen playing.
+type pollState struct {
+	lastPoll   time.Time
+	lastActive time.Time
+	next       time.Time
+	failures   int
+}
+
+// Schedule returns the characters polled so far, soonest first. Characters
+// not yet polled are due on the next run.
+func (p *DefaultPoller) Schedule() []ScheduledPoll {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	out := make([]ScheduledPoll, 0, len(p.schedule))
+	for char, st := range p.schedule {
+		out = append(out, ScheduledPoll{
+			Character:  char,
+			Active:     isActive(st.lastActive, now, p.cfg.ActiveWindow),
+			LastPoll:   st.lastPoll,
+			LastActive: st.lastActive,
+			NextPoll:   st.next,
+			Failures:   st.failures,
+		})
+	}
+	slices.SortFunc(out, func(a, b ScheduledPoll) int {
+		if c := a.NextPoll.Compare(b.NextPoll); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Character, b.Character)
+	})
+	return out
+}
+
+// due returns the characters whose next poll has come, or comes within
+// _dueTolerance of PollInterval, including any never polled.
+func (p *DefaultPoller) due(characters []models.Character, now time.Time) []models.Character {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cutoff := now.Add(time.Duration(float64(p.cfg.PollInterval) * _dueTolerance))
+	var out []models.Character
+	for _, char := range characters {
+		st, ok := p.schedule[char.Key()]
+		if !ok || !cutoff.Before(st.next) {
+			out = append(out, char)
+		}
+	}
+	return out
+}
+
+// recordPoll schedules a character's next poll after a successful fetch of
+// keys, the character's runs this week.
+func (p *DefaultPoller) recordPoll(charKey string, now time.Time, keys []models.CompletedKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	st, ok := p.schedule[charKey]
+	if !ok {
+		st = &pollState{}
+		p.schedule[charKey] = st
+	}
+	for _, key := range keys {
+		if t, err := timeutil.ParseRFC3339(key.CompletedAt); err == nil && t.After(st.lastActive) {
+			st.lastActive = t
+		}
+	}
+	st.lastPoll = now
+	st.failures = 0
+	st.next = nextPoll(now, st.lastActive, p.cfg)
+}
+
+// recordFailure backs off a character whose fetch failed, doubling the wait
+// from PollInterval with each consecutive failure up to the longest idle
+// wait, so a renamed or missing character is not fetched on every run.
+func (p *DefaultPoller) recordFailure(charKey string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	st, ok := p.schedule[charKey]
+	if !ok {
+		st = &pollState{}
+		p.schedule[charKey] = st
+	}
+	st.failures++
+	st.next = now.Add(failureBackoff(st.failures, p.cfg))
+}
+
+// failureBackoff returns the wait after the given number of consecutive
+// failed fetches.
+func failureBackoff(failures int, cfg Config) time.Duration {
+	limit := max(cfg.IdlePollInterval*_offHoursFactor, cfg.PollInterval)
+	wait := cfg.PollInterval
+	for i := 1; i < failures && wait < limit; i++ {
+		wait *= 2
+	}
+	return min(wait, limit)
+}
+
+// nextPoll returns when to poll a character next: every PollInterval while
+// it is active, every IdlePollInterval during play hours, and less often
+// overnight, but never later than the next refresh before the daily report.
+func nextPoll(now, lastActive time.Time, cfg Config) time.Time {
+	wait := cfg.PollInterval
+	if !isActive(lastActive, now, cfg.ActiveWindow) {
+		wait = cfg.IdlePollInterval
+		if !inPlayHours(now) {
+			wait *= _offHoursFactor
+		}
+	}
+	// The job only runs every PollInterval, so nothing is due sooner.
+	wait = max(wait, cfg.PollInterval)
+
+	next := now.Add(wait)
+	if refresh := nextRefresh(now); next.After(refresh) {
+		return refresh
+	}
+	return next
+}
+
+func isActive(lastActive, now time.Time, window time.Duration) bool {
+	return !lastActive.IsZero() && now.Sub(lastActive) < window
+}
+
+func inPlayHours(now time.Time) bool {
+	hour := now.In(timeutil.Location()).Hour()
+	return hour >= _playStartHour || hour < _playEndHour
+}
+
+// nextRefresh returns the first refresh time after now.
+func nextRefresh(now time.Time) time.Time {
+	local := now.In(timeutil.Location())
+	refresh := time.Date(local.Year(), local.Month(), local.Day(), _refreshHour, _refreshMinute, 0, 0, local.Location())
+	if !refresh.After(now) {
+		refresh = refresh.AddDate(0, 0, 1)
+	}
+	return refresh
+}
@@ -0,0 +1,147 @@
+package raiderio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+type fixedClock struct{ now time.Time }
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+func pacific(day, hour, minute int) time.Time {
+	return time.Date(2026, 2, day, hour, minute, 0, 0, timeutil.Location())
+}
+
+func TestNextPoll(t *testing.T) {
+	cfg := Config{PollInterval: time.Minute, ActiveWindow: 2 * time.Hour, IdlePollInterval: 30 * time.Minute}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		lastActive time.Time
+		want       time.Time
+	}{
+		{"active", pacific(5, 20, 0), pacific(5, 19, 30), pacific(5, 20, 1)},
+		{"active overnight", pacific(5, 3, 0), pacific(5, 2, 30), pacific(5, 3, 1)},
+		{"idle in play hours", pacific(5, 20, 0), pacific(5, 17, 0), pacific(5, 20, 30)},
+		{"never played", pacific(5, 20, 0), time.Time{}, pacific(5, 20, 30)},
+		{"idle overnight", pacific(5, 3, 0), time.Time{}, pacific(5, 5, 0)},
+		{"capped at refresh", pacific(5, 5, 45), time.Time{}, pacific(5, 6, 30)},
+		{"refresh tomorrow", pacific(5, 6, 30), time.Time{}, pacific(5, 8, 30)},
+	}
+	for _, tt := range tests {
+		if got := nextPoll(tt.now, tt.lastActive, cfg); !got.Equal(tt.want) {
+			t.Errorf("%s: nextPoll = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPollerPollsDueCharacters(t *testing.T) {
+	clk := &fixedClock{now: pacific(5, 20, 0)}
+	played := models.CompletedKey{
+		KeyID:       1,
+		Character:   "Arthas",
+		Region:      "us",
+		Realm:       "illidan",
+		Dungeon:     "Mists",
+		KeyLevel:    10,
+		CompletedAt: pacific(5, 19, 45).Format(time.RFC3339),
+		Source:      "raiderio",
+	}
+	arthas := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+	jaina := models.Character{Region: "us", Realm: "stormrage", Name: "Jaina"}
+
+	poller := New(Params{
+		Config: Config{PollInterval: time.Minute},
+		Client: &fakeClient{},
+		Store:  &fakeStore{characters: []models.Character{arthas, jaina}},
+		Clock:  clk,
+	})
+	poller.recordPoll(arthas.Key(), clk.now, []models.CompletedKey{played})
+	poller.recordPoll(jaina.Key(), clk.now, nil)
+
+	schedule := poller.Schedule()
+	if len(schedule) != 2 || schedule[0].Character != arthas.Key() || !schedule[0].Active || schedule[1].Active {
+		t.Fatalf("unexpected schedule: %+v", schedule)
+	}
+
+	clk.now = clk.now.Add(5 * time.Minute)
+	due := poller.due([]models.Character{arthas, jaina}, clk.now)
+	if len(due) != 1 || due[0] != arthas {
+		t.Fatalf("expected only the active character due, got %+v", due)
+	}
+
+	if err := poller.pollAllCharacters(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if got := poller.Schedule()[1]; got.Character != jaina.Key() || !got.LastPoll.Equal(pacific(5, 20, 0)) {
+		t.Errorf("idle character was polled early: %+v", got)
+	}
+}
+
+func TestPollerDueToleratesJitter(t *testing.T) {
+	clk := &fixedClock{now: pacific(5, 20, 0)}
+	arthas := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+	poller := New(Params{
+		Config: Config{PollInterval: 10 * time.Minute, IdlePollInterval: 10 * time.Minute},
+		Client: &fakeClient{},
+		Store:  &fakeStore{characters: []models.Character{arthas}},
+		Clock:  clk,
+	})
+	poller.recordPoll(arthas.Key(), clk.now, nil)
+
+	// The job ran early by its full jitter; the character is still due.
+	if due := poller.due([]models.Character{arthas}, clk.now.Add(9*time.Minute)); len(due) != 1 {
+		t.Fatalf("expected character due within tolerance, got %+v", due)
+	}
+	if due := poller.due([]models.Character{arthas}, clk.now.Add(8*time.Minute)); len(due) != 0 {
+		t.Fatalf("expected character not yet due, got %+v", due)
+	}
+}
+
+func TestPollerBacksOffFailedFetches(t *testing.T) {
+	clk := &fixedClock{now: pacific(5, 20, 0)}
+	arthas := models.Character{Region: "us", Realm: "illidan", Name: "Arthas"}
+	client := &fakeClient{err: errors.New("404 Not Found")}
+	poller := New(Params{
+		Config: Config{PollInterval: time.Minute, IdlePollInterval: 30 * time.Minute},
+		Client: client,
+		Store:  &fakeStore{characters: []models.Character{arthas}},
+		Clock:  clk,
+	})
+
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if err := poller.pollAllCharacters(context.Background()); err == nil {
+			t.Fatalf("poll %d: expected error", i)
+		}
+		got := poller.Schedule()[0]
+		if got.Failures != i+1 || !got.NextPoll.Equal(clk.now.Add(want)) {
+			t.Fatalf("poll %d: unexpected schedule %+v, want next in %s", i, got, want)
+		}
+		clk.now = got.NextPoll
+	}
+	if client.calls != 3 {
+		t.Fatalf("expected one fetch per due run, got %d", client.calls)
+	}
+
+	client.err = nil
+	if err := poller.pollAllCharacters(context.Background()); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if got := poller.Schedule()[0]; got.Failures != 0 {
+		t.Fatalf("expected success to reset failures, got %+v", got)
+	}
+}
+
+func TestFailureBackoffIsCapped(t *testing.T) {
+	cfg := Config{PollInterval: time.Minute, IdlePollInterval: 30 * time.Minute}
+	if got := failureBackoff(20, cfg); got != 2*time.Hour {
+		t.Fatalf("failureBackoff = %s, want 2h", got)
+	}
+}
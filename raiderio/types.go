@@ -1,9 +1,10 @@
 package raiderio
 
-import "context"
+import "github.com/tnicklin/celestial_orrey/jobs"
 
 // Poller defines the interface for polling RaiderIO for new M+ keys.
 type Poller interface {
-	Start(ctx context.Context) error
-	Stop()
+	Job() jobs.Job
+	Reload(cfg Config)
+	Schedule() []ScheduledPoll
 }
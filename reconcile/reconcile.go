@@ -0,0 +1,154 @@
+// Package reconcile decides when keys reported by different sources
+// describe the same run, so the store keeps only the most trusted copy.
+package reconcile
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+// Window is how far apart two API keys may complete and still be treated as
+// the same run.
+const Window = 30 * time.Minute
+
+// Since returns the earliest completion time of a stored key that could
+// describe the same run as key: the start of its reset week, where a manual
+// key for the run may sit, or Window before it if that is earlier. ok is
+// false if key has no usable time.
+func Since(key models.CompletedKey) (since time.Time, ok bool) {
+	keyTime, err := timeutil.ParseRFC3339(key.CompletedAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return minTime(timeutil.WeeklyResetAt(keyTime), keyTime.Add(-Window)), true
+}
+
+// Match returns the key among candidates, from a source other than key's,
+// that describes the same run as key.
+func Match(key models.CompletedKey, candidates []models.CompletedKey) (models.CompletedKey, bool) {
+	keyTime, err := timeutil.ParseRFC3339(key.CompletedAt)
+	if err != nil {
+		return models.CompletedKey{}, false
+	}
+
+	keyID := key.EffectiveID()
+	for _, candidate := range candidates {
+		if candidate.KeyID == keyID || candidate.Source == key.Source {
+			continue
+		}
+		if isManual(key, candidate) {
+			if manualRunKey(key) == manualRunKey(candidate) {
+				return candidate, true
+			}
+			continue
+		}
+		if !SameDungeon(key.Dungeon, candidate.Dungeon) {
+			continue
+		}
+		candidateTime, err := timeutil.ParseRFC3339(candidate.CompletedAt)
+		if err != nil || absDuration(keyTime.Sub(candidateTime)) > Window {
+			continue
+		}
+		return candidate, true
+	}
+	return models.CompletedKey{}, false
+}
+
+// Wins reports whether key should replace existing, a stored key for the
+// same run from another source.
+func Wins(key, existing models.CompletedKey) bool {
+	return models.SourcePriority(key.Source) > models.SourcePriority(existing.Source)
+}
+
+// SameDungeon reports whether two dungeon names refer to the same dungeon,
+// ignoring case and punctuation and allowing one to be a shortened form of
+// the other.
+func SameDungeon(a, b string) bool {
+	a, b = normalizeName(a), normalizeName(b)
+	if a == "" || b == "" {
+		return false
+	}
+	return a == b || strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+// LogConflicts logs where key and existing, two reports of the same run,
+// disagree on its level or run time.
+func LogConflicts(log logger.Logger, key, existing models.CompletedKey) {
+	if log == nil {
+		return
+	}
+	if key.KeyLevel != existing.KeyLevel {
+		log.WarnW("key level conflict between sources",
+			"character", key.Character,
+			"dungeon", key.Dungeon,
+			"source", key.Source,
+			"level", key.KeyLevel,
+			"existing_source", existing.Source,
+			"existing_level", existing.KeyLevel,
+		)
+	}
+	if key.RunTimeMS > 0 && existing.RunTimeMS > 0 && math.Abs(float64(key.RunTimeMS-existing.RunTimeMS)) > 5000 {
+		log.WarnW("run time conflict between sources",
+			"character", key.Character,
+			"dungeon", key.Dungeon,
+			"source", key.Source,
+			"run_time_ms", key.RunTimeMS,
+			"existing_source", existing.Source,
+			"existing_run_time_ms", existing.RunTimeMS,
+		)
+	}
+}
+
+// manualRunKey identifies a run the way a member recalls it: character,
+// dungeon, level and reset week. A manual key's completion time is only as
+// accurate as that recollection, so manual keys match other sources on this
+// key rather than on time. It is empty if key has no usable time.
+func manualRunKey(key models.CompletedKey) string {
+	completed, err := timeutil.ParseRFC3339(key.CompletedAt)
+	if err != nil {
+		return ""
+	}
+	return strings.Join([]string{
+		strings.ToLower(key.Region),
+		strings.ToLower(key.Realm),
+		strings.ToLower(key.Character),
+		normalizeName(key.Dungeon),
+		strconv.Itoa(key.KeyLevel),
+		timeutil.WeeklyResetAt(completed).UTC().Format(time.RFC3339),
+	}, "|")
+}
+
+func isManual(a, b models.CompletedKey) bool {
+	return a.Source == models.SourceManual || b.Source == models.SourceManual
+}
+
+func normalizeName(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
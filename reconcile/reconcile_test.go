@@ -0,0 +1,174 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "lowercase letters only",
+			input: "hello",
+			want:  "hello",
+		},
+		{
+			name:  "mixed case",
+			input: "HelloWorld",
+			want:  "helloworld",
+		},
+		{
+			name:  "with spaces",
+			input: "Hello World",
+			want:  "helloworld",
+		},
+		{
+			name:  "with numbers",
+			input: "Test123",
+			want:  "test123",
+		},
+		{
+			name:  "with special characters",
+			input: "Mists of Tirna Scithe",
+			want:  "mistsoftirnascithe",
+		},
+		{
+			name:  "with apostrophes",
+			input: "Ara-Kara, City of Echoes",
+			want:  "arakaracityofechoes",
+		},
+		{
+			name:  "empty string",
+			input: "",
+			want:  "",
+		},
+		{
+			name:  "only special characters",
+			input: "!@#$%",
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeName(tt.input)
+			if got != tt.want {
+				t.Errorf("normalizeName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	completed := time.Date(2026, 2, 4, 1, 23, 45, 0, time.UTC)
+	rio := models.CompletedKey{
+		KeyID:       9001,
+		Character:   "arthas",
+		Realm:       "illidan",
+		Region:      "us",
+		Dungeon:     "Mists of Tirna Scithe",
+		KeyLevel:    12,
+		RunTimeMS:   1500000,
+		CompletedAt: completed.Format(time.RFC3339),
+		Source:      models.SourceRaiderIO,
+	}
+	at := func(key models.CompletedKey, source string, offset time.Duration) models.CompletedKey {
+		key.KeyID = 0
+		key.Source = source
+		key.CompletedAt = completed.Add(offset).Format(time.RFC3339)
+		return key
+	}
+
+	tests := []struct {
+		name       string
+		key        models.CompletedKey
+		candidates []models.CompletedKey
+		want       bool
+	}{
+		{
+			name:       "blizzard copy of a raiderio key",
+			key:        at(rio, models.SourceBlizzard, 40*time.Second),
+			candidates: []models.CompletedKey{rio},
+			want:       true,
+		},
+		{
+			name:       "raiderio copy of a blizzard key",
+			key:        rio,
+			candidates: []models.CompletedKey{at(rio, models.SourceBlizzard, -time.Minute)},
+			want:       true,
+		},
+		{
+			name:       "same source is not a duplicate",
+			key:        at(rio, models.SourceRaiderIO, time.Minute),
+			candidates: []models.CompletedKey{rio},
+			want:       false,
+		},
+		{
+			name:       "outside the window",
+			key:        at(rio, models.SourceBlizzard, time.Hour),
+			candidates: []models.CompletedKey{rio},
+			want:       false,
+		},
+		{
+			name:       "manual key recalled hours off",
+			key:        at(rio, models.SourceManual, 8*time.Hour),
+			candidates: []models.CompletedKey{rio},
+			want:       true,
+		},
+		{
+			name: "manual key at another level",
+			key: func() models.CompletedKey {
+				k := at(rio, models.SourceManual, 0)
+				k.KeyLevel = 13
+				return k
+			}(),
+			candidates: []models.CompletedKey{rio},
+			want:       false,
+		},
+		{
+			name:       "manual key from the previous week",
+			key:        at(rio, models.SourceManual, -7*24*time.Hour),
+			candidates: []models.CompletedKey{rio},
+			want:       false,
+		},
+		{
+			name: "different dungeon",
+			key: func() models.CompletedKey {
+				k := at(rio, models.SourceBlizzard, 0)
+				k.Dungeon = "The Necrotic Wake"
+				return k
+			}(),
+			candidates: []models.CompletedKey{rio},
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := Match(tt.key, tt.candidates)
+			if got != tt.want {
+				t.Errorf("Match() found = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWins(t *testing.T) {
+	rio := models.CompletedKey{Source: models.SourceRaiderIO}
+	blizzard := models.CompletedKey{Source: models.SourceBlizzard}
+	if !Wins(rio, blizzard) {
+		t.Error("expected a raiderio key to replace a blizzard one")
+	}
+	if Wins(blizzard, rio) {
+		t.Error("expected a blizzard key not to replace a raiderio one")
+	}
+	if Wins(rio, rio) {
+		t.Error("expected a key not to replace one of equal priority")
+	}
+}
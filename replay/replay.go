@@ -0,0 +1,247 @@
+// Package replay records the responses of external HTTP APIs to fixture
+// files and plays them back, so the bot can run offline against captured
+// data and tests can exercise real payloads deterministically.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Modes select what a Transport does with requests.
+const (
+	// ModeOff sends requests to the network untouched.
+	ModeOff = ""
+	// ModeRecord sends requests to the network and saves each response.
+	ModeRecord = "record"
+	// ModeReplay answers requests from saved responses only.
+	ModeReplay = "replay"
+)
+
+// _redacted replaces recorded OAuth access tokens.
+const _redacted = "replay-token"
+
+// ErrNoFixture is returned in replay mode for a request that was never
+// recorded.
+var ErrNoFixture = errors.New("no recorded response")
+
+// Config selects record or replay mode for the external API clients.
+type Config struct {
+	// Mode is "record", "replay" or empty to disable.
+	Mode string `yaml:"mode"`
+	// Dir holds the fixture files.
+	Dir string `yaml:"dir"`
+}
+
+// Enabled reports whether responses are recorded or replayed.
+func (c Config) Enabled() bool {
+	return c.Mode != ModeOff
+}
+
+// Transport is an http.RoundTripper that records or replays responses.
+// Requests are matched on method, URL and body; headers such as
+// Authorization are ignored, so fixtures never hold credentials.
+type Transport struct {
+	mode string
+	dir  string
+	next http.RoundTripper
+}
+
+// Params holds configuration for creating a Transport.
+type Params struct {
+	Config Config
+	// Next sends requests in record mode. Nil uses http.DefaultTransport.
+	Next http.RoundTripper
+}
+
+// NewTransport creates a Transport for p.Config.
+func NewTransport(p Params) (*Transport, error) {
+	switch p.Config.Mode {
+	case ModeRecord, ModeReplay:
+	default:
+		return nil, fmt.Errorf("replay: unknown mode %q", p.Config.Mode)
+	}
+	if p.Config.Dir == "" {
+		return nil, errors.New("replay: dir is required")
+	}
+
+	next := p.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{mode: p.Config.Mode, dir: p.Config.Dir, next: next}, nil
+}
+
+// Client returns an HTTP client with the given timeout that records or
+// replays according to cfg, or a plain client when cfg is disabled.
+func Client(cfg Config, timeout time.Duration) (*http.Client, error) {
+	if !cfg.Enabled() {
+		return &http.Client{Timeout: timeout}, nil
+	}
+	t, err := NewTransport(Params{Config: cfg})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Timeout: timeout, Transport: t}, nil
+}
+
+// fixture is a recorded exchange, stored as indented JSON so it can be
+// read and edited by hand.
+type fixture struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body"`
+}
+
+// RoundTrip records or replays req.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	path := t.fixturePath(req, reqBody)
+
+	if t.mode == ModeReplay {
+		return t.replay(req, path)
+	}
+	return t.record(req, path, reqBody)
+}
+
+func (t *Transport) replay(req *http.Request, path string) (*http.Response, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("replay: %s %s: %w", req.Method, req.URL, ErrNoFixture)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f fixture
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("replay: %s: %w", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          io.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) record(req *http.Request, path, reqBody string) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	f := fixture{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: reqBody,
+		Status:      resp.StatusCode,
+		Header:      header,
+		Body:        redactTokens(body),
+	}
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return resp, nil
+}
+
+// fixturePath names the fixture for a request: a directory per host and a
+// file named after the path, suffixed with a hash of the whole request so
+// different queries and bodies do not collide.
+func (t *Transport) fixturePath(req *http.Request, body string) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String() + "\n" + body))
+	name := fmt.Sprintf("%s-%s-%s.json", strings.ToLower(req.Method), slug(req.URL.Path), hex.EncodeToString(sum[:])[:12])
+	return filepath.Join(t.dir, slug(req.URL.Host), name)
+}
+
+// readBody reads req's body and puts it back so it can still be sent.
+func readBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
+
+// redactTokens replaces the access token in an OAuth token response.
+func redactTokens(body []byte) string {
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return string(body)
+	}
+	if _, ok := payload["access_token"]; !ok {
+		return string(body)
+	}
+	payload["access_token"] = _redacted
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return string(body)
+	}
+	return string(raw)
+}
+
+// slug makes s safe and short enough for a file name.
+func slug(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' {
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	out := strings.TrimSuffix(sb.String(), "-")
+	if len(out) > 60 {
+		out = out[:60]
+	}
+	if out == "" {
+		out = "root"
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package replay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			_, _ = w.Write([]byte(`{"access_token":"live-secret","expires_in":3600}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(`{"echo":"` + r.URL.Query().Get("name") + string(body) + `"}`))
+	}))
+
+	rec, err := Client(Config{Mode: ModeRecord, Dir: dir}, time.Second)
+	if err != nil {
+		t.Fatalf("record client: %v", err)
+	}
+	get := func(c *http.Client, url string) (int, string, error) {
+		t.Helper()
+		resp, err := c.Get(url)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), nil
+	}
+	post := func(c *http.Client, url, body string) (string, error) {
+		t.Helper()
+		resp, err := c.Post(url, "text/plain", strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		out, _ := io.ReadAll(resp.Body)
+		return string(out), nil
+	}
+
+	if _, body, err := get(rec, server.URL+"/profile?name=arthas"); err != nil || body != `{"echo":"arthas"}` {
+		t.Fatalf("record get = %q, %v", body, err)
+	}
+	if body, err := post(rec, server.URL+"/graphql", "a"); err != nil || body != `{"echo":"a"}` {
+		t.Fatalf("record post = %q, %v", body, err)
+	}
+	if body, err := post(rec, server.URL+"/graphql", "b"); err != nil || body != `{"echo":"b"}` {
+		t.Fatalf("record post = %q, %v", body, err)
+	}
+	if _, err := post(rec, server.URL+"/oauth/token", "grant_type=client_credentials"); err != nil {
+		t.Fatalf("record token: %v", err)
+	}
+	server.Close()
+
+	play, err := Client(Config{Mode: ModeReplay, Dir: dir}, time.Second)
+	if err != nil {
+		t.Fatalf("replay client: %v", err)
+	}
+	status, body, err := get(play, server.URL+"/profile?name=arthas")
+	if err != nil || status != http.StatusOK || body != `{"echo":"arthas"}` {
+		t.Fatalf("replay get = %d %q, %v", status, body, err)
+	}
+	if body, err := post(play, server.URL+"/graphql", "b"); err != nil || body != `{"echo":"b"}` {
+		t.Fatalf("replay post = %q, %v", body, err)
+	}
+	if _, _, err := get(play, server.URL+"/profile?name=jaina"); !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("expected ErrNoFixture, got %v", err)
+	}
+
+	// Nothing secret is written to the fixtures.
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(string(raw), "live-secret") || strings.Contains(string(raw), "session=abc") {
+			t.Errorf("%s holds a secret:\n%s", path, raw)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk fixtures: %v", err)
+	}
+}
+
+func TestNewTransportValidates(t *testing.T) {
+	if _, err := NewTransport(Params{Config: Config{Mode: "rewind", Dir: "x"}}); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+	if _, err := NewTransport(Params{Config: Config{Mode: ModeReplay}}); err == nil {
+		t.Error("expected error for missing dir")
+	}
+}
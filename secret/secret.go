@@ -0,0 +1,39 @@
+// Package secret holds credentials that must not leak into logs or dumps.
+package secret
+
+// _redacted replaces a set secret wherever it is printed.
+const _redacted = "[redacted]"
+
+// String is a config value such as a token or password. It reads from YAML
+// like a plain string, but prints, logs and marshals as "[redacted]" when
+// set. Use Reveal to get the value itself.
+type String string
+
+// Reveal returns the secret value.
+func (s String) Reveal() string {
+	return string(s)
+}
+
+// IsSet reports whether the secret has a value.
+func (s String) IsSet() bool {
+	return s != ""
+}
+
+func (s String) String() string {
+	if s == "" {
+		return ""
+	}
+	return _redacted
+}
+
+func (s String) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+func (s String) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
+
+func (s String) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
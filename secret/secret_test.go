@@ -0,0 +1,43 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStringRedacts(t *testing.T) {
+	type config struct {
+		Token String `json:"token"`
+		Empty String `json:"empty"`
+	}
+	cfg := config{Token: "hunter2"}
+
+	outputs := map[string]string{
+		"%s":  fmt.Sprintf("%s", cfg.Token),
+		"%v":  fmt.Sprintf("%v", cfg),
+		"%+v": fmt.Sprintf("%+v", cfg),
+		"%#v": fmt.Sprintf("%#v", cfg),
+	}
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	outputs["json"] = string(raw)
+
+	for name, out := range outputs {
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("%s leaked the secret: %s", name, out)
+		}
+	}
+	if string(raw) != `{"token":"[redacted]","empty":""}` {
+		t.Errorf("unexpected json: %s", raw)
+	}
+	if cfg.Token.Reveal() != "hunter2" {
+		t.Errorf("Reveal() = %q", cfg.Token.Reveal())
+	}
+	if cfg.Empty.IsSet() || !cfg.Token.IsSet() {
+		t.Error("IsSet mismatch")
+	}
+}
@@ -0,0 +1,438 @@
+package store
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// BackupInfo describes one archive in the backup directory and the result of
+// its most recent integrity check.
+type BackupInfo struct {
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+	// CheckedAt is zero if the archive has not been verified since startup.
+	CheckedAt time.Time
+	// Err holds the integrity check failure, or is empty if the check passed.
+	Err string
+}
+
+// Healthy reports whether the archive passed its last integrity check.
+func (b BackupInfo) Healthy() bool {
+	return !b.CheckedAt.IsZero() && b.Err == ""
+}
+
+// ListBackups returns the snapshot, if configured, followed by the archives
+// in the backup directory, newest first, each with the result of its last
+// integrity check.
+func (s *SQLiteStore) ListBackups(ctx context.Context) ([]BackupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths, err := s.backupPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]BackupInfo, 0, len(paths))
+	for _, path := range paths {
+		out = append(out, s.backupInfo(path))
+	}
+	return out, nil
+}
+
+// VerifyBackups runs an integrity check against the snapshot and every
+// archive, returning the results in the same order as ListBackups. The
+// returned error joins every failed check. The checks run without holding
+// the store lock; the snapshot is checked from a copy.
+func (s *SQLiteStore) VerifyBackups(ctx context.Context) ([]BackupInfo, error) {
+	s.mu.RLock()
+	paths, err := s.backupPaths()
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]BackupInfo, 0, len(paths))
+	var errs []error
+	for _, path := range paths {
+		var err error
+		if path == s.snapshotPath {
+			err = s.verifySnapshot(ctx)
+		} else {
+			err = s.verifyBackup(ctx, path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+		}
+		out = append(out, s.backupInfo(path))
+	}
+	return out, errors.Join(errs...)
+}
+
+func (s *SQLiteStore) backupPaths() ([]string, error) {
+	archives, err := s.listArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	if s.snapshotPath != "" {
+		if _, err := os.Stat(s.snapshotPath); err == nil {
+			paths = append(paths, s.snapshotPath)
+		}
+	}
+	for i := len(archives) - 1; i >= 0; i-- {
+		paths = append(paths, archives[i])
+	}
+	return paths, nil
+}
+
+// verifyBackup runs PRAGMA integrity_check against the backup at path,
+// decompressing it first if needed, and records the result. The file must
+// not be written to during the check.
+func (s *SQLiteStore) verifyBackup(ctx context.Context, path string) error {
+	err := withArchiveFile(path, func(dbPath string) error {
+		return integrityCheck(ctx, dbPath)
+	})
+	s.recordCheck(path, err)
+	return err
+}
+
+// verifySnapshot checks a copy of the snapshot read under s.mu, so flushes
+// are only blocked while it is copied.
+func (s *SQLiteStore) verifySnapshot(ctx context.Context) error {
+	s.mu.RLock()
+	raw, err := os.ReadFile(s.snapshotPath)
+	s.mu.RUnlock()
+	if err != nil {
+		s.recordCheck(s.snapshotPath, err)
+		return err
+	}
+	return s.verifySnapshotData(ctx, raw)
+}
+
+// verifySnapshotData checks raw, a copy of the snapshot, and records the
+// result against the snapshot path.
+func (s *SQLiteStore) verifySnapshotData(ctx context.Context, raw []byte) error {
+	tmp, err := os.CreateTemp("", "celestial_orrey_snapshot_*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	err = integrityCheck(ctx, tmp.Name())
+	s.recordCheck(s.snapshotPath, err)
+	return err
+}
+
+// recordCheck stores the result of an integrity check for ListBackups.
+func (s *SQLiteStore) recordCheck(path string, err error) {
+	result := BackupInfo{Path: path, CheckedAt: s.clock.Now()}
+	if err != nil {
+		result.Err = err.Error()
+	}
+	s.checksMu.Lock()
+	if s.checks == nil {
+		s.checks = make(map[string]BackupInfo)
+	}
+	s.checks[path] = result
+	s.checksMu.Unlock()
+
+	if s.logger != nil {
+		if err != nil {
+			s.logger.ErrorW("backup integrity check failed", "path", path, "error", err)
+		} else {
+			s.logger.DebugW("backup integrity check passed", "path", path)
+		}
+	}
+}
+
+func (s *SQLiteStore) backupInfo(path string) BackupInfo {
+	s.checksMu.Lock()
+	info := s.checks[path]
+	s.checksMu.Unlock()
+
+	info.Path = path
+	if stat, err := os.Stat(path); err == nil {
+		info.Size = stat.Size()
+		info.CreatedAt = stat.ModTime()
+	}
+	if isArchiveName(filepath.Base(path)) {
+		info.CreatedAt = archiveTime(path)
+	}
+	return info
+}
+
+// compressArchive compresses the database at path with the configured
+// algorithm, removes the original and returns the new path. The compressed
+// file is written under a temporary name and renamed once complete, so a
+// failure never leaves a partial archive behind.
+func (s *SQLiteStore) compressArchive(path string) (string, error) {
+	ext, err := compressionExt(s.backupCfg.Compression)
+	if err != nil || ext == "" {
+		return path, err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dstPath := path + ext
+	// The temporary name does not look like an archive to listArchives.
+	dst, err := os.CreateTemp(filepath.Dir(path), filepath.Base(dstPath)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := dst.Name()
+	if err := writeCompressed(dst, src, s.backupCfg.Compression); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	return dstPath, nil
+}
+
+// writeCompressed compresses src into dst and closes dst.
+func writeCompressed(dst *os.File, src io.Reader, compression string) error {
+	w, err := newCompressor(dst, compression)
+	if err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		_ = w.Close()
+		_ = dst.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// compressionExt returns the file extension for a compression setting, or an
+// empty string for none.
+func compressionExt(compression string) (string, error) {
+	switch compression {
+	case "", CompressionNone:
+		return "", nil
+	case CompressionGzip:
+		return ".gz", nil
+	case CompressionZstd:
+		return ".zst", nil
+	default:
+		return "", fmt.Errorf("unknown backup compression %q", compression)
+	}
+}
+
+// newCompressor wraps w in a compressing writer. Closing it flushes the
+// compressed stream but leaves w open.
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown backup compression %q", compression)
+	}
+}
+
+// pruneArchives deletes archives outside the retention policy: the newest
+// KeepWeekly archives and the newest archive of each of the last KeepMonthly
+// months are kept. With neither set, every archive is kept.
+func (s *SQLiteStore) pruneArchives() ([]string, error) {
+	if s.backupCfg.KeepWeekly <= 0 && s.backupCfg.KeepMonthly <= 0 {
+		return nil, nil
+	}
+
+	paths, err := s.listArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := expiredArchives(paths, s.backupCfg.KeepWeekly, s.backupCfg.KeepMonthly)
+	var removed []string
+	for _, path := range expired {
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		s.checksMu.Lock()
+		delete(s.checks, path)
+		s.checksMu.Unlock()
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// expiredArchives returns the archives that fall outside the retention
+// policy.
+func expiredArchives(paths []string, keepWeekly, keepMonthly int) []string {
+	type archive struct {
+		path      string
+		createdAt time.Time
+	}
+	archives := make([]archive, 0, len(paths))
+	for _, path := range paths {
+		archives = append(archives, archive{path: path, createdAt: archiveTime(path)})
+	}
+	sort.SliceStable(archives, func(i, j int) bool {
+		return archives[i].createdAt.After(archives[j].createdAt)
+	})
+
+	keep := make(map[string]bool)
+	for i := 0; i < len(archives) && i < keepWeekly; i++ {
+		keep[archives[i].path] = true
+	}
+
+	months := make(map[string]bool)
+	for _, a := range archives {
+		if len(months) >= keepMonthly {
+			break
+		}
+		month := a.createdAt.Format("2006-01")
+		if months[month] {
+			continue
+		}
+		months[month] = true
+		keep[a.path] = true
+	}
+
+	var expired []string
+	for _, a := range archives {
+		if !keep[a.path] {
+			expired = append(expired, a.path)
+		}
+	}
+	return expired
+}
+
+// integrityCheck runs PRAGMA integrity_check against the SQLite database at
+// path.
+func integrityCheck(ctx context.Context, path string) error {
+	database, err := sql.Open("sqlite3", sqliteFileDSN(path)+"&mode=ro")
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	rows, err := database.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// withArchiveFile calls fn with the path of an uncompressed copy of the
+// archive at path. Compressed archives are expanded into a temporary file
+// that is removed afterwards.
+func withArchiveFile(path string, fn func(dbPath string) error) error {
+	var newReader func(io.Reader) (io.ReadCloser, error)
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		newReader = func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		}
+	case strings.HasSuffix(path, ".zst"):
+		newReader = func(r io.Reader) (io.ReadCloser, error) {
+			dec, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		}
+	default:
+		return fn(path)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	r, err := newReader(src)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", filepath.Base(path), err)
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp("", "celestial_orrey_archive_*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("decompress %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return fn(tmp.Name())
+}
+
+// isArchiveName reports whether name is a weekly archive file, compressed or
+// not.
+func isArchiveName(name string) bool {
+	if !strings.HasPrefix(name, _archivePrefix) {
+		return false
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".zst")
+	return strings.HasSuffix(name, ".db")
+}
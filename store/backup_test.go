@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+func TestSQLiteStoreArchiveWeekCompression(t *testing.T) {
+	tests := []struct {
+		compression string
+		ext         string
+	}{
+		{CompressionNone, ".db"},
+		{CompressionGzip, ".db.gz"},
+		{CompressionZstd, ".db.zst"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.compression, func(t *testing.T) {
+			ctx := context.Background()
+			backupDir := t.TempDir()
+
+			st := NewSQLiteStore(Params{
+				BackupDir: backupDir,
+				Backup:    BackupConfig{Compression: tt.compression},
+			})
+			if err := st.Open(ctx); err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer st.Close()
+
+			key := models.CompletedKey{
+				KeyID:       1,
+				Character:   "Arthas",
+				Region:      "us",
+				Realm:       "illidan",
+				Dungeon:     "Ara-Kara, City of Echoes",
+				KeyLevel:    10,
+				CompletedAt: "2026-02-04T01:00:00Z",
+				Source:      models.SourceRaiderIO,
+			}
+			if err := st.UpsertCompletedKey(ctx, key); err != nil {
+				t.Fatalf("upsert: %v", err)
+			}
+
+			if err := st.ArchiveWeek(ctx); err != nil {
+				t.Fatalf("archive: %v", err)
+			}
+
+			backups, err := st.ListBackups(ctx)
+			if err != nil {
+				t.Fatalf("list backups: %v", err)
+			}
+			if len(backups) != 1 {
+				t.Fatalf("expected 1 backup, got %d", len(backups))
+			}
+			if !strings.HasSuffix(backups[0].Path, tt.ext) {
+				t.Fatalf("expected %s archive, got %s", tt.ext, backups[0].Path)
+			}
+			if !backups[0].Healthy() {
+				t.Fatalf("expected archive to pass integrity check, got %q", backups[0].Err)
+			}
+
+			// The archive must be readable again after compression.
+			err = withArchiveFile(backups[0].Path, func(dbPath string) error {
+				return integrityCheck(ctx, dbPath)
+			})
+			if err != nil {
+				t.Fatalf("reopen archive: %v", err)
+			}
+		})
+	}
+}
+
+func TestSQLiteStoreVerifyBackupsReportsCorruption(t *testing.T) {
+	ctx := context.Background()
+	backupDir := t.TempDir()
+
+	bad := filepath.Join(backupDir, "celestial_orrey_2026-02-03_070000.db.gz")
+	if err := os.WriteFile(bad, []byte("not a gzip stream"), 0o644); err != nil {
+		t.Fatalf("write bad archive: %v", err)
+	}
+
+	st := NewSQLiteStore(Params{BackupDir: backupDir})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	good := filepath.Join(backupDir, "celestial_orrey_2026-02-10_070000.db")
+	if err := st.FlushToDisk(ctx, good); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	backups, err := st.VerifyBackups(ctx)
+	if err == nil || !strings.Contains(err.Error(), filepath.Base(bad)) {
+		t.Fatalf("expected an error naming the corrupt archive, got %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+	if backups[0].Path != good || !backups[0].Healthy() {
+		t.Fatalf("expected newest archive to be healthy, got %#v", backups[0])
+	}
+	if backups[1].Path != bad || backups[1].Healthy() || backups[1].Err == "" {
+		t.Fatalf("expected corrupt archive to fail verification, got %#v", backups[1])
+	}
+}
+
+func TestCompressArchiveLeavesNoPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "celestial_orrey_2026-02-03_070000.db")
+	// A directory cannot be read as a file, so compression fails mid-way.
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	st := NewSQLiteStore(Params{BackupDir: dir, Backup: BackupConfig{Compression: CompressionGzip}})
+	if _, err := st.compressArchive(path); err == nil {
+		t.Fatal("expected compression to fail")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("expected only the source left, got %q", names)
+	}
+}
+
+func TestExpiredArchives(t *testing.T) {
+	names := []string{
+		"celestial_orrey_2025-12-02_070000.db",
+		"celestial_orrey_2025-12-30_070000.db.zst",
+		"celestial_orrey_2026-01-06_070000.db.zst",
+		"celestial_orrey_2026-01-13_070000.db.zst",
+		"celestial_orrey_2026-01-27_070000.db.zst",
+		"celestial_orrey_2026-02-03_070000.db.zst",
+		"celestial_orrey_2026-02-10_070000.db.zst",
+	}
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, filepath.Join("backup", name))
+	}
+
+	tests := []struct {
+		name        string
+		keepWeekly  int
+		keepMonthly int
+		want        []string
+	}{
+		{
+			name:       "weekly only",
+			keepWeekly: 3,
+			want:       []string{names[3], names[2], names[1], names[0]},
+		},
+		{
+			name:        "weekly and monthly",
+			keepWeekly:  2,
+			keepMonthly: 3,
+			// Keeps Feb 10 and Feb 3 (weekly), Jan 27 and Dec 30 (monthly).
+			want: []string{names[3], names[2], names[0]},
+		},
+		{
+			name:        "monthly only",
+			keepMonthly: 1,
+			want:        []string{names[5], names[4], names[3], names[2], names[1], names[0]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expiredArchives(paths, tt.keepWeekly, tt.keepMonthly)
+			var gotNames []string
+			for _, path := range got {
+				gotNames = append(gotNames, filepath.Base(path))
+			}
+			if strings.Join(gotNames, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expired = %v; want %v", gotNames, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"testing"
 	"time"
 
@@ -38,6 +40,66 @@ func BenchmarkUpsertCompletedKey(b *testing.B) {
 	}
 }
 
+// BenchmarkUpsertProfile compares writing a profile result one key at a
+// time with writing it as a single batch.
+func BenchmarkUpsertProfile(b *testing.B) {
+	const profileSize = 8
+
+	profile := func(i int) []models.CompletedKey {
+		keys := make([]models.CompletedKey, profileSize)
+		for j := range keys {
+			keys[j] = models.CompletedKey{
+				KeyID:       int64(i*profileSize + j + 1),
+				Character:   "Arthas",
+				Region:      "us",
+				Realm:       "illidan",
+				Dungeon:     "Mists of Tirna Scithe",
+				KeyLevel:    10 + j,
+				RunTimeMS:   1320000,
+				ParTimeMS:   1500000,
+				CompletedAt: "2026-02-04T01:23:45Z",
+				Source:      "raiderio",
+			}
+		}
+		return keys
+	}
+
+	run := func(b *testing.B, write func(ctx context.Context, st *SQLiteStore, keys []models.CompletedKey) error) {
+		ctx := context.Background()
+		st := NewSQLiteStore(Params{})
+		st.SetFlushDebounce(1 * time.Hour)
+		if err := st.Open(ctx); err != nil {
+			b.Fatalf("open: %v", err)
+		}
+		defer st.Close()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := write(ctx, st, profile(i)); err != nil {
+				b.Fatalf("upsert: %v", err)
+			}
+		}
+	}
+
+	b.Run("per-key", func(b *testing.B) {
+		run(b, func(ctx context.Context, st *SQLiteStore, keys []models.CompletedKey) error {
+			for _, key := range keys {
+				if err := st.UpsertCompletedKey(ctx, key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		run(b, func(ctx context.Context, st *SQLiteStore, keys []models.CompletedKey) error {
+			_, err := st.UpsertCompletedKeys(ctx, keys)
+			return err
+		})
+	})
+}
+
 func BenchmarkListKeysByCharacterSince(b *testing.B) {
 	ctx := context.Background()
 	st := NewSQLiteStore(Params{})
@@ -70,7 +132,7 @@ func BenchmarkListKeysByCharacterSince(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		if _, err := st.ListKeysByCharacterSince(ctx, "Arthas", cutoff); err != nil {
+		if _, err := st.ListKeysByCharacterSince(ctx, "Arthas", "illidan", "us", cutoff); err != nil {
 			b.Fatalf("list: %v", err)
 		}
 	}
@@ -114,3 +176,80 @@ func BenchmarkCountKeysByCharacterSince(b *testing.B) {
 		}
 	}
 }
+
+// seedVaultRoster fills st with a roster of characters, each with a week of
+// keys, and returns the characters.
+func seedVaultRoster(b *testing.B, st *SQLiteStore, size int) []models.Character {
+	b.Helper()
+	ctx := context.Background()
+
+	chars := make([]models.Character, 0, size)
+	for c := 0; c < size; c++ {
+		char := models.Character{Name: fmt.Sprintf("char%03d", c), Realm: "illidan", Region: "us"}
+		chars = append(chars, char)
+		for k := 0; k < 12; k++ {
+			key := models.CompletedKey{
+				KeyID:       int64(c*100 + k + 1),
+				Character:   char.Name,
+				Region:      char.Region,
+				Realm:       char.Realm,
+				Dungeon:     "Mists of Tirna Scithe",
+				KeyLevel:    2 + (c+k)%12,
+				CompletedAt: fmt.Sprintf("2026-02-04T%02d:23:45Z", k),
+				Source:      "raiderio",
+			}
+			if err := st.UpsertCompletedKey(ctx, key); err != nil {
+				b.Fatalf("upsert: %v", err)
+			}
+		}
+	}
+	return chars
+}
+
+// BenchmarkVaultReportPerCharacter measures the previous report path: one
+// query per character followed by a sort in Go.
+func BenchmarkVaultReportPerCharacter(b *testing.B) {
+	ctx := context.Background()
+	st := NewSQLiteStore(Params{})
+	st.SetFlushDebounce(1 * time.Hour)
+	if err := st.Open(ctx); err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	chars := seedVaultRoster(b, st, 200)
+	cutoff := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, char := range chars {
+			keys, err := st.ListKeysByCharacterSince(ctx, char.Name, char.Realm, char.Region, cutoff)
+			if err != nil {
+				b.Fatalf("list: %v", err)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				return keys[i].KeyLevel > keys[j].KeyLevel
+			})
+		}
+	}
+}
+
+func BenchmarkListVaultProgressSince(b *testing.B) {
+	ctx := context.Background()
+	st := NewSQLiteStore(Params{})
+	st.SetFlushDebounce(1 * time.Hour)
+	if err := st.Open(ctx); err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	seedVaultRoster(b, st, 200)
+	cutoff := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := st.ListVaultProgressSince(ctx, cutoff); err != nil {
+			b.Fatalf("vault progress: %v", err)
+		}
+	}
+}
@@ -1,7 +1,66 @@
 package store
 
+import (
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/secret"
+)
+
+const (
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
 // Config holds store configuration.
 type Config struct {
-	Path      string `yaml:"path"`
-	BackupDir string `yaml:"backup_dir"`
+	// Backend is BackendSQLite (the default) or BackendPostgres. The path,
+	// backup and S3 settings only apply to SQLite.
+	Backend   string         `yaml:"backend"`
+	Postgres  PostgresConfig `yaml:"postgres"`
+	Path      string         `yaml:"path"`
+	BackupDir string         `yaml:"backup_dir"`
+	Backup    BackupConfig   `yaml:"backup"`
+	S3        S3Config       `yaml:"s3"`
+	// AllowEmptyOverwrite lets an empty database be flushed over a snapshot
+	// that holds data. Leave unset except to deliberately wipe the store.
+	AllowEmptyOverwrite bool `yaml:"allow_empty_overwrite"`
+}
+
+// BackupConfig controls how weekly archives are written and retained.
+type BackupConfig struct {
+	// Compression is "none", "gzip" or "zstd".
+	Compression string `yaml:"compression"`
+	// KeepWeekly is how many of the newest archives to keep.
+	KeepWeekly int `yaml:"keep_weekly"`
+	// KeepMonthly is how many months to keep the newest archive of.
+	// With both unset, archives are never deleted.
+	KeepMonthly int `yaml:"keep_monthly"`
+}
+
+// S3Config configures shipping backups to an S3-compatible bucket.
+type S3Config struct {
+	Endpoint        string        `yaml:"endpoint"`
+	Region          string        `yaml:"region"`
+	Bucket          string        `yaml:"bucket"`
+	Prefix          string        `yaml:"prefix"`
+	AccessKeyID     string        `yaml:"access_key_id"`
+	SecretAccessKey secret.String `yaml:"secret_access_key"`
+	// SnapshotInterval is the minimum time between snapshot uploads.
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+	// KeepSnapshots is how many uploaded snapshots to keep; 0 keeps all.
+	// Uploaded archives follow the local retention policy.
+	KeepSnapshots int `yaml:"keep_snapshots"`
+}
+
+// Enabled reports whether a bucket is configured.
+func (c S3Config) Enabled() bool {
+	return c.Bucket != ""
+}
+
+// PostgresConfig configures the Postgres backend.
+type PostgresConfig struct {
+	// DSN is a libpq connection string or postgres:// URL.
+	DSN secret.String `yaml:"dsn"`
+	// MaxOpenConns caps the connection pool; 0 leaves it unlimited.
+	MaxOpenConns int `yaml:"max_open_conns"`
 }
@@ -0,0 +1,175 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: history.sql
+
+package db
+
+import (
+	"context"
+)
+
+const insertWeeklyHistoryIfMissing = `-- name: InsertWeeklyHistoryIfMissing :execrows
+INSERT OR IGNORE INTO weekly_history(
+  week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertWeeklyHistoryIfMissingParams struct {
+	WeekStart   string  `json:"week_start"`
+	Region      string  `json:"region"`
+	Realm       string  `json:"realm"`
+	Name        string  `json:"name"`
+	KeyCount    int64   `json:"key_count"`
+	BestKeyLvl  int64   `json:"best_key_lvl"`
+	BestDungeon string  `json:"best_dungeon"`
+	Vault1Lvl   int64   `json:"vault_1_lvl"`
+	Vault2Lvl   int64   `json:"vault_2_lvl"`
+	Vault3Lvl   int64   `json:"vault_3_lvl"`
+	RioScore    float64 `json:"rio_score"`
+	Source      string  `json:"source"`
+}
+
+func (q *Queries) InsertWeeklyHistoryIfMissing(ctx context.Context, arg InsertWeeklyHistoryIfMissingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertWeeklyHistoryIfMissing,
+		arg.WeekStart,
+		arg.Region,
+		arg.Realm,
+		arg.Name,
+		arg.KeyCount,
+		arg.BestKeyLvl,
+		arg.BestDungeon,
+		arg.Vault1Lvl,
+		arg.Vault2Lvl,
+		arg.Vault3Lvl,
+		arg.RioScore,
+		arg.Source,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listWeeklyHistory = `-- name: ListWeeklyHistory :many
+SELECT week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+FROM weekly_history
+WHERE LOWER(name) = LOWER(?) AND LOWER(realm) = LOWER(?) AND LOWER(region) = LOWER(?)
+ORDER BY week_start DESC
+LIMIT ?
+`
+
+type ListWeeklyHistoryParams struct {
+	LOWER   string `json:"LOWER"`
+	LOWER_2 string `json:"LOWER_2"`
+	LOWER_3 string `json:"LOWER_3"`
+	Limit   int64  `json:"limit"`
+}
+
+type ListWeeklyHistoryRow struct {
+	WeekStart   string  `json:"week_start"`
+	Region      string  `json:"region"`
+	Realm       string  `json:"realm"`
+	Name        string  `json:"name"`
+	KeyCount    int64   `json:"key_count"`
+	BestKeyLvl  int64   `json:"best_key_lvl"`
+	BestDungeon string  `json:"best_dungeon"`
+	Vault1Lvl   int64   `json:"vault_1_lvl"`
+	Vault2Lvl   int64   `json:"vault_2_lvl"`
+	Vault3Lvl   int64   `json:"vault_3_lvl"`
+	RioScore    float64 `json:"rio_score"`
+	Source      string  `json:"source"`
+}
+
+func (q *Queries) ListWeeklyHistory(ctx context.Context, arg ListWeeklyHistoryParams) ([]ListWeeklyHistoryRow, error) {
+	rows, err := q.db.QueryContext(ctx, listWeeklyHistory,
+		arg.LOWER,
+		arg.LOWER_2,
+		arg.LOWER_3,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWeeklyHistoryRow
+	for rows.Next() {
+		var i ListWeeklyHistoryRow
+		if err := rows.Scan(
+			&i.WeekStart,
+			&i.Region,
+			&i.Realm,
+			&i.Name,
+			&i.KeyCount,
+			&i.BestKeyLvl,
+			&i.BestDungeon,
+			&i.Vault1Lvl,
+			&i.Vault2Lvl,
+			&i.Vault3Lvl,
+			&i.RioScore,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertWeeklyHistory = `-- name: UpsertWeeklyHistory :exec
+INSERT INTO weekly_history(
+  week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(week_start, region, realm, name) DO UPDATE SET
+  key_count = excluded.key_count,
+  best_key_lvl = excluded.best_key_lvl,
+  best_dungeon = excluded.best_dungeon,
+  vault_1_lvl = excluded.vault_1_lvl,
+  vault_2_lvl = excluded.vault_2_lvl,
+  vault_3_lvl = excluded.vault_3_lvl,
+  rio_score = CASE WHEN excluded.rio_score > 0 THEN excluded.rio_score ELSE weekly_history.rio_score END,
+  source = excluded.source,
+  recorded_at = strftime('%Y-%m-%dT%H:%M:%fZ','now')
+`
+
+type UpsertWeeklyHistoryParams struct {
+	WeekStart   string  `json:"week_start"`
+	Region      string  `json:"region"`
+	Realm       string  `json:"realm"`
+	Name        string  `json:"name"`
+	KeyCount    int64   `json:"key_count"`
+	BestKeyLvl  int64   `json:"best_key_lvl"`
+	BestDungeon string  `json:"best_dungeon"`
+	Vault1Lvl   int64   `json:"vault_1_lvl"`
+	Vault2Lvl   int64   `json:"vault_2_lvl"`
+	Vault3Lvl   int64   `json:"vault_3_lvl"`
+	RioScore    float64 `json:"rio_score"`
+	Source      string  `json:"source"`
+}
+
+func (q *Queries) UpsertWeeklyHistory(ctx context.Context, arg UpsertWeeklyHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, upsertWeeklyHistory,
+		arg.WeekStart,
+		arg.Region,
+		arg.Realm,
+		arg.Name,
+		arg.KeyCount,
+		arg.BestKeyLvl,
+		arg.BestDungeon,
+		arg.Vault1Lvl,
+		arg.Vault2Lvl,
+		arg.Vault3Lvl,
+		arg.RioScore,
+		arg.Source,
+	)
+	return err
+}
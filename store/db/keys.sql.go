@@ -9,6 +9,22 @@ import (
 	"context"
 )
 
+const clearPurgedCharacter = `-- name: ClearPurgedCharacter :exec
+DELETE FROM purged_characters
+WHERE region = ? AND realm = ? AND name = ?
+`
+
+type ClearPurgedCharacterParams struct {
+	Region string `json:"region"`
+	Realm  string `json:"realm"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) ClearPurgedCharacter(ctx context.Context, arg ClearPurgedCharacterParams) error {
+	_, err := q.db.ExecContext(ctx, clearPurgedCharacter, arg.Region, arg.Realm, arg.Name)
+	return err
+}
+
 const countKeysByCharacterSince = `-- name: CountKeysByCharacterSince :many
 SELECT c.region, c.realm, c.name, COUNT(*) AS key_count
 FROM completed_keys k
@@ -53,6 +69,35 @@ func (q *Queries) CountKeysByCharacterSince(ctx context.Context, completedAt str
 	return items, nil
 }
 
+const countPurgedCharacters = `-- name: CountPurgedCharacters :one
+SELECT COUNT(*) FROM purged_characters
+`
+
+func (q *Queries) CountPurgedCharacters(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPurgedCharacters)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countStoredRows = `-- name: CountStoredRows :one
+SELECT
+  (SELECT COUNT(*) FROM characters) AS character_count,
+  (SELECT COUNT(*) FROM completed_keys) AS key_count
+`
+
+type CountStoredRowsRow struct {
+	CharacterCount int64 `json:"character_count"`
+	KeyCount       int64 `json:"key_count"`
+}
+
+func (q *Queries) CountStoredRows(ctx context.Context) (CountStoredRowsRow, error) {
+	row := q.db.QueryRowContext(ctx, countStoredRows)
+	var i CountStoredRowsRow
+	err := row.Scan(&i.CharacterCount, &i.KeyCount)
+	return i, err
+}
+
 const deleteCharacter = `-- name: DeleteCharacter :exec
 DELETE FROM characters WHERE id = ?
 `
@@ -62,6 +107,23 @@ func (q *Queries) DeleteCharacter(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteCompletedKey = `-- name: DeleteCompletedKey :execrows
+DELETE FROM completed_keys WHERE key_id = ? AND character_id = ?
+`
+
+type DeleteCompletedKeyParams struct {
+	KeyID       int64 `json:"key_id"`
+	CharacterID int64 `json:"character_id"`
+}
+
+func (q *Queries) DeleteCompletedKey(ctx context.Context, arg DeleteCompletedKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCompletedKey, arg.KeyID, arg.CharacterID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteCompletedKeysByCharacter = `-- name: DeleteCompletedKeysByCharacter :exec
 DELETE FROM completed_keys WHERE character_id = ?
 `
@@ -71,6 +133,17 @@ func (q *Queries) DeleteCompletedKeysByCharacter(ctx context.Context, characterI
 	return err
 }
 
+const deleteOrphanedWarcraftLogsLinks = `-- name: DeleteOrphanedWarcraftLogsLinks :exec
+DELETE FROM warcraftlogs_links
+WHERE warcraftlogs_links.key_id = ?
+  AND NOT EXISTS (SELECT 1 FROM completed_keys k WHERE k.key_id = warcraftlogs_links.key_id)
+`
+
+func (q *Queries) DeleteOrphanedWarcraftLogsLinks(ctx context.Context, keyID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteOrphanedWarcraftLogsLinks, keyID)
+	return err
+}
+
 const deleteWarcraftLogsLinksByCharacter = `-- name: DeleteWarcraftLogsLinksByCharacter :exec
 DELETE FROM warcraftlogs_links
 WHERE key_id IN (
@@ -127,6 +200,40 @@ func (q *Queries) GetCharacterID(ctx context.Context, arg GetCharacterIDParams)
 	return id, err
 }
 
+const getCompletedKey = `-- name: GetCompletedKey :one
+SELECT dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
+FROM completed_keys
+WHERE key_id = ? AND character_id = ?
+`
+
+type GetCompletedKeyParams struct {
+	KeyID       int64 `json:"key_id"`
+	CharacterID int64 `json:"character_id"`
+}
+
+type GetCompletedKeyRow struct {
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) GetCompletedKey(ctx context.Context, arg GetCompletedKeyParams) (GetCompletedKeyRow, error) {
+	row := q.db.QueryRowContext(ctx, getCompletedKey, arg.KeyID, arg.CharacterID)
+	var i GetCompletedKeyRow
+	err := row.Scan(
+		&i.Dungeon,
+		&i.KeyLvl,
+		&i.RunTimeMs,
+		&i.ParTimeMs,
+		&i.CompletedAt,
+		&i.Source,
+	)
+	return i, err
+}
+
 const insertCompletedKey = `-- name: InsertCompletedKey :exec
 INSERT INTO completed_keys(
   key_id, character_id, dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
@@ -266,12 +373,15 @@ SELECT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
 k.run_time_ms, k.par_time_ms, k.completed_at, k.source
 FROM completed_keys k
 JOIN characters c ON c.id = k.character_id
-WHERE LOWER(c.name) = LOWER(?) AND k.completed_at > ?
+WHERE LOWER(c.name) = LOWER(?) AND LOWER(c.realm) = LOWER(?) AND LOWER(c.region) = LOWER(?)
+  AND k.completed_at > ?
 ORDER BY k.completed_at DESC
 `
 
 type ListKeysByCharacterSinceParams struct {
 	LOWER       string `json:"LOWER"`
+	LOWER_2     string `json:"LOWER_2"`
+	LOWER_3     string `json:"LOWER_3"`
 	CompletedAt string `json:"completed_at"`
 }
 
@@ -289,7 +399,12 @@ type ListKeysByCharacterSinceRow struct {
 }
 
 func (q *Queries) ListKeysByCharacterSince(ctx context.Context, arg ListKeysByCharacterSinceParams) ([]ListKeysByCharacterSinceRow, error) {
-	rows, err := q.db.QueryContext(ctx, listKeysByCharacterSince, arg.LOWER, arg.CompletedAt)
+	rows, err := q.db.QueryContext(ctx, listKeysByCharacterSince,
+		arg.LOWER,
+		arg.LOWER_2,
+		arg.LOWER_3,
+		arg.CompletedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -435,6 +550,79 @@ func (q *Queries) ListUnlinkedKeysSince(ctx context.Context, completedAt string)
 	return items, nil
 }
 
+const listVaultProgressSince = `-- name: ListVaultProgressSince :many
+WITH ranked AS (
+  SELECT k.character_id, k.key_lvl,
+    ROW_NUMBER() OVER (PARTITION BY k.character_id ORDER BY k.key_lvl DESC, k.completed_at ASC) AS key_rank,
+    COUNT(*) OVER (PARTITION BY k.character_id) AS key_count
+  FROM completed_keys k
+  WHERE k.completed_at > ?
+)
+SELECT c.region, c.realm, c.name, c.rio_score,
+  COALESCE(r.key_count, 0) AS key_count,
+  COALESCE(r.key_lvl, 0) AS key_lvl
+FROM characters c
+LEFT JOIN ranked r ON r.character_id = c.id AND r.key_rank <= 8
+ORDER BY c.region, c.realm, c.name, r.key_rank
+`
+
+type ListVaultProgressSinceRow struct {
+	Region   string  `json:"region"`
+	Realm    string  `json:"realm"`
+	Name     string  `json:"name"`
+	RioScore float64 `json:"rio_score"`
+	KeyCount int64   `json:"key_count"`
+	KeyLvl   int64   `json:"key_lvl"`
+}
+
+func (q *Queries) ListVaultProgressSince(ctx context.Context, completedAt string) ([]ListVaultProgressSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listVaultProgressSince, completedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListVaultProgressSinceRow
+	for rows.Next() {
+		var i ListVaultProgressSinceRow
+		if err := rows.Scan(
+			&i.Region,
+			&i.Realm,
+			&i.Name,
+			&i.RioScore,
+			&i.KeyCount,
+			&i.KeyLvl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordPurgedCharacter = `-- name: RecordPurgedCharacter :exec
+INSERT INTO purged_characters (region, realm, name)
+VALUES (?, ?, ?)
+ON CONFLICT (region, realm, name) DO UPDATE SET
+  purged_at = strftime('%Y-%m-%dT%H:%M:%fZ','now')
+`
+
+type RecordPurgedCharacterParams struct {
+	Region string `json:"region"`
+	Realm  string `json:"realm"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) RecordPurgedCharacter(ctx context.Context, arg RecordPurgedCharacterParams) error {
+	_, err := q.db.ExecContext(ctx, recordPurgedCharacter, arg.Region, arg.Realm, arg.Name)
+	return err
+}
+
 const updateCharacterScore = `-- name: UpdateCharacterScore :exec
 UPDATE characters SET rio_score = ?
 WHERE LOWER(name) = LOWER(?) AND LOWER(realm) = LOWER(?) AND LOWER(region) = LOWER(?)
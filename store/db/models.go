@@ -37,6 +37,28 @@ type ElvuiVersion struct {
 	CheckedAt    string `json:"checked_at"`
 }
 
+type Outbox struct {
+	ID            int64  `json:"id"`
+	DedupKey      string `json:"dedup_key"`
+	ChannelID     string `json:"channel_id"`
+	Content       string `json:"content"`
+	Embeds        string `json:"embeds"`
+	Attempts      int64  `json:"attempts"`
+	LastError     string `json:"last_error"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	ClaimedAt     string `json:"claimed_at"`
+	SentAt        string `json:"sent_at"`
+	FailedAt      string `json:"failed_at"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type PurgedCharacter struct {
+	Region   string `json:"region"`
+	Realm    string `json:"realm"`
+	Name     string `json:"name"`
+	PurgedAt string `json:"purged_at"`
+}
+
 type WarcraftlogsLink struct {
 	ID         int64          `json:"id"`
 	KeyID      int64          `json:"key_id"`
@@ -46,3 +68,19 @@ type WarcraftlogsLink struct {
 	Url        sql.NullString `json:"url"`
 	InsertedAt string         `json:"inserted_at"`
 }
+
+type WeeklyHistory struct {
+	WeekStart   string  `json:"week_start"`
+	Region      string  `json:"region"`
+	Realm       string  `json:"realm"`
+	Name        string  `json:"name"`
+	KeyCount    int64   `json:"key_count"`
+	BestKeyLvl  int64   `json:"best_key_lvl"`
+	BestDungeon string  `json:"best_dungeon"`
+	Vault1Lvl   int64   `json:"vault_1_lvl"`
+	Vault2Lvl   int64   `json:"vault_2_lvl"`
+	Vault3Lvl   int64   `json:"vault_3_lvl"`
+	RioScore    float64 `json:"rio_score"`
+	Source      string  `json:"source"`
+	RecordedAt  string  `json:"recorded_at"`
+}
@@ -0,0 +1,211 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbox.sql
+
+package db
+
+import (
+	"context"
+)
+
+const claimOutboxMessage = `-- name: ClaimOutboxMessage :exec
+UPDATE outbox SET claimed_at = ?, attempts = attempts + 1
+WHERE id = ?
+`
+
+type ClaimOutboxMessageParams struct {
+	ClaimedAt string `json:"claimed_at"`
+	ID        int64  `json:"id"`
+}
+
+func (q *Queries) ClaimOutboxMessage(ctx context.Context, arg ClaimOutboxMessageParams) error {
+	_, err := q.db.ExecContext(ctx, claimOutboxMessage, arg.ClaimedAt, arg.ID)
+	return err
+}
+
+const enqueueOutboxMessage = `-- name: EnqueueOutboxMessage :execrows
+INSERT OR IGNORE INTO outbox(dedup_key, channel_id, content, embeds, next_attempt_at)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type EnqueueOutboxMessageParams struct {
+	DedupKey      string `json:"dedup_key"`
+	ChannelID     string `json:"channel_id"`
+	Content       string `json:"content"`
+	Embeds        string `json:"embeds"`
+	NextAttemptAt string `json:"next_attempt_at"`
+}
+
+func (q *Queries) EnqueueOutboxMessage(ctx context.Context, arg EnqueueOutboxMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueOutboxMessage,
+		arg.DedupKey,
+		arg.ChannelID,
+		arg.Content,
+		arg.Embeds,
+		arg.NextAttemptAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listDueOutboxMessages = `-- name: ListDueOutboxMessages :many
+SELECT id, dedup_key, channel_id, content, embeds, attempts, last_error, next_attempt_at, claimed_at, sent_at, failed_at, created_at FROM outbox
+WHERE sent_at = '' AND failed_at = '' AND next_attempt_at <= ?
+ORDER BY id
+LIMIT ?
+`
+
+type ListDueOutboxMessagesParams struct {
+	NextAttemptAt string `json:"next_attempt_at"`
+	Limit         int64  `json:"limit"`
+}
+
+func (q *Queries) ListDueOutboxMessages(ctx context.Context, arg ListDueOutboxMessagesParams) ([]Outbox, error) {
+	rows, err := q.db.QueryContext(ctx, listDueOutboxMessages, arg.NextAttemptAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.DedupKey,
+			&i.ChannelID,
+			&i.Content,
+			&i.Embeds,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.ClaimedAt,
+			&i.SentAt,
+			&i.FailedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFailedOutboxMessages = `-- name: ListFailedOutboxMessages :many
+SELECT id, dedup_key, channel_id, content, embeds, attempts, last_error, next_attempt_at, claimed_at, sent_at, failed_at, created_at FROM outbox
+WHERE failed_at != ''
+ORDER BY failed_at DESC
+LIMIT ?
+`
+
+func (q *Queries) ListFailedOutboxMessages(ctx context.Context, limit int64) ([]Outbox, error) {
+	rows, err := q.db.QueryContext(ctx, listFailedOutboxMessages, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.DedupKey,
+			&i.ChannelID,
+			&i.Content,
+			&i.Embeds,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.ClaimedAt,
+			&i.SentAt,
+			&i.FailedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markOutboxMessageFailed = `-- name: MarkOutboxMessageFailed :exec
+UPDATE outbox SET last_error = ?, next_attempt_at = ?, failed_at = ?, claimed_at = ''
+WHERE id = ?
+`
+
+type MarkOutboxMessageFailedParams struct {
+	LastError     string `json:"last_error"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	FailedAt      string `json:"failed_at"`
+	ID            int64  `json:"id"`
+}
+
+func (q *Queries) MarkOutboxMessageFailed(ctx context.Context, arg MarkOutboxMessageFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxMessageFailed,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.FailedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const markOutboxMessageSent = `-- name: MarkOutboxMessageSent :exec
+UPDATE outbox SET sent_at = ?, claimed_at = '', last_error = ''
+WHERE id = ?
+`
+
+type MarkOutboxMessageSentParams struct {
+	SentAt string `json:"sent_at"`
+	ID     int64  `json:"id"`
+}
+
+func (q *Queries) MarkOutboxMessageSent(ctx context.Context, arg MarkOutboxMessageSentParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxMessageSent, arg.SentAt, arg.ID)
+	return err
+}
+
+const pruneSentOutboxMessages = `-- name: PruneSentOutboxMessages :execrows
+DELETE FROM outbox
+WHERE sent_at != '' AND sent_at < ?
+`
+
+func (q *Queries) PruneSentOutboxMessages(ctx context.Context, sentAt string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneSentOutboxMessages, sentAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const requeueOutboxMessage = `-- name: RequeueOutboxMessage :execrows
+UPDATE outbox SET failed_at = '', attempts = 0, next_attempt_at = ?
+WHERE id = ? AND failed_at != ''
+`
+
+type RequeueOutboxMessageParams struct {
+	NextAttemptAt string `json:"next_attempt_at"`
+	ID            int64  `json:"id"`
+}
+
+func (q *Queries) RequeueOutboxMessage(ctx context.Context, arg RequeueOutboxMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, requeueOutboxMessage, arg.NextAttemptAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
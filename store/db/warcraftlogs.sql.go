@@ -10,10 +10,24 @@ import (
 	"database/sql"
 )
 
-const insertWarcraftLogsLink = `-- name: InsertWarcraftLogsLink :exec
+const deleteWarcraftLogsLinksForKey = `-- name: DeleteWarcraftLogsLinksForKey :exec
+DELETE FROM warcraftlogs_links WHERE key_id = ?
+`
+
+func (q *Queries) DeleteWarcraftLogsLinksForKey(ctx context.Context, keyID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWarcraftLogsLinksForKey, keyID)
+	return err
+}
+
+const insertWarcraftLogsLink = `-- name: InsertWarcraftLogsLink :execrows
 INSERT OR IGNORE INTO warcraftlogs_links(
   key_id, report_code, fight_id, pull_id, url
-) VALUES (?, ?, ?, ?, ?)
+)
+SELECT ?1, ?2, ?3, ?4, ?5
+WHERE NOT EXISTS (
+  SELECT 1 FROM warcraftlogs_links
+  WHERE key_id = ?1 AND report_code = ?2 AND fight_id IS ?3 AND pull_id IS ?4
+)
 `
 
 type InsertWarcraftLogsLinkParams struct {
@@ -24,15 +38,20 @@ type InsertWarcraftLogsLinkParams struct {
 	Url        sql.NullString `json:"url"`
 }
 
-func (q *Queries) InsertWarcraftLogsLink(ctx context.Context, arg InsertWarcraftLogsLinkParams) error {
-	_, err := q.db.ExecContext(ctx, insertWarcraftLogsLink,
+// The unique constraint treats NULL fight and pull IDs as distinct, so
+// duplicates of links without them are skipped explicitly.
+func (q *Queries) InsertWarcraftLogsLink(ctx context.Context, arg InsertWarcraftLogsLinkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertWarcraftLogsLink,
 		arg.KeyID,
 		arg.ReportCode,
 		arg.FightID,
 		arg.PullID,
 		arg.Url,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const listWarcraftLogsLinksForKey = `-- name: ListWarcraftLogsLinksForKey :many
@@ -80,3 +99,27 @@ func (q *Queries) ListWarcraftLogsLinksForKey(ctx context.Context, keyID int64)
 	}
 	return items, nil
 }
+
+const moveWarcraftLogsLinks = `-- name: MoveWarcraftLogsLinks :exec
+UPDATE OR IGNORE warcraftlogs_links SET key_id = ?1
+WHERE key_id = ?2
+  AND NOT EXISTS (
+    SELECT 1 FROM warcraftlogs_links o
+    WHERE o.key_id = ?1 AND o.report_code = warcraftlogs_links.report_code
+      AND o.fight_id IS warcraftlogs_links.fight_id
+      AND o.pull_id IS warcraftlogs_links.pull_id
+  )
+`
+
+type MoveWarcraftLogsLinksParams struct {
+	KeyID   int64 `json:"key_id"`
+	KeyID_2 int64 `json:"key_id_2"`
+}
+
+// Links the target key already has stay where they are. The unique
+// constraint treats NULL fight and pull IDs as distinct, so they are
+// compared with IS, as in InsertWarcraftLogsLink.
+func (q *Queries) MoveWarcraftLogsLinks(ctx context.Context, arg MoveWarcraftLogsLinksParams) error {
+	_, err := q.db.ExecContext(ctx, moveWarcraftLogsLinks, arg.KeyID, arg.KeyID_2)
+	return err
+}
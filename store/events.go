@@ -0,0 +1,185 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store/db"
+	"go.uber.org/atomic"
+)
+
+// _defaultEventBuffer is the subscription buffer used when none is given.
+const _defaultEventBuffer = 64
+
+// Event is a change published by the store after it has been committed.
+// Subscribers switch on the concrete type.
+type Event interface {
+	// OccurredAt is when the change was committed.
+	OccurredAt() time.Time
+}
+
+type eventTime struct {
+	At time.Time
+}
+
+func (e eventTime) OccurredAt() time.Time { return e.At }
+
+// KeyInserted is published when a key is stored for a character for the
+// first time. Key.KeyID holds the ID it was stored under.
+type KeyInserted struct {
+	eventTime
+	Key models.CompletedKey
+}
+
+// KeyUpdated is published when a stored key's details change.
+type KeyUpdated struct {
+	eventTime
+	Key models.CompletedKey
+}
+
+// KeyReplaced is published when a key takes the place of one from a
+// lower-priority source.
+type KeyReplaced struct {
+	eventTime
+	OldKeyID int64
+	Key      models.CompletedKey
+}
+
+// KeyDeleted is published when a single key is removed from a character.
+type KeyDeleted struct {
+	eventTime
+	Character models.Character
+	KeyID     int64
+}
+
+// LinkAdded is published when a new WarcraftLogs link is stored.
+type LinkAdded struct {
+	eventTime
+	Link WarcraftLogsLink
+}
+
+// ScoreChanged is published when a character's RaiderIO score changes.
+type ScoreChanged struct {
+	eventTime
+	Character models.Character
+	OldScore  float64
+}
+
+// CharacterAdded is published when a character is tracked without a key,
+// such as by an import.
+type CharacterAdded struct {
+	eventTime
+	Character models.Character
+}
+
+// CharacterDeleted is published when a character and its keys are removed.
+type CharacterDeleted struct {
+	eventTime
+	Character models.Character
+}
+
+// WeekArchived is published after the weekly archive has been written and
+// verified.
+type WeekArchived struct {
+	eventTime
+	Path string
+}
+
+// Subscription receives store events. Delivery never blocks the store: when
+// the buffer is full, further events are dropped and counted until the
+// subscriber catches up.
+type Subscription struct {
+	ch      chan Event
+	dropped atomic.Int64
+	bus     *eventBus
+	once    sync.Once
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events were discarded because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the events channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.remove(s)
+		close(s.ch)
+	})
+}
+
+type eventBus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func (b *eventBus) subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = _defaultEventBuffer
+	}
+	sub := &Subscription{ch: make(chan Event, buffer), bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[*Subscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *eventBus) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
+
+func (b *eventBus) publish(events ...Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		for _, ev := range events {
+			select {
+			case sub.ch <- ev:
+			default:
+				sub.dropped.Inc()
+			}
+		}
+	}
+}
+
+// sameKey reports whether stored already holds key's details.
+func sameKey(stored, key models.CompletedKey) bool {
+	return stored.Dungeon == key.Dungeon &&
+		stored.KeyLevel == key.KeyLevel &&
+		stored.RunTimeMS == key.RunTimeMS &&
+		stored.ParTimeMS == key.ParTimeMS &&
+		stored.CompletedAt == key.CompletedAt &&
+		stored.Source == key.Source
+}
+
+// storedKey maps the details GetCompletedKey returns onto a key.
+func storedKey(row db.GetCompletedKeyRow) models.CompletedKey {
+	return models.CompletedKey{
+		Dungeon:     row.Dungeon,
+		KeyLevel:    int(row.KeyLvl),
+		RunTimeMS:   row.RunTimeMs,
+		ParTimeMS:   row.ParTimeMs,
+		CompletedAt: row.CompletedAt,
+		Source:      row.Source,
+	}
+}
+
+// Subscribe registers for events committed from now on, buffering up to
+// buffer of them (a default size if buffer is not positive). Call Close on
+// the subscription when done.
+func (s *SQLiteStore) Subscribe(buffer int) *Subscription {
+	return s.events.subscribe(buffer)
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+// drainEvents returns the events already buffered on sub.
+func drainEvents(sub *Subscription) []Event {
+	var out []Event
+	for {
+		select {
+		case ev := <-sub.Events():
+			out = append(out, ev)
+		default:
+			return out
+		}
+	}
+}
+
+func eventTypes(events []Event) string {
+	var names []string
+	for _, ev := range events {
+		names = append(names, fmt.Sprintf("%T", ev))
+	}
+	return fmt.Sprint(names)
+}
+
+func TestSQLiteStorePublishesEvents(t *testing.T) {
+	ctx := context.Background()
+	st := NewSQLiteStore(Params{BackupDir: t.TempDir()})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	sub := st.Subscribe(16)
+	defer sub.Close()
+
+	key := testKey(1)
+	steps := []struct {
+		name string
+		do   func() error
+		want string
+	}{
+		{
+			name: "new key",
+			do:   func() error { return st.UpsertCompletedKey(ctx, key) },
+			want: "[store.KeyInserted]",
+		},
+		{
+			name: "unchanged key",
+			do:   func() error { return st.UpsertCompletedKey(ctx, key) },
+			want: "[]",
+		},
+		{
+			name: "changed key",
+			do: func() error {
+				changed := key
+				changed.KeyLevel = 12
+				return st.UpsertCompletedKey(ctx, changed)
+			},
+			want: "[store.KeyUpdated]",
+		},
+		{
+			name: "new link",
+			do: func() error {
+				return st.UpsertWarcraftLogsLink(ctx, WarcraftLogsLink{KeyID: 1, ReportCode: "ABC123"})
+			},
+			want: "[store.LinkAdded]",
+		},
+		{
+			name: "duplicate link",
+			do: func() error {
+				return st.UpsertWarcraftLogsLink(ctx, WarcraftLogsLink{KeyID: 1, ReportCode: "ABC123"})
+			},
+			want: "[]",
+		},
+		{
+			name: "new score",
+			do:   func() error { return st.UpdateCharacterScore(ctx, "Arthas", "illidan", "us", 2500) },
+			want: "[store.ScoreChanged]",
+		},
+		{
+			name: "same score",
+			do:   func() error { return st.UpdateCharacterScore(ctx, "Arthas", "illidan", "us", 2500) },
+			want: "[]",
+		},
+		{
+			name: "archive",
+			do:   func() error { return st.ArchiveWeek(ctx) },
+			want: "[store.WeekArchived]",
+		},
+		{
+			name: "delete key",
+			do:   func() error { return st.DeleteCompletedKey(ctx, "arthas", "illidan", "us", 1) },
+			want: "[store.KeyDeleted]",
+		},
+		{
+			name: "delete missing key",
+			do:   func() error { return st.DeleteCompletedKey(ctx, "arthas", "illidan", "us", 1) },
+			want: "[]",
+		},
+		{
+			name: "delete character",
+			do:   func() error { return st.DeleteCharacter(ctx, "arthas", "illidan", "us") },
+			want: "[store.CharacterDeleted]",
+		},
+		{
+			name: "add character",
+			do: func() error {
+				_, err := st.AddCharacter(ctx, models.Character{Name: "Jaina", Realm: "illidan", Region: "us"})
+				return err
+			},
+			want: "[store.CharacterAdded]",
+		},
+		{
+			name: "add tracked character",
+			do: func() error {
+				_, err := st.AddCharacter(ctx, models.Character{Name: "jaina", Realm: "illidan", Region: "us"})
+				return err
+			},
+			want: "[]",
+		},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := eventTypes(drainEvents(sub)); got != step.want {
+			t.Fatalf("%s: events = %s; want %s", step.name, got, step.want)
+		}
+	}
+}
+
+func TestSQLiteStoreEventPayloads(t *testing.T) {
+	ctx := context.Background()
+	st := NewSQLiteStore(Params{})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	sub := st.Subscribe(4)
+	defer sub.Close()
+
+	key := testKey(0)
+	key.Character = "ARTHAS"
+	if err := st.UpsertCompletedKey(ctx, key); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if err := st.UpdateCharacterScore(ctx, "arthas", "illidan", "us", 3000); err != nil {
+		t.Fatalf("score: %v", err)
+	}
+
+	events := drainEvents(sub)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %s", eventTypes(events))
+	}
+	inserted := events[0].(KeyInserted)
+	if inserted.Key.KeyID != key.EffectiveID() {
+		t.Fatalf("expected synthetic key ID %d, got %d", key.EffectiveID(), inserted.Key.KeyID)
+	}
+	if inserted.OccurredAt().IsZero() {
+		t.Fatal("expected event time to be set")
+	}
+	score := events[1].(ScoreChanged)
+	if score.OldScore != 0 || score.Character.RIOScore != 3000 || score.Character.Name != "arthas" {
+		t.Fatalf("unexpected score event %#v", score)
+	}
+}
+
+func TestSubscriptionDropsWhenFull(t *testing.T) {
+	ctx := context.Background()
+	st := NewSQLiteStore(Params{})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	slow := st.Subscribe(1)
+	fast := st.Subscribe(8)
+	defer fast.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		if err := st.UpsertCompletedKey(ctx, testKey(i)); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+
+	if got := len(drainEvents(slow)); got != 1 {
+		t.Fatalf("expected 1 buffered event for slow subscriber, got %d", got)
+	}
+	if slow.Dropped() != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", slow.Dropped())
+	}
+	if got := len(drainEvents(fast)); got != 3 || fast.Dropped() != 0 {
+		t.Fatalf("expected fast subscriber to get all 3 events, got %d (dropped %d)", got, fast.Dropped())
+	}
+
+	slow.Close()
+	slow.Close()
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("expected closed subscription channel")
+	}
+	if err := st.UpsertCompletedKey(ctx, testKey(4)); err != nil {
+		t.Fatalf("upsert after close: %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store/db"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+const (
+	// HistorySourceLive marks history rows summarized from the live database.
+	HistorySourceLive = "live"
+
+	_archivePrefix    = "celestial_orrey_"
+	_archiveTimestamp = "2006-01-02_150405"
+)
+
+// WeekSummary is one character's result for a completed week.
+type WeekSummary struct {
+	WeekStart    time.Time
+	Character    models.Character
+	KeyCount     int64
+	BestKeyLevel int
+	BestDungeon  string
+	// VaultLevels holds the key level that unlocked each M+ vault slot
+	// (1, 4 and 8 keys), or 0 if the slot was not earned.
+	VaultLevels [3]int
+	// Source is HistorySourceLive or the archive file the week came from.
+	Source string
+}
+
+// IndexHistory records a summary of every completed week before now. Weeks
+// still present in the live database are refreshed from it; weeks found only
+// in archive files under the backup directory are added without overwriting
+// existing rows. It returns the number of rows written.
+//
+// Archives are read and summarized without holding s.mu, so indexing at
+// startup does not block the store; the lock is only taken to summarize the
+// live database and write the rows.
+func (s *SQLiteStore) IndexHistory(ctx context.Context, now time.Time) (int, error) {
+	archives, err := s.listArchives()
+	if err != nil {
+		return 0, err
+	}
+	archived := make(map[string][]WeekSummary, len(archives))
+	for _, path := range archives {
+		summaries, err := readArchiveHistory(ctx, path)
+		if err != nil {
+			if s.logger != nil {
+				s.logger.WarnW("index archive", "path", path, "error", err)
+			}
+			continue
+		}
+		archived[path] = summaries
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return 0, errors.New("store is not open")
+	}
+
+	written, err := s.recordHistoryLocked(ctx, now)
+	if err != nil {
+		return written, err
+	}
+	for _, path := range archives {
+		summaries, ok := archived[path]
+		if !ok {
+			continue
+		}
+		n, err := s.insertArchiveHistoryLocked(ctx, filepath.Base(path), summaries)
+		if err != nil {
+			if s.logger != nil {
+				s.logger.WarnW("index archive", "path", path, "error", err)
+			}
+			continue
+		}
+		written += n
+	}
+
+	if written > 0 {
+		s.scheduleFlush()
+	}
+	return written, nil
+}
+
+// ListWeeklyHistory returns up to weeks recorded summaries for a character,
+// newest first.
+func (s *SQLiteStore) ListWeeklyHistory(ctx context.Context, name, realm, region string, weeks int) ([]WeekSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	queries := db.New(s.db)
+	rows, err := queries.ListWeeklyHistory(ctx, db.ListWeeklyHistoryParams{
+		LOWER:   name,
+		LOWER_2: realm,
+		LOWER_3: region,
+		Limit:   int64(weeks),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]WeekSummary, 0, len(rows))
+	for _, row := range rows {
+		weekStart, err := timeutil.ParseRFC3339(row.WeekStart)
+		if err != nil {
+			continue
+		}
+		out = append(out, WeekSummary{
+			WeekStart: weekStart,
+			Character: models.Character{
+				Region:   row.Region,
+				Realm:    row.Realm,
+				Name:     row.Name,
+				RIOScore: row.RioScore,
+			},
+			KeyCount:     row.KeyCount,
+			BestKeyLevel: int(row.BestKeyLvl),
+			BestDungeon:  row.BestDungeon,
+			VaultLevels:  [3]int{int(row.Vault1Lvl), int(row.Vault2Lvl), int(row.Vault3Lvl)},
+			Source:       row.Source,
+		})
+	}
+	return out, nil
+}
+
+// recordHistoryLocked summarizes the completed weeks held in the live
+// database. Callers must hold s.mu.
+func (s *SQLiteStore) recordHistoryLocked(ctx context.Context, now time.Time) (int, error) {
+	queries := db.New(s.db)
+	keys, err := queries.ListAllKeysWithCharacters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	chars, err := queries.ListCharacters(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	summaries := summarizeWeeks(keys, characterScores(chars), now)
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	txQueries := db.New(tx)
+	for _, summary := range summaries {
+		if err := txQueries.UpsertWeeklyHistory(ctx, historyParams(summary, HistorySourceLive)); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(summaries), nil
+}
+
+// readArchiveHistory summarizes the completed weeks in one archive file. It
+// only touches the file, so callers need not hold s.mu.
+func readArchiveHistory(ctx context.Context, path string) ([]WeekSummary, error) {
+	var (
+		keys   []db.ListAllKeysWithCharactersRow
+		scores map[string]float64
+	)
+	err := withArchiveFile(path, func(dbPath string) error {
+		archiveDB, err := sql.Open("sqlite3", sqliteFileDSN(dbPath)+"&mode=ro")
+		if err != nil {
+			return err
+		}
+		defer archiveDB.Close()
+
+		archiveQueries := db.New(archiveDB)
+		keys, err = archiveQueries.ListAllKeysWithCharacters(ctx)
+		if err != nil {
+			return fmt.Errorf("read keys: %w", err)
+		}
+		// Archives written before scores were tracked have no rio_score column.
+		if chars, err := archiveQueries.ListCharacters(ctx); err == nil {
+			scores = characterScores(chars)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summarizeWeeks(keys, scores, archiveTime(path)), nil
+}
+
+// insertArchiveHistoryLocked adds the summaries from one archive that have
+// no history row yet. Callers must hold s.mu.
+func (s *SQLiteStore) insertArchiveHistoryLocked(ctx context.Context, source string, summaries []WeekSummary) (int, error) {
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	txQueries := db.New(tx)
+	written := 0
+	for _, summary := range summaries {
+		p := historyParams(summary, source)
+		n, err := txQueries.InsertWeeklyHistoryIfMissing(ctx, db.InsertWeeklyHistoryIfMissingParams(p))
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		written += int(n)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// listArchives returns the weekly archive files in the backup directory,
+// oldest first.
+func (s *SQLiteStore) listArchives() ([]string, error) {
+	if s.backupDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(s.backupDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isArchiveName(name) {
+			continue
+		}
+		paths = append(paths, filepath.Join(s.backupDir, name))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// archiveTime returns when an archive was written, from its file name or,
+// failing that, its modification time.
+func archiveTime(path string) time.Time {
+	stamp := strings.TrimPrefix(filepath.Base(path), _archivePrefix)
+	stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ".zst")
+	stamp = strings.TrimSuffix(stamp, ".db")
+	if t, err := time.ParseInLocation(_archiveTimestamp, stamp, time.Local); err == nil {
+		return t
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// summarizeWeeks groups keys by character and week, skipping the week that
+// contains at. Scores are attached to the most recent completed week only,
+// since that is the only week they describe.
+func summarizeWeeks(keys []db.ListAllKeysWithCharactersRow, scores map[string]float64, at time.Time) []WeekSummary {
+	currentWeek := timeutil.WeeklyResetAt(at)
+	lastWeek := currentWeek.AddDate(0, 0, -7)
+
+	type weekChar struct {
+		week time.Time
+		char string
+	}
+	grouped := make(map[weekChar][]db.ListAllKeysWithCharactersRow)
+	var order []weekChar
+
+	for _, key := range keys {
+		completedAt, err := timeutil.ParseRFC3339(key.CompletedAt)
+		if err != nil || !completedAt.Before(currentWeek) {
+			continue
+		}
+		char := models.Character{Region: key.Region, Realm: key.Realm, Name: key.Character}
+		// Normalize to UTC so equal weeks compare equal as map keys.
+		wc := weekChar{week: timeutil.WeeklyResetAt(completedAt).UTC(), char: char.Key()}
+		if _, ok := grouped[wc]; !ok {
+			order = append(order, wc)
+		}
+		grouped[wc] = append(grouped[wc], key)
+	}
+
+	out := make([]WeekSummary, 0, len(order))
+	for _, wc := range order {
+		weekKeys := grouped[wc]
+		sort.SliceStable(weekKeys, func(i, j int) bool {
+			return weekKeys[i].KeyLvl > weekKeys[j].KeyLvl
+		})
+
+		first := weekKeys[0]
+		summary := WeekSummary{
+			WeekStart: wc.week,
+			Character: models.Character{
+				Region: strings.ToLower(first.Region),
+				Realm:  strings.ToLower(first.Realm),
+				Name:   strings.ToLower(first.Character),
+			},
+			KeyCount:     int64(len(weekKeys)),
+			BestKeyLevel: int(first.KeyLvl),
+			BestDungeon:  first.Dungeon,
+		}
+		for i, slot := range []int{0, 3, 7} {
+			if slot < len(weekKeys) {
+				summary.VaultLevels[i] = int(weekKeys[slot].KeyLvl)
+			}
+		}
+		if wc.week.Equal(lastWeek) {
+			summary.Character.RIOScore = scores[wc.char]
+		}
+		out = append(out, summary)
+	}
+	return out
+}
+
+func characterScores(chars []db.ListCharactersRow) map[string]float64 {
+	scores := make(map[string]float64, len(chars))
+	for _, c := range chars {
+		scores[models.Character{Region: c.Region, Realm: c.Realm, Name: c.Name}.Key()] = c.RioScore
+	}
+	return scores
+}
+
+func historyParams(summary WeekSummary, source string) db.UpsertWeeklyHistoryParams {
+	return db.UpsertWeeklyHistoryParams{
+		WeekStart:   summary.WeekStart.UTC().Format(time.RFC3339),
+		Region:      summary.Character.Region,
+		Realm:       summary.Character.Realm,
+		Name:        summary.Character.Name,
+		KeyCount:    summary.KeyCount,
+		BestKeyLvl:  int64(summary.BestKeyLevel),
+		BestDungeon: summary.BestDungeon,
+		Vault1Lvl:   int64(summary.VaultLevels[0]),
+		Vault2Lvl:   int64(summary.VaultLevels[1]),
+		Vault3Lvl:   int64(summary.VaultLevels[2]),
+		RioScore:    summary.Character.RIOScore,
+		Source:      source,
+	}
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+func TestSQLiteStoreIndexHistory(t *testing.T) {
+	ctx := context.Background()
+	backupDir := t.TempDir()
+
+	arthas := func(id int64, level int, completedAt string) models.CompletedKey {
+		return models.CompletedKey{
+			KeyID:       id,
+			Character:   "Arthas",
+			Region:      "us",
+			Realm:       "illidan",
+			Dungeon:     "Ara-Kara, City of Echoes",
+			KeyLevel:    level,
+			CompletedAt: completedAt,
+			Source:      models.SourceRaiderIO,
+		}
+	}
+
+	// An older snapshot holding the week of Jan 27 plus part of the week of
+	// Feb 3, archived after the Feb 10 reset.
+	old := NewSQLiteStore(Params{})
+	if err := old.Open(ctx); err != nil {
+		t.Fatalf("open archive store: %v", err)
+	}
+	for _, key := range []models.CompletedKey{
+		arthas(1, 8, "2026-01-28T02:00:00Z"),
+		arthas(2, 12, "2026-01-29T02:00:00Z"),
+		arthas(3, 10, "2026-01-30T02:00:00Z"),
+		arthas(4, 9, "2026-02-04T02:00:00Z"),
+	} {
+		if err := old.UpsertCompletedKey(ctx, key); err != nil {
+			t.Fatalf("upsert archive key: %v", err)
+		}
+	}
+	archivePath := filepath.Join(backupDir, "celestial_orrey_2026-02-11_120000.db")
+	if err := old.FlushToDisk(ctx, archivePath); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	old.Close()
+
+	st := NewSQLiteStore(Params{BackupDir: backupDir})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	for _, key := range []models.CompletedKey{
+		arthas(4, 9, "2026-02-04T02:00:00Z"),
+		arthas(5, 14, "2026-02-06T02:00:00Z"),
+		arthas(6, 15, "2026-02-10T18:00:00Z"), // current week
+	} {
+		if err := st.UpsertCompletedKey(ctx, key); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+	if err := st.UpdateCharacterScore(ctx, "arthas", "illidan", "us", 3000); err != nil {
+		t.Fatalf("score: %v", err)
+	}
+
+	now := time.Date(2026, 2, 11, 20, 0, 0, 0, time.UTC)
+	written, err := st.IndexHistory(ctx, now)
+	if err != nil {
+		t.Fatalf("index history: %v", err)
+	}
+	if written != 2 {
+		t.Fatalf("expected 2 history rows written, got %d", written)
+	}
+
+	history, err := st.ListWeeklyHistory(ctx, "Arthas", "illidan", "us", 10)
+	if err != nil {
+		t.Fatalf("list history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 weeks, got %#v", history)
+	}
+
+	live := history[0]
+	if !live.WeekStart.Equal(time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected newest week to start Feb 3, got %s", live.WeekStart)
+	}
+	if live.Source != HistorySourceLive || live.KeyCount != 2 || live.BestKeyLevel != 14 {
+		t.Fatalf("unexpected live week %#v", live)
+	}
+	if live.VaultLevels != [3]int{14, 0, 0} {
+		t.Fatalf("expected vault levels [14 0 0], got %v", live.VaultLevels)
+	}
+	if live.Character.RIOScore != 3000 {
+		t.Fatalf("expected score on last week, got %v", live.Character.RIOScore)
+	}
+
+	archived := history[1]
+	if archived.Source != filepath.Base(archivePath) || archived.KeyCount != 3 || archived.BestKeyLevel != 12 {
+		t.Fatalf("unexpected archived week %#v", archived)
+	}
+	if archived.Character.RIOScore != 0 {
+		t.Fatalf("expected no score on older week, got %v", archived.Character.RIOScore)
+	}
+
+	// Re-indexing refreshes live weeks but adds nothing from archives.
+	written, err = st.IndexHistory(ctx, now)
+	if err != nil {
+		t.Fatalf("reindex history: %v", err)
+	}
+	if written != 1 {
+		t.Fatalf("expected only the live week rewritten, got %d", written)
+	}
+
+	latest, err := st.ListWeeklyHistory(ctx, "arthas", "Illidan", "US", 1)
+	if err != nil {
+		t.Fatalf("list latest: %v", err)
+	}
+	if len(latest) != 1 || latest[0].KeyCount != 2 {
+		t.Fatalf("expected only the newest week, got %#v", latest)
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	_sinkArchives  = "archives/"
+	_sinkSnapshots = "snapshots/"
+)
+
+// shipArchive uploads a verified archive to the sink and applies the local
+// retention policy to the archives already there.
+func (s *SQLiteStore) shipArchive(ctx context.Context, archivePath string) error {
+	if s.sink == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return err
+	}
+	key := _sinkArchives + filepath.Base(archivePath)
+	if err := s.sink.Put(ctx, key, data); err != nil {
+		return err
+	}
+
+	if s.backupCfg.KeepWeekly <= 0 && s.backupCfg.KeepMonthly <= 0 {
+		return nil
+	}
+	keys, err := s.sinkKeys(ctx, _sinkArchives)
+	if err != nil {
+		return err
+	}
+	for _, expired := range expiredArchives(keys, s.backupCfg.KeepWeekly, s.backupCfg.KeepMonthly) {
+		if err := s.sink.Delete(ctx, expired); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shipSnapshot uploads the current snapshot if the configured interval has
+// passed since the last upload, keeping the newest KeepSnapshots uploads.
+func (s *SQLiteStore) shipSnapshot(ctx context.Context) error {
+	if s.sink == nil {
+		return nil
+	}
+
+	s.shipMu.Lock()
+	defer s.shipMu.Unlock()
+
+	now := s.clock.Now()
+	if !s.lastSnapshotShip.IsZero() && now.Sub(s.lastSnapshotShip) < s.sinkCfg.SnapshotInterval {
+		return nil
+	}
+
+	s.mu.RLock()
+	raw, err := os.ReadFile(s.snapshotPath)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := s.verifySnapshotData(ctx, raw); err != nil {
+		return fmt.Errorf("verify snapshot: %w", err)
+	}
+
+	data := raw
+	ext, err := compressionExt(s.backupCfg.Compression)
+	if err != nil {
+		return err
+	}
+	if ext != "" {
+		var buf bytes.Buffer
+		w, err := newCompressor(&buf, s.backupCfg.Compression)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	key := fmt.Sprintf("%s%s%s.db%s", _sinkSnapshots, _archivePrefix, now.Format(_archiveTimestamp), ext)
+	if err := s.sink.Put(ctx, key, data); err != nil {
+		return err
+	}
+	s.lastSnapshotShip = now
+
+	if s.sinkCfg.KeepSnapshots <= 0 {
+		return nil
+	}
+	keys, err := s.sinkKeys(ctx, _sinkSnapshots)
+	if err != nil {
+		return err
+	}
+	// Snapshot keys embed their timestamp, so they sort oldest first.
+	sort.Strings(keys)
+	for i := 0; i < len(keys)-s.sinkCfg.KeepSnapshots; i++ {
+		if err := s.sink.Delete(ctx, keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreFromSink downloads a backup from the sink, checks it and loads it
+// into memory, replacing the current contents.
+func (s *SQLiteStore) RestoreFromSink(ctx context.Context, key string) error {
+	if s.sink == nil {
+		return errors.New("no backup sink configured")
+	}
+
+	data, err := s.sink.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Keep the key's extension so withArchiveFile knows how to expand it.
+	tmp, err := os.CreateTemp("", "celestial_orrey_sink_*_"+path.Base(key))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := s.RestoreArchive(ctx, tmp.Name()); err != nil {
+		return fmt.Errorf("restore %s: %w", key, err)
+	}
+	return nil
+}
+
+// newestSinkBackup returns the key of the most recent snapshot or archive in
+// the sink, or an empty string if there is none.
+func (s *SQLiteStore) newestSinkBackup(ctx context.Context) (string, error) {
+	if s.sink == nil {
+		return "", nil
+	}
+
+	var (
+		newest     string
+		newestTime time.Time
+	)
+	for _, prefix := range []string{_sinkSnapshots, _sinkArchives} {
+		keys, err := s.sinkKeys(ctx, prefix)
+		if err != nil {
+			return "", err
+		}
+		for _, key := range keys {
+			if t := archiveTime(key); newest == "" || t.After(newestTime) {
+				newest, newestTime = key, t
+			}
+		}
+	}
+	return newest, nil
+}
+
+// sinkKeys lists the backup objects under prefix.
+func (s *SQLiteStore) sinkKeys(ctx context.Context, prefix string) ([]string, error) {
+	objects, err := s.sink.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, obj := range objects {
+		if isArchiveName(path.Base(obj.Key)) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,349 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/store/db"
+)
+
+// _outboxTime is the fixed-width UTC layout outbox times are stored in, so
+// that they order correctly as text.
+const _outboxTime = "2006-01-02T15:04:05.000Z"
+
+var (
+	_ Outbox = (*SQLiteStore)(nil)
+	_ Outbox = (*PostgresStore)(nil)
+)
+
+// Outbox is a durable queue of Discord posts. Messages are deduplicated by
+// DedupKey, so enqueueing the same announcement twice posts it once.
+//
+// A claim and a sent mark are durable when the call returns; the SQLite
+// store writes its snapshot before returning from ClaimMessage and
+// MarkMessageSent instead of waiting for the debounced flush. A crash
+// between the send and MarkMessageSent leaves the message in doubt, and
+// the dispatcher checks the channel for it before sending again.
+type Outbox interface {
+	// EnqueueMessage queues msg for delivery. It reports false if a message
+	// with the same DedupKey was already queued.
+	EnqueueMessage(ctx context.Context, msg OutboxMessage) (bool, error)
+	// RecordElvUIVersion stores v and, if its version differs from the
+	// stored one, enqueues announce in the same transaction. An announce
+	// without a DedupKey is not queued. It reports whether the version
+	// changed.
+	RecordElvUIVersion(ctx context.Context, v ElvUIVersion, announce OutboxMessage) (bool, error)
+
+	// DueMessages returns up to limit undelivered messages whose next
+	// attempt is at or before now, oldest first.
+	DueMessages(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error)
+	// ClaimMessage records that a delivery attempt is starting.
+	ClaimMessage(ctx context.Context, id int64, at time.Time) error
+	MarkMessageSent(ctx context.Context, id int64, at time.Time) error
+	// MarkMessageFailed records a failed attempt. The message is retried at
+	// retryAt, or parked as failed if giveUp is set.
+	MarkMessageFailed(ctx context.Context, id int64, cause string, retryAt time.Time, giveUp bool) error
+
+	// FailedMessages returns up to limit messages that were given up on,
+	// most recent first.
+	FailedMessages(ctx context.Context, limit int) ([]OutboxMessage, error)
+	// RequeueMessage puts a failed message back in the queue. It reports
+	// false if no failed message has that ID.
+	RequeueMessage(ctx context.Context, id int64, at time.Time) (bool, error)
+	// PruneSentMessages deletes messages delivered before cutoff and
+	// returns how many were removed. Failed messages are kept for retry.
+	PruneSentMessages(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// OutboxMessage is a Discord post waiting for delivery.
+type OutboxMessage struct {
+	ID        int64
+	DedupKey  string
+	ChannelID string
+	Content   string
+	// Embeds holds JSON-encoded Discord embeds, or is empty.
+	Embeds string
+
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	// ClaimedAt is set while a delivery attempt is in progress. A due
+	// message that still has it set was interrupted mid-send.
+	ClaimedAt time.Time
+	SentAt    time.Time
+	FailedAt  time.Time
+	CreatedAt time.Time
+}
+
+// InDoubt reports whether an earlier delivery attempt stopped before its
+// outcome was recorded, so the message may already have been posted.
+func (m OutboxMessage) InDoubt() bool {
+	return !m.ClaimedAt.IsZero()
+}
+
+func (s *SQLiteStore) EnqueueMessage(ctx context.Context, msg OutboxMessage) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	n, err := s.enqueueMessage(ctx, db.New(s.db), msg)
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	s.scheduleFlush()
+	return true, nil
+}
+
+func (s *SQLiteStore) RecordElvUIVersion(ctx context.Context, v ElvUIVersion, announce OutboxMessage) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	queries := db.New(tx)
+	current, err := queries.GetElvUIVersion(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return false, err
+	}
+	changed := err != nil || current.Version != v.Version
+
+	if err := queries.UpsertElvUIVersion(ctx, db.UpsertElvUIVersionParams{
+		Version:      v.Version,
+		DownloadUrl:  v.DownloadURL,
+		ChangelogUrl: v.ChangelogURL,
+		LastUpdate:   v.LastUpdate,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	if changed && announce.DedupKey != "" {
+		if _, err := s.enqueueMessage(ctx, queries, announce); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	s.scheduleFlush()
+	return changed, nil
+}
+
+func (s *SQLiteStore) DueMessages(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := db.New(s.db).ListDueOutboxMessages(ctx, db.ListDueOutboxMessagesParams{
+		NextAttemptAt: formatOutboxTime(now),
+		Limit:         int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outboxMessages(rows), nil
+}
+
+func (s *SQLiteStore) ClaimMessage(ctx context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	if err := db.New(s.db).ClaimOutboxMessage(ctx, db.ClaimOutboxMessageParams{
+		ClaimedAt: formatOutboxTime(at),
+		ID:        id,
+	}); err != nil {
+		return err
+	}
+
+	// The send follows at once, so the claim must survive a crash.
+	return s.persistLocked(ctx)
+}
+
+func (s *SQLiteStore) MarkMessageSent(ctx context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	if err := db.New(s.db).MarkOutboxMessageSent(ctx, db.MarkOutboxMessageSentParams{
+		SentAt: formatOutboxTime(at),
+		ID:     id,
+	}); err != nil {
+		return err
+	}
+
+	return s.persistLocked(ctx)
+}
+
+func (s *SQLiteStore) MarkMessageFailed(ctx context.Context, id int64, cause string, retryAt time.Time, giveUp bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	var failedAt string
+	if giveUp {
+		failedAt = formatOutboxTime(s.clock.Now())
+	}
+	if err := db.New(s.db).MarkOutboxMessageFailed(ctx, db.MarkOutboxMessageFailedParams{
+		LastError:     cause,
+		NextAttemptAt: formatOutboxTime(retryAt),
+		FailedAt:      failedAt,
+		ID:            id,
+	}); err != nil {
+		return err
+	}
+
+	s.scheduleFlush()
+	return nil
+}
+
+func (s *SQLiteStore) FailedMessages(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := db.New(s.db).ListFailedOutboxMessages(ctx, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+	return outboxMessages(rows), nil
+}
+
+func (s *SQLiteStore) RequeueMessage(ctx context.Context, id int64, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	n, err := db.New(s.db).RequeueOutboxMessage(ctx, db.RequeueOutboxMessageParams{
+		NextAttemptAt: formatOutboxTime(at),
+		ID:            id,
+	})
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	s.scheduleFlush()
+	return true, nil
+}
+
+func (s *SQLiteStore) PruneSentMessages(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return 0, errors.New("store is not open")
+	}
+
+	n, err := db.New(s.db).PruneSentOutboxMessages(ctx, formatOutboxTime(cutoff))
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	s.scheduleFlush()
+	return n, nil
+}
+
+// persistLocked writes the snapshot now rather than at the next debounced
+// flush. If that fails the debounced flush is still scheduled. Callers must
+// hold s.mu.
+func (s *SQLiteStore) persistLocked(ctx context.Context) error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+	err := s.guardEmptyOverwrite(ctx, s.snapshotPath)
+	if err == nil {
+		err = s.flushLocked(ctx, s.snapshotPath)
+	}
+	if err != nil {
+		s.scheduleFlush()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) enqueueMessage(ctx context.Context, queries *db.Queries, msg OutboxMessage) (int64, error) {
+	if msg.DedupKey == "" {
+		return 0, errors.New("outbox message needs a dedup key")
+	}
+	next := msg.NextAttemptAt
+	if next.IsZero() {
+		next = s.clock.Now()
+	}
+	return queries.EnqueueOutboxMessage(ctx, db.EnqueueOutboxMessageParams{
+		DedupKey:      msg.DedupKey,
+		ChannelID:     msg.ChannelID,
+		Content:       msg.Content,
+		Embeds:        msg.Embeds,
+		NextAttemptAt: formatOutboxTime(next),
+	})
+}
+
+func outboxMessages(rows []db.Outbox) []OutboxMessage {
+	out := make([]OutboxMessage, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, OutboxMessage{
+			ID:            row.ID,
+			DedupKey:      row.DedupKey,
+			ChannelID:     row.ChannelID,
+			Content:       row.Content,
+			Embeds:        row.Embeds,
+			Attempts:      int(row.Attempts),
+			LastError:     row.LastError,
+			NextAttemptAt: parseOutboxTime(row.NextAttemptAt),
+			ClaimedAt:     parseOutboxTime(row.ClaimedAt),
+			SentAt:        parseOutboxTime(row.SentAt),
+			FailedAt:      parseOutboxTime(row.FailedAt),
+			CreatedAt:     parseOutboxTime(row.CreatedAt),
+		})
+	}
+	return out
+}
+
+func formatOutboxTime(t time.Time) string {
+	return t.UTC().Format(_outboxTime)
+}
+
+// parseOutboxTime returns the zero time for unset or unparseable values.
+func parseOutboxTime(value string) time.Time {
+	t, err := time.Parse(_outboxTime, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreOutbox(t *testing.T) {
+	runBackends(t, testStoreOutbox)
+}
+
+func testStoreOutbox(t *testing.T, st Store) {
+	ctx := context.Background()
+	now := time.Date(2026, 2, 4, 15, 0, 0, 0, time.UTC)
+	msg := OutboxMessage{DedupKey: "report:2026-02-04", ChannelID: "chan", Content: "hello", NextAttemptAt: now}
+
+	added, err := st.EnqueueMessage(ctx, msg)
+	if err != nil || !added {
+		t.Fatalf("enqueue: added=%v err=%v", added, err)
+	}
+	added, err = st.EnqueueMessage(ctx, msg)
+	if err != nil || added {
+		t.Fatalf("duplicate enqueue: added=%v err=%v", added, err)
+	}
+
+	if due, err := st.DueMessages(ctx, now.Add(-time.Second), 10); err != nil || len(due) != 0 {
+		t.Fatalf("expected nothing due yet, got %d (err %v)", len(due), err)
+	}
+	due, err := st.DueMessages(ctx, now, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected 1 due message, got %d (err %v)", len(due), err)
+	}
+	got := due[0]
+	if got.Content != "hello" || got.InDoubt() || !got.NextAttemptAt.Equal(now) {
+		t.Fatalf("unexpected message %#v", got)
+	}
+
+	if err := st.ClaimMessage(ctx, got.ID, now); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	due, _ = st.DueMessages(ctx, now, 10)
+	if len(due) != 1 || !due[0].InDoubt() || due[0].Attempts != 1 {
+		t.Fatalf("expected claimed message to be in doubt, got %#v", due)
+	}
+
+	if err := st.MarkMessageFailed(ctx, got.ID, "boom", now.Add(time.Minute), true); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if due, _ := st.DueMessages(ctx, now.Add(time.Hour), 10); len(due) != 0 {
+		t.Fatalf("expected failed message to leave the queue, got %d", len(due))
+	}
+	failed, err := st.FailedMessages(ctx, 10)
+	if err != nil || len(failed) != 1 || failed[0].LastError != "boom" || failed[0].InDoubt() {
+		t.Fatalf("unexpected failed messages %#v (err %v)", failed, err)
+	}
+
+	if ok, err := st.RequeueMessage(ctx, 999, now); err != nil || ok {
+		t.Fatalf("requeue missing: ok=%v err=%v", ok, err)
+	}
+	if ok, err := st.RequeueMessage(ctx, got.ID, now); err != nil || !ok {
+		t.Fatalf("requeue: ok=%v err=%v", ok, err)
+	}
+	due, _ = st.DueMessages(ctx, now, 10)
+	if len(due) != 1 || due[0].Attempts != 0 {
+		t.Fatalf("expected requeued message to be due, got %#v", due)
+	}
+
+	if err := st.MarkMessageSent(ctx, got.ID, now); err != nil {
+		t.Fatalf("sent: %v", err)
+	}
+	if due, _ := st.DueMessages(ctx, now.Add(time.Hour), 10); len(due) != 0 {
+		t.Fatalf("expected sent message to leave the queue, got %d", len(due))
+	}
+}
+
+func TestStoreRecordElvUIVersion(t *testing.T) {
+	runBackends(t, testStoreRecordElvUIVersion)
+}
+
+func testStoreRecordElvUIVersion(t *testing.T, st Store) {
+	ctx := context.Background()
+	announce := func(version string) OutboxMessage {
+		return OutboxMessage{DedupKey: "elvui:" + version, ChannelID: "chan", Content: version}
+	}
+
+	// The first sighting is recorded without an announcement.
+	changed, err := st.RecordElvUIVersion(ctx, ElvUIVersion{Version: "13.80"}, OutboxMessage{})
+	if err != nil || !changed {
+		t.Fatalf("first record: changed=%v err=%v", changed, err)
+	}
+	changed, err = st.RecordElvUIVersion(ctx, ElvUIVersion{Version: "13.80"}, announce("13.80"))
+	if err != nil || changed {
+		t.Fatalf("same version: changed=%v err=%v", changed, err)
+	}
+	changed, err = st.RecordElvUIVersion(ctx, ElvUIVersion{Version: "13.81"}, announce("13.81"))
+	if err != nil || !changed {
+		t.Fatalf("new version: changed=%v err=%v", changed, err)
+	}
+
+	due, err := st.DueMessages(ctx, time.Now().Add(time.Minute), 10)
+	if err != nil || len(due) != 1 || due[0].DedupKey != "elvui:13.81" {
+		t.Fatalf("expected only the 13.81 announcement, got %#v (err %v)", due, err)
+	}
+	v, err := st.GetElvUIVersion(ctx)
+	if err != nil || v.Version != "13.81" {
+		t.Fatalf("expected stored version 13.81, got %q (err %v)", v.Version, err)
+	}
+}
+
+func TestStorePruneSentMessages(t *testing.T) {
+	runBackends(t, func(t *testing.T, st Store) {
+		ctx := context.Background()
+		now := time.Date(2026, 2, 4, 15, 0, 0, 0, time.UTC)
+
+		ids := map[string]int64{}
+		for _, key := range []string{"old", "new", "pending", "failed"} {
+			if _, err := st.EnqueueMessage(ctx, OutboxMessage{DedupKey: key, ChannelID: "chan", NextAttemptAt: now}); err != nil {
+				t.Fatalf("enqueue %s: %v", key, err)
+			}
+		}
+		due, err := st.DueMessages(ctx, now, 10)
+		if err != nil || len(due) != 4 {
+			t.Fatalf("expected 4 due messages, got %d (err %v)", len(due), err)
+		}
+		for _, m := range due {
+			ids[m.DedupKey] = m.ID
+		}
+
+		if err := st.MarkMessageSent(ctx, ids["old"], now.Add(-48*time.Hour)); err != nil {
+			t.Fatalf("sent old: %v", err)
+		}
+		if err := st.MarkMessageSent(ctx, ids["new"], now); err != nil {
+			t.Fatalf("sent new: %v", err)
+		}
+		if err := st.MarkMessageFailed(ctx, ids["failed"], "boom", now, true); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+
+		n, err := st.PruneSentMessages(ctx, now.Add(-24*time.Hour))
+		if err != nil || n != 1 {
+			t.Fatalf("prune: removed=%d err=%v; want 1", n, err)
+		}
+		if due, _ := st.DueMessages(ctx, now, 10); len(due) != 1 || due[0].DedupKey != "pending" {
+			t.Fatalf("expected the pending message to stay queued, got %#v", due)
+		}
+		if failed, _ := st.FailedMessages(ctx, 10); len(failed) != 1 {
+			t.Fatalf("expected the failed message to be kept, got %d", len(failed))
+		}
+	})
+}
+
+func TestSQLiteStoreClaimIsDurable(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+
+	st := NewSQLiteStore(Params{Path: path})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+
+	now := time.Date(2026, 2, 4, 15, 0, 0, 0, time.UTC)
+	if _, err := st.EnqueueMessage(ctx, OutboxMessage{DedupKey: "report", ChannelID: "chan", NextAttemptAt: now}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	due, err := st.DueMessages(ctx, now, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected 1 due message, got %d (err %v)", len(due), err)
+	}
+	if err := st.ClaimMessage(ctx, due[0].ID, now); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+
+	// Read the snapshot while st is still open, as a restart after a crash
+	// would, without waiting for the debounced flush.
+	restored := NewSQLiteStore(Params{Path: path})
+	if err := restored.Open(ctx); err != nil {
+		t.Fatalf("open restore: %v", err)
+	}
+	defer restored.Close()
+	if err := restored.RestoreFromDisk(ctx, path); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	due, err = restored.DueMessages(ctx, now, 10)
+	if err != nil || len(due) != 1 || !due[0].InDoubt() {
+		t.Fatalf("expected the claimed message to be in doubt after restart, got %#v (err %v)", due, err)
+	}
+}
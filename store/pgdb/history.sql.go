@@ -0,0 +1,132 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: history.sql
+
+package pgdb
+
+import (
+	"context"
+)
+
+const listWeeklyHistory = `-- name: ListWeeklyHistory :many
+SELECT week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+FROM weekly_history
+WHERE LOWER(name) = LOWER($1) AND LOWER(realm) = LOWER($2) AND LOWER(region) = LOWER($3)
+ORDER BY week_start DESC
+LIMIT $4
+`
+
+type ListWeeklyHistoryParams struct {
+	Lower   string `json:"lower"`
+	Lower_2 string `json:"lower_2"`
+	Lower_3 string `json:"lower_3"`
+	Limit   int32  `json:"limit"`
+}
+
+type ListWeeklyHistoryRow struct {
+	WeekStart   string  `json:"week_start"`
+	Region      string  `json:"region"`
+	Realm       string  `json:"realm"`
+	Name        string  `json:"name"`
+	KeyCount    int64   `json:"key_count"`
+	BestKeyLvl  int64   `json:"best_key_lvl"`
+	BestDungeon string  `json:"best_dungeon"`
+	Vault1Lvl   int64   `json:"vault_1_lvl"`
+	Vault2Lvl   int64   `json:"vault_2_lvl"`
+	Vault3Lvl   int64   `json:"vault_3_lvl"`
+	RioScore    float64 `json:"rio_score"`
+	Source      string  `json:"source"`
+}
+
+func (q *Queries) ListWeeklyHistory(ctx context.Context, arg ListWeeklyHistoryParams) ([]ListWeeklyHistoryRow, error) {
+	rows, err := q.db.QueryContext(ctx, listWeeklyHistory,
+		arg.Lower,
+		arg.Lower_2,
+		arg.Lower_3,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWeeklyHistoryRow
+	for rows.Next() {
+		var i ListWeeklyHistoryRow
+		if err := rows.Scan(
+			&i.WeekStart,
+			&i.Region,
+			&i.Realm,
+			&i.Name,
+			&i.KeyCount,
+			&i.BestKeyLvl,
+			&i.BestDungeon,
+			&i.Vault1Lvl,
+			&i.Vault2Lvl,
+			&i.Vault3Lvl,
+			&i.RioScore,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertWeeklyHistory = `-- name: UpsertWeeklyHistory :exec
+INSERT INTO weekly_history(
+  week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT(week_start, region, realm, name) DO UPDATE SET
+  key_count = excluded.key_count,
+  best_key_lvl = excluded.best_key_lvl,
+  best_dungeon = excluded.best_dungeon,
+  vault_1_lvl = excluded.vault_1_lvl,
+  vault_2_lvl = excluded.vault_2_lvl,
+  vault_3_lvl = excluded.vault_3_lvl,
+  rio_score = CASE WHEN excluded.rio_score > 0 THEN excluded.rio_score ELSE weekly_history.rio_score END,
+  source = excluded.source,
+  recorded_at = to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+`
+
+type UpsertWeeklyHistoryParams struct {
+	WeekStart   string  `json:"week_start"`
+	Region      string  `json:"region"`
+	Realm       string  `json:"realm"`
+	Name        string  `json:"name"`
+	KeyCount    int64   `json:"key_count"`
+	BestKeyLvl  int64   `json:"best_key_lvl"`
+	BestDungeon string  `json:"best_dungeon"`
+	Vault1Lvl   int64   `json:"vault_1_lvl"`
+	Vault2Lvl   int64   `json:"vault_2_lvl"`
+	Vault3Lvl   int64   `json:"vault_3_lvl"`
+	RioScore    float64 `json:"rio_score"`
+	Source      string  `json:"source"`
+}
+
+func (q *Queries) UpsertWeeklyHistory(ctx context.Context, arg UpsertWeeklyHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, upsertWeeklyHistory,
+		arg.WeekStart,
+		arg.Region,
+		arg.Realm,
+		arg.Name,
+		arg.KeyCount,
+		arg.BestKeyLvl,
+		arg.BestDungeon,
+		arg.Vault1Lvl,
+		arg.Vault2Lvl,
+		arg.Vault3Lvl,
+		arg.RioScore,
+		arg.Source,
+	)
+	return err
+}
@@ -0,0 +1,618 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: keys.sql
+
+package pgdb
+
+import (
+	"context"
+)
+
+const countKeysByCharacterSince = `-- name: CountKeysByCharacterSince :many
+SELECT c.region, c.realm, c.name, COUNT(*) AS key_count
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+WHERE k.completed_at > $1
+GROUP BY c.region, c.realm, c.name
+ORDER BY key_count DESC
+`
+
+type CountKeysByCharacterSinceRow struct {
+	Region   string `json:"region"`
+	Realm    string `json:"realm"`
+	Name     string `json:"name"`
+	KeyCount int64  `json:"key_count"`
+}
+
+func (q *Queries) CountKeysByCharacterSince(ctx context.Context, completedAt string) ([]CountKeysByCharacterSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, countKeysByCharacterSince, completedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountKeysByCharacterSinceRow
+	for rows.Next() {
+		var i CountKeysByCharacterSinceRow
+		if err := rows.Scan(
+			&i.Region,
+			&i.Realm,
+			&i.Name,
+			&i.KeyCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countStoredRows = `-- name: CountStoredRows :one
+SELECT
+  (SELECT COUNT(*) FROM characters) AS character_count,
+  (SELECT COUNT(*) FROM completed_keys) AS key_count
+`
+
+type CountStoredRowsRow struct {
+	CharacterCount int64 `json:"character_count"`
+	KeyCount       int64 `json:"key_count"`
+}
+
+func (q *Queries) CountStoredRows(ctx context.Context) (CountStoredRowsRow, error) {
+	row := q.db.QueryRowContext(ctx, countStoredRows)
+	var i CountStoredRowsRow
+	err := row.Scan(&i.CharacterCount, &i.KeyCount)
+	return i, err
+}
+
+const deleteCharacter = `-- name: DeleteCharacter :exec
+DELETE FROM characters WHERE id = $1
+`
+
+func (q *Queries) DeleteCharacter(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCharacter, id)
+	return err
+}
+
+const deleteCompletedKey = `-- name: DeleteCompletedKey :exec
+DELETE FROM completed_keys WHERE key_id = $1 AND character_id = $2
+`
+
+type DeleteCompletedKeyParams struct {
+	KeyID       int64 `json:"key_id"`
+	CharacterID int64 `json:"character_id"`
+}
+
+func (q *Queries) DeleteCompletedKey(ctx context.Context, arg DeleteCompletedKeyParams) error {
+	_, err := q.db.ExecContext(ctx, deleteCompletedKey, arg.KeyID, arg.CharacterID)
+	return err
+}
+
+const deleteCompletedKeysByCharacter = `-- name: DeleteCompletedKeysByCharacter :exec
+DELETE FROM completed_keys WHERE character_id = $1
+`
+
+func (q *Queries) DeleteCompletedKeysByCharacter(ctx context.Context, characterID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCompletedKeysByCharacter, characterID)
+	return err
+}
+
+const deleteOrphanedWarcraftLogsLinks = `-- name: DeleteOrphanedWarcraftLogsLinks :exec
+DELETE FROM warcraftlogs_links
+WHERE warcraftlogs_links.key_id = $1
+  AND NOT EXISTS (SELECT 1 FROM completed_keys k WHERE k.key_id = warcraftlogs_links.key_id)
+`
+
+func (q *Queries) DeleteOrphanedWarcraftLogsLinks(ctx context.Context, keyID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteOrphanedWarcraftLogsLinks, keyID)
+	return err
+}
+
+const deleteWarcraftLogsLinksByCharacter = `-- name: DeleteWarcraftLogsLinksByCharacter :exec
+DELETE FROM warcraftlogs_links
+WHERE key_id IN (
+  SELECT k.key_id FROM completed_keys k
+  JOIN characters c ON c.id = k.character_id
+  WHERE c.id = $1
+)
+`
+
+func (q *Queries) DeleteWarcraftLogsLinksByCharacter(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWarcraftLogsLinksByCharacter, id)
+	return err
+}
+
+const getCharacter = `-- name: GetCharacter :one
+SELECT id, region, realm, name, rio_score FROM characters
+WHERE LOWER(name) = LOWER($1) AND LOWER(realm) = LOWER($2) AND LOWER(region) = LOWER($3)
+`
+
+type GetCharacterParams struct {
+	Lower   string `json:"lower"`
+	Lower_2 string `json:"lower_2"`
+	Lower_3 string `json:"lower_3"`
+}
+
+func (q *Queries) GetCharacter(ctx context.Context, arg GetCharacterParams) (Character, error) {
+	row := q.db.QueryRowContext(ctx, getCharacter, arg.Lower, arg.Lower_2, arg.Lower_3)
+	var i Character
+	err := row.Scan(
+		&i.ID,
+		&i.Region,
+		&i.Realm,
+		&i.Name,
+		&i.RioScore,
+	)
+	return i, err
+}
+
+const getCharacterID = `-- name: GetCharacterID :one
+SELECT id FROM characters
+WHERE LOWER(name) = LOWER($1) AND LOWER(realm) = LOWER($2) AND LOWER(region) = LOWER($3)
+`
+
+type GetCharacterIDParams struct {
+	Lower   string `json:"lower"`
+	Lower_2 string `json:"lower_2"`
+	Lower_3 string `json:"lower_3"`
+}
+
+func (q *Queries) GetCharacterID(ctx context.Context, arg GetCharacterIDParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getCharacterID, arg.Lower, arg.Lower_2, arg.Lower_3)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getCompletedKey = `-- name: GetCompletedKey :one
+SELECT dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
+FROM completed_keys
+WHERE key_id = $1 AND character_id = $2
+`
+
+type GetCompletedKeyParams struct {
+	KeyID       int64 `json:"key_id"`
+	CharacterID int64 `json:"character_id"`
+}
+
+type GetCompletedKeyRow struct {
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) GetCompletedKey(ctx context.Context, arg GetCompletedKeyParams) (GetCompletedKeyRow, error) {
+	row := q.db.QueryRowContext(ctx, getCompletedKey, arg.KeyID, arg.CharacterID)
+	var i GetCompletedKeyRow
+	err := row.Scan(
+		&i.Dungeon,
+		&i.KeyLvl,
+		&i.RunTimeMs,
+		&i.ParTimeMs,
+		&i.CompletedAt,
+		&i.Source,
+	)
+	return i, err
+}
+
+const insertCompletedKey = `-- name: InsertCompletedKey :exec
+INSERT INTO completed_keys(
+  key_id, character_id, dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT(key_id, character_id) DO UPDATE SET
+  dungeon = excluded.dungeon,
+  key_lvl = excluded.key_lvl,
+  run_time_ms = excluded.run_time_ms,
+  par_time_ms = excluded.par_time_ms,
+  completed_at = excluded.completed_at,
+  source = excluded.source
+`
+
+type InsertCompletedKeyParams struct {
+	KeyID       int64  `json:"key_id"`
+	CharacterID int64  `json:"character_id"`
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) InsertCompletedKey(ctx context.Context, arg InsertCompletedKeyParams) error {
+	_, err := q.db.ExecContext(ctx, insertCompletedKey,
+		arg.KeyID,
+		arg.CharacterID,
+		arg.Dungeon,
+		arg.KeyLvl,
+		arg.RunTimeMs,
+		arg.ParTimeMs,
+		arg.CompletedAt,
+		arg.Source,
+	)
+	return err
+}
+
+const listAllKeysWithCharacters = `-- name: ListAllKeysWithCharacters :many
+SELECT k.key_id, c.id as character_id, c.region, c.realm, c.name AS character,
+k.dungeon, k.key_lvl, k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+ORDER BY k.completed_at DESC
+`
+
+type ListAllKeysWithCharactersRow struct {
+	KeyID       int64  `json:"key_id"`
+	CharacterID int64  `json:"character_id"`
+	Region      string `json:"region"`
+	Realm       string `json:"realm"`
+	Character   string `json:"character"`
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) ListAllKeysWithCharacters(ctx context.Context) ([]ListAllKeysWithCharactersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAllKeysWithCharacters)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAllKeysWithCharactersRow
+	for rows.Next() {
+		var i ListAllKeysWithCharactersRow
+		if err := rows.Scan(
+			&i.KeyID,
+			&i.CharacterID,
+			&i.Region,
+			&i.Realm,
+			&i.Character,
+			&i.Dungeon,
+			&i.KeyLvl,
+			&i.RunTimeMs,
+			&i.ParTimeMs,
+			&i.CompletedAt,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCharacters = `-- name: ListCharacters :many
+SELECT region, realm, name, rio_score FROM characters ORDER BY region, realm, name
+`
+
+type ListCharactersRow struct {
+	Region   string  `json:"region"`
+	Realm    string  `json:"realm"`
+	Name     string  `json:"name"`
+	RioScore float64 `json:"rio_score"`
+}
+
+func (q *Queries) ListCharacters(ctx context.Context) ([]ListCharactersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listCharacters)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCharactersRow
+	for rows.Next() {
+		var i ListCharactersRow
+		if err := rows.Scan(
+			&i.Region,
+			&i.Realm,
+			&i.Name,
+			&i.RioScore,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listKeysByCharacterSince = `-- name: ListKeysByCharacterSince :many
+SELECT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
+k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+WHERE LOWER(c.name) = LOWER($1) AND LOWER(c.realm) = LOWER($2) AND LOWER(c.region) = LOWER($3)
+  AND k.completed_at > $4
+ORDER BY k.completed_at DESC
+`
+
+type ListKeysByCharacterSinceParams struct {
+	Lower       string `json:"lower"`
+	Lower_2     string `json:"lower_2"`
+	Lower_3     string `json:"lower_3"`
+	CompletedAt string `json:"completed_at"`
+}
+
+type ListKeysByCharacterSinceRow struct {
+	KeyID       int64  `json:"key_id"`
+	Region      string `json:"region"`
+	Realm       string `json:"realm"`
+	Character   string `json:"character"`
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) ListKeysByCharacterSince(ctx context.Context, arg ListKeysByCharacterSinceParams) ([]ListKeysByCharacterSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listKeysByCharacterSince,
+		arg.Lower,
+		arg.Lower_2,
+		arg.Lower_3,
+		arg.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListKeysByCharacterSinceRow
+	for rows.Next() {
+		var i ListKeysByCharacterSinceRow
+		if err := rows.Scan(
+			&i.KeyID,
+			&i.Region,
+			&i.Realm,
+			&i.Character,
+			&i.Dungeon,
+			&i.KeyLvl,
+			&i.RunTimeMs,
+			&i.ParTimeMs,
+			&i.CompletedAt,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listKeysSince = `-- name: ListKeysSince :many
+SELECT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
+  k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+WHERE k.completed_at > $1
+ORDER BY k.completed_at DESC
+`
+
+type ListKeysSinceRow struct {
+	KeyID       int64  `json:"key_id"`
+	Region      string `json:"region"`
+	Realm       string `json:"realm"`
+	Character   string `json:"character"`
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) ListKeysSince(ctx context.Context, completedAt string) ([]ListKeysSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listKeysSince, completedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListKeysSinceRow
+	for rows.Next() {
+		var i ListKeysSinceRow
+		if err := rows.Scan(
+			&i.KeyID,
+			&i.Region,
+			&i.Realm,
+			&i.Character,
+			&i.Dungeon,
+			&i.KeyLvl,
+			&i.RunTimeMs,
+			&i.ParTimeMs,
+			&i.CompletedAt,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnlinkedKeysSince = `-- name: ListUnlinkedKeysSince :many
+SELECT DISTINCT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
+  k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+LEFT JOIN warcraftlogs_links w ON w.key_id = k.key_id
+WHERE k.completed_at > $1 AND w.id IS NULL
+ORDER BY k.completed_at DESC
+`
+
+type ListUnlinkedKeysSinceRow struct {
+	KeyID       int64  `json:"key_id"`
+	Region      string `json:"region"`
+	Realm       string `json:"realm"`
+	Character   string `json:"character"`
+	Dungeon     string `json:"dungeon"`
+	KeyLvl      int64  `json:"key_lvl"`
+	RunTimeMs   int64  `json:"run_time_ms"`
+	ParTimeMs   int64  `json:"par_time_ms"`
+	CompletedAt string `json:"completed_at"`
+	Source      string `json:"source"`
+}
+
+func (q *Queries) ListUnlinkedKeysSince(ctx context.Context, completedAt string) ([]ListUnlinkedKeysSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUnlinkedKeysSince, completedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnlinkedKeysSinceRow
+	for rows.Next() {
+		var i ListUnlinkedKeysSinceRow
+		if err := rows.Scan(
+			&i.KeyID,
+			&i.Region,
+			&i.Realm,
+			&i.Character,
+			&i.Dungeon,
+			&i.KeyLvl,
+			&i.RunTimeMs,
+			&i.ParTimeMs,
+			&i.CompletedAt,
+			&i.Source,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listVaultProgressSince = `-- name: ListVaultProgressSince :many
+WITH ranked AS (
+  SELECT k.character_id, k.key_lvl,
+    ROW_NUMBER() OVER (PARTITION BY k.character_id ORDER BY k.key_lvl DESC, k.completed_at ASC) AS key_rank,
+    COUNT(*) OVER (PARTITION BY k.character_id) AS key_count
+  FROM completed_keys k
+  WHERE k.completed_at > $1
+)
+SELECT c.region, c.realm, c.name, c.rio_score,
+  COALESCE(r.key_count, 0)::BIGINT AS key_count,
+  COALESCE(r.key_lvl, 0)::BIGINT AS key_lvl
+FROM characters c
+LEFT JOIN ranked r ON r.character_id = c.id AND r.key_rank <= 8
+ORDER BY c.region, c.realm, c.name, r.key_rank
+`
+
+type ListVaultProgressSinceRow struct {
+	Region   string  `json:"region"`
+	Realm    string  `json:"realm"`
+	Name     string  `json:"name"`
+	RioScore float64 `json:"rio_score"`
+	KeyCount int64   `json:"key_count"`
+	KeyLvl   int64   `json:"key_lvl"`
+}
+
+func (q *Queries) ListVaultProgressSince(ctx context.Context, completedAt string) ([]ListVaultProgressSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listVaultProgressSince, completedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListVaultProgressSinceRow
+	for rows.Next() {
+		var i ListVaultProgressSinceRow
+		if err := rows.Scan(
+			&i.Region,
+			&i.Realm,
+			&i.Name,
+			&i.RioScore,
+			&i.KeyCount,
+			&i.KeyLvl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateCharacterScore = `-- name: UpdateCharacterScore :exec
+UPDATE characters SET rio_score = $1
+WHERE LOWER(name) = LOWER($2) AND LOWER(realm) = LOWER($3) AND LOWER(region) = LOWER($4)
+`
+
+type UpdateCharacterScoreParams struct {
+	RioScore float64 `json:"rio_score"`
+	Lower    string  `json:"lower"`
+	Lower_2  string  `json:"lower_2"`
+	Lower_3  string  `json:"lower_3"`
+}
+
+func (q *Queries) UpdateCharacterScore(ctx context.Context, arg UpdateCharacterScoreParams) error {
+	_, err := q.db.ExecContext(ctx, updateCharacterScore,
+		arg.RioScore,
+		arg.Lower,
+		arg.Lower_2,
+		arg.Lower_3,
+	)
+	return err
+}
+
+const upsertCharacter = `-- name: UpsertCharacter :one
+INSERT INTO characters(region, realm, name)
+VALUES ($1, $2, $3)
+ON CONFLICT(region, realm, name) DO UPDATE SET name=excluded.name
+RETURNING id
+`
+
+type UpsertCharacterParams struct {
+	Region string `json:"region"`
+	Realm  string `json:"realm"`
+	Name   string `json:"name"`
+}
+
+func (q *Queries) UpsertCharacter(ctx context.Context, arg UpsertCharacterParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, upsertCharacter, arg.Region, arg.Realm, arg.Name)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
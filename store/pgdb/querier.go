@@ -0,0 +1,53 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package pgdb
+
+import (
+	"context"
+)
+
+type Querier interface {
+	ClaimOutboxMessage(ctx context.Context, arg ClaimOutboxMessageParams) error
+	CountKeysByCharacterSince(ctx context.Context, completedAt string) ([]CountKeysByCharacterSinceRow, error)
+	CountStoredRows(ctx context.Context) (CountStoredRowsRow, error)
+	DeleteCharacter(ctx context.Context, id int64) error
+	DeleteCompletedKey(ctx context.Context, arg DeleteCompletedKeyParams) error
+	DeleteCompletedKeysByCharacter(ctx context.Context, characterID int64) error
+	DeleteOrphanedWarcraftLogsLinks(ctx context.Context, keyID int64) error
+	DeleteWarcraftLogsLinksByCharacter(ctx context.Context, id int64) error
+	DeleteWarcraftLogsLinksForKey(ctx context.Context, keyID int64) error
+	EnqueueOutboxMessage(ctx context.Context, arg EnqueueOutboxMessageParams) (int64, error)
+	GetCharacter(ctx context.Context, arg GetCharacterParams) (Character, error)
+	GetCharacterID(ctx context.Context, arg GetCharacterIDParams) (int64, error)
+	GetCompletedKey(ctx context.Context, arg GetCompletedKeyParams) (GetCompletedKeyRow, error)
+	GetElvUIVersion(ctx context.Context) (GetElvUIVersionRow, error)
+	InsertCompletedKey(ctx context.Context, arg InsertCompletedKeyParams) error
+	// The unique constraint treats NULL fight and pull IDs as distinct, so
+	// duplicates of links without them are skipped explicitly.
+	InsertWarcraftLogsLink(ctx context.Context, arg InsertWarcraftLogsLinkParams) error
+	ListAllKeysWithCharacters(ctx context.Context) ([]ListAllKeysWithCharactersRow, error)
+	ListCharacters(ctx context.Context) ([]ListCharactersRow, error)
+	ListDueOutboxMessages(ctx context.Context, arg ListDueOutboxMessagesParams) ([]Outbox, error)
+	ListFailedOutboxMessages(ctx context.Context, limit int32) ([]Outbox, error)
+	ListKeysByCharacterSince(ctx context.Context, arg ListKeysByCharacterSinceParams) ([]ListKeysByCharacterSinceRow, error)
+	ListKeysSince(ctx context.Context, completedAt string) ([]ListKeysSinceRow, error)
+	ListUnlinkedKeysSince(ctx context.Context, completedAt string) ([]ListUnlinkedKeysSinceRow, error)
+	ListVaultProgressSince(ctx context.Context, completedAt string) ([]ListVaultProgressSinceRow, error)
+	ListWarcraftLogsLinksForKey(ctx context.Context, keyID int64) ([]ListWarcraftLogsLinksForKeyRow, error)
+	ListWeeklyHistory(ctx context.Context, arg ListWeeklyHistoryParams) ([]ListWeeklyHistoryRow, error)
+	MarkOutboxMessageFailed(ctx context.Context, arg MarkOutboxMessageFailedParams) error
+	MarkOutboxMessageSent(ctx context.Context, arg MarkOutboxMessageSentParams) error
+	// Links the target key already has stay where they are. NULL fight and pull
+	// IDs compare equal here, as in InsertWarcraftLogsLink.
+	MoveWarcraftLogsLinks(ctx context.Context, arg MoveWarcraftLogsLinksParams) error
+	PruneSentOutboxMessages(ctx context.Context, sentAt string) (int64, error)
+	RequeueOutboxMessage(ctx context.Context, arg RequeueOutboxMessageParams) (int64, error)
+	UpdateCharacterScore(ctx context.Context, arg UpdateCharacterScoreParams) error
+	UpsertCharacter(ctx context.Context, arg UpsertCharacterParams) (int64, error)
+	UpsertElvUIVersion(ctx context.Context, arg UpsertElvUIVersionParams) error
+	UpsertWeeklyHistory(ctx context.Context, arg UpsertWeeklyHistoryParams) error
+}
+
+var _ Querier = (*Queries)(nil)
@@ -0,0 +1,125 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: warcraftlogs.sql
+
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+const deleteWarcraftLogsLinksForKey = `-- name: DeleteWarcraftLogsLinksForKey :exec
+DELETE FROM warcraftlogs_links WHERE key_id = $1
+`
+
+func (q *Queries) DeleteWarcraftLogsLinksForKey(ctx context.Context, keyID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWarcraftLogsLinksForKey, keyID)
+	return err
+}
+
+const insertWarcraftLogsLink = `-- name: InsertWarcraftLogsLink :exec
+INSERT INTO warcraftlogs_links(
+  key_id, report_code, fight_id, pull_id, url
+)
+SELECT $1::BIGINT, $2::TEXT,
+  $3::BIGINT, $4::BIGINT, $5::TEXT
+WHERE NOT EXISTS (
+  SELECT 1 FROM warcraftlogs_links
+  WHERE key_id = $1::BIGINT AND report_code = $2::TEXT
+    AND fight_id IS NOT DISTINCT FROM $3::BIGINT
+    AND pull_id IS NOT DISTINCT FROM $4::BIGINT
+)
+ON CONFLICT DO NOTHING
+`
+
+type InsertWarcraftLogsLinkParams struct {
+	KeyID      int64          `json:"key_id"`
+	ReportCode string         `json:"report_code"`
+	FightID    sql.NullInt64  `json:"fight_id"`
+	PullID     sql.NullInt64  `json:"pull_id"`
+	Url        sql.NullString `json:"url"`
+}
+
+// The unique constraint treats NULL fight and pull IDs as distinct, so
+// duplicates of links without them are skipped explicitly.
+func (q *Queries) InsertWarcraftLogsLink(ctx context.Context, arg InsertWarcraftLogsLinkParams) error {
+	_, err := q.db.ExecContext(ctx, insertWarcraftLogsLink,
+		arg.KeyID,
+		arg.ReportCode,
+		arg.FightID,
+		arg.PullID,
+		arg.Url,
+	)
+	return err
+}
+
+const listWarcraftLogsLinksForKey = `-- name: ListWarcraftLogsLinksForKey :many
+SELECT key_id, report_code, fight_id, pull_id, url, inserted_at
+FROM warcraftlogs_links
+WHERE key_id = $1
+ORDER BY inserted_at DESC
+`
+
+type ListWarcraftLogsLinksForKeyRow struct {
+	KeyID      int64          `json:"key_id"`
+	ReportCode string         `json:"report_code"`
+	FightID    sql.NullInt64  `json:"fight_id"`
+	PullID     sql.NullInt64  `json:"pull_id"`
+	Url        sql.NullString `json:"url"`
+	InsertedAt string         `json:"inserted_at"`
+}
+
+func (q *Queries) ListWarcraftLogsLinksForKey(ctx context.Context, keyID int64) ([]ListWarcraftLogsLinksForKeyRow, error) {
+	rows, err := q.db.QueryContext(ctx, listWarcraftLogsLinksForKey, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListWarcraftLogsLinksForKeyRow
+	for rows.Next() {
+		var i ListWarcraftLogsLinksForKeyRow
+		if err := rows.Scan(
+			&i.KeyID,
+			&i.ReportCode,
+			&i.FightID,
+			&i.PullID,
+			&i.Url,
+			&i.InsertedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveWarcraftLogsLinks = `-- name: MoveWarcraftLogsLinks :exec
+UPDATE warcraftlogs_links l SET key_id = $1
+WHERE l.key_id = $2
+  AND NOT EXISTS (
+    SELECT 1 FROM warcraftlogs_links o
+    WHERE o.key_id = $1 AND o.report_code = l.report_code
+      AND o.fight_id IS NOT DISTINCT FROM l.fight_id
+      AND o.pull_id IS NOT DISTINCT FROM l.pull_id
+  )
+`
+
+type MoveWarcraftLogsLinksParams struct {
+	KeyID   int64 `json:"key_id"`
+	KeyID_2 int64 `json:"key_id_2"`
+}
+
+// Links the target key already has stay where they are. NULL fight and pull
+// IDs compare equal here, as in InsertWarcraftLogsLink.
+func (q *Queries) MoveWarcraftLogsLinks(ctx context.Context, arg MoveWarcraftLogsLinksParams) error {
+	_, err := q.db.ExecContext(ctx, moveWarcraftLogsLinks, arg.KeyID, arg.KeyID_2)
+	return err
+}
@@ -0,0 +1,1179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/reconcile"
+	"github.com/tnicklin/celestial_orrey/store/db"
+	"github.com/tnicklin/celestial_orrey/store/pgdb"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+var _ Store = (*PostgresStore)(nil)
+
+// PostgresStore is a Store backed by a PostgreSQL database. Postgres is
+// durable on its own, so there is no snapshot file: the disk and archive
+// methods only maintain weekly history, and backups are left to the
+// database server.
+type PostgresStore struct {
+	mu     sync.RWMutex
+	db     *sql.DB
+	dsn    string
+	conns  int
+	logger logger.Logger
+	clock  clock.Clock
+}
+
+type PostgresParams struct {
+	Config PostgresConfig
+	Logger logger.Logger
+	// Clock dates weekly history. Nil uses the system clock.
+	Clock clock.Clock
+}
+
+func NewPostgresStore(p PostgresParams) *PostgresStore {
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
+
+	return &PostgresStore{
+		dsn:    p.Config.DSN.Reveal(),
+		conns:  p.Config.MaxOpenConns,
+		logger: p.Logger,
+		clock:  clk,
+	}
+}
+
+func (s *PostgresStore) Open(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db != nil {
+		return nil
+	}
+	if s.dsn == "" {
+		return errors.New("postgres: dsn is required")
+	}
+
+	database, err := sql.Open("postgres", s.dsn)
+	if err != nil {
+		return err
+	}
+	if s.conns > 0 {
+		database.SetMaxOpenConns(s.conns)
+	}
+
+	if err = database.PingContext(ctx); err != nil {
+		_ = database.Close()
+		return err
+	}
+
+	s.db = database
+	return s.applyMigrations(ctx)
+}
+
+func (s *PostgresStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// Shutdown closes the database; every write is already durable.
+func (s *PostgresStore) Shutdown(ctx context.Context) error {
+	return s.Close()
+}
+
+// RestoreSnapshot does nothing; Postgres keeps its data itself, so there is
+// no snapshot to load.
+func (s *PostgresStore) RestoreSnapshot(ctx context.Context) (RestoreResult, error) {
+	return RestoreResult{}, nil
+}
+
+// RestoreFromDisk is not supported; the data already lives in Postgres.
+func (s *PostgresStore) RestoreFromDisk(ctx context.Context, path string) error {
+	return fmt.Errorf("postgres: restore from disk: %w", errors.ErrUnsupported)
+}
+
+// FlushToDisk is not supported; writes are durable once committed.
+func (s *PostgresStore) FlushToDisk(ctx context.Context, path string) error {
+	return fmt.Errorf("postgres: flush to disk: %w", errors.ErrUnsupported)
+}
+
+// ArchiveWeek records weekly history for the week that just finished. No
+// archive file is written.
+func (s *PostgresStore) ArchiveWeek(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	n, err := s.recordHistory(ctx, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("record weekly history: %w", err)
+	}
+	if s.logger != nil {
+		s.logger.InfoW("archived week", "history_rows", n)
+	}
+	return nil
+}
+
+// IndexHistory records a summary of every completed week before now that is
+// still in the database. There are no archive files to backfill from.
+func (s *PostgresStore) IndexHistory(ctx context.Context, now time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return 0, errors.New("store is not open")
+	}
+	return s.recordHistory(ctx, now)
+}
+
+// Vacuum reclaims dead rows and refreshes planner statistics.
+func (s *PostgresStore) Vacuum(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+	_, err := s.db.ExecContext(ctx, "VACUUM ANALYZE")
+	return err
+}
+
+// ListBackups returns nothing; Postgres backups are managed by the server.
+func (s *PostgresStore) ListBackups(ctx context.Context) ([]BackupInfo, error) {
+	return nil, nil
+}
+
+// VerifyBackups returns nothing; Postgres backups are managed by the server.
+func (s *PostgresStore) VerifyBackups(ctx context.Context) ([]BackupInfo, error) {
+	return nil, nil
+}
+
+func (s *PostgresStore) UpsertCompletedKey(ctx context.Context, key models.CompletedKey) error {
+	_, err := s.UpsertCompletedKeys(ctx, []models.CompletedKey{key})
+	return err
+}
+
+// UpsertCompletedKeys writes keys in a single transaction and returns what
+// happened to each, in order.
+func (s *PostgresStore) UpsertCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]UpsertStatus, error) {
+	return s.writeKeys(ctx, keys, false)
+}
+
+// ReconcileCompletedKeys writes keys like UpsertCompletedKeys, resolving each
+// against stored keys from other sources in the same transaction, as the
+// SQLite store does.
+func (s *PostgresStore) ReconcileCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]UpsertStatus, error) {
+	return s.writeKeys(ctx, keys, true)
+}
+
+func (s *PostgresStore) writeKeys(ctx context.Context, keys []models.CompletedKey, reconciling bool) ([]UpsertStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := pgdb.New(tx)
+	characterIDs := make(map[pgdb.UpsertCharacterParams]int64)
+	statuses := make([]UpsertStatus, len(keys))
+
+	for i, key := range keys {
+		character := pgdb.UpsertCharacterParams{
+			Region: strings.ToLower(key.Region),
+			Realm:  strings.ToLower(key.Realm),
+			Name:   strings.ToLower(key.Character),
+		}
+		characterID, ok := characterIDs[character]
+		if !ok {
+			characterID, err = queries.UpsertCharacter(ctx, character)
+			if err != nil {
+				_ = tx.Rollback()
+				return nil, err
+			}
+			characterIDs[character] = characterID
+		}
+
+		var (
+			match models.CompletedKey
+			found bool
+		)
+		if reconciling {
+			match, found, err = s.reconcileMatch(ctx, queries, key)
+			if err != nil {
+				_ = tx.Rollback()
+				return nil, err
+			}
+		}
+
+		key.KeyID = key.EffectiveID()
+		switch {
+		case !found:
+			statuses[i], err = s.writeKey(ctx, queries, characterID, key)
+		case !reconcile.Wins(key, match):
+			// The stored copy of the run is at least as trusted.
+		default:
+			err = s.replaceKey(ctx, queries, characterID, match.KeyID, key)
+			statuses[i] = UpsertReplaced
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// ReplaceCompletedKey swaps the key stored under oldKeyID for key's character
+// with key, moving any WarcraftLogs links over to the replacement.
+func (s *PostgresStore) ReplaceCompletedKey(ctx context.Context, oldKeyID int64, key models.CompletedKey) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	queries := pgdb.New(tx)
+	characterID, err := queries.UpsertCharacter(ctx, pgdb.UpsertCharacterParams{
+		Region: strings.ToLower(key.Region),
+		Realm:  strings.ToLower(key.Realm),
+		Name:   strings.ToLower(key.Character),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	key.KeyID = key.EffectiveID()
+	if err := s.replaceKey(ctx, queries, characterID, oldKeyID, key); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reconcileMatch returns the stored key from another source that describes
+// the same run as key, logging any conflicts between the two.
+func (s *PostgresStore) reconcileMatch(ctx context.Context, queries *pgdb.Queries, key models.CompletedKey) (models.CompletedKey, bool, error) {
+	since, ok := reconcile.Since(key)
+	if !ok {
+		return models.CompletedKey{}, false, nil
+	}
+
+	rows, err := queries.ListKeysByCharacterSince(ctx, pgdb.ListKeysByCharacterSinceParams{
+		Lower:       key.Character,
+		Lower_2:     key.Realm,
+		Lower_3:     key.Region,
+		CompletedAt: formatCutoff(since),
+	})
+	if err != nil {
+		return models.CompletedKey{}, false, err
+	}
+
+	candidates := make([]models.CompletedKey, 0, len(rows))
+	for _, row := range rows {
+		candidates = append(candidates, completedKey(pgdb.ListKeysSinceRow(row)))
+	}
+
+	match, found := reconcile.Match(key, candidates)
+	if found {
+		reconcile.LogConflicts(s.logger, key, match)
+	}
+	return match, found, nil
+}
+
+// writeKey inserts or refreshes key, whose KeyID is set, for characterID.
+func (s *PostgresStore) writeKey(ctx context.Context, queries *pgdb.Queries, characterID int64, key models.CompletedKey) (UpsertStatus, error) {
+	existing, err := queries.GetCompletedKey(ctx, pgdb.GetCompletedKeyParams{
+		KeyID:       key.KeyID,
+		CharacterID: characterID,
+	})
+	inserted := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !inserted {
+		return UpsertUnchanged, err
+	}
+	if !inserted && sameKey(pgStoredKey(existing), key) {
+		return UpsertUnchanged, nil
+	}
+
+	if err := s.insertKey(ctx, queries, characterID, key); err != nil {
+		return UpsertUnchanged, err
+	}
+	if inserted {
+		return UpsertInserted, nil
+	}
+	return UpsertUpdated, nil
+}
+
+// replaceKey stores key, whose KeyID is set, in place of oldKeyID for
+// characterID, moving any WarcraftLogs links over to it.
+func (s *PostgresStore) replaceKey(ctx context.Context, queries *pgdb.Queries, characterID, oldKeyID int64, key models.CompletedKey) error {
+	if err := s.insertKey(ctx, queries, characterID, key); err != nil {
+		return err
+	}
+	if oldKeyID == key.KeyID {
+		return nil
+	}
+
+	if err := queries.MoveWarcraftLogsLinks(ctx, pgdb.MoveWarcraftLogsLinksParams{
+		KeyID:   key.KeyID,
+		KeyID_2: oldKeyID,
+	}); err != nil {
+		return err
+	}
+
+	// Links the replacement already had are left behind by the move.
+	if err := queries.DeleteWarcraftLogsLinksForKey(ctx, oldKeyID); err != nil {
+		return err
+	}
+
+	return queries.DeleteCompletedKey(ctx, pgdb.DeleteCompletedKeyParams{
+		KeyID:       oldKeyID,
+		CharacterID: characterID,
+	})
+}
+
+func (s *PostgresStore) insertKey(ctx context.Context, queries *pgdb.Queries, characterID int64, key models.CompletedKey) error {
+	return queries.InsertCompletedKey(ctx, pgdb.InsertCompletedKeyParams{
+		KeyID:       key.KeyID,
+		CharacterID: characterID,
+		Dungeon:     key.Dungeon,
+		KeyLvl:      int64(key.KeyLevel),
+		RunTimeMs:   key.RunTimeMS,
+		ParTimeMs:   key.ParTimeMS,
+		CompletedAt: key.CompletedAt,
+		Source:      key.Source,
+	})
+}
+
+func (s *PostgresStore) UpsertWarcraftLogsLink(ctx context.Context, link WarcraftLogsLink) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	var fightID sql.NullInt64
+	if link.FightID != nil {
+		fightID = sql.NullInt64{Int64: *link.FightID, Valid: true}
+	}
+	var pullID sql.NullInt64
+	if link.PullID != nil {
+		pullID = sql.NullInt64{Int64: *link.PullID, Valid: true}
+	}
+	var url sql.NullString
+	if link.URL != "" {
+		url = sql.NullString{String: link.URL, Valid: true}
+	}
+
+	return pgdb.New(s.db).InsertWarcraftLogsLink(ctx, pgdb.InsertWarcraftLogsLinkParams{
+		KeyID:      link.KeyID,
+		ReportCode: link.ReportCode,
+		FightID:    fightID,
+		PullID:     pullID,
+		Url:        url,
+	})
+}
+
+// AddCharacter starts tracking char with its RaiderIO score. It reports
+// false, leaving the stored character untouched, if it is already tracked.
+func (s *PostgresStore) AddCharacter(ctx context.Context, char models.Character) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	queries := pgdb.New(tx)
+	_, err = queries.GetCharacter(ctx, pgdb.GetCharacterParams{
+		Lower:   char.Name,
+		Lower_2: char.Realm,
+		Lower_3: char.Region,
+	})
+	if err == nil {
+		return false, tx.Rollback()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	name, realm, region := strings.ToLower(char.Name), strings.ToLower(char.Realm), strings.ToLower(char.Region)
+	if _, err := queries.UpsertCharacter(ctx, pgdb.UpsertCharacterParams{
+		Region: region,
+		Realm:  realm,
+		Name:   name,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := queries.UpdateCharacterScore(ctx, pgdb.UpdateCharacterScoreParams{
+		RioScore: char.RIOScore,
+		Lower:    name,
+		Lower_2:  realm,
+		Lower_3:  region,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (s *PostgresStore) UpdateCharacterScore(ctx context.Context, name, realm, region string, score float64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	return pgdb.New(s.db).UpdateCharacterScore(ctx, pgdb.UpdateCharacterScoreParams{
+		RioScore: score,
+		Lower:    name,
+		Lower_2:  realm,
+		Lower_3:  region,
+	})
+}
+
+func (s *PostgresStore) DeleteCharacter(ctx context.Context, name, realm, region string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	queries := pgdb.New(tx)
+
+	charID, err := queries.GetCharacterID(ctx, pgdb.GetCharacterIDParams{
+		Lower:   name,
+		Lower_2: realm,
+		Lower_3: region,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("character not found: %s-%s (%s)", name, realm, region)
+	}
+
+	if err := queries.DeleteWarcraftLogsLinksByCharacter(ctx, charID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := queries.DeleteCompletedKeysByCharacter(ctx, charID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := queries.DeleteCharacter(ctx, charID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteCompletedKey removes a single key from a character. WarcraftLogs
+// links for the key are removed once no other character shares it.
+func (s *PostgresStore) DeleteCompletedKey(ctx context.Context, name, realm, region string, keyID int64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	queries := pgdb.New(tx)
+
+	charID, err := queries.GetCharacterID(ctx, pgdb.GetCharacterIDParams{
+		Lower:   name,
+		Lower_2: realm,
+		Lower_3: region,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("character not found: %s-%s (%s)", name, realm, region)
+	}
+
+	if err := queries.DeleteCompletedKey(ctx, pgdb.DeleteCompletedKeyParams{
+		KeyID:       keyID,
+		CharacterID: charID,
+	}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := queries.DeleteOrphanedWarcraftLogsLinks(ctx, keyID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresStore) UpsertElvUIVersion(ctx context.Context, v ElvUIVersion) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	return pgdb.New(s.db).UpsertElvUIVersion(ctx, pgdb.UpsertElvUIVersionParams{
+		Version:      v.Version,
+		DownloadUrl:  v.DownloadURL,
+		ChangelogUrl: v.ChangelogURL,
+		LastUpdate:   v.LastUpdate,
+	})
+}
+
+func (s *PostgresStore) GetElvUIVersion(ctx context.Context) (*ElvUIVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	row, err := pgdb.New(s.db).GetElvUIVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ElvUIVersion{
+		Version:      row.Version,
+		DownloadURL:  row.DownloadUrl,
+		ChangelogURL: row.ChangelogUrl,
+		LastUpdate:   row.LastUpdate,
+		CheckedAt:    row.CheckedAt,
+	}, nil
+}
+
+func (s *PostgresStore) ListCharacters(ctx context.Context) ([]models.Character, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListCharacters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.Character, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, models.Character{
+			Region:   row.Region,
+			Realm:    row.Realm,
+			Name:     row.Name,
+			RIOScore: row.RioScore,
+		})
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) GetCharacter(ctx context.Context, name, realm, region string) (*models.Character, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	row, err := pgdb.New(s.db).GetCharacter(ctx, pgdb.GetCharacterParams{
+		Lower:   name,
+		Lower_2: realm,
+		Lower_3: region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Character{
+		Region:   row.Region,
+		Realm:    row.Realm,
+		Name:     row.Name,
+		RIOScore: row.RioScore,
+	}, nil
+}
+
+func (s *PostgresStore) CountKeysByCharacterSince(ctx context.Context, cutoff time.Time) ([]CountRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).CountKeysByCharacterSince(ctx, formatCutoff(cutoff))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]CountRow, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, CountRow{
+			Region:   row.Region,
+			Realm:    row.Realm,
+			Name:     row.Name,
+			KeyCount: row.KeyCount,
+		})
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) ListKeysByCharacterSince(ctx context.Context, name, realm, region string, cutoff time.Time) ([]models.CompletedKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListKeysByCharacterSince(ctx, pgdb.ListKeysByCharacterSinceParams{
+		Lower:       name,
+		Lower_2:     realm,
+		Lower_3:     region,
+		CompletedAt: formatCutoff(cutoff),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.CompletedKey, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, completedKey(pgdb.ListKeysSinceRow(row)))
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) ListKeysSince(ctx context.Context, cutoff time.Time) ([]models.CompletedKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListKeysSince(ctx, formatCutoff(cutoff))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.CompletedKey, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, completedKey(row))
+	}
+	return out, nil
+}
+
+// ListVaultProgressSince returns every character with its key count and top
+// eight key levels since cutoff, in a single query.
+func (s *PostgresStore) ListVaultProgressSince(ctx context.Context, cutoff time.Time) ([]VaultRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListVaultProgressSince(ctx, formatCutoff(cutoff))
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows arrive grouped by character, one per ranked key, or a single
+	// row with a zero key count for characters without keys.
+	var out []VaultRow
+	for _, row := range rows {
+		n := len(out)
+		if n == 0 || out[n-1].Character.Region != row.Region ||
+			out[n-1].Character.Realm != row.Realm || out[n-1].Character.Name != row.Name {
+			out = append(out, VaultRow{
+				Character: models.Character{
+					Region:   row.Region,
+					Realm:    row.Realm,
+					Name:     row.Name,
+					RIOScore: row.RioScore,
+				},
+				KeyCount: row.KeyCount,
+			})
+			n++
+		}
+		if row.KeyCount > 0 {
+			out[n-1].TopLevels = append(out[n-1].TopLevels, int(row.KeyLvl))
+		}
+	}
+	return out, nil
+}
+
+// ListWeeklyHistory returns up to weeks recorded summaries for a character,
+// newest first.
+func (s *PostgresStore) ListWeeklyHistory(ctx context.Context, name, realm, region string, weeks int) ([]WeekSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListWeeklyHistory(ctx, pgdb.ListWeeklyHistoryParams{
+		Lower:   name,
+		Lower_2: realm,
+		Lower_3: region,
+		Limit:   int32(weeks),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]WeekSummary, 0, len(rows))
+	for _, row := range rows {
+		weekStart, err := timeutil.ParseRFC3339(row.WeekStart)
+		if err != nil {
+			continue
+		}
+		out = append(out, WeekSummary{
+			WeekStart: weekStart,
+			Character: models.Character{
+				Region:   row.Region,
+				Realm:    row.Realm,
+				Name:     row.Name,
+				RIOScore: row.RioScore,
+			},
+			KeyCount:     row.KeyCount,
+			BestKeyLevel: int(row.BestKeyLvl),
+			BestDungeon:  row.BestDungeon,
+			VaultLevels:  [3]int{int(row.Vault1Lvl), int(row.Vault2Lvl), int(row.Vault3Lvl)},
+			Source:       row.Source,
+		})
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) ListUnlinkedKeysSince(ctx context.Context, cutoff time.Time) ([]models.CompletedKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListUnlinkedKeysSince(ctx, formatCutoff(cutoff))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.CompletedKey, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, completedKey(pgdb.ListKeysSinceRow(row)))
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) ListWarcraftLogsLinksForKey(ctx context.Context, keyID int64) ([]WarcraftLogsLink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListWarcraftLogsLinksForKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]WarcraftLogsLink, 0, len(rows))
+	for _, row := range rows {
+		var fightID *int64
+		if row.FightID.Valid {
+			value := row.FightID.Int64
+			fightID = &value
+		}
+		var pullID *int64
+		if row.PullID.Valid {
+			value := row.PullID.Int64
+			pullID = &value
+		}
+		out = append(out, WarcraftLogsLink{
+			KeyID:      row.KeyID,
+			ReportCode: row.ReportCode,
+			FightID:    fightID,
+			PullID:     pullID,
+			URL:        row.Url.String,
+			InsertedAt: row.InsertedAt,
+		})
+	}
+	return out, nil
+}
+
+// recordHistory summarizes the completed weeks held in the database.
+// Callers must hold s.mu.
+func (s *PostgresStore) recordHistory(ctx context.Context, now time.Time) (int, error) {
+	queries := pgdb.New(s.db)
+	rows, err := queries.ListAllKeysWithCharacters(ctx)
+	if err != nil {
+		return 0, err
+	}
+	chars, err := queries.ListCharacters(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// The generated row types match the SQLite ones field for field.
+	keys := make([]db.ListAllKeysWithCharactersRow, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, db.ListAllKeysWithCharactersRow(row))
+	}
+	charRows := make([]db.ListCharactersRow, 0, len(chars))
+	for _, c := range chars {
+		charRows = append(charRows, db.ListCharactersRow(c))
+	}
+
+	summaries := summarizeWeeks(keys, characterScores(charRows), now)
+	if len(summaries) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	txQueries := pgdb.New(tx)
+	for _, summary := range summaries {
+		p := pgdb.UpsertWeeklyHistoryParams(historyParams(summary, HistorySourceLive))
+		if err := txQueries.UpsertWeeklyHistory(ctx, p); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(summaries), nil
+}
+
+func (s *PostgresStore) applyMigrations(ctx context.Context) error {
+	migrationsPath, err := resolveMigrationsPath(filepath.Join("schema", "postgres", "migrations"))
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		content, err := os.ReadFile(filepath.Join(migrationsPath, name))
+		if err != nil {
+			return err
+		}
+		sqlText := strings.TrimSpace(string(content))
+		if sqlText == "" {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, sqlText); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// pgStoredKey maps the details GetCompletedKey returns onto a key.
+func pgStoredKey(row pgdb.GetCompletedKeyRow) models.CompletedKey {
+	return models.CompletedKey{
+		Dungeon:     row.Dungeon,
+		KeyLevel:    int(row.KeyLvl),
+		RunTimeMS:   row.RunTimeMs,
+		ParTimeMS:   row.ParTimeMs,
+		CompletedAt: row.CompletedAt,
+		Source:      row.Source,
+	}
+}
+
+func completedKey(row pgdb.ListKeysSinceRow) models.CompletedKey {
+	return models.CompletedKey{
+		KeyID:       row.KeyID,
+		Region:      row.Region,
+		Realm:       row.Realm,
+		Character:   row.Character,
+		Dungeon:     row.Dungeon,
+		KeyLevel:    int(row.KeyLvl),
+		RunTimeMS:   row.RunTimeMs,
+		ParTimeMS:   row.ParTimeMs,
+		CompletedAt: row.CompletedAt,
+		Source:      row.Source,
+	}
+}
+
+func (s *PostgresStore) EnqueueMessage(ctx context.Context, msg OutboxMessage) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	n, err := s.enqueueMessage(ctx, pgdb.New(s.db), msg)
+	return n > 0, err
+}
+
+func (s *PostgresStore) RecordElvUIVersion(ctx context.Context, v ElvUIVersion, announce OutboxMessage) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	queries := pgdb.New(tx)
+	current, err := queries.GetElvUIVersion(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return false, err
+	}
+	changed := err != nil || current.Version != v.Version
+
+	if err := queries.UpsertElvUIVersion(ctx, pgdb.UpsertElvUIVersionParams{
+		Version:      v.Version,
+		DownloadUrl:  v.DownloadURL,
+		ChangelogUrl: v.ChangelogURL,
+		LastUpdate:   v.LastUpdate,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	if changed && announce.DedupKey != "" {
+		if _, err := s.enqueueMessage(ctx, queries, announce); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+func (s *PostgresStore) DueMessages(ctx context.Context, now time.Time, limit int) ([]OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListDueOutboxMessages(ctx, pgdb.ListDueOutboxMessagesParams{
+		NextAttemptAt: formatOutboxTime(now),
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pgOutboxMessages(rows), nil
+}
+
+func (s *PostgresStore) ClaimMessage(ctx context.Context, id int64, at time.Time) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	return pgdb.New(s.db).ClaimOutboxMessage(ctx, pgdb.ClaimOutboxMessageParams{
+		ClaimedAt: formatOutboxTime(at),
+		ID:        id,
+	})
+}
+
+func (s *PostgresStore) MarkMessageSent(ctx context.Context, id int64, at time.Time) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	return pgdb.New(s.db).MarkOutboxMessageSent(ctx, pgdb.MarkOutboxMessageSentParams{
+		SentAt: formatOutboxTime(at),
+		ID:     id,
+	})
+}
+
+func (s *PostgresStore) MarkMessageFailed(ctx context.Context, id int64, cause string, retryAt time.Time, giveUp bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	var failedAt string
+	if giveUp {
+		failedAt = formatOutboxTime(s.clock.Now())
+	}
+	return pgdb.New(s.db).MarkOutboxMessageFailed(ctx, pgdb.MarkOutboxMessageFailedParams{
+		LastError:     cause,
+		NextAttemptAt: formatOutboxTime(retryAt),
+		FailedAt:      failedAt,
+		ID:            id,
+	})
+}
+
+func (s *PostgresStore) FailedMessages(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	rows, err := pgdb.New(s.db).ListFailedOutboxMessages(ctx, int32(limit))
+	if err != nil {
+		return nil, err
+	}
+	return pgOutboxMessages(rows), nil
+}
+
+func (s *PostgresStore) RequeueMessage(ctx context.Context, id int64, at time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	n, err := pgdb.New(s.db).RequeueOutboxMessage(ctx, pgdb.RequeueOutboxMessageParams{
+		NextAttemptAt: formatOutboxTime(at),
+		ID:            id,
+	})
+	return n > 0, err
+}
+
+func (s *PostgresStore) PruneSentMessages(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return 0, errors.New("store is not open")
+	}
+
+	return pgdb.New(s.db).PruneSentOutboxMessages(ctx, formatOutboxTime(cutoff))
+}
+
+func (s *PostgresStore) enqueueMessage(ctx context.Context, queries *pgdb.Queries, msg OutboxMessage) (int64, error) {
+	if msg.DedupKey == "" {
+		return 0, errors.New("outbox message needs a dedup key")
+	}
+	next := msg.NextAttemptAt
+	if next.IsZero() {
+		next = s.clock.Now()
+	}
+	return queries.EnqueueOutboxMessage(ctx, pgdb.EnqueueOutboxMessageParams{
+		DedupKey:      msg.DedupKey,
+		ChannelID:     msg.ChannelID,
+		Content:       msg.Content,
+		Embeds:        msg.Embeds,
+		NextAttemptAt: formatOutboxTime(next),
+	})
+}
+
+func pgOutboxMessages(rows []pgdb.Outbox) []OutboxMessage {
+	out := make([]OutboxMessage, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, OutboxMessage{
+			ID:            row.ID,
+			DedupKey:      row.DedupKey,
+			ChannelID:     row.ChannelID,
+			Content:       row.Content,
+			Embeds:        row.Embeds,
+			Attempts:      int(row.Attempts),
+			LastError:     row.LastError,
+			NextAttemptAt: parseOutboxTime(row.NextAttemptAt),
+			ClaimedAt:     parseOutboxTime(row.ClaimedAt),
+			SentAt:        parseOutboxTime(row.SentAt),
+			FailedAt:      parseOutboxTime(row.FailedAt),
+			CreatedAt:     parseOutboxTime(row.CreatedAt),
+		})
+	}
+	return out
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tnicklin/celestial_orrey/store/db"
+)
+
+// ErrEmptyOverwrite is returned by FlushToDisk when it would replace a
+// snapshot holding data with an empty database.
+var ErrEmptyOverwrite = errors.New("refusing to overwrite non-empty snapshot with empty database")
+
+// RestoreResult describes where RestoreSnapshot loaded the database from.
+type RestoreResult struct {
+	// RestoredFrom is the file the database was loaded from, or empty if
+	// the store started empty.
+	RestoredFrom string
+	// Quarantined is where an unusable snapshot was moved, or empty.
+	Quarantined string
+	// Reason explains why the snapshot was not used, or is empty if it was.
+	Reason string
+}
+
+// FellBack reports whether the snapshot was passed over for an archive or an
+// empty start.
+func (r RestoreResult) FellBack() bool {
+	return r.Reason != ""
+}
+
+// RestoreSnapshot loads the snapshot into memory after checking it. A
+// snapshot that fails its integrity check is moved aside as .corrupt-<time>,
+// and one that is empty while an archive still holds data as .empty-<time>;
+// the newest healthy, non-empty archive in the backup directory is then
+// restored instead. A snapshot emptied by purging characters holds their
+// purge records and is kept, so purged characters stay purged. A missing
+// snapshot also falls back to the newest archive. When there is no usable
+// local archive and a backup sink is configured, the newest remote snapshot
+// or archive is downloaded.
+//
+// Falling back from a corrupt snapshot restores characters purged since the
+// archive was written, since the purge records are lost with the snapshot.
+func (s *SQLiteStore) RestoreSnapshot(ctx context.Context) (RestoreResult, error) {
+	s.mu.RLock()
+	path := s.snapshotPath
+	s.mu.RUnlock()
+
+	var (
+		result   RestoreResult
+		rows     int64
+		checkErr error
+		exists   bool
+	)
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			exists = true
+			rows, checkErr = inspectDatabase(ctx, path)
+		}
+	}
+
+	if exists && checkErr == nil && (rows > 0 || countPurges(ctx, path) > 0) {
+		result.RestoredFrom = path
+		return result, s.RestoreFromDisk(ctx, path)
+	}
+
+	archive, err := s.newestHealthyArchive(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// Only a missing or unreadable snapshot is worth a download.
+	var remote string
+	if archive == "" && (!exists || checkErr != nil) {
+		remote, err = s.newestSinkBackup(ctx)
+		if err != nil && s.logger != nil {
+			s.logger.WarnW("list remote backups", "error", err)
+		}
+	}
+
+	switch {
+	case exists && checkErr != nil:
+		result.Reason = fmt.Sprintf("snapshot failed integrity check: %v", checkErr)
+	case exists && archive != "":
+		result.Reason = "snapshot is empty but archives hold data"
+	case exists:
+		// A healthy, empty snapshot with nothing better to fall back to.
+		result.RestoredFrom = path
+		return result, s.RestoreFromDisk(ctx, path)
+	case archive != "" || remote != "":
+		result.Reason = "snapshot is missing"
+	default:
+		return result, nil
+	}
+
+	if exists {
+		suffix := "corrupt"
+		if checkErr == nil {
+			suffix = "empty"
+		}
+		quarantined := fmt.Sprintf("%s.%s-%s", path, suffix, s.clock.Now().Format(_archiveTimestamp))
+		if err := os.Rename(path, quarantined); err != nil {
+			return result, fmt.Errorf("quarantine snapshot: %w", err)
+		}
+		result.Quarantined = quarantined
+	}
+
+	if archive == "" {
+		if remote == "" {
+			return result, nil
+		}
+		if err := s.RestoreFromSink(ctx, remote); err != nil {
+			return result, err
+		}
+		result.RestoredFrom = remote
+		return result, nil
+	}
+
+	err = withArchiveFile(archive, func(dbPath string) error {
+		return s.RestoreFromDisk(ctx, dbPath)
+	})
+	if err != nil {
+		return result, fmt.Errorf("restore archive %s: %w", archive, err)
+	}
+	result.RestoredFrom = archive
+
+	// Write the recovered state back out as the new snapshot.
+	s.scheduleFlush()
+	return result, nil
+}
+
+// newestHealthyArchive returns the newest archive that passes its integrity
+// check and holds data, or an empty path if there is none.
+func (s *SQLiteStore) newestHealthyArchive(ctx context.Context) (string, error) {
+	archives, err := s.listArchives()
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(archives) - 1; i >= 0; i-- {
+		var rows int64
+		err := withArchiveFile(archives[i], func(dbPath string) error {
+			var err error
+			rows, err = inspectDatabase(ctx, dbPath)
+			return err
+		})
+		if err != nil {
+			if s.logger != nil {
+				s.logger.WarnW("skipping unusable archive", "path", archives[i], "error", err)
+			}
+			continue
+		}
+		if rows > 0 {
+			return archives[i], nil
+		}
+	}
+	return "", nil
+}
+
+// guardEmptyOverwrite returns ErrEmptyOverwrite if the in-memory database is
+// empty and the file at path holds data, unless overwriting is allowed.
+// Callers must hold s.mu.
+func (s *SQLiteStore) guardEmptyOverwrite(ctx context.Context, path string) error {
+	if s.allowEmptyOverwrite || s.db == nil {
+		return nil
+	}
+
+	queries := db.New(s.db)
+	counts, err := queries.CountStoredRows(ctx)
+	if err != nil || counts.CharacterCount+counts.KeyCount > 0 {
+		return nil
+	}
+	// Purging every character empties the database on purpose.
+	if purged, err := queries.CountPurgedCharacters(ctx); err != nil || purged > 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	// An unreadable file has already been quarantined or is beyond saving.
+	rows, err := countRows(ctx, path)
+	if err != nil || rows == 0 {
+		return nil
+	}
+	return ErrEmptyOverwrite
+}
+
+// inspectDatabase runs an integrity check against the database at path and
+// returns how many characters and keys it holds.
+func inspectDatabase(ctx context.Context, path string) (int64, error) {
+	if err := integrityCheck(ctx, path); err != nil {
+		return 0, err
+	}
+	return countRows(ctx, path)
+}
+
+func countRows(ctx context.Context, path string) (int64, error) {
+	database, err := sql.Open("sqlite3", sqliteFileDSN(path)+"&mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer database.Close()
+
+	counts, err := db.New(database).CountStoredRows(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return counts.CharacterCount + counts.KeyCount, nil
+}
+
+// countPurges returns how many purge records the database at path holds.
+// Snapshots written before purges were recorded have none.
+func countPurges(ctx context.Context, path string) int64 {
+	database, err := sql.Open("sqlite3", sqliteFileDSN(path)+"&mode=ro")
+	if err != nil {
+		return 0
+	}
+	defer database.Close()
+
+	purged, err := db.New(database).CountPurgedCharacters(ctx)
+	if err != nil {
+		return 0
+	}
+	return purged
+}
+
+// RestoreArchive replaces the database with the snapshot or archive at
+// path after checking its integrity, and schedules a flush so the snapshot
+// follows.
+func (s *SQLiteStore) RestoreArchive(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	err := withArchiveFile(path, func(dbPath string) error {
+		if err := integrityCheck(ctx, dbPath); err != nil {
+			return err
+		}
+		return s.RestoreFromDisk(ctx, dbPath)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.scheduleFlush()
+	return nil
+}
+
+// OpenSnapshot opens an in-memory store loaded from a snapshot or archive
+// file, for offline tools. The store has no snapshot path, so nothing is
+// ever written back to path.
+func OpenSnapshot(ctx context.Context, path string) (*SQLiteStore, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	st := NewSQLiteStore(Params{})
+	if err := st.Open(ctx); err != nil {
+		return nil, err
+	}
+	if err := withArchiveFile(path, func(dbPath string) error {
+		return st.RestoreFromDisk(ctx, dbPath)
+	}); err != nil {
+		_ = st.Close()
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return st, nil
+}
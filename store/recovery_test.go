@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+// writeTestDatabase writes a database file at path holding keys.
+func writeTestDatabase(t *testing.T, path string, keys ...models.CompletedKey) {
+	t.Helper()
+	ctx := context.Background()
+
+	st := NewSQLiteStore(Params{})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open seed store: %v", err)
+	}
+	defer st.Close()
+
+	for _, key := range keys {
+		if err := st.UpsertCompletedKey(ctx, key); err != nil {
+			t.Fatalf("upsert seed key: %v", err)
+		}
+	}
+	if err := st.FlushToDisk(ctx, path); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func testKey(id int64) models.CompletedKey {
+	return models.CompletedKey{
+		KeyID:       id,
+		Character:   "Arthas",
+		Region:      "us",
+		Realm:       "illidan",
+		Dungeon:     "Ara-Kara, City of Echoes",
+		KeyLevel:    10,
+		CompletedAt: "2026-02-04T01:00:00Z",
+		Source:      models.SourceRaiderIO,
+	}
+}
+
+func TestSQLiteStoreRestoreSnapshot(t *testing.T) {
+	tests := []struct {
+		name         string
+		snapshot     func(t *testing.T, path string)
+		archive      bool
+		wantFallback bool
+		wantKeys     int
+		// wantMoved is how a snapshot passed over is renamed, if it is.
+		wantMoved string
+	}{
+		{
+			name: "healthy snapshot",
+			snapshot: func(t *testing.T, path string) {
+				writeTestDatabase(t, path, testKey(1), testKey(2))
+			},
+			archive:  true,
+			wantKeys: 2,
+		},
+		{
+			name: "corrupt snapshot",
+			snapshot: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte(strings.Repeat("garbage", 1000)), 0o644); err != nil {
+					t.Fatalf("write corrupt snapshot: %v", err)
+				}
+			},
+			archive:      true,
+			wantFallback: true,
+			wantKeys:     1,
+			wantMoved:    ".corrupt-",
+		},
+		{
+			name: "empty snapshot",
+			snapshot: func(t *testing.T, path string) {
+				writeTestDatabase(t, path)
+			},
+			archive:      true,
+			wantFallback: true,
+			wantKeys:     1,
+			wantMoved:    ".empty-",
+		},
+		{
+			name: "purged snapshot",
+			snapshot: func(t *testing.T, path string) {
+				ctx := context.Background()
+				st := NewSQLiteStore(Params{})
+				if err := st.Open(ctx); err != nil {
+					t.Fatalf("open seed store: %v", err)
+				}
+				defer st.Close()
+				if err := st.UpsertCompletedKey(ctx, testKey(1)); err != nil {
+					t.Fatalf("upsert seed key: %v", err)
+				}
+				if err := st.DeleteCharacter(ctx, "Arthas", "illidan", "us"); err != nil {
+					t.Fatalf("purge: %v", err)
+				}
+				if err := st.FlushToDisk(ctx, path); err != nil {
+					t.Fatalf("write %s: %v", path, err)
+				}
+			},
+			archive:  true,
+			wantKeys: 0,
+		},
+		{
+			name:         "missing snapshot",
+			snapshot:     func(t *testing.T, path string) {},
+			archive:      true,
+			wantFallback: true,
+			wantKeys:     1,
+		},
+		{
+			name: "corrupt snapshot without archives",
+			snapshot: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+					t.Fatalf("write corrupt snapshot: %v", err)
+				}
+			},
+			wantFallback: true,
+			wantKeys:     0,
+			wantMoved:    ".corrupt-",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "celestial_orrey.db")
+			backupDir := filepath.Join(dir, "backup")
+
+			tt.snapshot(t, path)
+			if tt.archive {
+				writeTestDatabase(t, filepath.Join(backupDir, "celestial_orrey_2026-02-10_070000.db"), testKey(1))
+			}
+
+			st := NewSQLiteStore(Params{Path: path, BackupDir: backupDir})
+			st.SetFlushDebounce(time.Hour)
+			if err := st.Open(ctx); err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer st.Close()
+
+			result, err := st.RestoreSnapshot(ctx)
+			if err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+			if result.FellBack() != tt.wantFallback {
+				t.Fatalf("fallback = %v (%q); want %v", result.FellBack(), result.Reason, tt.wantFallback)
+			}
+
+			keys, err := st.ListKeysSince(ctx, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(keys) != tt.wantKeys {
+				t.Fatalf("expected %d keys, got %d", tt.wantKeys, len(keys))
+			}
+
+			if !strings.Contains(result.Quarantined, tt.wantMoved) || (tt.wantMoved == "") != (result.Quarantined == "") {
+				t.Fatalf("snapshot moved to %q, want a name containing %q", result.Quarantined, tt.wantMoved)
+			}
+			if result.Quarantined != "" {
+				if _, err := os.Stat(result.Quarantined); err != nil {
+					t.Fatalf("quarantined snapshot missing: %v", err)
+				}
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Fatalf("expected bad snapshot to be moved, stat err = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteStoreFlushRefusesEmptyOverwrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "celestial_orrey.db")
+	writeTestDatabase(t, path, testKey(1))
+
+	st := NewSQLiteStore(Params{})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := st.FlushToDisk(ctx, path); !errors.Is(err, ErrEmptyOverwrite) {
+		t.Fatalf("expected ErrEmptyOverwrite, got %v", err)
+	}
+	if rows, err := countRows(ctx, path); err != nil || rows == 0 {
+		t.Fatalf("expected snapshot to keep its data, rows=%d err=%v", rows, err)
+	}
+	st.Close()
+
+	override := NewSQLiteStore(Params{AllowEmptyOverwrite: true})
+	if err := override.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer override.Close()
+	if err := override.FlushToDisk(ctx, path); err != nil {
+		t.Fatalf("flush with override: %v", err)
+	}
+	if rows, err := countRows(ctx, path); err != nil || rows != 0 {
+		t.Fatalf("expected empty snapshot after override, rows=%d err=%v", rows, err)
+	}
+}
+
+func TestSQLiteStoreFlushAllowsPurgedOverwrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "celestial_orrey.db")
+	writeTestDatabase(t, path, testKey(1))
+
+	st := NewSQLiteStore(Params{})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+	if err := st.RestoreFromDisk(ctx, path); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := st.DeleteCharacter(ctx, "Arthas", "illidan", "us"); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if err := st.FlushToDisk(ctx, path); err != nil {
+		t.Fatalf("flush after purging every character: %v", err)
+	}
+	if rows, err := countRows(ctx, path); err != nil || rows != 0 {
+		t.Fatalf("expected empty snapshot, rows=%d err=%v", rows, err)
+	}
+}
+
+func TestOpenSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+
+	src := NewSQLiteStore(Params{})
+	if err := src.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := src.UpsertCompletedKey(ctx, testKey(1)); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if err := src.FlushToDisk(ctx, path); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	_ = src.Close()
+
+	st, err := OpenSnapshot(ctx, path)
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer st.Close()
+
+	keys, err := st.ListKeysSince(ctx, time.Time{})
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("expected 1 key from snapshot, got %d (err %v)", len(keys), err)
+	}
+
+	if _, err := OpenSnapshot(ctx, filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatal("expected error for missing snapshot")
+	}
+}
+
+func TestSQLiteStoreRestoreArchive(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.db")
+	writeTestDatabase(t, archive, testKey(1), testKey(2))
+
+	snapshot := filepath.Join(dir, "snapshot.db")
+	st := NewSQLiteStore(Params{Path: snapshot})
+	if err := st.Open(ctx); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer st.Close()
+	if err := st.UpsertCompletedKey(ctx, testKey(3)); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	if err := st.RestoreArchive(ctx, archive); err != nil {
+		t.Fatalf("restore archive: %v", err)
+	}
+	keys, err := st.ListKeysSince(ctx, time.Time{})
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("expected the archive's 2 keys, got %d (err %v)", len(keys), err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.db")
+	if err := os.WriteFile(corrupt, []byte("not a database"), 0o644); err != nil {
+		t.Fatalf("write corrupt: %v", err)
+	}
+	if err := st.RestoreArchive(ctx, corrupt); err == nil {
+		t.Fatal("expected error for corrupt archive")
+	}
+	if err := st.RestoreArchive(ctx, filepath.Join(dir, "missing.db")); err == nil {
+		t.Fatal("expected error for missing archive")
+	}
+}
@@ -0,0 +1,354 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when a downloaded object does not match
+// the checksum recorded at upload.
+var ErrChecksumMismatch = errors.New("backup checksum mismatch")
+
+const (
+	_s3ChecksumHeader = "X-Amz-Meta-Sha256"
+	_s3Service        = "s3"
+	_s3Algorithm      = "AWS4-HMAC-SHA256"
+	_s3DateFormat     = "20060102T150405Z"
+)
+
+// BackupSink stores copies of backups off the host.
+type BackupSink interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	List(ctx context.Context, prefix string) ([]SinkObject, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// SinkObject describes one object in a BackupSink.
+type SinkObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+var _ BackupSink = (*S3Sink)(nil)
+
+// S3Sink is a BackupSink backed by an S3-compatible bucket, addressed
+// path-style so it also works against MinIO and similar servers. Every
+// object carries a SHA-256 checksum in its metadata that is checked on
+// download.
+type S3Sink struct {
+	endpoint   *url.URL
+	region     string
+	bucket     string
+	prefix     string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+type S3Params struct {
+	Config     S3Config
+	HTTPClient *http.Client
+}
+
+func NewS3Sink(p S3Params) (*S3Sink, error) {
+	cfg := p.Config
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3: endpoint and bucket are required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3: access key and secret are required")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: parse endpoint: %w", err)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 2 * time.Minute}
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Sink{
+		endpoint:   endpoint,
+		region:     region,
+		bucket:     cfg.Bucket,
+		prefix:     prefix,
+		accessKey:  cfg.AccessKeyID,
+		secretKey:  cfg.SecretAccessKey.Reveal(),
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+func (s *S3Sink) Put(ctx context.Context, key string, data []byte) error {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set(_s3ChecksumHeader, checksum)
+
+	resp, err := s.do(req, checksum)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkS3Response(resp, "put "+key)
+}
+
+func (s *S3Sink) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkS3Response(resp, "get "+key); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if want := resp.Header.Get(_s3ChecksumHeader); want != "" {
+		sum := sha256.Sum256(data)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, key)
+		}
+	}
+	return data, nil
+}
+
+func (s *S3Sink) List(ctx context.Context, prefix string) ([]SinkObject, error) {
+	var (
+		out   []SinkObject
+		token string
+	)
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		u := *s.endpoint
+		u.Path = path.Join("/", u.Path, s.bucket) + "/"
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = checkS3Response(resp, "list "+prefix)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range result.Contents {
+			lastModified, _ := time.Parse(time.RFC3339, obj.LastModified)
+			out = append(out, SinkObject{
+				Key:          strings.TrimPrefix(obj.Key, s.prefix),
+				Size:         obj.Size,
+				LastModified: lastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return out, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Sink) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkS3Response(resp, "delete "+key)
+}
+
+func (s *S3Sink) objectURL(key string) string {
+	u := *s.endpoint
+	u.Path = path.Join("/", u.Path, s.bucket, s.prefix+key)
+	return u.String()
+}
+
+func (s *S3Sink) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signS3Request(req, payloadHash, s.accessKey, s.secretKey, s.region, s.now())
+	return s.httpClient.Do(req)
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func checkS3Response(resp *http.Response, op string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s: status %d: %s", op, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request adds AWS Signature Version 4 headers to req. Every header
+// already set on req is signed, along with the host.
+func signS3Request(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(_s3DateFormat)
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + _s3Service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		_s3Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, _s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		_s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalURI percent-encodes each segment of path as SigV4 expects, which
+// escapes more than url.URL.EscapedPath does (e.g. "$").
+func canonicalURI(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), values[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes s as SigV4 expects: everything but unreserved
+// characters, with spaces as %20.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,19 @@
+-- Per-character summary of each completed week, recorded when the week is
+-- archived and backfilled from archive files. Rows are keyed by character
+-- identity rather than characters.id so history outlives a purge.
+CREATE TABLE IF NOT EXISTS weekly_history (
+  week_start    TEXT NOT NULL,
+  region        TEXT NOT NULL,
+  realm         TEXT NOT NULL,
+  name          TEXT NOT NULL,
+  key_count     INTEGER NOT NULL,
+  best_key_lvl  INTEGER NOT NULL,
+  best_dungeon  TEXT NOT NULL,
+  vault_1_lvl   INTEGER NOT NULL,
+  vault_2_lvl   INTEGER NOT NULL,
+  vault_3_lvl   INTEGER NOT NULL,
+  rio_score     REAL NOT NULL DEFAULT 0,
+  source        TEXT NOT NULL,
+  recorded_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  PRIMARY KEY (week_start, region, realm, name)
+);
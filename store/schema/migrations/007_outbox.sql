@@ -0,0 +1,21 @@
+-- Discord posts waiting for delivery. Rows are written in the same
+-- transaction as the change they announce and removed from the queue by
+-- setting sent_at or, after too many failed attempts, failed_at. Times are
+-- fixed-width UTC text so they compare as strings; '' means unset.
+CREATE TABLE IF NOT EXISTS outbox (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  dedup_key       TEXT NOT NULL UNIQUE,
+  channel_id      TEXT NOT NULL,
+  content         TEXT NOT NULL DEFAULT '',
+  embeds          TEXT NOT NULL DEFAULT '',
+  attempts        INTEGER NOT NULL DEFAULT 0,
+  last_error      TEXT NOT NULL DEFAULT '',
+  next_attempt_at TEXT NOT NULL,
+  claimed_at      TEXT NOT NULL DEFAULT '',
+  sent_at         TEXT NOT NULL DEFAULT '',
+  failed_at       TEXT NOT NULL DEFAULT '',
+  created_at      TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_due
+ON outbox(sent_at, failed_at, next_attempt_at);
@@ -0,0 +1,11 @@
+-- Characters removed with !char purge. A snapshot with no characters but
+-- purge records was emptied on purpose, so startup recovery keeps it
+-- instead of restoring an older archive. Re-adding a character clears its
+-- record.
+CREATE TABLE IF NOT EXISTS purged_characters (
+  region     TEXT NOT NULL,
+  realm      TEXT NOT NULL,
+  name       TEXT NOT NULL,
+  purged_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  PRIMARY KEY (region, realm, name)
+);
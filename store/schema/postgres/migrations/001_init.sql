@@ -0,0 +1,69 @@
+-- Postgres equivalent of the SQLite schema after its migrations. Timestamps
+-- stay as RFC 3339 text so both backends compare and return them the same way.
+CREATE TABLE IF NOT EXISTS characters (
+  id        BIGSERIAL PRIMARY KEY,
+  region    TEXT NOT NULL,
+  realm     TEXT NOT NULL,
+  name      TEXT NOT NULL,
+  rio_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+  UNIQUE(region, realm, name)
+);
+
+CREATE TABLE IF NOT EXISTS completed_keys (
+  key_id        BIGINT NOT NULL,
+  character_id  BIGINT NOT NULL REFERENCES characters(id),
+  dungeon       TEXT NOT NULL,
+  key_lvl       BIGINT NOT NULL,
+  run_time_ms   BIGINT NOT NULL,
+  par_time_ms   BIGINT NOT NULL,
+  completed_at  TEXT NOT NULL,
+  source        TEXT NOT NULL,
+  inserted_at   TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+  PRIMARY KEY (key_id, character_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_completed_keys_completed_at
+ON completed_keys(completed_at);
+
+CREATE INDEX IF NOT EXISTS idx_completed_keys_character_time
+ON completed_keys(character_id, completed_at);
+
+CREATE TABLE IF NOT EXISTS warcraftlogs_links (
+  id          BIGSERIAL PRIMARY KEY,
+  key_id      BIGINT NOT NULL,
+  report_code TEXT NOT NULL,
+  fight_id    BIGINT,
+  pull_id     BIGINT,
+  url         TEXT,
+  inserted_at TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+  UNIQUE(key_id, report_code, fight_id, pull_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_warcraftlogs_links_key
+ON warcraftlogs_links(key_id);
+
+CREATE TABLE IF NOT EXISTS elvui_versions (
+  id            BIGINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+  version       TEXT NOT NULL,
+  download_url  TEXT NOT NULL,
+  changelog_url TEXT NOT NULL,
+  last_update   TEXT NOT NULL,
+  checked_at    TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+);
+
+CREATE TABLE IF NOT EXISTS weekly_history (
+  week_start    TEXT NOT NULL,
+  region        TEXT NOT NULL,
+  realm         TEXT NOT NULL,
+  name          TEXT NOT NULL,
+  key_count     BIGINT NOT NULL,
+  best_key_lvl  BIGINT NOT NULL,
+  best_dungeon  TEXT NOT NULL,
+  vault_1_lvl   BIGINT NOT NULL,
+  vault_2_lvl   BIGINT NOT NULL,
+  vault_3_lvl   BIGINT NOT NULL,
+  rio_score     DOUBLE PRECISION NOT NULL DEFAULT 0,
+  source        TEXT NOT NULL,
+  recorded_at   TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'),
+  PRIMARY KEY (week_start, region, realm, name)
+);
@@ -0,0 +1,19 @@
+-- Discord posts waiting for delivery, as in the SQLite outbox. Times are
+-- fixed-width UTC text so they compare as strings; '' means unset.
+CREATE TABLE IF NOT EXISTS outbox (
+  id              BIGSERIAL PRIMARY KEY,
+  dedup_key       TEXT NOT NULL UNIQUE,
+  channel_id      TEXT NOT NULL,
+  content         TEXT NOT NULL DEFAULT '',
+  embeds          TEXT NOT NULL DEFAULT '',
+  attempts        BIGINT NOT NULL DEFAULT 0,
+  last_error      TEXT NOT NULL DEFAULT '',
+  next_attempt_at TEXT NOT NULL,
+  claimed_at      TEXT NOT NULL DEFAULT '',
+  sent_at         TEXT NOT NULL DEFAULT '',
+  failed_at       TEXT NOT NULL DEFAULT '',
+  created_at      TEXT NOT NULL DEFAULT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"')
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_due
+ON outbox(sent_at, failed_at, next_attempt_at);
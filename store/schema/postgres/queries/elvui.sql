@@ -0,0 +1,14 @@
+-- name: UpsertElvUIVersion :exec
+INSERT INTO elvui_versions (id, version, download_url, changelog_url, last_update)
+VALUES (1, $1, $2, $3, $4)
+ON CONFLICT(id) DO UPDATE SET
+  version = excluded.version,
+  download_url = excluded.download_url,
+  changelog_url = excluded.changelog_url,
+  last_update = excluded.last_update,
+  checked_at = to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+
+-- name: GetElvUIVersion :one
+SELECT version, download_url, changelog_url, last_update, checked_at
+FROM elvui_versions
+WHERE id = 1;
@@ -0,0 +1,23 @@
+-- name: UpsertWeeklyHistory :exec
+INSERT INTO weekly_history(
+  week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT(week_start, region, realm, name) DO UPDATE SET
+  key_count = excluded.key_count,
+  best_key_lvl = excluded.best_key_lvl,
+  best_dungeon = excluded.best_dungeon,
+  vault_1_lvl = excluded.vault_1_lvl,
+  vault_2_lvl = excluded.vault_2_lvl,
+  vault_3_lvl = excluded.vault_3_lvl,
+  rio_score = CASE WHEN excluded.rio_score > 0 THEN excluded.rio_score ELSE weekly_history.rio_score END,
+  source = excluded.source,
+  recorded_at = to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"');
+
+-- name: ListWeeklyHistory :many
+SELECT week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+FROM weekly_history
+WHERE LOWER(name) = LOWER($1) AND LOWER(realm) = LOWER($2) AND LOWER(region) = LOWER($3)
+ORDER BY week_start DESC
+LIMIT $4;
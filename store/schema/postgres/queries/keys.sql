@@ -0,0 +1,120 @@
+-- name: UpsertCharacter :one
+INSERT INTO characters(region, realm, name)
+VALUES ($1, $2, $3)
+ON CONFLICT(region, realm, name) DO UPDATE SET name=excluded.name
+RETURNING id;
+
+-- name: InsertCompletedKey :exec
+INSERT INTO completed_keys(
+  key_id, character_id, dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT(key_id, character_id) DO UPDATE SET
+  dungeon = excluded.dungeon,
+  key_lvl = excluded.key_lvl,
+  run_time_ms = excluded.run_time_ms,
+  par_time_ms = excluded.par_time_ms,
+  completed_at = excluded.completed_at,
+  source = excluded.source;
+
+-- name: GetCompletedKey :one
+SELECT dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
+FROM completed_keys
+WHERE key_id = $1 AND character_id = $2;
+
+-- name: CountKeysByCharacterSince :many
+SELECT c.region, c.realm, c.name, COUNT(*) AS key_count
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+WHERE k.completed_at > $1
+GROUP BY c.region, c.realm, c.name
+ORDER BY key_count DESC;
+
+-- name: ListKeysByCharacterSince :many
+SELECT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
+k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+WHERE LOWER(c.name) = LOWER($1) AND LOWER(c.realm) = LOWER($2) AND LOWER(c.region) = LOWER($3)
+  AND k.completed_at > $4
+ORDER BY k.completed_at DESC;
+
+-- name: ListAllKeysWithCharacters :many
+SELECT k.key_id, c.id as character_id, c.region, c.realm, c.name AS character,
+k.dungeon, k.key_lvl, k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+ORDER BY k.completed_at DESC;
+
+-- name: ListKeysSince :many
+SELECT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
+  k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+WHERE k.completed_at > $1
+ORDER BY k.completed_at DESC;
+
+-- name: UpdateCharacterScore :exec
+UPDATE characters SET rio_score = $1
+WHERE LOWER(name) = LOWER($2) AND LOWER(realm) = LOWER($3) AND LOWER(region) = LOWER($4);
+
+-- name: ListCharacters :many
+SELECT region, realm, name, rio_score FROM characters ORDER BY region, realm, name;
+
+-- name: GetCharacter :one
+SELECT id, region, realm, name, rio_score FROM characters
+WHERE LOWER(name) = LOWER($1) AND LOWER(realm) = LOWER($2) AND LOWER(region) = LOWER($3);
+
+-- name: GetCharacterID :one
+SELECT id FROM characters
+WHERE LOWER(name) = LOWER($1) AND LOWER(realm) = LOWER($2) AND LOWER(region) = LOWER($3);
+
+-- name: DeleteWarcraftLogsLinksByCharacter :exec
+DELETE FROM warcraftlogs_links
+WHERE key_id IN (
+  SELECT k.key_id FROM completed_keys k
+  JOIN characters c ON c.id = k.character_id
+  WHERE c.id = $1
+);
+
+-- name: DeleteCompletedKeysByCharacter :exec
+DELETE FROM completed_keys WHERE character_id = $1;
+
+-- name: DeleteCharacter :exec
+DELETE FROM characters WHERE id = $1;
+
+-- name: ListUnlinkedKeysSince :many
+SELECT DISTINCT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
+  k.run_time_ms, k.par_time_ms, k.completed_at, k.source
+FROM completed_keys k
+JOIN characters c ON c.id = k.character_id
+LEFT JOIN warcraftlogs_links w ON w.key_id = k.key_id
+WHERE k.completed_at > $1 AND w.id IS NULL
+ORDER BY k.completed_at DESC;
+
+-- name: DeleteCompletedKey :exec
+DELETE FROM completed_keys WHERE key_id = $1 AND character_id = $2;
+
+-- name: DeleteOrphanedWarcraftLogsLinks :exec
+DELETE FROM warcraftlogs_links
+WHERE warcraftlogs_links.key_id = $1
+  AND NOT EXISTS (SELECT 1 FROM completed_keys k WHERE k.key_id = warcraftlogs_links.key_id);
+
+-- name: ListVaultProgressSince :many
+WITH ranked AS (
+  SELECT k.character_id, k.key_lvl,
+    ROW_NUMBER() OVER (PARTITION BY k.character_id ORDER BY k.key_lvl DESC, k.completed_at ASC) AS key_rank,
+    COUNT(*) OVER (PARTITION BY k.character_id) AS key_count
+  FROM completed_keys k
+  WHERE k.completed_at > $1
+)
+SELECT c.region, c.realm, c.name, c.rio_score,
+  COALESCE(r.key_count, 0)::BIGINT AS key_count,
+  COALESCE(r.key_lvl, 0)::BIGINT AS key_lvl
+FROM characters c
+LEFT JOIN ranked r ON r.character_id = c.id AND r.key_rank <= 8
+ORDER BY c.region, c.realm, c.name, r.key_rank;
+
+-- name: CountStoredRows :one
+SELECT
+  (SELECT COUNT(*) FROM characters) AS character_count,
+  (SELECT COUNT(*) FROM completed_keys) AS key_count;
@@ -0,0 +1,36 @@
+-- name: EnqueueOutboxMessage :execrows
+INSERT INTO outbox(dedup_key, channel_id, content, embeds, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (dedup_key) DO NOTHING;
+
+-- name: ListDueOutboxMessages :many
+SELECT * FROM outbox
+WHERE sent_at = '' AND failed_at = '' AND next_attempt_at <= $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ClaimOutboxMessage :exec
+UPDATE outbox SET claimed_at = $1, attempts = attempts + 1
+WHERE id = $2;
+
+-- name: MarkOutboxMessageSent :exec
+UPDATE outbox SET sent_at = $1, claimed_at = '', last_error = ''
+WHERE id = $2;
+
+-- name: MarkOutboxMessageFailed :exec
+UPDATE outbox SET last_error = $1, next_attempt_at = $2, failed_at = $3, claimed_at = ''
+WHERE id = $4;
+
+-- name: ListFailedOutboxMessages :many
+SELECT * FROM outbox
+WHERE failed_at != ''
+ORDER BY failed_at DESC
+LIMIT $1;
+
+-- name: RequeueOutboxMessage :execrows
+UPDATE outbox SET failed_at = '', attempts = 0, next_attempt_at = $1
+WHERE id = $2 AND failed_at != '';
+
+-- name: PruneSentOutboxMessages :execrows
+DELETE FROM outbox
+WHERE sent_at != '' AND sent_at < $1;
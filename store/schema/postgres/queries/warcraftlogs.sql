@@ -0,0 +1,36 @@
+-- name: InsertWarcraftLogsLink :exec
+-- The unique constraint treats NULL fight and pull IDs as distinct, so
+-- duplicates of links without them are skipped explicitly.
+INSERT INTO warcraftlogs_links(
+  key_id, report_code, fight_id, pull_id, url
+)
+SELECT sqlc.arg(key_id)::BIGINT, sqlc.arg(report_code)::TEXT,
+  sqlc.narg(fight_id)::BIGINT, sqlc.narg(pull_id)::BIGINT, sqlc.narg(url)::TEXT
+WHERE NOT EXISTS (
+  SELECT 1 FROM warcraftlogs_links
+  WHERE key_id = sqlc.arg(key_id)::BIGINT AND report_code = sqlc.arg(report_code)::TEXT
+    AND fight_id IS NOT DISTINCT FROM sqlc.narg(fight_id)::BIGINT
+    AND pull_id IS NOT DISTINCT FROM sqlc.narg(pull_id)::BIGINT
+)
+ON CONFLICT DO NOTHING;
+
+-- name: ListWarcraftLogsLinksForKey :many
+SELECT key_id, report_code, fight_id, pull_id, url, inserted_at
+FROM warcraftlogs_links
+WHERE key_id = $1
+ORDER BY inserted_at DESC;
+
+-- name: MoveWarcraftLogsLinks :exec
+-- Links the target key already has stay where they are. NULL fight and pull
+-- IDs compare equal here, as in InsertWarcraftLogsLink.
+UPDATE warcraftlogs_links l SET key_id = $1
+WHERE l.key_id = $2
+  AND NOT EXISTS (
+    SELECT 1 FROM warcraftlogs_links o
+    WHERE o.key_id = $1 AND o.report_code = l.report_code
+      AND o.fight_id IS NOT DISTINCT FROM l.fight_id
+      AND o.pull_id IS NOT DISTINCT FROM l.pull_id
+  );
+
+-- name: DeleteWarcraftLogsLinksForKey :exec
+DELETE FROM warcraftlogs_links WHERE key_id = $1;
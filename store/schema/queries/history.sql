@@ -0,0 +1,29 @@
+-- name: UpsertWeeklyHistory :exec
+INSERT INTO weekly_history(
+  week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(week_start, region, realm, name) DO UPDATE SET
+  key_count = excluded.key_count,
+  best_key_lvl = excluded.best_key_lvl,
+  best_dungeon = excluded.best_dungeon,
+  vault_1_lvl = excluded.vault_1_lvl,
+  vault_2_lvl = excluded.vault_2_lvl,
+  vault_3_lvl = excluded.vault_3_lvl,
+  rio_score = CASE WHEN excluded.rio_score > 0 THEN excluded.rio_score ELSE weekly_history.rio_score END,
+  source = excluded.source,
+  recorded_at = strftime('%Y-%m-%dT%H:%M:%fZ','now');
+
+-- name: InsertWeeklyHistoryIfMissing :execrows
+INSERT OR IGNORE INTO weekly_history(
+  week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListWeeklyHistory :many
+SELECT week_start, region, realm, name, key_count, best_key_lvl, best_dungeon,
+  vault_1_lvl, vault_2_lvl, vault_3_lvl, rio_score, source
+FROM weekly_history
+WHERE LOWER(name) = LOWER(?) AND LOWER(realm) = LOWER(?) AND LOWER(region) = LOWER(?)
+ORDER BY week_start DESC
+LIMIT ?;
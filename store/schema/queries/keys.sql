@@ -16,6 +16,11 @@ ON CONFLICT(key_id, character_id) DO UPDATE SET
   completed_at = excluded.completed_at,
   source = excluded.source;
 
+-- name: GetCompletedKey :one
+SELECT dungeon, key_lvl, run_time_ms, par_time_ms, completed_at, source
+FROM completed_keys
+WHERE key_id = ? AND character_id = ?;
+
 -- name: CountKeysByCharacterSince :many
 SELECT c.region, c.realm, c.name, COUNT(*) AS key_count
 FROM completed_keys k
@@ -29,7 +34,8 @@ SELECT k.key_id, c.region, c.realm, c.name AS character, k.dungeon, k.key_lvl,
 k.run_time_ms, k.par_time_ms, k.completed_at, k.source
 FROM completed_keys k
 JOIN characters c ON c.id = k.character_id
-WHERE LOWER(c.name) = LOWER(?) AND k.completed_at > ?
+WHERE LOWER(c.name) = LOWER(?) AND LOWER(c.realm) = LOWER(?) AND LOWER(c.region) = LOWER(?)
+  AND k.completed_at > ?
 ORDER BY k.completed_at DESC;
 
 -- name: ListAllKeysWithCharacters :many
@@ -84,3 +90,44 @@ JOIN characters c ON c.id = k.character_id
 LEFT JOIN warcraftlogs_links w ON w.key_id = k.key_id
 WHERE k.completed_at > ? AND w.id IS NULL
 ORDER BY k.completed_at DESC;
+
+-- name: DeleteCompletedKey :execrows
+DELETE FROM completed_keys WHERE key_id = ? AND character_id = ?;
+
+-- name: DeleteOrphanedWarcraftLogsLinks :exec
+DELETE FROM warcraftlogs_links
+WHERE warcraftlogs_links.key_id = ?
+  AND NOT EXISTS (SELECT 1 FROM completed_keys k WHERE k.key_id = warcraftlogs_links.key_id);
+
+-- name: ListVaultProgressSince :many
+WITH ranked AS (
+  SELECT k.character_id, k.key_lvl,
+    ROW_NUMBER() OVER (PARTITION BY k.character_id ORDER BY k.key_lvl DESC, k.completed_at ASC) AS key_rank,
+    COUNT(*) OVER (PARTITION BY k.character_id) AS key_count
+  FROM completed_keys k
+  WHERE k.completed_at > ?
+)
+SELECT c.region, c.realm, c.name, c.rio_score,
+  COALESCE(r.key_count, 0) AS key_count,
+  COALESCE(r.key_lvl, 0) AS key_lvl
+FROM characters c
+LEFT JOIN ranked r ON r.character_id = c.id AND r.key_rank <= 8
+ORDER BY c.region, c.realm, c.name, r.key_rank;
+
+-- name: CountStoredRows :one
+SELECT
+  (SELECT COUNT(*) FROM characters) AS character_count,
+  (SELECT COUNT(*) FROM completed_keys) AS key_count;
+
+-- name: RecordPurgedCharacter :exec
+INSERT INTO purged_characters (region, realm, name)
+VALUES (?, ?, ?)
+ON CONFLICT (region, realm, name) DO UPDATE SET
+  purged_at = strftime('%Y-%m-%dT%H:%M:%fZ','now');
+
+-- name: ClearPurgedCharacter :exec
+DELETE FROM purged_characters
+WHERE region = ? AND realm = ? AND name = ?;
+
+-- name: CountPurgedCharacters :one
+SELECT COUNT(*) FROM purged_characters;
@@ -0,0 +1,35 @@
+-- name: EnqueueOutboxMessage :execrows
+INSERT OR IGNORE INTO outbox(dedup_key, channel_id, content, embeds, next_attempt_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListDueOutboxMessages :many
+SELECT * FROM outbox
+WHERE sent_at = '' AND failed_at = '' AND next_attempt_at <= ?
+ORDER BY id
+LIMIT ?;
+
+-- name: ClaimOutboxMessage :exec
+UPDATE outbox SET claimed_at = ?, attempts = attempts + 1
+WHERE id = ?;
+
+-- name: MarkOutboxMessageSent :exec
+UPDATE outbox SET sent_at = ?, claimed_at = '', last_error = ''
+WHERE id = ?;
+
+-- name: MarkOutboxMessageFailed :exec
+UPDATE outbox SET last_error = ?, next_attempt_at = ?, failed_at = ?, claimed_at = ''
+WHERE id = ?;
+
+-- name: ListFailedOutboxMessages :many
+SELECT * FROM outbox
+WHERE failed_at != ''
+ORDER BY failed_at DESC
+LIMIT ?;
+
+-- name: RequeueOutboxMessage :execrows
+UPDATE outbox SET failed_at = '', attempts = 0, next_attempt_at = ?
+WHERE id = ? AND failed_at != '';
+
+-- name: PruneSentOutboxMessages :execrows
+DELETE FROM outbox
+WHERE sent_at != '' AND sent_at < ?;
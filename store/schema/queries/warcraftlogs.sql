@@ -1,10 +1,33 @@
--- name: InsertWarcraftLogsLink :exec
+-- name: InsertWarcraftLogsLink :execrows
+-- The unique constraint treats NULL fight and pull IDs as distinct, so
+-- duplicates of links without them are skipped explicitly.
 INSERT OR IGNORE INTO warcraftlogs_links(
   key_id, report_code, fight_id, pull_id, url
-) VALUES (?, ?, ?, ?, ?);
+)
+SELECT ?1, ?2, ?3, ?4, ?5
+WHERE NOT EXISTS (
+  SELECT 1 FROM warcraftlogs_links
+  WHERE key_id = ?1 AND report_code = ?2 AND fight_id IS ?3 AND pull_id IS ?4
+);
 
 -- name: ListWarcraftLogsLinksForKey :many
 SELECT key_id, report_code, fight_id, pull_id, url, inserted_at
 FROM warcraftlogs_links
 WHERE key_id = ?
 ORDER BY inserted_at DESC;
+
+-- name: MoveWarcraftLogsLinks :exec
+-- Links the target key already has stay where they are. The unique
+-- constraint treats NULL fight and pull IDs as distinct, so they are
+-- compared with IS, as in InsertWarcraftLogsLink.
+UPDATE OR IGNORE warcraftlogs_links SET key_id = ?1
+WHERE key_id = ?2
+  AND NOT EXISTS (
+    SELECT 1 FROM warcraftlogs_links o
+    WHERE o.key_id = ?1 AND o.report_code = warcraftlogs_links.report_code
+      AND o.fight_id IS warcraftlogs_links.fight_id
+      AND o.pull_id IS warcraftlogs_links.pull_id
+  );
+
+-- name: DeleteWarcraftLogsLinksForKey :exec
+DELETE FROM warcraftlogs_links WHERE key_id = ?;
@@ -2,10 +2,7 @@ package store
 
 import (
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/binary"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -16,8 +13,10 @@ import (
 	"time"
 
 	"github.com/mattn/go-sqlite3"
+	"github.com/tnicklin/celestial_orrey/clock"
 	"github.com/tnicklin/celestial_orrey/logger"
 	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/reconcile"
 	"github.com/tnicklin/celestial_orrey/store/db"
 	"go.uber.org/atomic"
 )
@@ -34,13 +33,33 @@ type SQLiteStore struct {
 	db           *sql.DB
 	snapshotPath string
 	backupDir    string
+	backupCfg    BackupConfig
 	logger       logger.Logger
+	clock        clock.Clock
+
+	// allowEmptyOverwrite lets an empty database replace a non-empty snapshot
+	allowEmptyOverwrite bool
+
+	// Backup integrity check results, by path
+	checksMu sync.Mutex
+	checks   map[string]BackupInfo
+
+	// Subscribers to committed changes
+	events eventBus
+
+	// Off-host copies of snapshots and archives
+	sink             BackupSink
+	sinkCfg          S3Config
+	shipMu           sync.Mutex
+	lastSnapshotShip time.Time
 
 	// Debounced flush
 	flushDebounce time.Duration
-	flushTimer    *time.Timer
+	flushTimer    clock.Timer
 	flushMu       sync.Mutex
 	dirty         atomic.Bool
+	lastFlush     time.Time
+	lastFlushErr  string
 	ctx           context.Context
 	cancel        context.CancelFunc
 }
@@ -48,15 +67,36 @@ type SQLiteStore struct {
 type Params struct {
 	Path      string
 	BackupDir string
+	Backup    BackupConfig
 	Logger    logger.Logger
+	// AllowEmptyOverwrite disables the guard against flushing an empty
+	// database over a snapshot that holds data.
+	AllowEmptyOverwrite bool
+	// Sink, if set, receives a copy of every archive and periodic
+	// snapshots according to SinkConfig.
+	Sink       BackupSink
+	SinkConfig S3Config
+	// Clock dates archives, events and outbox messages. Nil uses the system
+	// clock.
+	Clock clock.Clock
 }
 
 func NewSQLiteStore(p Params) *SQLiteStore {
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
+
 	return &SQLiteStore{
-		snapshotPath:  p.Path,
-		backupDir:     p.BackupDir,
-		flushDebounce: defaultDebounce,
-		logger:        p.Logger,
+		snapshotPath:        p.Path,
+		backupDir:           p.BackupDir,
+		backupCfg:           p.Backup,
+		allowEmptyOverwrite: p.AllowEmptyOverwrite,
+		sink:                p.Sink,
+		sinkCfg:             p.SinkConfig,
+		flushDebounce:       defaultDebounce,
+		logger:              p.Logger,
+		clock:               clk,
 	}
 }
 
@@ -163,6 +203,9 @@ func (s *SQLiteStore) RestoreFromDisk(ctx context.Context, path string) error {
 	return s.applyMigrations(ctx)
 }
 
+// FlushToDisk writes the database to path. The copy is not checked here,
+// since flushes are frequent and hold the write lock; the snapshot is
+// verified before each upload to the sink and by VerifyBackups.
 func (s *SQLiteStore) FlushToDisk(ctx context.Context, path string) error {
 	if path == "" {
 		return nil
@@ -170,31 +213,103 @@ func (s *SQLiteStore) FlushToDisk(ctx context.Context, path string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.guardEmptyOverwrite(ctx, path); err != nil {
+		return err
+	}
 	return s.flushLocked(ctx, path)
 }
 
-// ArchiveWeek creates a timestamped backup of the current database in the backup directory.
+// Vacuum rebuilds the database to reclaim free pages and marks it for the
+// next flush, which then writes the compacted snapshot.
+func (s *SQLiteStore) Vacuum(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+	if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return err
+	}
+
+	s.scheduleFlush()
+	return nil
+}
+
+// ArchiveWeek records weekly history and creates a timestamped backup of the
+// current database in the backup directory. Only the history and the copy
+// are made under s.mu; compressing, checking, pruning and uploading work on
+// the archive file alone, so they do not block the store.
 func (s *SQLiteStore) ArchiveWeek(ctx context.Context) error {
 	if s.backupDir == "" {
 		return nil
 	}
+
+	backupPath, err := s.writeArchive(ctx)
+	if err != nil {
+		return err
+	}
+
+	backupPath, err = s.compressArchive(backupPath)
+	if err != nil {
+		return fmt.Errorf("compress archive: %w", err)
+	}
+
+	if err := s.verifyBackup(ctx, backupPath); err != nil {
+		return fmt.Errorf("verify archive: %w", err)
+	}
+
+	removed, err := s.pruneArchives()
+	if err != nil {
+		return fmt.Errorf("prune archives: %w", err)
+	}
+
+	// A failed upload leaves the local archive in place, so only log it
+	if err := s.shipArchive(ctx, backupPath); err != nil && s.logger != nil {
+		s.logger.WarnW("ship archive", "path", backupPath, "error", err)
+	}
+
+	if s.logger != nil {
+		s.logger.InfoW("archived week", "path", backupPath, "pruned", len(removed))
+	}
+	s.events.publish(WeekArchived{eventTime: s.eventNow(), Path: backupPath})
+	return nil
+}
+
+// writeArchive records the finished week's history and copies the database
+// to a new timestamped file in the backup directory, returning its path.
+func (s *SQLiteStore) writeArchive(ctx context.Context) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.db == nil {
-		return errors.New("store is not open")
+		return "", errors.New("store is not open")
 	}
 
 	// Create backup directory if it doesn't exist
 	if err := os.MkdirAll(s.backupDir, 0o755); err != nil {
-		return fmt.Errorf("create backup dir: %w", err)
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+
+	now := s.clock.Now()
+
+	// Summarize the finished week so it stays queryable after the archive
+	if _, err := s.recordHistoryLocked(ctx, now); err != nil && s.logger != nil {
+		s.logger.WarnW("record weekly history", "error", err)
 	}
 
 	// Generate timestamped filename
-	timestamp := time.Now().Format("2006-01-02_150405")
-	backupPath := filepath.Join(s.backupDir, fmt.Sprintf("celestial_orrey_%s.db", timestamp))
+	timestamp := now.Format(_archiveTimestamp)
+	backupPath := filepath.Join(s.backupDir, fmt.Sprintf("%s%s.db", _archivePrefix, timestamp))
+
+	if err := s.flushLocked(ctx, backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
 
-	return s.flushLocked(ctx, backupPath)
+func (s *SQLiteStore) eventNow() eventTime {
+	return eventTime{At: s.clock.Now()}
 }
 
 func (s *SQLiteStore) scheduleFlush() {
@@ -210,7 +325,7 @@ func (s *SQLiteStore) scheduleFlush() {
 		s.flushTimer.Stop()
 	}
 
-	s.flushTimer = time.AfterFunc(s.flushDebounce, func() {
+	s.flushTimer = clock.AfterFunc(s.clock, s.flushDebounce, func() {
 		s.performScheduledFlush()
 	})
 }
@@ -225,10 +340,48 @@ func (s *SQLiteStore) performScheduledFlush() {
 
 	if err := s.FlushToDisk(ctx, s.snapshotPath); err != nil {
 		fmt.Fprintf(os.Stderr, "scheduled flush failed: %v\n", err)
+		s.recordFlush(err)
 		return
 	}
 
 	s.dirty.Store(false)
+	s.recordFlush(nil)
+
+	if err := s.shipSnapshot(ctx); err != nil && s.logger != nil {
+		s.logger.WarnW("ship snapshot", "error", err)
+	}
+}
+
+// FlushState describes how current the on-disk snapshot is.
+type FlushState struct {
+	// Dirty is set while changes are waiting for the next flush.
+	Dirty     bool
+	LastFlush time.Time
+	// LastError is the error from the latest scheduled flush, if it failed.
+	LastError string
+}
+
+// FlushState reports whether changes are waiting to be flushed and how the
+// latest scheduled flush went.
+func (s *SQLiteStore) FlushState() FlushState {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	return FlushState{
+		Dirty:     s.dirty.Load(),
+		LastFlush: s.lastFlush,
+		LastError: s.lastFlushErr,
+	}
+}
+
+func (s *SQLiteStore) recordFlush(err error) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	if err != nil {
+		s.lastFlushErr = err.Error()
+		return
+	}
+	s.lastFlush = s.clock.Now()
+	s.lastFlushErr = ""
 }
 
 func (s *SQLiteStore) stopFlushTimer() {
@@ -239,6 +392,119 @@ func (s *SQLiteStore) stopFlushTimer() {
 }
 
 func (s *SQLiteStore) UpsertCompletedKey(ctx context.Context, key models.CompletedKey) error {
+	_, err := s.UpsertCompletedKeys(ctx, []models.CompletedKey{key})
+	return err
+}
+
+// UpsertCompletedKeys writes keys in a single transaction and returns what
+// happened to each, in order. Either every key is written or none are.
+func (s *SQLiteStore) UpsertCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]UpsertStatus, error) {
+	return s.writeKeys(ctx, keys, false)
+}
+
+// ReconcileCompletedKeys writes keys like UpsertCompletedKeys, but first
+// checks each against the character's stored keys from other sources (see
+// package reconcile) in the same transaction. A key that outranks the stored
+// copy of its run replaces it, carrying its links over, and reports
+// UpsertReplaced; one that does not is dropped and reports UpsertUnchanged.
+// Keys earlier in the batch are visible to later ones.
+func (s *SQLiteStore) ReconcileCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]UpsertStatus, error) {
+	return s.writeKeys(ctx, keys, true)
+}
+
+func (s *SQLiteStore) writeKeys(ctx context.Context, keys []models.CompletedKey, reconciling bool) ([]UpsertStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := db.New(tx)
+	characterIDs := make(map[db.UpsertCharacterParams]int64)
+	statuses := make([]UpsertStatus, len(keys))
+	var events []Event
+
+	for i, key := range keys {
+		character := db.UpsertCharacterParams{
+			Region: strings.ToLower(key.Region),
+			Realm:  strings.ToLower(key.Realm),
+			Name:   strings.ToLower(key.Character),
+		}
+		characterID, ok := characterIDs[character]
+		if !ok {
+			characterID, err = queries.UpsertCharacter(ctx, character)
+			if err != nil {
+				_ = tx.Rollback()
+				return nil, err
+			}
+			characterIDs[character] = characterID
+		}
+
+		var (
+			match models.CompletedKey
+			found bool
+		)
+		if reconciling {
+			match, found, err = s.reconcileMatch(ctx, queries, key)
+			if err != nil {
+				_ = tx.Rollback()
+				return nil, err
+			}
+		}
+
+		key.KeyID = key.EffectiveID()
+		switch {
+		case !found:
+			statuses[i], err = s.writeKey(ctx, queries, characterID, key)
+			if err != nil {
+				_ = tx.Rollback()
+				return nil, err
+			}
+			switch statuses[i] {
+			case UpsertInserted:
+				events = append(events, KeyInserted{eventTime: s.eventNow(), Key: key})
+			case UpsertUpdated:
+				events = append(events, KeyUpdated{eventTime: s.eventNow(), Key: key})
+			}
+		case !reconcile.Wins(key, match):
+			// The stored copy of the run is at least as trusted.
+		default:
+			if err := s.replaceKey(ctx, queries, characterID, match.KeyID, key); err != nil {
+				_ = tx.Rollback()
+				return nil, err
+			}
+			statuses[i] = UpsertReplaced
+			events = append(events, KeyReplaced{eventTime: s.eventNow(), OldKeyID: match.KeyID, Key: key})
+		}
+	}
+
+	if len(events) == 0 {
+		return statuses, tx.Rollback()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Schedule debounced flush instead of immediate flush
+	s.scheduleFlush()
+
+	s.events.publish(events...)
+	return statuses, nil
+}
+
+// ReplaceCompletedKey swaps the key stored under oldKeyID for key's character
+// with key, moving any WarcraftLogs links over to the replacement.
+func (s *SQLiteStore) ReplaceCompletedKey(ctx context.Context, oldKeyID int64, key models.CompletedKey) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -251,9 +517,8 @@ func (s *SQLiteStore) UpsertCompletedKey(ctx context.Context, key models.Complet
 		return err
 	}
 
-	var characterID int64
 	queries := db.New(tx)
-	characterID, err = queries.UpsertCharacter(ctx, db.UpsertCharacterParams{
+	characterID, err := queries.UpsertCharacter(ctx, db.UpsertCharacterParams{
 		Region: strings.ToLower(key.Region),
 		Realm:  strings.ToLower(key.Realm),
 		Name:   strings.ToLower(key.Character),
@@ -263,22 +528,8 @@ func (s *SQLiteStore) UpsertCompletedKey(ctx context.Context, key models.Complet
 		return err
 	}
 
-	keyID := key.KeyID
-	if keyID <= 0 {
-		keyID = syntheticKeyID(key)
-	}
-
-	err = queries.InsertCompletedKey(ctx, db.InsertCompletedKeyParams{
-		KeyID:       keyID,
-		CharacterID: characterID,
-		Dungeon:     key.Dungeon,
-		KeyLvl:      int64(key.KeyLevel),
-		RunTimeMs:   key.RunTimeMS,
-		ParTimeMs:   key.ParTimeMS,
-		CompletedAt: key.CompletedAt,
-		Source:      key.Source,
-	})
-	if err != nil {
+	key.KeyID = key.EffectiveID()
+	if err := s.replaceKey(ctx, queries, characterID, oldKeyID, key); err != nil {
 		_ = tx.Rollback()
 		return err
 	}
@@ -287,11 +538,103 @@ func (s *SQLiteStore) UpsertCompletedKey(ctx context.Context, key models.Complet
 		return err
 	}
 
-	// Schedule debounced flush instead of immediate flush
 	s.scheduleFlush()
+
+	s.events.publish(KeyReplaced{eventTime: s.eventNow(), OldKeyID: oldKeyID, Key: key})
 	return nil
 }
 
+// reconcileMatch returns the stored key from another source that describes
+// the same run as key, logging any conflicts between the two.
+func (s *SQLiteStore) reconcileMatch(ctx context.Context, queries *db.Queries, key models.CompletedKey) (models.CompletedKey, bool, error) {
+	since, ok := reconcile.Since(key)
+	if !ok {
+		return models.CompletedKey{}, false, nil
+	}
+
+	rows, err := queries.ListKeysByCharacterSince(ctx, db.ListKeysByCharacterSinceParams{
+		LOWER:       key.Character,
+		LOWER_2:     key.Realm,
+		LOWER_3:     key.Region,
+		CompletedAt: formatCutoff(since),
+	})
+	if err != nil {
+		return models.CompletedKey{}, false, err
+	}
+
+	match, found := reconcile.Match(key, characterKeys(rows))
+	if found {
+		reconcile.LogConflicts(s.logger, key, match)
+	}
+	return match, found, nil
+}
+
+// writeKey inserts or refreshes key, whose KeyID is set, for characterID.
+func (s *SQLiteStore) writeKey(ctx context.Context, queries *db.Queries, characterID int64, key models.CompletedKey) (UpsertStatus, error) {
+	existing, err := queries.GetCompletedKey(ctx, db.GetCompletedKeyParams{
+		KeyID:       key.KeyID,
+		CharacterID: characterID,
+	})
+	inserted := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !inserted {
+		return UpsertUnchanged, err
+	}
+	if !inserted && sameKey(storedKey(existing), key) {
+		// Nothing to write or announce.
+		return UpsertUnchanged, nil
+	}
+
+	if err := s.insertKey(ctx, queries, characterID, key); err != nil {
+		return UpsertUnchanged, err
+	}
+	if inserted {
+		return UpsertInserted, nil
+	}
+	return UpsertUpdated, nil
+}
+
+// replaceKey stores key, whose KeyID is set, in place of oldKeyID for
+// characterID, moving any WarcraftLogs links over to it.
+func (s *SQLiteStore) replaceKey(ctx context.Context, queries *db.Queries, characterID, oldKeyID int64, key models.CompletedKey) error {
+	if err := s.insertKey(ctx, queries, characterID, key); err != nil {
+		return err
+	}
+	if oldKeyID == key.KeyID {
+		return nil
+	}
+
+	if err := queries.MoveWarcraftLogsLinks(ctx, db.MoveWarcraftLogsLinksParams{
+		KeyID:   key.KeyID,
+		KeyID_2: oldKeyID,
+	}); err != nil {
+		return err
+	}
+
+	// Links the replacement already had are left behind by the move.
+	if err := queries.DeleteWarcraftLogsLinksForKey(ctx, oldKeyID); err != nil {
+		return err
+	}
+
+	_, err := queries.DeleteCompletedKey(ctx, db.DeleteCompletedKeyParams{
+		KeyID:       oldKeyID,
+		CharacterID: characterID,
+	})
+	return err
+}
+
+func (s *SQLiteStore) insertKey(ctx context.Context, queries *db.Queries, characterID int64, key models.CompletedKey) error {
+	return queries.InsertCompletedKey(ctx, db.InsertCompletedKeyParams{
+		KeyID:       key.KeyID,
+		CharacterID: characterID,
+		Dungeon:     key.Dungeon,
+		KeyLvl:      int64(key.KeyLevel),
+		RunTimeMs:   key.RunTimeMS,
+		ParTimeMs:   key.ParTimeMS,
+		CompletedAt: key.CompletedAt,
+		Source:      key.Source,
+	})
+}
+
 func (s *SQLiteStore) UpsertWarcraftLogsLink(ctx context.Context, link WarcraftLogsLink) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -314,18 +657,23 @@ func (s *SQLiteStore) UpsertWarcraftLogsLink(ctx context.Context, link WarcraftL
 		url = sql.NullString{String: link.URL, Valid: true}
 	}
 
-	if err := queries.InsertWarcraftLogsLink(ctx, db.InsertWarcraftLogsLinkParams{
+	added, err := queries.InsertWarcraftLogsLink(ctx, db.InsertWarcraftLogsLinkParams{
 		KeyID:      link.KeyID,
 		ReportCode: link.ReportCode,
 		FightID:    fightID,
 		PullID:     pullID,
 		Url:        url,
-	}); err != nil {
+	})
+	if err != nil {
 		return err
 	}
+	if added == 0 {
+		return nil
+	}
 
 	// Schedule debounced flush instead of immediate flush
 	s.scheduleFlush()
+	s.events.publish(LinkAdded{eventTime: s.eventNow(), Link: link})
 	return nil
 }
 
@@ -338,7 +686,7 @@ func (s *SQLiteStore) CountKeysByCharacterSince(ctx context.Context, cutoff time
 	}
 
 	queries := db.New(s.db)
-	rows, err := queries.CountKeysByCharacterSince(ctx, cutoff.Format(time.RFC3339))
+	rows, err := queries.CountKeysByCharacterSince(ctx, formatCutoff(cutoff))
 	if err != nil {
 		return nil, err
 	}
@@ -355,7 +703,7 @@ func (s *SQLiteStore) CountKeysByCharacterSince(ctx context.Context, cutoff time
 	return out, nil
 }
 
-func (s *SQLiteStore) ListKeysByCharacterSince(ctx context.Context, character string, cutoff time.Time) ([]models.CompletedKey, error) {
+func (s *SQLiteStore) ListKeysByCharacterSince(ctx context.Context, name, realm, region string, cutoff time.Time) ([]models.CompletedKey, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -363,17 +711,21 @@ func (s *SQLiteStore) ListKeysByCharacterSince(ctx context.Context, character st
 		return nil, errors.New("store is not open")
 	}
 
-	characterLower := strings.ToLower(character)
-
 	queries := db.New(s.db)
 	rows, err := queries.ListKeysByCharacterSince(ctx, db.ListKeysByCharacterSinceParams{
-		LOWER:       characterLower,
-		CompletedAt: cutoff.Format(time.RFC3339),
+		LOWER:       name,
+		LOWER_2:     realm,
+		LOWER_3:     region,
+		CompletedAt: formatCutoff(cutoff),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	return characterKeys(rows), nil
+}
+
+func characterKeys(rows []db.ListKeysByCharacterSinceRow) []models.CompletedKey {
 	out := make([]models.CompletedKey, 0, len(rows))
 	for _, row := range rows {
 		key := models.CompletedKey{
@@ -390,6 +742,47 @@ func (s *SQLiteStore) ListKeysByCharacterSince(ctx context.Context, character st
 		}
 		out = append(out, key)
 	}
+	return out
+}
+
+// ListVaultProgressSince returns every character with its key count and top
+// eight key levels since cutoff, in a single query.
+func (s *SQLiteStore) ListVaultProgressSince(ctx context.Context, cutoff time.Time) ([]VaultRow, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.db == nil {
+		return nil, errors.New("store is not open")
+	}
+
+	queries := db.New(s.db)
+	rows, err := queries.ListVaultProgressSince(ctx, formatCutoff(cutoff))
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows arrive grouped by character, one per ranked key, or a single
+	// row with a zero key count for characters without keys.
+	var out []VaultRow
+	for _, row := range rows {
+		n := len(out)
+		if n == 0 || out[n-1].Character.Region != row.Region ||
+			out[n-1].Character.Realm != row.Realm || out[n-1].Character.Name != row.Name {
+			out = append(out, VaultRow{
+				Character: models.Character{
+					Region:   row.Region,
+					Realm:    row.Realm,
+					Name:     row.Name,
+					RIOScore: row.RioScore,
+				},
+				KeyCount: row.KeyCount,
+			})
+			n++
+		}
+		if row.KeyCount > 0 {
+			out[n-1].TopLevels = append(out[n-1].TopLevels, int(row.KeyLvl))
+		}
+	}
 
 	return out, nil
 }
@@ -403,7 +796,7 @@ func (s *SQLiteStore) ListKeysSince(ctx context.Context, cutoff time.Time) ([]mo
 	}
 
 	queries := db.New(s.db)
-	rows, err := queries.ListKeysSince(ctx, cutoff.Format(time.RFC3339))
+	rows, err := queries.ListKeysSince(ctx, formatCutoff(cutoff))
 	if err != nil {
 		return nil, err
 	}
@@ -435,7 +828,7 @@ func (s *SQLiteStore) ListUnlinkedKeysSince(ctx context.Context, cutoff time.Tim
 	}
 
 	queries := db.New(s.db)
-	rows, err := queries.ListUnlinkedKeysSince(ctx, cutoff.Format(time.RFC3339))
+	rows, err := queries.ListUnlinkedKeysSince(ctx, formatCutoff(cutoff))
 	if err != nil {
 		return nil, err
 	}
@@ -554,6 +947,73 @@ func (s *SQLiteStore) GetCharacter(ctx context.Context, name, realm, region stri
 	}, nil
 }
 
+// AddCharacter starts tracking char with its RaiderIO score. It reports
+// false, leaving the stored character untouched, if it is already tracked.
+func (s *SQLiteStore) AddCharacter(ctx context.Context, char models.Character) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return false, errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	queries := db.New(tx)
+	_, err = queries.GetCharacter(ctx, db.GetCharacterParams{
+		LOWER:   char.Name,
+		LOWER_2: char.Realm,
+		LOWER_3: char.Region,
+	})
+	if err == nil {
+		return false, tx.Rollback()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	char.Region = strings.ToLower(char.Region)
+	char.Realm = strings.ToLower(char.Realm)
+	char.Name = strings.ToLower(char.Name)
+	if _, err := queries.UpsertCharacter(ctx, db.UpsertCharacterParams{
+		Region: char.Region,
+		Realm:  char.Realm,
+		Name:   char.Name,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := queries.ClearPurgedCharacter(ctx, db.ClearPurgedCharacterParams{
+		Region: char.Region,
+		Realm:  char.Realm,
+		Name:   char.Name,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := queries.UpdateCharacterScore(ctx, db.UpdateCharacterScoreParams{
+		RioScore: char.RIOScore,
+		LOWER:    char.Name,
+		LOWER_2:  char.Realm,
+		LOWER_3:  char.Region,
+	}); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	s.scheduleFlush()
+	s.events.publish(CharacterAdded{eventTime: s.eventNow(), Character: char})
+	return true, nil
+}
+
 func (s *SQLiteStore) UpdateCharacterScore(ctx context.Context, name, realm, region string, score float64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -563,6 +1023,21 @@ func (s *SQLiteStore) UpdateCharacterScore(ctx context.Context, name, realm, reg
 	}
 
 	queries := db.New(s.db)
+	char, err := queries.GetCharacter(ctx, db.GetCharacterParams{
+		LOWER:   name,
+		LOWER_2: realm,
+		LOWER_3: region,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if char.RioScore == score {
+		return nil
+	}
+
 	if err := queries.UpdateCharacterScore(ctx, db.UpdateCharacterScoreParams{
 		RioScore: score,
 		LOWER:    name,
@@ -573,6 +1048,12 @@ func (s *SQLiteStore) UpdateCharacterScore(ctx context.Context, name, realm, reg
 	}
 
 	s.scheduleFlush()
+	s.events.publish(ScoreChanged{eventTime: s.eventNow(), OldScore: char.RioScore, Character: models.Character{
+		Region:   char.Region,
+		Realm:    char.Realm,
+		Name:     char.Name,
+		RIOScore: score,
+	}})
 	return nil
 }
 
@@ -591,8 +1072,7 @@ func (s *SQLiteStore) DeleteCharacter(ctx context.Context, name, realm, region s
 
 	queries := db.New(tx)
 
-	// Get character ID
-	charID, err := queries.GetCharacterID(ctx, db.GetCharacterIDParams{
+	char, err := queries.GetCharacter(ctx, db.GetCharacterParams{
 		LOWER:   name,
 		LOWER_2: realm,
 		LOWER_3: region,
@@ -601,6 +1081,7 @@ func (s *SQLiteStore) DeleteCharacter(ctx context.Context, name, realm, region s
 		_ = tx.Rollback()
 		return fmt.Errorf("character not found: %s-%s (%s)", name, realm, region)
 	}
+	charID := char.ID
 
 	// Delete WCL links for this character's keys
 	if err := queries.DeleteWarcraftLogsLinksByCharacter(ctx, charID); err != nil {
@@ -620,12 +1101,86 @@ func (s *SQLiteStore) DeleteCharacter(ctx context.Context, name, realm, region s
 		return err
 	}
 
+	// Remember the purge so recovery does not restore it from an archive
+	if err := queries.RecordPurgedCharacter(ctx, db.RecordPurgedCharacterParams{
+		Region: char.Region,
+		Realm:  char.Realm,
+		Name:   char.Name,
+	}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 
 	// Schedule debounced flush instead of immediate flush
 	s.scheduleFlush()
+	s.events.publish(CharacterDeleted{eventTime: s.eventNow(), Character: models.Character{
+		Region:   char.Region,
+		Realm:    char.Realm,
+		Name:     char.Name,
+		RIOScore: char.RioScore,
+	}})
+	return nil
+}
+
+// DeleteCompletedKey removes a single key from a character. WarcraftLogs
+// links for the key are removed once no other character shares it.
+func (s *SQLiteStore) DeleteCompletedKey(ctx context.Context, name, realm, region string, keyID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return errors.New("store is not open")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	queries := db.New(tx)
+
+	char, err := queries.GetCharacter(ctx, db.GetCharacterParams{
+		LOWER:   name,
+		LOWER_2: realm,
+		LOWER_3: region,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("character not found: %s-%s (%s)", name, realm, region)
+	}
+
+	deleted, err := queries.DeleteCompletedKey(ctx, db.DeleteCompletedKeyParams{
+		KeyID:       keyID,
+		CharacterID: char.ID,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if deleted == 0 {
+		return tx.Rollback()
+	}
+
+	if err := queries.DeleteOrphanedWarcraftLogsLinks(ctx, keyID); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.scheduleFlush()
+	s.events.publish(KeyDeleted{eventTime: s.eventNow(), KeyID: keyID, Character: models.Character{
+		Region:   char.Region,
+		Realm:    char.Realm,
+		Name:     char.Name,
+		RIOScore: char.RioScore,
+	}})
 	return nil
 }
 
@@ -733,7 +1288,7 @@ func (s *SQLiteStore) applyMigrations(ctx context.Context) error {
 		return errors.New("store is not open")
 	}
 
-	migrationsPath, err := resolveMigrationsPath()
+	migrationsPath, err := resolveMigrationsPath(filepath.Join("schema", "migrations"))
 	if err != nil {
 		return err
 	}
@@ -776,10 +1331,13 @@ func (s *SQLiteStore) applyMigrations(ctx context.Context) error {
 	return nil
 }
 
-func resolveMigrationsPath() (string, error) {
+// resolveMigrationsPath finds dir, given relative to the store package, from
+// the package, the repository root or another top-level package's tests.
+func resolveMigrationsPath(dir string) (string, error) {
 	paths := []string{
-		filepath.Join("schema", "migrations"),
-		filepath.Join("store", "schema", "migrations"),
+		dir,
+		filepath.Join("store", dir),
+		filepath.Join("..", "store", dir),
 	}
 	for _, path := range paths {
 		if info, err := os.Stat(path); err == nil && info.IsDir() {
@@ -792,17 +1350,3 @@ func resolveMigrationsPath() (string, error) {
 func sqliteFileDSN(path string) string {
 	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
 }
-
-func syntheticKeyID(key models.CompletedKey) int64 {
-	synthetic := key.SyntheticKey()
-	raw, err := hex.DecodeString(synthetic)
-	if err != nil || len(raw) < 8 {
-		sum := sha256.Sum256([]byte(synthetic))
-		raw = sum[:]
-	}
-	value := int64(binary.BigEndian.Uint64(raw[:8]) & 0x7FFFFFFFFFFFFFFF)
-	if value == 0 {
-		return 1
-	}
-	return value
-}
@@ -14,6 +14,15 @@ type CountRow struct {
 	KeyCount int64
 }
 
+// VaultRow summarizes one character's keys since a cutoff for the vault
+// report. TopLevels holds the highest key levels, best first, up to the
+// eighth vault slot.
+type VaultRow struct {
+	Character models.Character
+	KeyCount  int64
+	TopLevels []int
+}
+
 type WarcraftLogsLink struct {
 	KeyID      int64
 	ReportCode string
@@ -31,18 +40,62 @@ type ElvUIVersion struct {
 	CheckedAt    string
 }
 
+// UpsertStatus is what an upsert did with a single key.
+type UpsertStatus int
+
+const (
+	// UpsertUnchanged means the key was already stored with the same details.
+	UpsertUnchanged UpsertStatus = iota
+	// UpsertInserted means the key was new for its character.
+	UpsertInserted
+	// UpsertUpdated means a stored key's details were refreshed.
+	UpsertUpdated
+	// UpsertReplaced means the key took the place of a less trusted key
+	// for the same run from another source.
+	UpsertReplaced
+)
+
+func (s UpsertStatus) String() string {
+	switch s {
+	case UpsertInserted:
+		return "inserted"
+	case UpsertUpdated:
+		return "updated"
+	case UpsertReplaced:
+		return "replaced"
+	default:
+		return "unchanged"
+	}
+}
+
 type Store interface {
 	Open(ctx context.Context) error
 	Close() error
+	// Shutdown writes out anything not yet persisted and closes the store.
+	Shutdown(ctx context.Context) error
 
+	// RestoreSnapshot loads the store's local snapshot after opening it,
+	// falling back to backups if it is unusable.
+	RestoreSnapshot(ctx context.Context) (RestoreResult, error)
 	RestoreFromDisk(ctx context.Context, path string) error
 	FlushToDisk(ctx context.Context, path string) error
 	ArchiveWeek(ctx context.Context) error
+	IndexHistory(ctx context.Context, now time.Time) (int, error)
+	ListBackups(ctx context.Context) ([]BackupInfo, error)
+	VerifyBackups(ctx context.Context) ([]BackupInfo, error)
+	Vacuum(ctx context.Context) error
 
 	UpsertCompletedKey(ctx context.Context, key models.CompletedKey) error
+	UpsertCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]UpsertStatus, error)
+	// ReconcileCompletedKeys writes keys in one transaction, replacing or
+	// dropping each where another source already stored the same run.
+	ReconcileCompletedKeys(ctx context.Context, keys []models.CompletedKey) ([]UpsertStatus, error)
+	ReplaceCompletedKey(ctx context.Context, oldKeyID int64, key models.CompletedKey) error
 	UpsertWarcraftLogsLink(ctx context.Context, link WarcraftLogsLink) error
+	AddCharacter(ctx context.Context, char models.Character) (bool, error)
 	UpdateCharacterScore(ctx context.Context, name, realm, region string, score float64) error
 	DeleteCharacter(ctx context.Context, name, realm, region string) error
+	DeleteCompletedKey(ctx context.Context, name, realm, region string, keyID int64) error
 
 	UpsertElvUIVersion(ctx context.Context, v ElvUIVersion) error
 	GetElvUIVersion(ctx context.Context) (*ElvUIVersion, error)
@@ -50,8 +103,19 @@ type Store interface {
 	ListCharacters(ctx context.Context) ([]models.Character, error)
 	GetCharacter(ctx context.Context, name, realm, region string) (*models.Character, error)
 	CountKeysByCharacterSince(ctx context.Context, cutoff time.Time) ([]CountRow, error)
-	ListKeysByCharacterSince(ctx context.Context, character string, cutoff time.Time) ([]models.CompletedKey, error)
+	ListKeysByCharacterSince(ctx context.Context, name, realm, region string, cutoff time.Time) ([]models.CompletedKey, error)
 	ListKeysSince(ctx context.Context, cutoff time.Time) ([]models.CompletedKey, error)
+	ListVaultProgressSince(ctx context.Context, cutoff time.Time) ([]VaultRow, error)
+	ListWeeklyHistory(ctx context.Context, name, realm, region string, weeks int) ([]WeekSummary, error)
 	ListUnlinkedKeysSince(ctx context.Context, cutoff time.Time) ([]models.CompletedKey, error)
 	ListWarcraftLogsLinksForKey(ctx context.Context, keyID int64) ([]WarcraftLogsLink, error)
+
+	Outbox
+}
+
+// formatCutoff formats a cutoff for comparison with completed_at, which
+// holds RFC 3339 text in UTC. Comparing against another zone's offset would
+// shift the cutoff by that offset.
+func formatCutoff(cutoff time.Time) string {
+	return cutoff.UTC().Format(time.RFC3339)
 }
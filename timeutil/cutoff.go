@@ -3,6 +3,8 @@ package timeutil
 import (
 	"fmt"
 	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
 )
 
 const weeklyResetLocation = "America/Los_Angeles"
@@ -18,8 +20,9 @@ func Location() *time.Location {
 // WeeklyResetHour is the hour when WoW weekly reset occurs (7am PST).
 const WeeklyResetHour = 7
 
-func WeeklyReset() time.Time {
-	return WeeklyResetAt(time.Now())
+// WeeklyReset returns the most recent weekly reset as of c's current time.
+func WeeklyReset(c clock.Clock) time.Time {
+	return WeeklyResetAt(c.Now())
 }
 
 func WeeklyResetAt(now time.Time) time.Time {
@@ -38,8 +41,8 @@ func WeeklyResetIn(now time.Time, loc *time.Location) time.Time {
 }
 
 // LastTuesday9AM is deprecated. Use WeeklyReset instead.
-func LastTuesday9AM() time.Time {
-	return WeeklyReset()
+func LastTuesday9AM(c clock.Clock) time.Time {
+	return WeeklyReset(c)
 }
 
 // LastTuesday9AMAt is deprecated. Use WeeklyResetAt instead.
@@ -62,8 +65,8 @@ func ParseRFC3339(value string) (time.Time, error) {
 	return time.Parse(time.RFC3339, value)
 }
 
-func WithinLastHour(t time.Time) bool {
-	return WithinLastHourAt(t, time.Now())
+func WithinLastHour(t time.Time, c clock.Clock) bool {
+	return WithinLastHourAt(t, c.Now())
 }
 
 func WithinLastHourAt(t time.Time, now time.Time) bool {
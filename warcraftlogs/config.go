@@ -1,7 +1,9 @@
 package warcraftlogs
 
+import "github.com/tnicklin/celestial_orrey/secret"
+
 // Config holds WarcraftLogs client configuration.
 type Config struct {
-	ClientID     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
+	ClientID     string        `yaml:"client_id"`
+	ClientSecret secret.String `yaml:"client_secret"`
 }
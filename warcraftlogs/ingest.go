@@ -0,0 +1,154 @@
+package warcraftlogs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/reconcile"
+	"github.com/tnicklin/celestial_orrey/store"
+	"github.com/tnicklin/celestial_orrey/timeutil"
+)
+
+// IngestMissingRuns stores timed M+ runs found on WarcraftLogs for char that
+// have no matching key in the store. Ingested keys use synthetic IDs and are
+// linked to the fight they came from. It returns the number of keys stored,
+// and any failures to store or link a run joined into one error.
+func (l *Linker) IngestMissingRuns(ctx context.Context, char models.Character, since time.Time) (int, error) {
+	if l.Store == nil || l.Client == nil {
+		return 0, errors.New("warcraftlogs: store and client are required")
+	}
+
+	runs, err := l.Client.FetchCharacterMythicPlus(ctx, char, 20)
+	if err != nil {
+		return 0, err
+	}
+
+	existing, err := l.characterKeysSince(ctx, char, since)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	ingested := 0
+	for _, run := range runs {
+		if !isTimed(run) || !run.CompletedAt.After(since) {
+			continue
+		}
+		if runCovered(run, existing, l.DungeonMatch) {
+			continue
+		}
+
+		key := runToKey(char, run)
+		statuses, err := l.ReconcileKeys(ctx, []models.CompletedKey{key})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("store %s run from %s: %w", run.Dungeon, run.ReportCode, err))
+			continue
+		}
+		existing = append(existing, key)
+		if statuses[0] == store.UpsertUnchanged {
+			// Another source already has the run.
+			continue
+		}
+
+		fightID := int64(run.FightID)
+		if err := l.Store.UpsertWarcraftLogsLink(ctx, store.WarcraftLogsLink{
+			KeyID:      key.EffectiveID(),
+			ReportCode: run.ReportCode,
+			FightID:    &fightID,
+			URL:        BuildMythicPlusURL(run),
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("link %s run to %s: %w", run.Dungeon, run.ReportCode, err))
+		}
+
+		if l.Logger != nil {
+			l.Logger.InfoW("ingested key from warcraftlogs",
+				"character", char.Key(),
+				"dungeon", run.Dungeon,
+				"level", run.KeystoneLevel,
+				"report", run.ReportCode,
+			)
+		}
+		ingested++
+	}
+
+	return ingested, errors.Join(errs...)
+}
+
+// Reconcile stores key, resolving overlap with keys from other sources that
+// describe the same run. Keys from lower-priority sources (see
+// models.SourcePriority) are replaced by key, carrying their links over; if a
+// key of equal or higher priority already covers the run, key is dropped.
+// Level and run time mismatches between the two are logged as conflicts.
+func (l *Linker) Reconcile(ctx context.Context, key models.CompletedKey) error {
+	_, err := l.ReconcileKeys(ctx, []models.CompletedKey{key})
+	return err
+}
+
+// ReconcileKeys reconciles keys like Reconcile in a single store
+// transaction. The returned statuses are in the order of keys; replaced keys
+// report UpsertReplaced and dropped ones UpsertUnchanged.
+func (l *Linker) ReconcileKeys(ctx context.Context, keys []models.CompletedKey) ([]store.UpsertStatus, error) {
+	if l.Store == nil {
+		return nil, errors.New("warcraftlogs: store is required")
+	}
+	return l.Store.ReconcileCompletedKeys(ctx, keys)
+}
+
+// characterKeysSince lists stored keys belonging to exactly char.
+func (l *Linker) characterKeysSince(ctx context.Context, char models.Character, since time.Time) ([]models.CompletedKey, error) {
+	return l.Store.ListKeysByCharacterSince(ctx, char.Name, char.Realm, char.Region, since)
+}
+
+// isTimed reports whether a WarcraftLogs run finished within the timer.
+func isTimed(run MythicPlusRun) bool {
+	return run.KeystoneLevel > 0 && run.KeystoneBonus > 0
+}
+
+// runCovered reports whether any stored key already accounts for run. Manual
+// keys never cover a run; Reconcile replaces them instead.
+func runCovered(run MythicPlusRun, keys []models.CompletedKey, matchFn func(string, string) bool) bool {
+	if matchFn == nil {
+		matchFn = defaultDungeonMatch
+	}
+	for _, key := range keys {
+		if key.Source == models.SourceManual {
+			continue
+		}
+		if !matchFn(key.Dungeon, run.Dungeon) {
+			continue
+		}
+		keyTime, err := timeutil.ParseRFC3339(key.CompletedAt)
+		if err != nil {
+			continue
+		}
+		if absDuration(keyTime.Sub(run.CompletedAt)) <= reconcile.Window {
+			return true
+		}
+	}
+	return false
+}
+
+// runToKey converts a WarcraftLogs run into a completed key without a key ID.
+func runToKey(char models.Character, run MythicPlusRun) models.CompletedKey {
+	return models.CompletedKey{
+		Character:   strings.ToLower(char.Name),
+		Region:      strings.ToLower(char.Region),
+		Realm:       strings.ToLower(char.Realm),
+		Dungeon:     run.Dungeon,
+		KeyLevel:    run.KeystoneLevel,
+		RunTimeMS:   run.KeystoneTime,
+		CompletedAt: run.CompletedAt.UTC().Format(time.RFC3339),
+		Source:      models.SourceWarcraftLogs,
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
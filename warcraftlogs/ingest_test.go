@@ -0,0 +1,300 @@
+package warcraftlogs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/store"
+)
+
+func newTestStore(t *testing.T) *store.SQLiteStore {
+	t.Helper()
+	st := store.NewSQLiteStore(store.Params{})
+	if err := st.Open(context.Background()); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	return st
+}
+
+// fakeWCL serves fixed M+ runs and counts the fetches.
+type fakeWCL struct {
+	runs    []MythicPlusRun
+	fetches int
+}
+
+func (f *fakeWCL) Query(context.Context, string, map[string]any) (json.RawMessage, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeWCL) FetchReports(context.Context, ReportFilter) ([]ReportSummary, error) {
+	return nil, nil
+}
+
+func (f *fakeWCL) FetchCharacterMythicPlus(context.Context, models.Character, int) ([]MythicPlusRun, error) {
+	f.fetches++
+	return f.runs, nil
+}
+
+// linkFailingStore fails every WarcraftLogs link write.
+type linkFailingStore struct {
+	store.Store
+}
+
+func (linkFailingStore) UpsertWarcraftLogsLink(context.Context, store.WarcraftLogsLink) error {
+	return errors.New("disk full")
+}
+
+func storedKeys(t *testing.T, st store.Store) []models.CompletedKey {
+	t.Helper()
+	keys, err := st.ListKeysByCharacterSince(context.Background(), "arthas", "illidan", "us", time.Time{})
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	return keys
+}
+
+func TestIsTimed(t *testing.T) {
+	tests := []struct {
+		name string
+		run  MythicPlusRun
+		want bool
+	}{
+		{
+			name: "timed with bonus",
+			run:  MythicPlusRun{KeystoneLevel: 10, KeystoneBonus: 1, Kill: true},
+			want: true,
+		},
+		{
+			name: "depleted",
+			run:  MythicPlusRun{KeystoneLevel: 10, KeystoneBonus: 0, Kill: true},
+			want: false,
+		},
+		{
+			name: "not a keystone",
+			run:  MythicPlusRun{KeystoneLevel: 0, KeystoneBonus: 1},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTimed(tt.run); got != tt.want {
+				t.Errorf("isTimed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCovered(t *testing.T) {
+	completed := time.Date(2026, 2, 4, 1, 23, 45, 0, time.UTC)
+	run := MythicPlusRun{
+		Dungeon:       "Mists of Tirna Scithe",
+		KeystoneLevel: 10,
+		KeystoneBonus: 1,
+		CompletedAt:   completed,
+	}
+
+	tests := []struct {
+		name string
+		keys []models.CompletedKey
+		want bool
+	}{
+		{
+			name: "no keys",
+			want: false,
+		},
+		{
+			name: "same dungeon within window",
+			keys: []models.CompletedKey{
+				{Dungeon: "Mists of Tirna Scithe", CompletedAt: completed.Add(2 * time.Minute).Format(time.RFC3339)},
+			},
+			want: true,
+		},
+		{
+			name: "same dungeon outside window",
+			keys: []models.CompletedKey{
+				{Dungeon: "Mists of Tirna Scithe", CompletedAt: completed.Add(2 * time.Hour).Format(time.RFC3339)},
+			},
+			want: false,
+		},
+		{
+			name: "different dungeon",
+			keys: []models.CompletedKey{
+				{Dungeon: "The Necrotic Wake", CompletedAt: completed.Format(time.RFC3339)},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runCovered(run, tt.keys, nil); got != tt.want {
+				t.Errorf("runCovered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunToKey(t *testing.T) {
+	char := models.Character{Name: "Arthas", Realm: "Illidan", Region: "US"}
+	run := MythicPlusRun{
+		ReportCode:    "ABC123",
+		FightID:       5,
+		Dungeon:       "Mists of Tirna Scithe",
+		KeystoneLevel: 12,
+		KeystoneTime:  1320000,
+		KeystoneBonus: 1,
+		CompletedAt:   time.Date(2026, 2, 4, 1, 23, 45, 0, time.FixedZone("PST", -8*3600)),
+	}
+
+	key := runToKey(char, run)
+	if key.KeyID != 0 {
+		t.Errorf("expected no key ID, got %d", key.KeyID)
+	}
+	if key.Source != models.SourceWarcraftLogs {
+		t.Errorf("expected source %q, got %q", models.SourceWarcraftLogs, key.Source)
+	}
+	if key.Character != "arthas" || key.Realm != "illidan" || key.Region != "us" {
+		t.Errorf("expected lowercased identity, got %s-%s (%s)", key.Character, key.Realm, key.Region)
+	}
+	if key.CompletedAt != "2026-02-04T09:23:45Z" {
+		t.Errorf("expected UTC completion time, got %s", key.CompletedAt)
+	}
+	if key.KeyLevel != 12 || key.RunTimeMS != 1320000 {
+		t.Errorf("unexpected level/time: %d/%d", key.KeyLevel, key.RunTimeMS)
+	}
+}
+
+func TestReconcileBlizzardAndRaiderIO(t *testing.T) {
+	ctx := context.Background()
+	completed := time.Date(2026, 2, 4, 1, 23, 45, 0, time.UTC)
+	rio := models.CompletedKey{
+		KeyID:       9001,
+		Character:   "arthas",
+		Realm:       "illidan",
+		Region:      "us",
+		Dungeon:     "Mists of Tirna Scithe",
+		KeyLevel:    12,
+		RunTimeMS:   1500000,
+		CompletedAt: completed.Format(time.RFC3339),
+		Source:      models.SourceRaiderIO,
+	}
+	blizzard := rio
+	blizzard.KeyID = 0
+	blizzard.Source = models.SourceBlizzard
+	blizzard.CompletedAt = completed.Add(40 * time.Second).Format(time.RFC3339)
+
+	t.Run("blizzard after raiderio is dropped", func(t *testing.T) {
+		st := newTestStore(t)
+		linker := &Linker{Store: st}
+		if err := linker.Reconcile(ctx, rio); err != nil {
+			t.Fatalf("reconcile raiderio: %v", err)
+		}
+		if err := linker.Reconcile(ctx, blizzard); err != nil {
+			t.Fatalf("reconcile blizzard: %v", err)
+		}
+		keys := storedKeys(t, st)
+		if len(keys) != 1 || keys[0].Source != models.SourceRaiderIO {
+			t.Fatalf("expected only the raiderio key, got %#v", keys)
+		}
+	})
+
+	t.Run("raiderio replaces blizzard", func(t *testing.T) {
+		st := newTestStore(t)
+		linker := &Linker{Store: st}
+		if err := linker.Reconcile(ctx, blizzard); err != nil {
+			t.Fatalf("reconcile blizzard: %v", err)
+		}
+		if err := linker.Reconcile(ctx, rio); err != nil {
+			t.Fatalf("reconcile raiderio: %v", err)
+		}
+		keys := storedKeys(t, st)
+		if len(keys) != 1 || keys[0].KeyID != rio.KeyID || keys[0].Source != models.SourceRaiderIO {
+			t.Fatalf("expected the raiderio key to replace the blizzard one, got %#v", keys)
+		}
+	})
+}
+
+func TestIngestMissingRuns(t *testing.T) {
+	ctx := context.Background()
+	char := models.Character{Name: "arthas", Realm: "illidan", Region: "us"}
+	since := time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 2, 4, 1, 0, 0, 0, time.UTC)
+
+	client := &fakeWCL{runs: []MythicPlusRun{
+		// Covered by the raiderio key below.
+		{ReportCode: "AAA", FightID: 1, Dungeon: "Mists of Tirna Scithe", KeystoneLevel: 12, KeystoneBonus: 1, CompletedAt: completed},
+		// Missing from every other source.
+		{ReportCode: "BBB", FightID: 4, Dungeon: "The Necrotic Wake", KeystoneLevel: 11, KeystoneBonus: 2, CompletedAt: completed.Add(time.Hour)},
+		// Depleted.
+		{ReportCode: "CCC", FightID: 7, Dungeon: "Halls of Atonement", KeystoneLevel: 10, CompletedAt: completed.Add(2 * time.Hour)},
+		// Before the reset.
+		{ReportCode: "DDD", FightID: 2, Dungeon: "Halls of Atonement", KeystoneLevel: 10, KeystoneBonus: 1, CompletedAt: since.Add(-time.Hour)},
+	}}
+
+	st := newTestStore(t)
+	if err := st.UpsertCompletedKey(ctx, models.CompletedKey{
+		KeyID:       9001,
+		Character:   "arthas",
+		Realm:       "illidan",
+		Region:      "us",
+		Dungeon:     "Mists of Tirna Scithe",
+		KeyLevel:    12,
+		CompletedAt: completed.Add(time.Minute).Format(time.RFC3339),
+		Source:      models.SourceRaiderIO,
+	}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	linker := &Linker{Store: st, Client: client}
+	n, err := linker.IngestMissingRuns(ctx, char, since)
+	if err != nil || n != 1 {
+		t.Fatalf("ingest: n=%d err=%v; want 1", n, err)
+	}
+	keys := storedKeys(t, st)
+	if len(keys) != 2 {
+		t.Fatalf("expected the raiderio key and one ingested key, got %#v", keys)
+	}
+	var ingested models.CompletedKey
+	for _, key := range keys {
+		if key.Source == models.SourceWarcraftLogs {
+			ingested = key
+		}
+	}
+	if ingested.Dungeon != "The Necrotic Wake" {
+		t.Fatalf("expected the Necrotic Wake run to be ingested, got %#v", keys)
+	}
+	links, err := st.ListWarcraftLogsLinksForKey(ctx, ingested.KeyID)
+	if err != nil || len(links) != 1 || links[0].ReportCode != "BBB" {
+		t.Fatalf("expected the ingested key to link its fight, got %#v (err %v)", links, err)
+	}
+
+	// A second pass finds nothing new.
+	if n, err := linker.IngestMissingRuns(ctx, char, since); err != nil || n != 0 {
+		t.Fatalf("second ingest: n=%d err=%v; want 0", n, err)
+	}
+}
+
+func TestIngestMissingRunsReturnsLinkErrors(t *testing.T) {
+	ctx := context.Background()
+	char := models.Character{Name: "arthas", Realm: "illidan", Region: "us"}
+	since := time.Date(2026, 2, 3, 15, 0, 0, 0, time.UTC)
+	client := &fakeWCL{runs: []MythicPlusRun{
+		{ReportCode: "BBB", FightID: 4, Dungeon: "The Necrotic Wake", KeystoneLevel: 11, KeystoneBonus: 2, CompletedAt: since.Add(10 * time.Hour)},
+	}}
+
+	linker := &Linker{Store: linkFailingStore{newTestStore(t)}, Client: client}
+	n, err := linker.IngestMissingRuns(ctx, char, since)
+	if n != 1 {
+		t.Fatalf("expected the key to be stored despite the link failure, got %d", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("expected the link error to be returned, got %v", err)
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"errors"
 	"math"
-	"strings"
 	"time"
-	"unicode"
 
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/logger"
 	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/reconcile"
 	"github.com/tnicklin/celestial_orrey/store"
 	"github.com/tnicklin/celestial_orrey/timeutil"
 )
@@ -22,6 +23,7 @@ type Linker struct {
 	PreBuffer    time.Duration
 	PostBuffer   time.Duration
 	DungeonMatch func(dungeon, zone string) bool
+	Logger       logger.Logger
 }
 
 // LinkerParams holds configuration for creating a new Linker.
@@ -29,15 +31,24 @@ type LinkerParams struct {
 	Store  store.Store
 	Client WCL
 	Filter ReportFilter
+	Logger logger.Logger
+	Clock  clock.Clock
 }
 
 // NewLinker creates a new Linker with the given parameters.
 func NewLinker(p LinkerParams) *Linker {
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
+	now := clk.Now()
+
 	return &Linker{
 		Store:       p.Store,
 		Client:      p.Client,
 		Filter:      p.Filter,
-		MatchWindow: time.Since(timeutil.WeeklyReset()) + 24*time.Hour,
+		Logger:      p.Logger,
+		MatchWindow: now.Sub(timeutil.WeeklyResetAt(now)) + 24*time.Hour,
 		PreBuffer:   15 * time.Minute,
 		PostBuffer:  30 * time.Minute,
 		DungeonMatch: func(dungeon, zone string) bool {
@@ -160,22 +171,7 @@ func bestReportMatch(key models.CompletedKey, reports []ReportSummary, matchFn f
 }
 
 func defaultDungeonMatch(dungeon, zone string) bool {
-	a := normalizeName(dungeon)
-	b := normalizeName(zone)
-	if a == "" || b == "" {
-		return false
-	}
-	return a == b || strings.Contains(a, b) || strings.Contains(b, a)
-}
-
-func normalizeName(value string) string {
-	var b strings.Builder
-	for _, r := range strings.ToLower(value) {
-		if unicode.IsLetter(r) || unicode.IsNumber(r) {
-			b.WriteRune(r)
-		}
-	}
-	return b.String()
+	return reconcile.SameDungeon(dungeon, zone)
 }
 
 // MatchKey attempts to find a WarcraftLogs M+ run that matches a RaiderIO completed key.
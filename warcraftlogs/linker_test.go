@@ -7,64 +7,6 @@ import (
 	"github.com/tnicklin/celestial_orrey/models"
 )
 
-func TestNormalizeName(t *testing.T) {
-	tests := []struct {
-		name  string
-		input string
-		want  string
-	}{
-		{
-			name:  "lowercase letters only",
-			input: "hello",
-			want:  "hello",
-		},
-		{
-			name:  "mixed case",
-			input: "HelloWorld",
-			want:  "helloworld",
-		},
-		{
-			name:  "with spaces",
-			input: "Hello World",
-			want:  "helloworld",
-		},
-		{
-			name:  "with numbers",
-			input: "Test123",
-			want:  "test123",
-		},
-		{
-			name:  "with special characters",
-			input: "Mists of Tirna Scithe",
-			want:  "mistsoftirnascithe",
-		},
-		{
-			name:  "with apostrophes",
-			input: "Ara-Kara, City of Echoes",
-			want:  "arakaracityofechoes",
-		},
-		{
-			name:  "empty string",
-			input: "",
-			want:  "",
-		},
-		{
-			name:  "only special characters",
-			input: "!@#$%",
-			want:  "",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := normalizeName(tt.input)
-			if got != tt.want {
-				t.Errorf("normalizeName(%q) = %q, want %q", tt.input, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestDefaultDungeonMatch(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -3,9 +3,12 @@ package warcraftlogs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/jobs"
+	"github.com/tnicklin/celestial_orrey/logger"
 	"github.com/tnicklin/celestial_orrey/store"
 	"github.com/tnicklin/celestial_orrey/timeutil"
 )
@@ -14,28 +17,38 @@ var _ Poller = (*DefaultPoller)(nil)
 
 // Poller is the interface for the background WCL linking poller.
 type Poller interface {
-	Start(ctx context.Context) error
-	Stop()
+	Job() jobs.Job
 }
 
 // DefaultPoller polls for unlinked keys and attempts to link them to WarcraftLogs.
+// It also ingests timed runs that WarcraftLogs saw but RaiderIO has not reported.
 type DefaultPoller struct {
-	store       store.Store
-	client      WCL
-	clock       clock.Clock
-	interval    time.Duration
-	matchWindow time.Duration
-	stop        chan struct{}
-	done        chan struct{}
+	store          store.Store
+	client         WCL
+	clock          clock.Clock
+	logger         logger.Logger
+	interval       time.Duration
+	ingestInterval time.Duration
+	matchWindow    time.Duration
+
+	// lastIngest is when missing runs were last fetched. Only the job
+	// touches it, and the runner never overlaps runs of one job.
+	lastIngest time.Time
 }
 
 // PollerParams holds configuration for creating a new WCL Poller.
 type PollerParams struct {
-	Store       store.Store
-	Client      WCL
-	Clock       clock.Clock
-	Interval    time.Duration
-	MatchWindow time.Duration
+	Store    store.Store
+	Client   WCL
+	Clock    clock.Clock
+	Logger   logger.Logger
+	Interval time.Duration
+	// IngestInterval is how often every character's WarcraftLogs runs are
+	// fetched to find runs no other source reported. That costs one API
+	// call per character, so it runs less often than linking. Defaults to
+	// an hour.
+	IngestInterval time.Duration
+	MatchWindow    time.Duration
 }
 
 // NewPoller creates a new WCL background linker poller.
@@ -45,6 +58,11 @@ func NewPoller(p PollerParams) *DefaultPoller {
 		interval = 5 * time.Minute
 	}
 
+	ingestInterval := p.IngestInterval
+	if ingestInterval <= 0 {
+		ingestInterval = time.Hour
+	}
+
 	matchWindow := p.MatchWindow
 	if matchWindow <= 0 {
 		matchWindow = 24 * time.Hour
@@ -56,16 +74,30 @@ func NewPoller(p PollerParams) *DefaultPoller {
 	}
 
 	return &DefaultPoller{
-		store:       p.Store,
-		client:      p.Client,
-		clock:       clk,
-		interval:    interval,
-		matchWindow: matchWindow,
+		store:          p.Store,
+		client:         p.Client,
+		clock:          clk,
+		logger:         p.Logger,
+		interval:       interval,
+		ingestInterval: ingestInterval,
+		matchWindow:    matchWindow,
+	}
+}
+
+// JobName names the poller's job in the job runner.
+const JobName = "warcraftlogs"
+
+// Job returns the poller's job, which links this week's unlinked keys and,
+// every IngestInterval, ingests runs only WarcraftLogs has seen.
+func (p *DefaultPoller) Job() jobs.Job {
+	return jobs.Job{
+		Name:     JobName,
+		Interval: p.interval,
+		Run:      p.pollOnce,
 	}
 }
 
-// Start begins the background polling loop.
-func (p *DefaultPoller) Start(ctx context.Context) error {
+func (p *DefaultPoller) pollOnce(ctx context.Context) error {
 	if p.store == nil {
 		return errors.New("warcraftlogs poller: store is required")
 	}
@@ -73,59 +105,48 @@ func (p *DefaultPoller) Start(ctx context.Context) error {
 		return errors.New("warcraftlogs poller: client is required")
 	}
 
-	p.stop = make(chan struct{})
-	p.done = make(chan struct{})
-
-	go p.run(ctx)
-	return nil
-}
-
-// Stop stops the background polling loop.
-func (p *DefaultPoller) Stop() {
-	if p.stop != nil {
-		close(p.stop)
-		<-p.done
-	}
-}
-
-func (p *DefaultPoller) run(ctx context.Context) {
-	defer close(p.done)
-
-	ticker := time.NewTicker(p.interval)
-	defer ticker.Stop()
-
-	p.pollOnce(ctx)
-	for {
-		select {
-		case <-p.stop:
-			return
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			p.pollOnce(ctx)
-		}
-	}
-}
-
-func (p *DefaultPoller) pollOnce(ctx context.Context) {
 	now := p.clock.Now()
 	cutoff := timeutil.WeeklyResetAt(now)
 	matchWindow := now.Sub(cutoff) + 24*time.Hour
 
-	keys, err := p.store.ListUnlinkedKeysSince(ctx, cutoff)
-	if err != nil || len(keys) == 0 {
-		return
-	}
-
 	linker := NewLinker(LinkerParams{
 		Store:  p.store,
 		Client: p.client,
+		Logger: p.logger,
 	})
 	linker.MatchWindow = matchWindow
 
+	if err := p.linkUnlinked(ctx, linker, cutoff); err != nil {
+		return err
+	}
+	if !p.lastIngest.IsZero() && now.Sub(p.lastIngest) < p.ingestInterval {
+		return nil
+	}
+	p.lastIngest = now
+	return p.ingestMissing(ctx, linker, cutoff)
+}
+
+// linkUnlinked links this week's unlinked keys to their fights. It fails
+// when every lookup failed, as happens when the credentials are rejected.
+func (p *DefaultPoller) linkUnlinked(ctx context.Context, linker *Linker, cutoff time.Time) error {
+	keys, err := p.store.ListUnlinkedKeysSince(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("list unlinked keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var lastErr error
+	failed := 0
 	for _, key := range keys {
 		match, err := linker.MatchKey(ctx, key)
-		if err != nil || match == nil {
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		if match == nil {
 			continue
 		}
 
@@ -140,4 +161,25 @@ func (p *DefaultPoller) pollOnce(ctx context.Context) {
 		}
 		_ = p.store.UpsertWarcraftLogsLink(ctx, link)
 	}
+
+	if failed == len(keys) {
+		return fmt.Errorf("match keys: %w", lastErr)
+	}
+	return nil
+}
+
+// ingestMissing stores timed WarcraftLogs runs that no other source has
+// reported.
+func (p *DefaultPoller) ingestMissing(ctx context.Context, linker *Linker, cutoff time.Time) error {
+	characters, err := p.store.ListCharacters(ctx)
+	if err != nil {
+		return fmt.Errorf("list characters: %w", err)
+	}
+
+	for _, char := range characters {
+		if _, err := linker.IngestMissingRuns(ctx, char, cutoff); err != nil && p.logger != nil {
+			p.logger.WarnW("ingest warcraftlogs runs", "character", char.Key(), "error", err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,47 @@
+package warcraftlogs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/clock"
+	"github.com/tnicklin/celestial_orrey/models"
+)
+
+func TestPollerIngestsEveryIngestInterval(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 2, 4, 12, 0, 0, 0, time.UTC))
+	st := newTestStore(t)
+	if _, err := st.AddCharacter(ctx, models.Character{Name: "arthas", Realm: "illidan", Region: "us"}); err != nil {
+		t.Fatalf("add character: %v", err)
+	}
+
+	client := &fakeWCL{}
+	poller := NewPoller(PollerParams{
+		Store:          st,
+		Client:         client,
+		Clock:          clk,
+		Interval:       5 * time.Minute,
+		IngestInterval: time.Hour,
+	})
+
+	run := poller.Job().Run
+	for range 3 {
+		if err := run(ctx); err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+		clk.Advance(5 * time.Minute)
+	}
+	if client.fetches != 1 {
+		t.Fatalf("expected one ingest fetch within the hour, got %d", client.fetches)
+	}
+
+	clk.Advance(time.Hour)
+	if err := run(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if client.fetches != 2 {
+		t.Fatalf("expected another ingest fetch after an hour, got %d", client.fetches)
+	}
+}
@@ -0,0 +1,51 @@
+package warcraftlogs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tnicklin/celestial_orrey/models"
+	"github.com/tnicklin/celestial_orrey/replay"
+)
+
+// TestLinkerReplaysReports matches a key against a recorded WarcraftLogs
+// report holding a timed and an abandoned run.
+func TestLinkerReplaysReports(t *testing.T) {
+	httpClient, err := replay.Client(replay.Config{Mode: replay.ModeReplay, Dir: "testdata/replay"}, time.Second)
+	if err != nil {
+		t.Fatalf("replay client: %v", err)
+	}
+	linker := NewLinker(LinkerParams{
+		Client: New(Params{ClientID: "replay", ClientSecret: "replay", HTTPClient: httpClient}),
+	})
+	linker.MatchWindow = time.Hour
+
+	key := models.CompletedKey{
+		KeyID:       41234567,
+		Character:   "arthas",
+		Region:      "us",
+		Realm:       "illidan",
+		Dungeon:     "Ara-Kara, City of Echoes",
+		KeyLevel:    12,
+		RunTimeMS:   1702345,
+		ParTimeMS:   1800999,
+		CompletedAt: "2026-02-05T03:41:12.000Z",
+	}
+	match, err := linker.MatchKey(context.Background(), key)
+	if err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if match == nil {
+		t.Fatal("expected a match")
+	}
+	if match.Run.ReportCode != "aBcD1234eFgH5678" || match.Run.FightID != 3 {
+		t.Errorf("matched %+v", match.Run)
+	}
+
+	key.Dungeon = "Operation: Floodgate"
+	key.KeyLevel = 11
+	if match, err := linker.MatchKey(context.Background(), key); err != nil || match != nil {
+		t.Errorf("abandoned run matched: %+v, %v", match, err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/tnicklin/celestial_orrey/clock"
 	"github.com/tnicklin/celestial_orrey/models"
 )
 
@@ -31,6 +32,7 @@ type DefaultWCL struct {
 	clientID   string
 	secret     string
 	http       *http.Client
+	clock      clock.Clock
 
 	mu          sync.Mutex
 	token       string
@@ -45,6 +47,8 @@ type Params struct {
 	TokenURL     string
 	UserAgent    string
 	HTTPClient   *http.Client
+	// Clock times token expiry. Nil uses the system clock.
+	Clock clock.Clock
 }
 
 // New creates a new DefaultWCL with the given parameters.
@@ -61,6 +65,10 @@ func New(p Params) *DefaultWCL {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: 15 * time.Second}
 	}
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.System()
+	}
 
 	return &DefaultWCL{
 		graphQLURL: graphQLURL,
@@ -69,6 +77,7 @@ func New(p Params) *DefaultWCL {
 		clientID:   p.ClientID,
 		secret:     p.ClientSecret,
 		http:       httpClient,
+		clock:      clk,
 	}
 }
 
@@ -135,7 +144,7 @@ func (c *DefaultWCL) getToken(ctx context.Context) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.token != "" && time.Now().Before(c.tokenExpiry.Add(-30*time.Second)) {
+	if c.token != "" && c.clock.Now().Before(c.tokenExpiry.Add(-30*time.Second)) {
 		return c.token, nil
 	}
 
@@ -175,9 +184,9 @@ func (c *DefaultWCL) getToken(ctx context.Context) (string, error) {
 
 	c.token = payload.AccessToken
 	if payload.ExpiresIn > 0 {
-		c.tokenExpiry = time.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
+		c.tokenExpiry = c.clock.Now().Add(time.Duration(payload.ExpiresIn) * time.Second)
 	} else {
-		c.tokenExpiry = time.Now().Add(5 * time.Minute)
+		c.tokenExpiry = c.clock.Now().Add(5 * time.Minute)
 	}
 
 	return c.token, nil